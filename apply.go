@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newApplyCmd builds the apply subcommand, which fills values into an existing file.
+func newApplyCmd(cfg *config) *cobra.Command {
+	var valuesFile, valuesEnv, missing string
+	var write bool
+	cmd := &cobra.Command{
+		Use:   "apply file (--values values.json | --values-env PREFIX_)",
+		Short: "Fill values from JSON or the environment into an existing file",
+		Long: `apply replaces the values of existing keys in place, keeping comments, ordering
+and alignment. Values come either from a JSON file, as {"section": {"key": value}}
+objects or "section.key": value pairs, or from environment variables named
+PREFIX_ + SECTION_KEY (the naming used by the env subcommand).
+
+Keys that are not present in the file are handled per --missing: 'error'
+(the default) reports them, 'add' appends them to their section (creating it
+if needed) and 'ignore' skips them. Keys can only be added from JSON.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeOneFile,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			defer func() { usageFor(cmd, err) }()
+			if (valuesFile == "") == (valuesEnv == "") {
+				return optionError("--values", errors.New("exactly one of --values or --values-env is required"))
+			}
+			switch missing {
+			case "add", "error", "ignore":
+			default:
+				return optionError("--missing", fmt.Errorf("invalid --missing %q (want add, error or ignore)", missing))
+			}
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return err
+			}
+
+			var assigns []assignment
+			if valuesFile != "" {
+				data, err := os.ReadFile(valuesFile)
+				if err != nil {
+					return fmt.Errorf("reading values: %w", err)
+				}
+				if assigns, err = parseJSONValues(data); err != nil {
+					return err
+				}
+			} else {
+				assigns = envAssignments(in.lines, valuesEnv, os.Environ(), cfg.format)
+			}
+
+			result, err := applyValues(in.lines, assigns, missing, cfg.format)
+			if err != nil {
+				return err
+			}
+			out := *cfg
+			out.write = write
+			out.lineEnding = "auto"
+			return writeOutput(out, args[0], in, result)
+		},
+	}
+	cmd.Flags().StringVar(&valuesFile, "values", "", "JSON file with the values to apply")
+	cmd.Flags().StringVar(&valuesEnv, "values-env", "", "Take values from environment variables with this prefix")
+	cmd.Flags().StringVar(&missing, "missing", "error", "Keys missing from the file: 'add', 'error' or 'ignore'")
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result back to the file")
+	return cmd
+}
+
+// assignment is a value to apply. When section is unknown (flat JSON paths and
+// environment variables) path is resolved against the file.
+type assignment struct {
+	path     string
+	section  string
+	key      string
+	resolved bool
+	value    string
+	fromEnv  bool
+}
+
+// parseJSONValues reads {"section": {"key": value}} objects and flat
+// "section.key": value pairs. Scalars are stringified; null becomes empty.
+func parseJSONValues(data []byte) ([]assignment, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var top map[string]any
+	if err := dec.Decode(&top); err != nil {
+		return nil, fmt.Errorf("parsing values: %w", err)
+	}
+	var assigns []assignment
+	for _, name := range sortedKeys(top) {
+		if obj, ok := top[name].(map[string]any); ok {
+			for _, key := range sortedKeys(obj) {
+				value, err := jsonScalar(obj[key])
+				if err != nil {
+					return nil, fmt.Errorf("value of %s.%s: %w", name, key, err)
+				}
+				assigns = append(assigns, assignment{path: name + "." + key, section: name, key: key, resolved: true, value: value})
+			}
+			continue
+		}
+		value, err := jsonScalar(top[name])
+		if err != nil {
+			return nil, fmt.Errorf("value of %s: %w", name, err)
+		}
+		assigns = append(assigns, assignment{path: name, value: value})
+	}
+	return assigns, nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// jsonScalar converts a decoded JSON scalar to its INI value text.
+func jsonScalar(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if strings.ContainsAny(v, "\r\n") {
+			return "", errors.New("values cannot contain newlines")
+		}
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported JSON value %T", v)
+}
+
+// envAssignments takes values for the keys of the file from environment
+// variables named prefix + envName(section, key). Variables with the prefix
+// that match no key are returned unresolved so they can be reported.
+func envAssignments(lines []string, prefix string, environ []string, cfg format.Options) []assignment {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, prefix) {
+			env[strings.TrimPrefix(name, prefix)] = value
+		}
+	}
+	var assigns []assignment
+	used := make(map[string]bool)
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		name := envName(kv.Section, kv.Key)
+		value, ok := env[name]
+		if !ok || used[kv.Path()] {
+			continue
+		}
+		used[kv.Path()] = true
+		delete(env, name)
+		assigns = append(assigns, assignment{path: kv.Path(), section: kv.Section, key: kv.Key, resolved: true, value: value})
+	}
+	for _, name := range sortedKeys(env) {
+		assigns = append(assigns, assignment{path: prefix + name, value: env[name], fromEnv: true})
+	}
+	return assigns
+}
+
+// applyValues replaces the values of existing keys and handles missing keys
+// per missing. Existing lines keep everything up to and including the
+// delimiter, so the '=' column and thus the alignment never moves.
+func applyValues(lines []string, assigns []assignment, missing string, cfg format.Options) ([]string, error) {
+	result := slices.Clone(lines)
+	kvs := format.ParseKeyValues(result, cfg)
+	var notFound []string
+	for _, a := range assigns {
+		matched := false
+		for _, kv := range kvs {
+			if !kv.HasValue || !a.matches(kv) {
+				continue
+			}
+			matched = true
+			result[kv.Line-1] = replaceValue(result[kv.Line-1], a.value, cfg)
+		}
+		if matched {
+			continue
+		}
+		switch {
+		case missing == "ignore":
+		case missing == "add" && !a.fromEnv:
+			section, key := a.section, a.key
+			if !a.resolved {
+				section, key = splitPath(a.path)
+			}
+			result = addKey(result, section, key, a.value, cfg)
+			kvs = format.ParseKeyValues(result, cfg)
+		default:
+			notFound = append(notFound, a.path)
+		}
+	}
+	if len(notFound) > 0 {
+		return nil, fmt.Errorf("keys not found in file: %s", strings.Join(notFound, ", "))
+	}
+	return result, nil
+}
+
+// matches reports whether the assignment addresses kv.
+func (a assignment) matches(kv format.KeyValue) bool {
+	if a.resolved {
+		return kv.Section == a.section && kv.Key == a.key
+	}
+	return kv.Path() == a.path
+}
+
+// splitPath splits a flat path at its last dot into section and key.
+func splitPath(path string) (section, key string) {
+	if idx := strings.LastIndex(path, "."); idx != -1 {
+		return path[:idx], path[idx+1:]
+	}
+	return "", path
+}
+
+// replaceValue swaps the value of a key/value line, keeping the key, padding,
+// delimiter and the whitespace that followed it.
+func replaceValue(line, value string, cfg format.Options) string {
+	before, after, _ := cfg.Cut(line)
+	gap := valueGap(before, after)
+	return before + "=" + gap + value
+}
+
+// valueGap returns the whitespace between the delimiter and the value of a
+// line cut into before and after. An empty value has lost that whitespace,
+// so it is taken to mirror the whitespace before the delimiter.
+func valueGap(before, after string) string {
+	if strings.TrimSpace(after) == "" {
+		if strings.HasSuffix(before, " ") {
+			return " "
+		}
+		return ""
+	}
+	return after[:len(after)-len(strings.TrimLeft(after, " \t"))]
+}
+
+// addKey inserts key = value after the last key line of section, creating
+// the section at the end of the file when it does not exist. The new line is
+// padded to the '=' column of the key above it; when the new key is too long
+// for that column the surrounding block is realigned.
+func addKey(lines []string, section, key, value string, cfg format.Options) []string {
+	newLine := key + " = " + value
+	headerIdx := -1
+	if section != "" {
+		for i, line := range lines {
+			if format.IsHeader(line) && format.HeaderName(line) == section {
+				headerIdx = i
+			}
+		}
+		if headerIdx == -1 {
+			if len(lines) > 0 && !format.IsBlank(lines[len(lines)-1]) {
+				lines = append(lines, "")
+			}
+			return append(lines, "["+section+"]", newLine)
+		}
+	}
+
+	// Find the last key line of the section.
+	insertAt, prev := headerIdx+1, -1
+	for i := headerIdx + 1; i < len(lines) && !format.IsHeader(lines[i]); i++ {
+		if !format.IsBlank(lines[i]) && !cfg.IsComment(lines[i]) {
+			if _, _, ok := cfg.Cut(lines[i]); ok {
+				insertAt, prev = i+1, i
+			}
+		}
+	}
+	lines = slices.Insert(lines, insertAt, newLine)
+	if prev != -1 {
+		setKeyLine(lines, insertAt, prev, key, value, cfg)
+	}
+	return lines
+}
+
+// setKeyLine sets lines[i] to key = value, padded to the '=' column of the
+// key line at ref in the same block. When the key is too long for that column
+// the block is realigned, provided it was aligned without line i.
+func setKeyLine(lines []string, i, ref int, key, value string, cfg format.Options) {
+	before, after, _ := cfg.Cut(lines[ref])
+	gap := valueGap(before, after)
+	if pad := keyWidth(before, cfg) - keyWidth(key, cfg); pad >= 1 {
+		lines[i] = key + strings.Repeat(" ", pad) + "=" + gap + value
+		return
+	}
+	lines[i] = key + " = " + value
+	start, end := blockBounds(lines, ref)
+	others := slices.Delete(slices.Clone(lines[start:end]), i-start, i-start+1)
+	if blockAligned(others, cfg) {
+		copy(lines[start:end], format.AlignSection(lines[start:end], cfg))
+	}
+}
+
+// blockBounds returns the bounds of the run of lines around index i that is
+// delimited by blank lines and headers.
+func blockBounds(lines []string, i int) (start, end int) {
+	start, end = i, i+1
+	for start > 0 && !format.IsBlank(lines[start-1]) && !format.IsHeader(lines[start-1]) {
+		start--
+	}
+	for end < len(lines) && !format.IsBlank(lines[end]) && !format.IsHeader(lines[end]) {
+		end++
+	}
+	return start, end
+}
+
+// blockAligned reports whether block is as alignment leaves it, i.e. it was
+// formatted with alignment rather than single-space style. A block whose keys
+// are all as wide, or that holds a single key, counts as aligned.
+func blockAligned(block []string, cfg format.Options) bool {
+	return slices.Equal(block, format.AlignSection(block, cfg))
+}
+
+// keyWidth returns the columns the start of a key line up to the delimiter
+// occupies, with tabs expanded as the formatter expands them.
+func keyWidth(s string, cfg format.Options) int {
+	tabWidth := cfg.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = format.DefaultTabWidth
+	}
+	return format.ExpandedWidth(s, tabWidth)
+}
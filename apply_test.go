@@ -0,0 +1,118 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestApplyValuesJSON(t *testing.T) {
+	lines := []string{
+		"; deployment settings",
+		"[server]",
+		"host      = PLACEHOLDER",
+		"port      = 0",
+		"log_level = info",
+		"",
+		"[db]",
+		"url=PLACEHOLDER",
+	}
+	assigns, err := parseJSONValues([]byte(`{
+		"server": {"host": "web1.internal", "port": 8443, "tls": true},
+		"db.url": "postgres://db1/app",
+		"cache": {"ttl": 60}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := applyValues(lines, assigns, "add", format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"; deployment settings",
+		"[server]",
+		"host      = web1.internal",
+		"port      = 8443",
+		"log_level = info",
+		"tls       = true",
+		"",
+		"[db]",
+		"url=postgres://db1/app",
+		"",
+		"[cache]",
+		"ttl = 60",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("applyValues() =\n%q\nwant\n%q", got, want)
+	}
+
+	if _, err := applyValues(lines, assigns, "error", format.Options{}); err == nil {
+		t.Error("applyValues(missing=error) expected error for server.tls and cache.ttl")
+	}
+	if _, err := applyValues(lines, assigns, "ignore", format.Options{}); err != nil {
+		t.Errorf("applyValues(missing=ignore) unexpected error: %v", err)
+	}
+}
+
+func TestApplyAddRealignsAlignedBlock(t *testing.T) {
+	lines := []string{"[s]", "a  = 1", "bb = 2"}
+	assigns := []assignment{{section: "s", key: "longer", value: "3", resolved: true}}
+	got, err := applyValues(lines, assigns, "add", format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[s]", "a      = 1", "bb     = 2", "longer = 3"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("applyValues() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyAddRealignsEqualKeys(t *testing.T) {
+	tests := []struct {
+		lines, want []string
+	}{
+		{
+			lines: []string{"[s]", "port = 80", "host = x"},
+			want:  []string{"[s]", "port    = 80", "host    = x", "timeout = 30"},
+		},
+		{
+			lines: []string{"[s]", "port = 80"},
+			want:  []string{"[s]", "port    = 80", "timeout = 30"},
+		},
+		{
+			lines: []string{"[s]", "a = 1", "port = 80"},
+			want:  []string{"[s]", "a = 1", "port = 80", "timeout = 30"},
+		},
+	}
+	assigns := []assignment{{section: "s", key: "timeout", value: "30", resolved: true}}
+	for _, tt := range tests {
+		got, err := applyValues(tt.lines, assigns, "add", format.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("applyValues(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestApplyValuesEnv(t *testing.T) {
+	lines := []string{"[server]", "host = x", "port = 1"}
+	environ := []string{"APP_SERVER_PORT=9000", "APP_UNKNOWN=1", "OTHER=2"}
+	assigns := envAssignments(lines, "APP_", environ, format.Options{})
+
+	if _, err := applyValues(lines, assigns, "add", format.Options{}); err == nil {
+		t.Error("applyValues() expected error for unmatched APP_UNKNOWN")
+	}
+	got, err := applyValues(lines, assigns, "ignore", format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[server]", "host = x", "port = 9000"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("applyValues(env) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// cacheVersion numbers the layout of the result cache. Entries written under
+// another version are never looked up, so a change to what an entry records
+// bumps it.
+const cacheVersion = 1
+
+// cacheLimit is how many entries the result cache keeps. Once a run leaves
+// more, the least recently used go.
+var cacheLimit = 10000
+
+// cacheNeutralFlags are the flags that change how a run reports or where it
+// writes, but not what formatting a file gives, so that they do not split
+// the cache.
+var cacheNeutralFlags = []string{
+	"cache", "cache-dir", "no-cache", "write", "check", "diff", "format", "force", "summary", "report",
+	"quiet", "verbose", "log-level", "log-format", "progress", "timings",
+	"since", "stdin-filename", "help", "show-config", "list-presets",
+}
+
+// cacheEnabled reports whether the run uses the result cache: --cache or
+// --cache-dir is given and --no-cache is not.
+func (cfg config) cacheEnabled() bool {
+	return (cfg.cache || cfg.cacheDir != "") && !cfg.noCache
+}
+
+// cacheSettings returns the effective settings of flags and the per-section
+// tables of the config files as text, the part of a cache key standing for
+// the options a file is formatted with.
+func cacheSettings(flags *pflag.FlagSet, sections []sectionConfig) string {
+	var b strings.Builder
+	for _, s := range effectiveSettings(flags) {
+		if !slices.Contains(cacheNeutralFlags, s.Name) {
+			fmt.Fprintf(&b, "%s=%v\n", s.Name, s.Value)
+		}
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&b, "[section %q] %v\n", s.pattern, s.settings)
+	}
+	return b.String()
+}
+
+// buildVersion identifies the inifmt binary in cache keys: its module
+// version and the revision it was built from, and, for a build from a
+// modified or unknown tree, the size and modification time of the executable.
+func buildVersion() string {
+	var version, revision string
+	modified := true
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	v := version + " " + revision
+	if modified || revision == "" {
+		if exe, err := os.Executable(); err == nil {
+			if fi, err := os.Stat(exe); err == nil {
+				v += fmt.Sprintf(" %d %d", fi.Size(), fi.ModTime().UnixNano())
+			}
+		}
+	}
+	return v
+}
+
+// resultCache records, on disk, which file contents formatting leaves as they
+// are. An entry is an empty file named by the hash of the content, the
+// options and the inifmt version, so that a change to any of them misses.
+type resultCache struct {
+	dir     string
+	version string
+	added   bool // whether the run recorded an entry, so pruning may be due
+}
+
+// openCache returns the result cache cfg asks for, or nil when the run does
+// not use one. Without --cache-dir, the cache lives in an inifmt directory
+// under the user cache directory.
+func openCache(cfg config) (*resultCache, error) {
+	if !cfg.cacheEnabled() {
+		return nil, nil
+	}
+	dir := cfg.cacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, optionError("--cache", fmt.Errorf("no user cache directory (%w); give one with --cache-dir", err))
+		}
+		dir = filepath.Join(base, "inifmt")
+	}
+	return &resultCache{dir: filepath.Join(dir, fmt.Sprintf("v%d", cacheVersion)), version: buildVersion()}, nil
+}
+
+// key returns the key of the result of formatting in, read from filename,
+// with the settings of cfg, or "" when the run cannot use the cache for the
+// file: only --check, --diff and --write runs over files in the ini format
+// that --explain, --to-utf8 and digests leave alone can skip a file that is
+// already formatted.
+func (c *resultCache) key(cfg config, filename string, in *input) string {
+	if c == nil || cfg.cacheSettings == "" || !cfg.write && !cfg.check && !cfg.diff || filename == "" || isURL(filename) ||
+		cfg.to != "ini" || cfg.toUTF8 || cfg.explain || cfg.hashes() {
+		return ""
+	}
+	// The same file is the same entry whatever directory inifmt runs in.
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "inifmt %s\n%s\n%s", c.version, filename, cfg.cacheSettings)
+	for _, kv := range cfg.format.Defaults {
+		fmt.Fprintf(h, "default %q %q %q %t\n", kv.Section, kv.Key, kv.Value, kv.HasValue)
+	}
+	fmt.Fprintf(h, "%d\n%s", len(in.text), in.text)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the file of the entry key.
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// formatted reports whether the cache records the content key stands for as
+// already formatted, marking the entry as used.
+func (c *resultCache) formatted(key string) bool {
+	if key == "" {
+		return false
+	}
+	now := time.Now()
+	return os.Chtimes(c.path(key), now, now) == nil
+}
+
+// record records the content key stands for as already formatted. The entry
+// is written to a temporary file and renamed into place, so that runs sharing
+// the cache never see half of one.
+func (c *resultCache) record(key string) error {
+	if key == "" {
+		return nil
+	}
+	dir := filepath.Dir(c.path(key))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	c.added = true
+	return nil
+}
+
+// prune removes the least recently used entries beyond cacheLimit, once the
+// run has recorded any. An entry another run removed first is no error.
+func (c *resultCache) prune() error {
+	if c == nil || !c.added {
+		return nil
+	}
+	type entry struct {
+		path string
+		used time.Time
+	}
+	var entries []entry
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entry{path, fi.ModTime()})
+		return nil
+	})
+	if err != nil || len(entries) <= cacheLimit {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return a.used.Compare(b.used) })
+	for _, e := range entries[:len(entries)-cacheLimit] {
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheFile records in the cache that formatting leaves the file key stands
+// for as it is, when the output of formatFile, result ending in eol, is the
+// input byte for byte. A cache that cannot be written only costs the next
+// run its time, so the failure is a warning.
+func (c *resultCache) cacheFile(cfg config, name, key string, in *input, result []string, eol string) {
+	if key == "" || !sameOutput(in, result, eol) {
+		return
+	}
+	if err := c.record(key); err != nil {
+		cfg.logger().Warn(fmt.Sprintf("%s: cannot record in the cache: %v", name, err), "file", name, "error", err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cacheEntries counts the entries of the cache in dir.
+func cacheEntries(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCacheWrite(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	writeFiles(t, dir, map[string]string{"a.ini": "[s]\na = 1\nb = 2\n", "b.ini": "[s]\na=1\n"})
+	a, b := filepath.Join(dir, "a.ini"), filepath.Join(dir, "b.ini")
+	old := time.Now().Add(-time.Hour).Round(time.Second)
+	// written reports whether the last run rewrote file, and makes it look
+	// old again for the next.
+	written := func(file string) bool {
+		t.Helper()
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, old, old); err != nil {
+			t.Fatal(err)
+		}
+		return !fi.ModTime().Equal(old)
+	}
+	written(a)
+
+	tests := []struct {
+		name    string
+		args    []string
+		written bool
+		entries int
+	}{
+		{"first run records", []string{"-w", "--cache-dir", cacheDir, a}, true, 1},
+		{"second run skips", []string{"-w", "--cache-dir", cacheDir, a}, false, 1},
+		{"reporting flags share entries", []string{"-w", "--cache-dir", cacheDir, "--summary", a}, false, 1},
+		{"other options miss", []string{"-w", "--cache-dir", cacheDir, "--per-section", a}, true, 2},
+		{"--no-cache bypasses", []string{"-w", "--cache-dir", cacheDir, "--no-cache", a}, true, 2},
+		{"without --write", []string{"--cache-dir", cacheDir, a}, false, 2},
+		{"unformatted file is not recorded", []string{"-w", "--cache-dir", cacheDir, b}, false, 2},
+		{"until a run finds it formatted", []string{"-w", "--cache-dir", cacheDir, b}, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdoutOf(t, tt.args...)
+			if got := written(a); got != tt.written {
+				t.Errorf("a.ini rewritten = %v, want %v", got, tt.written)
+			}
+			if got := cacheEntries(t, cacheDir); got != tt.entries {
+				t.Errorf("%d cache entries, want %d", got, tt.entries)
+			}
+		})
+	}
+	if got := mustRead(t, b); got != "[s]\na = 1\n" {
+		t.Errorf("b.ini = %q", got)
+	}
+
+	// A change to the file misses.
+	writeFiles(t, dir, map[string]string{"a.ini": "[s]\na = 1\nb = 3\n"})
+	stdoutOf(t, "-w", "--cache-dir", cacheDir, a)
+	if got := cacheEntries(t, cacheDir); got != 4 {
+		t.Errorf("%d cache entries after changing a.ini, want 4", got)
+	}
+}
+
+func TestCacheDefaultDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("HOME", dir)
+	writeFiles(t, dir, map[string]string{"a.ini": "a = 1\n"})
+	stdoutOf(t, "-w", "--cache", filepath.Join(dir, "a.ini"))
+	base, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	if got := cacheEntries(t, filepath.Join(base, "inifmt")); got != 1 {
+		t.Errorf("%d entries in the default cache, want 1", got)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	c := &resultCache{dir: t.TempDir(), version: "v1"}
+	cfg := config{write: true, to: "ini", cacheSettings: "tab-width=4\n"}
+	in := textInput("a = 1\n")
+	key := c.key(cfg, "a.ini", in)
+	if key == "" {
+		t.Fatal("key() = \"\" for a --write run")
+	}
+	other := cfg
+	other.cacheSettings = "tab-width=8\n"
+	newer := *c
+	newer.version = "v2"
+	keys := map[string]string{
+		"content":  c.key(cfg, "a.ini", textInput("a = 2\n")),
+		"file":     c.key(cfg, "b.ini", in),
+		"settings": c.key(other, "a.ini", in),
+		"version":  newer.key(cfg, "a.ini", in),
+	}
+	for what, k := range keys {
+		if k == key || k == "" {
+			t.Errorf("changing the %s gives key %q, want another than %q", what, k, key)
+		}
+	}
+
+	for name, change := range map[string]func(*config){
+		"without --write": func(c *config) { c.write = false },
+		"--to=flat":       func(c *config) { c.to = "flat" },
+		"--hash":          func(c *config) { c.hash = true },
+		"cache off":       func(c *config) { c.cacheSettings = "" },
+	} {
+		off := cfg
+		change(&off)
+		if k := c.key(off, "a.ini", in); k != "" {
+			t.Errorf("key() %s = %q, want \"\"", name, k)
+		}
+	}
+	if k := c.key(cfg, "", in); k != "" {
+		t.Errorf("key() for stdin = %q, want \"\"", k)
+	}
+	if k := (*resultCache)(nil).key(cfg, "a.ini", in); k != "" {
+		t.Errorf("key() without a cache = %q, want \"\"", k)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	saved := cacheLimit
+	cacheLimit = 2
+	defer func() { cacheLimit = saved }()
+
+	c := &resultCache{dir: t.TempDir()}
+	keys := []string{"aa01", "bb02", "cc03"}
+	now := time.Now()
+	for i, key := range keys {
+		if err := c.record(key); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(time.Duration(i-10) * time.Minute)
+		if err := os.Chtimes(c.path(key), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A hit makes the oldest entry the most recently used.
+	if !c.formatted(keys[0]) {
+		t.Fatalf("formatted(%s) = false after record", keys[0])
+	}
+	if err := c.prune(); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		want := i != 1
+		if got := c.formatted(key); got != want {
+			t.Errorf("formatted(%s) = %v after prune, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkFile finishes formatting filename under --check or --diff, which
+// write no output, with res, the outcome of formatting in into result. It
+// records the file in cache when formatting leaves it as it is, gives res the
+// first line formatting changes, and with --diff prints the changes.
+func checkFile(cfg config, filename string, in *input, result []string, res fileResult, cache *resultCache, key string) (fileResult, error) {
+	eol := in.outputEOL(cfg.lineEnding)
+	cache.cacheFile(cfg, cfg.displayName(filename), key, in, result, eol)
+	res.line = firstChange(in, result, eol)
+	if !cfg.diff || res.Status != statusFormatted {
+		return res, nil
+	}
+	name := cfg.displayName(filename)
+	if filename == "" && cfg.stdinFilename == "" {
+		name = "<stdin>"
+	}
+	return res, writeDiff(os.Stdout, name, in.text, outputText(result, eol))
+}
+
+// firstChange returns the number of the first line of in that formatting
+// changes into result, written with eol after every line, counting a changed
+// line ending as a change to its line.
+func firstChange(in *input, result []string, eol string) int {
+	for i, line := range in.lines {
+		if i >= len(result) || line != result[i] || in.endings[i] != eol {
+			return i + 1
+		}
+	}
+	return max(len(in.lines), 1)
+}
+
+// writeCheckFinding reports that the file name is not formatted, as a line
+// on stderr or, when how is "github", as a GitHub Actions annotation on the
+// first line formatting changes on stdout. reason says why when there is more
+// to it, such as only its line endings differing.
+func writeCheckFinding(stdout, stderr io.Writer, name string, line int, reason, how string) error {
+	message := name + " is not formatted"
+	if reason != "" {
+		message += ": " + reason
+	}
+	var err error
+	if how == "github" {
+		_, err = fmt.Fprintln(stdout, githubAnnotation("error", name, line, message))
+	} else {
+		_, err = fmt.Fprintln(stderr, message)
+	}
+	if err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
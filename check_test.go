@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCheck runs inifmt --check with args on stdin, in dir, and returns what
+// it printed on stdout and stderr and its exit status.
+func runCheck(t *testing.T, dir, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	return runInifmt(t, dir, stdin, append([]string{"--check"}, args...)...)
+}
+
+// runInifmt runs inifmt --no-config with args on stdin, in dir, and returns
+// what it printed on stdout and stderr and its exit status.
+func runInifmt(t *testing.T, dir, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	tmp := t.TempDir()
+	files := make([]*os.File, 3)
+	for i, name := range []string{"stdin", "stdout", "stderr"} {
+		f, err := os.Create(filepath.Join(tmp, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files[i] = f
+	}
+	if _, err := files[0].WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files[0].Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	saved := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	os.Stdin, os.Stdout, os.Stderr = files[0], files[1], files[2]
+	defer func() { os.Stdin, os.Stdout, os.Stderr = saved[0], saved[1], saved[2] }()
+	t.Chdir(dir)
+
+	cmd := newRootCmd()
+	cmd.SetOut(files[2])
+	cmd.SetErr(files[2])
+	cmd.SetArgs(append([]string{"--no-config"}, args...))
+	code := 0
+	if err := cmd.Execute(); err != nil {
+		code = exitCode(err)
+	}
+	return mustRead(t, files[1].Name()), mustRead(t, files[2].Name()), code
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GITHUB_ACTIONS", "")
+	files := map[string]string{
+		"ok.ini":       "[s]\na    = 1\nlong = 2\n",
+		"bad.ini":      "[s]\na    = 1\nlong=2\n",
+		"crlf.ini":     "[s]\r\na    = 1\r\nlong = 2\r\n",
+		"sections.ini": "[a]\nx=1\n\n[b]\nx    = 1\nlong = 2\n",
+		"keys.ini":     "[s]\nmy_a    = 1\nmy_long = 2\nother=3\n",
+		"doc.md":       "# Doc\n\n```ini\na=1\n```\n",
+		"doc-ok.md":    "# Doc\n\n```ini\na = 1\n```\n",
+	}
+	writeFiles(t, dir, files)
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		stdout string
+		stderr string
+		code   int
+	}{
+		{name: "formatted", args: []string{"ok.ini"}},
+		{name: "not formatted", args: []string{"bad.ini"}, stderr: "bad.ini is not formatted\n", code: 1},
+		{name: "quiet", args: []string{"--quiet", "bad.ini"}, code: 1},
+		{name: "with the options of the editor", args: []string{"--single-space", "bad.ini"}, stderr: "bad.ini is not formatted\n", code: 1},
+		{name: "single space passes", args: []string{"--single-space"}, stdin: "[s]\na = 1\nlong = 2\n"},
+		{name: "empty stdin", stdin: ""},
+		{name: "stdin", stdin: "a=1\n", stderr: "- is not formatted\n", code: 1},
+		{name: "stdin filename", args: []string{"--stdin-filename", "app.ini"}, stdin: "a=1\n", stderr: "app.ini is not formatted\n", code: 1},
+		{name: "line endings", args: []string{"crlf.ini"}, stderr: "crlf.ini is not formatted: " + reasonEOLOnly + "\n", code: 1},
+		{name: "line endings ignored", args: []string{"--check-ignore-eol", "crlf.ini"}},
+		{name: "line endings kept", args: []string{"--line-ending=auto", "crlf.ini"}},
+		{name: "selected section formatted", args: []string{"--only-sections=b", "sections.ini"}},
+		{name: "selected section not", args: []string{"--only-sections=a", "sections.ini"}, stderr: "sections.ini is not formatted\n", code: 1},
+		{name: "matching keys formatted", args: []string{"--match-keys=^my_", "keys.ini"}},
+		{name: "other keys not", args: []string{"--ignore-keys=^my_", "keys.ini"}, stderr: "keys.ini is not formatted\n", code: 1},
+		{name: "markdown", args: []string{"doc.md"}, stderr: "doc.md is not formatted\n", code: 1},
+		{name: "markdown formatted", args: []string{"doc-ok.md"}},
+		{
+			name:   "github",
+			args:   []string{"--format=github", "bad.ini"},
+			stdout: "::error file=bad.ini,line=3,title=inifmt::bad.ini is not formatted\n",
+			code:   1,
+		},
+		{
+			name:   "github line endings",
+			args:   []string{"--format", "github", "crlf.ini"},
+			stdout: "::error file=crlf.ini,line=1,title=inifmt::crlf.ini is not formatted: only line endings differ (--check-ignore-eol ignores them)\n",
+			code:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runCheck(t, dir, tt.stdin, tt.args...)
+			if code != tt.code {
+				t.Errorf("exit %d, want %d", code, tt.code)
+			}
+			if stdout != tt.stdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.stdout)
+			}
+			if stderr != tt.stderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.stderr)
+			}
+		})
+	}
+	for name, want := range files {
+		if got := mustRead(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("--check changed %s to %q", name, got)
+		}
+	}
+}
+
+func TestCheckInGitHubActions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"bad.ini": "a=1\n"})
+	t.Setenv("GITHUB_ACTIONS", "true")
+	stdout, _, code := runCheck(t, dir, "", "bad.ini")
+	if want := "::error file=bad.ini,line=1,title=inifmt::bad.ini is not formatted\n"; stdout != want || code != 1 {
+		t.Errorf("--check = %q, exit %d; want %q, exit 1", stdout, code, want)
+	}
+	if _, stderr, code := runCheck(t, dir, "", "--format=xml", "bad.ini"); code != 2 || !strings.Contains(stderr, "invalid --format") {
+		t.Errorf("--format=xml = exit %d, %q; want a usage error", code, stderr)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	writeFiles(t, dir, map[string]string{"ok.ini": "a = 1\n", "bad.ini": "a=1\n"})
+	for range 2 {
+		if _, _, code := runCheck(t, dir, "", "--cache-dir", cacheDir, "ok.ini"); code != 0 {
+			t.Errorf("--check ok.ini = exit %d, want 0", code)
+		}
+		if _, _, code := runCheck(t, dir, "", "--cache-dir", cacheDir, "bad.ini"); code != 1 {
+			t.Errorf("--check bad.ini = exit %d, want 1", code)
+		}
+	}
+	if got := cacheEntries(t, cacheDir); got != 1 {
+		t.Errorf("%d cache entries, want 1 for ok.ini", got)
+	}
+	// A --write run shares the entries of --check.
+	stdoutOf(t, "-w", "--cache-dir", cacheDir, filepath.Join(dir, "ok.ini"))
+	if got := cacheEntries(t, cacheDir); got != 1 {
+		t.Errorf("%d cache entries after --write, want 1", got)
+	}
+}
+
+func TestFirstChange(t *testing.T) {
+	tests := []struct {
+		in     string
+		result []string
+		want   int
+	}{
+		{"a = 1\nb=2\n", []string{"a = 1", "b = 2"}, 2},
+		{"a = 1\r\nb = 2\r\n", []string{"a = 1", "b = 2"}, 1},
+		{"a = 1\nb = 2", []string{"a = 1", "b = 2"}, 2},
+		{"a = 1\n\n\n", []string{"a = 1"}, 2},
+		{"a = 1\n", []string{"a = 1", "b = 2"}, 1},
+		{"", []string{"a = 1"}, 1},
+	}
+	for _, tt := range tests {
+		if got := firstChange(textInput(tt.in), tt.result, "\n"); got != tt.want {
+			t.Errorf("firstChange(%q, %q) = %d, want %d", tt.in, tt.result, got, tt.want)
+		}
+	}
+}
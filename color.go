@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// ANSI styles for each token kind. Whitespace is never styled.
+var tokenColors = map[format.TokenKind]string{
+	format.TokenSectionName:   "\x1b[1;34m",
+	format.TokenKey:           "\x1b[36m",
+	format.TokenDelimiter:     "\x1b[33m",
+	format.TokenValue:         "\x1b[32m",
+	format.TokenCommentMarker: "\x1b[90m",
+	format.TokenCommentText:   "\x1b[90m",
+}
+
+const colorReset = "\x1b[0m"
+
+// useColor decides whether output is colorized. Colors are only ever written
+// to a terminal and never to files, with --write, --output or --output-dir;
+// 'auto' additionally honors NO_COLOR, which 'always' overrides.
+func useColor(cfg config) bool {
+	if cfg.color == "never" || cfg.write || cfg.output != "" || cfg.outputDir != "" || cfg.hashes() || cfg.to == "markdown" || cfg.to == "html" {
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok && cfg.color != "always" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorizeLines wraps the tokens of each line in ANSI colors. Stripping the
+// escape sequences gives back exactly the input lines.
+func colorizeLines(lines []string, cfg format.Options) []string {
+	result := make([]string, len(lines))
+	for i, toks := range format.TokenizeLines(lines, cfg) {
+		var b strings.Builder
+		for _, tok := range toks {
+			if style, ok := tokenColors[tok.Kind]; ok {
+				b.WriteString(style + tok.Text + colorReset)
+			} else {
+				b.WriteString(tok.Text)
+			}
+		}
+		result[i] = b.String()
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestColorizeLinesKeepsText(t *testing.T) {
+	lines := []string{"; top", "[s] ; note", "key   = value ; why", "", "bare"}
+	ansi := regexp.MustCompile("\x1b\\[[0-9;]*m")
+	for i, got := range colorizeLines(lines, format.Options{}) {
+		if got == lines[i] && lines[i] != "" {
+			t.Errorf("line %q was not colorized", lines[i])
+		}
+		if plain := ansi.ReplaceAllString(got, ""); plain != lines[i] {
+			t.Errorf("colorized line %q strips to %q, want %q", got, plain, lines[i])
+		}
+	}
+}
+
+func TestUseColorNeverWhenWriting(t *testing.T) {
+	for _, cfg := range []config{
+		{color: "always", write: true},
+		{color: "always", output: "out.ini"},
+		{color: "never"},
+		{color: "always", to: "markdown"},
+	} {
+		if useColor(cfg) {
+			t.Errorf("useColor(%+v) = true, want false", cfg)
+		}
+	}
+}
+
+func TestNoColorInOutputFile(t *testing.T) {
+	// /dev/null is a character device, so stdout looks like a terminal.
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+	if !isTerminal(tty) {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+	saved := os.Stdout
+	os.Stdout = tty
+	defer func() { os.Stdout = saved }()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"in.ini": "[s]\na=1\n"})
+	out := filepath.Join(dir, "out.ini")
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--color=always", "-o", out, filepath.Join(dir, "in.ini")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustRead(t, out); strings.Contains(got, "\x1b") || got != "[s]\na = 1\n" {
+		t.Errorf("-o wrote %q, want plain output", got)
+	}
+}
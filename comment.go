@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// errNotFound reports that comment or uncomment found nothing to change.
+var errNotFound = errors.New("not found")
+
+// newCommentCmd builds the comment subcommand, which comments out a key.
+func newCommentCmd(cfg *config) *cobra.Command {
+	return newToggleCmd(cfg, "comment", "Comment out a key",
+		`comment turns every line setting section.key into a comment such as
+"; debug = true", using the comment marker the file already uses most. The
+other keys keep their alignment.`, commentKey)
+}
+
+// newUncommentCmd builds the uncomment subcommand, which restores a
+// commented-out key.
+func newUncommentCmd(cfg *config) *cobra.Command {
+	return newToggleCmd(cfg, "uncomment", "Restore a commented-out key",
+		`uncomment finds the commented-out assignment of section.key in its section,
+such as "; debug = true" or "#debug=true", strips the comment marker and
+aligns the key with the keys around it. Several commented-out candidates are
+an error listing their line numbers.`, uncommentKey)
+}
+
+// newToggleCmd builds comment or uncomment around edit, which changes the
+// lines of one key.
+func newToggleCmd(cfg *config, name, short, long string, edit func([]string, string, format.Options) ([]string, error)) *cobra.Command {
+	var write bool
+	cmd := &cobra.Command{
+		Use:   name + " file section.key",
+		Short: short,
+		Long: long + `
+
+The result is printed, or written back to the file with -w. Exits 0 when the
+key was changed, 1 when there was nothing to change and 2 on errors.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+		ValidArgsFunction: completeFileThen(cfg, completePaths),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			result, err := edit(in.lines, args[1], dialectOptions(cfg.format, args[0], in.lines))
+			if errors.Is(err, errNotFound) {
+				return &exitError{code: 1, err: fmt.Errorf("%s: %w", args[1], err)}
+			} else if err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			out := *cfg
+			out.write = write
+			out.lineEnding = "auto"
+			if err := writeOutput(out, args[0], in, result); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result back to the file")
+	return cmd
+}
+
+// commentKey comments out every line setting path. The comment keeps the
+// line's indentation and puts single spaces around the delimiter; the lines
+// around it keep their '=' column.
+func commentKey(lines []string, path string, cfg format.Options) ([]string, error) {
+	marker := commentMarker(lines, cfg)
+	result := make([]string, len(lines))
+	copy(result, lines)
+	found := false
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		if kv.Path() != path {
+			continue
+		}
+		found = true
+		line := lines[kv.Line-1]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		text := strings.TrimSpace(line)
+		if before, after, ok := cfg.Cut(text); ok {
+			text = strings.TrimSpace(before) + " = " + strings.TrimSpace(after)
+			text = strings.TrimSuffix(text, " ")
+		}
+		result[kv.Line-1] = indent + marker + " " + text
+	}
+	if !found {
+		return nil, errNotFound
+	}
+	return result, nil
+}
+
+// uncommentKey restores the single commented-out assignment of path in its
+// section. It fails when the key is already set, and when several comments
+// could be meant.
+func uncommentKey(lines []string, path string, cfg format.Options) ([]string, error) {
+	if kv, ok := format.FindKey(format.ParseKeyValues(lines, cfg), path); ok {
+		return nil, fmt.Errorf("%s is already set on line %d", path, kv.Line)
+	}
+	type candidate struct {
+		line       int
+		key, value string
+	}
+	var candidates []candidate
+	section := ""
+	for i, line := range lines {
+		switch {
+		case format.IsHeader(line):
+			section = format.HeaderName(line)
+			continue
+		case !cfg.IsComment(line):
+			continue
+		}
+		before, after, ok := cfg.Cut(cfg.CommentText(line))
+		if !ok {
+			continue
+		}
+		key := strings.TrimSpace(before)
+		if (format.KeyValue{Section: section, Key: key}).Path() == path {
+			candidates = append(candidates, candidate{i, key, strings.TrimSpace(after)})
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, errNotFound
+	case 1:
+	default:
+		numbers := make([]string, len(candidates))
+		for i, c := range candidates {
+			numbers[i] = strconv.Itoa(c.line + 1)
+		}
+		return nil, fmt.Errorf("%s is commented out on several lines (%s); edit the one you mean by hand", path, strings.Join(numbers, ", "))
+	}
+
+	c := candidates[0]
+	result := make([]string, len(lines))
+	copy(result, lines)
+	result[c.line] = c.key + " = " + c.value
+	if ref := neighbourKey(result, c.line, cfg); ref != -1 {
+		setKeyLine(result, c.line, ref, c.key, c.value, cfg)
+	}
+	return result, nil
+}
+
+// neighbourKey returns the index of the nearest key line to i within its
+// block, looking above first, or -1 when the block has no other key.
+func neighbourKey(lines []string, i int, cfg format.Options) int {
+	start, end := blockBounds(lines, i)
+	isKey := func(j int) bool {
+		if cfg.IsComment(lines[j]) {
+			return false
+		}
+		_, _, ok := cfg.Cut(lines[j])
+		return ok
+	}
+	for j := i - 1; j >= start; j-- {
+		if isKey(j) {
+			return j
+		}
+	}
+	for j := i + 1; j < end; j++ {
+		if isKey(j) {
+			return j
+		}
+	}
+	return -1
+}
+
+// commentMarker returns the comment prefix most full-line comments of the
+// file use, or the first configured prefix when there are none.
+func commentMarker(lines []string, cfg format.Options) string {
+	prefixes := cfg.CommentPrefixes
+	if prefixes == nil {
+		dialect := cfg.Dialect
+		if dialect == nil {
+			dialect = format.DialectINI
+		}
+		prefixes = dialect.CommentPrefixes()
+	}
+	if len(prefixes) == 0 {
+		return ";"
+	}
+	counts := make(map[string]int)
+	best := prefixes[0]
+	for _, line := range lines {
+		if !cfg.IsComment(line) {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		for _, p := range prefixes {
+			if strings.HasPrefix(trimmed, p) {
+				counts[p]++
+				if counts[p] > counts[best] {
+					best = p
+				}
+				break
+			}
+		}
+	}
+	return best
+}
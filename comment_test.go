@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestCommentKey(t *testing.T) {
+	lines := []string{"# settings", "[server]", "host  = x", "debug = true", "  port=1", "[other]", "debug = false"}
+	got, err := commentKey(lines, "server.debug", format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"# settings", "[server]", "host  = x", "# debug = true", "  port=1", "[other]", "debug = false"}
+	if !slices.Equal(got, want) {
+		t.Errorf("commentKey() = %q, want %q", got, want)
+	}
+	got, _ = commentKey(lines, "server.port", format.Options{})
+	if got[4] != "  # port = 1" {
+		t.Errorf("commentKey(port) = %q, want %q", got[4], "  # port = 1")
+	}
+	if _, err := commentKey(lines, "server.missing", format.Options{}); !errors.Is(err, errNotFound) {
+		t.Errorf("commentKey(missing) error = %v, want errNotFound", err)
+	}
+}
+
+func TestUncommentKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		path  string
+		want  []string
+		err   string
+	}{
+		{
+			name:  "aligned to neighbours",
+			lines: []string{"[server]", "host  = x", ";debug=true", "port  = 1"},
+			path:  "server.debug",
+			want:  []string{"[server]", "host  = x", "debug = true", "port  = 1"},
+		},
+		{
+			name:  "block realigned for a longer key",
+			lines: []string{"[s]", "a  = 1", "bb = 2", "# verbose = yes"},
+			path:  "s.verbose",
+			want:  []string{"[s]", "a       = 1", "bb      = 2", "verbose = yes"},
+		},
+		{
+			name:  "prose is not an assignment",
+			lines: []string{"[s]", "; set debug to true for tracing", "a = 1"},
+			path:  "s.debug",
+			err:   "not found",
+		},
+		{
+			name:  "other section",
+			lines: []string{"[a]", "; debug = 1", "[b]", "x = 1"},
+			path:  "b.debug",
+			err:   "not found",
+		},
+		{
+			name:  "ambiguous",
+			lines: []string{"[s]", "; debug = 1", "x = 1", "# debug = 2"},
+			path:  "s.debug",
+			err:   "s.debug is commented out on several lines (2, 4); edit the one you mean by hand",
+		},
+		{
+			name:  "already set",
+			lines: []string{"[s]", "; debug = 1", "debug = 2"},
+			path:  "s.debug",
+			err:   "s.debug is already set on line 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uncommentKey(tt.lines, tt.path, format.Options{})
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("uncommentKey() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("uncommentKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentCommandExitCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("[server]\r\nhost  = x\r\ndebug = true\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"comment", "-w", path, "server.debug"}, 0, "[server]\r\nhost  = x\r\n; debug = true\r\n"},
+		{[]string{"comment", "-w", path, "server.debug"}, 1, "[server]\r\nhost  = x\r\n; debug = true\r\n"},
+		{[]string{"uncomment", "-w", path, "server.debug"}, 0, "[server]\r\nhost  = x\r\ndebug = true\r\n"},
+		{[]string{"uncomment", "-w", path, "server.debug"}, 2, "[server]\r\nhost  = x\r\ndebug = true\r\n"},
+		{[]string{"uncomment", path}, 2, "[server]\r\nhost  = x\r\ndebug = true\r\n"},
+	}
+	for _, tt := range tests {
+		cmd := newRootCmd()
+		cmd.SetArgs(tt.args)
+		code := 0
+		var ee *exitError
+		if err := cmd.Execute(); errors.As(err, &ee) {
+			code = ee.code
+		} else if err != nil {
+			t.Fatalf("%v: unexpected error %v", tt.args, err)
+		}
+		if code != tt.code {
+			t.Errorf("%v: exit %d, want %d", tt.args, code, tt.code)
+		}
+		if got := mustRead(t, path); got != tt.want {
+			t.Errorf("%v: file = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
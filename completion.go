@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// completionExtensions are the file extensions offered when completing a file
+// argument: those the library formats, registry exports and compressed files.
+var completionExtensions = append(slices.Clone(format.Extensions), "reg", "gz")
+
+// completeFiles completes file arguments, offering only INI-like files.
+func completeFiles(_ *cobra.Command, _ []string, _ string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return completionExtensions, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeFileThen completes the first argument as a file and the second with
+// next, which is given the file's contents.
+func completeFileThen(cfg *config, next func(lines []string, opts format.Options, toComplete string) []cobra.Completion) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeFiles(cmd, args, toComplete)
+		case 1:
+			if isURL(args[0]) {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return next(in.lines, dialectOptions(cfg.format, args[0], in.lines), toComplete), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeSections offers the section names of lines starting with toComplete.
+func completeSections(lines []string, _ format.Options, toComplete string) []cobra.Completion {
+	var names []cobra.Completion
+	for _, name := range format.SectionNames(lines) {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// completePaths offers section names and, once toComplete names a section
+// followed by a dot, the section.key paths of its keys. Keys before the first
+// section are offered bare.
+func completePaths(lines []string, opts format.Options, toComplete string) []cobra.Completion {
+	paths := completeSections(lines, opts, toComplete)
+	for _, kv := range format.ParseKeyValues(lines, opts) {
+		path := kv.Path()
+		if kv.Section != "" && !strings.HasPrefix(toComplete, kv.Section+".") {
+			continue
+		}
+		if strings.HasPrefix(path, toComplete) && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// completeOneFile completes the single file argument of a command.
+func completeOneFile(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeFiles(cmd, args, toComplete)
+}
+
+// completePresets completes --preset with the names of the presets.
+func completePresets(_ *cobra.Command, _ []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	var names []cobra.Completion
+	for _, p := range presets {
+		if strings.HasPrefix(p.name, toComplete) {
+			names = append(names, cobra.CompletionWithDesc(p.name, p.description))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// complete runs the hidden completion command and returns its candidates
+// without the trailing directive line.
+func complete(t *testing.T, args ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"__complete"}, args...))
+	cmd.SetOut(&out)
+	cmd.SetErr(new(bytes.Buffer))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	return lines[:len(lines)-1]
+}
+
+func TestCompletion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	content := "top = 1\n[server]\nhost = a\nport = 2\n; old = 3\n[db]\nname = x\n[server.tls]\ncert = c\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"has", path, ""}, []string{"server", "db", "server.tls", "top"}},
+		{[]string{"has", path, "se"}, []string{"server", "server.tls"}},
+		{[]string{"has", path, "server."}, []string{"server.tls", "server.host", "server.port"}},
+		{[]string{"has", path, "server.tls."}, []string{"server.tls.cert"}},
+		{[]string{"has", path, "db.n"}, []string{"db.name"}},
+		{[]string{"has", path, "server.port", ""}, nil},
+		{[]string{"env", path, "d"}, []string{"db"}},
+		{[]string{"has", filepath.Join(t.TempDir(), "missing.ini"), ""}, nil},
+		{[]string{"has", ""}, completionExtensions},
+		{[]string{"grep", ""}, nil},
+		{[]string{"grep", "port", ""}, completionExtensions},
+		{[]string{"keys", path, ""}, nil},
+		{[]string{"--preset", "t"}, []string{"tidy\talign each section, sort keys and indent comments like their keys"}},
+	}
+	for _, tt := range tests {
+		if got := complete(t, tt.args...); !slices.Equal(got, tt.want) && len(got)+len(tt.want) > 0 {
+			t.Errorf("complete(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipInput describes the gzip stream an input was decompressed from, so the
+// output can be compressed the same way.
+type gzipInput struct {
+	header gzip.Header
+	level  int
+}
+
+// decompressInput decompresses r when filename ends in .gz or r starts with
+// the gzip magic bytes. It returns r unchanged and nil for other input.
+// Decompressed input larger than limit bytes is an error, so that a small
+// compressed file cannot expand without bound.
+func decompressInput(r *bufio.Reader, filename string, limit int64) (io.Reader, *gzipInput, error) {
+	head, _ := r.Peek(gzipHeaderSize)
+	if !bytes.HasPrefix(head, gzipMagic) && !strings.HasSuffix(strings.ToLower(filename), ".gz") {
+		return r, nil, nil
+	}
+	if !bytes.HasPrefix(head, gzipMagic) {
+		return nil, nil, fmt.Errorf("decompressing input: %s is not in gzip format", filename)
+	}
+	level := gzipLevel(head)
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decompressing input: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decompressing input: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, nil, fmt.Errorf("decompressing input: decompressed size exceeds --max-size of %d bytes", limit)
+	}
+	return bytes.NewReader(data), &gzipInput{header: zr.Header, level: level}, nil
+}
+
+// gzipHeaderSize is the size of the fixed part of a gzip header.
+const gzipHeaderSize = 10
+
+// gzipLevel recovers the compression level from the extra flags (XFL) of a
+// gzip header, which only record whether the best or the fastest level was
+// used; anything else is taken to be the default level.
+func gzipLevel(head []byte) int {
+	if len(head) < gzipHeaderSize {
+		return gzip.DefaultCompression
+	}
+	switch head[8] {
+	case 2:
+		return gzip.BestCompression
+	case 4:
+		return gzip.BestSpeed
+	}
+	return gzip.DefaultCompression
+}
+
+// compressOutput compresses data like the stream described by gz.
+func compressOutput(data []byte, gz *gzipInput) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gz.level)
+	if err != nil {
+		return nil, err
+	}
+	zw.Header = gz.header
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("compressing output: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing output: %w", err)
+	}
+	return buf.Bytes(), nil
+}
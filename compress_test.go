@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string, level int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Name = "config.ini"
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gunzip(t *testing.T, data []byte) (string, *gzip.Reader) {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(plain), zr
+}
+
+func TestGzipWriteRoundTrip(t *testing.T) {
+	for _, level := range []int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		path := filepath.Join(t.TempDir(), "config.ini.gz")
+		original := gzipBytes(t, "[s]\na=1\nlong_key=2\n", level)
+		if err := os.WriteFile(path, original, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"-w", path})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, zr := gunzip(t, got)
+		if want := "[s]\na        = 1\nlong_key = 2\n"; plain != want {
+			t.Errorf("level %d: decompressed output = %q, want %q", level, plain, want)
+		}
+		if zr.Name != "config.ini" {
+			t.Errorf("level %d: header name = %q, want config.ini", level, zr.Name)
+		}
+		if got[8] != original[8] {
+			t.Errorf("level %d: XFL = %d, want %d", level, got[8], original[8])
+		}
+	}
+}
+
+func TestReadInputGzip(t *testing.T) {
+	dir := t.TempDir()
+	// Detected by the magic bytes even without the extension.
+	path := filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(path, gzipBytes(t, "a=1\n", gzip.DefaultCompression), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in, err := readInput(path, sourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.gzip == nil || len(in.lines) != 1 || in.lines[0] != "a=1" {
+		t.Errorf("readInput(gzip) = %q, gzip %v", in.lines, in.gzip)
+	}
+
+	var out bytes.Buffer
+	data, err := compressOutput([]byte("a = 1\n"), in.gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write(data)
+	if plain, _ := gunzip(t, out.Bytes()); plain != "a = 1\n" {
+		t.Errorf("compressOutput() decompresses to %q", plain)
+	}
+
+	bad := filepath.Join(dir, "bad.ini.gz")
+	if err := os.WriteFile(bad, []byte("a=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readInput(bad, sourceOptions{}); err == nil || !strings.Contains(err.Error(), "bad.ini.gz is not in gzip format") {
+		t.Errorf("readInput(not gzip) error = %v", err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.ini.gz")
+	full := gzipBytes(t, strings.Repeat("key = value\n", 100), gzip.DefaultCompression)
+	if err := os.WriteFile(truncated, full[:len(full)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readInput(truncated, sourceOptions{}); err == nil || !strings.Contains(err.Error(), "decompressing input") {
+		t.Errorf("readInput(truncated) error = %v", err)
+	}
+
+	// --max-size bounds the decompressed size, not the compressed one.
+	bomb := filepath.Join(dir, "bomb.ini.gz")
+	if err := os.WriteFile(bomb, gzipBytes(t, strings.Repeat("a", 4096), gzip.BestCompression), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(bomb); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() > 1024 {
+		t.Fatalf("compressed test input is %d bytes, want under 1K", fi.Size())
+	}
+	if _, err := readInput(bomb, sourceOptions{maxSize: "1K"}); err == nil || !strings.Contains(err.Error(), "exceeds --max-size of 1024 bytes") {
+		t.Errorf("readInput(over --max-size) error = %v", err)
+	}
+	if _, err := readInput(bomb, sourceOptions{maxSize: "4K"}); err != nil {
+		t.Errorf("readInput(at --max-size) error = %v", err)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// projectConfigName is the file name of the project configuration, looked up
+// from the directory of the formatted file towards the filesystem root.
+const projectConfigName = ".inifmt.toml"
+
+// findProjectConfig returns the path of the nearest project configuration in
+// dir or one of its parents, or "" when there is none.
+func findProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// sectionTable is the key of the project config holding the per-section
+// tables, e.g. [section."env"].
+const sectionTable = "section"
+
+// overrideTable is the key of the project config holding the per-path
+// [[override]] tables.
+const overrideTable = "override"
+
+// sectionSettings are the flags a per-section table may set: those that
+// change how the keys of a single section are formatted.
+var sectionSettings = []string{
+	"align-comment-indent", "align-pairs", "collate", "collate-locale",
+	"dedupe-keys", "empty-quoted", "empty-unset", "expand-env",
+	"group-by-comments", "group-by-prefix", "group-separators", "hex-case",
+	"inline-comment-gap", "join-continuations", "join-separator",
+	"list-separator", "list-trailing-comma", "no-lossy", "normalize-lists",
+	"normalize-numbers", "normalize-unicode-delimiters", "per-block", "redact-reveal",
+	"remove-empty-values", "single-space", "sort-case", "sort-keys",
+	"sort-list-values", "split-on", "strip-comments", "tab-width", "unique",
+	"unique-list-values", "with-comments", "wrap-values",
+}
+
+// sectionConfig is a [section."pattern"] table of the project config: the
+// settings of the sections whose name matches pattern.
+type sectionConfig struct {
+	path     string
+	pattern  string
+	settings map[string]any
+}
+
+// userConfigPath returns the path of the user configuration:
+// $XDG_CONFIG_HOME/inifmt/config.toml, falling back to ~/.config on Unix and
+// to %AppData% on Windows. It returns "" when no home directory is known.
+func userConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "inifmt", "config.toml")
+	}
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "inifmt", "config.toml")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "inifmt", "config.toml")
+}
+
+// applyConfigs applies the project configuration for filename and then the
+// user configuration, each leaving alone the flags set before it, and returns
+// their per-section tables, the project's first. A user configuration that
+// cannot be read or applied is logged as a warning and ignored.
+func applyConfigs(flags *pflag.FlagSet, filename string, log *slog.Logger) ([]sectionConfig, error) {
+	sections, err := applyProjectConfig(flags, filename, log)
+	if err != nil {
+		return nil, err
+	}
+	path := userConfigPath()
+	if path == "" {
+		return sections, nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return sections, nil
+	}
+	// The user's globs match absolute paths, from the filesystem root.
+	rel, _ := relativePath(string(filepath.Separator), filename)
+	// A dry run on fresh flags first, so a bad file changes nothing.
+	if _, err := applyConfigFile(newRootCmd().Flags(), path, rel); err != nil {
+		log.Warn(fmt.Sprintf("ignoring user config: %v", err), "config", path, "error", err)
+		return sections, nil
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok && !flags.Changed("color") {
+		// The environment beats the user config.
+		if err := setFlag(flags, "color", "never", "env NO_COLOR"); err != nil {
+			return nil, err
+		}
+	}
+	log.Debug(fmt.Sprintf("using user config %s", path), "config", path)
+	user, err := applyConfigFile(flags, path, rel)
+	if err != nil {
+		return nil, err
+	}
+	return append(sections, user...), nil
+}
+
+// applyProjectConfig finds the project configuration for filename (the current
+// directory for stdin and URLs) and applies it to flags. It returns the
+// per-section tables of the configuration.
+func applyProjectConfig(flags *pflag.FlagSet, filename string, log *slog.Logger) ([]sectionConfig, error) {
+	dir := "."
+	if filename != "" && !isURL(filename) {
+		dir = filepath.Dir(filename)
+	}
+	path, err := findProjectConfig(dir)
+	if err != nil {
+		return nil, fmt.Errorf("finding project config: %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	rel, _ := relativePath(filepath.Dir(path), filename)
+	log.Debug(fmt.Sprintf("using project config %s", path), "config", path)
+	return applyConfigFile(flags, path, rel)
+}
+
+// applyConfigFile sets the flags named by the top-level keys of the TOML file
+// at path, unless they were given on the command line. Keys are long flag
+// names, with '_' accepted for '-'. Arrays become comma-separated lists, and
+// true for a flag taking an optional value, such as sort-keys, means the bare
+// flag. The [[override]] tables whose path glob matches rel, the formatted
+// file's slash-separated path relative to the directory the globs start from
+// ("" for none), are applied on top, and the [section."pattern"] tables are
+// returned for sectionOptions.
+func applyConfigFile(flags *pflag.FlagSet, path, rel string) ([]sectionConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	var settings map[string]any
+	if _, err := toml.Decode(string(data), &settings); err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	var sections []sectionConfig
+	for _, key := range sortedKeys(settings) {
+		if key == sectionTable {
+			if sections, err = sectionConfigs(path, settings[key]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if key == overrideTable {
+			continue
+		}
+		name := strings.ReplaceAll(key, "_", "-")
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if flags.Changed(name) {
+			continue
+		}
+		value, err := configValue(flag, settings[key])
+		if err != nil {
+			return nil, fmt.Errorf("%s: setting %q: %w", path, key, err)
+		}
+		if value == nil {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", path, configKeyLine(string(data), key))
+		if err := setFlag(flags, name, *value, source); err != nil {
+			return nil, fmt.Errorf("%s: setting %q: %w", path, key, err)
+		}
+	}
+	if err := applyOverrides(flags, path, string(data), settings[overrideTable], rel); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// applyOverrides applies the [[override]] tables of the config at path whose
+// path glob matches rel. Matching
+// tables are applied from the least to the most specific glob, so the most
+// specific one wins, and tables that tie are applied in file order. Flags
+// given on the command line are left alone.
+func applyOverrides(flags *pflag.FlagSet, path, text string, v any, rel string) error {
+	if v == nil {
+		return nil
+	}
+	tables, ok := v.([]map[string]any)
+	if !ok {
+		return fmt.Errorf(`%s: setting %q: want [[override]] tables`, path, overrideTable)
+	}
+	type override struct {
+		index    int
+		glob     string
+		settings map[string]any
+	}
+	var matched []override
+	for i, table := range tables {
+		glob, ok := table["path"].(string)
+		if !ok || glob == "" {
+			return fmt.Errorf("%s: override %d: want a path glob such as path = \"deploy/**/*.service\"", path, i+1)
+		}
+		if err := checkPathGlob(glob); err != nil {
+			return fmt.Errorf("%s: override %d: invalid path %q: %w", path, i+1, glob, err)
+		}
+		for key := range table {
+			if key != "path" && flags.Lookup(strings.ReplaceAll(key, "_", "-")) == nil {
+				return fmt.Errorf("%s: override %d: unknown setting %q", path, i+1, key)
+			}
+		}
+		if rel != "" && matchPath(glob, rel) {
+			matched = append(matched, override{i, glob, table})
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b override) int {
+		return globLiterals(a.glob) - globLiterals(b.glob)
+	})
+	for _, o := range matched {
+		for _, key := range sortedKeys(o.settings) {
+			if key == "path" {
+				continue
+			}
+			name := strings.ReplaceAll(key, "_", "-")
+			flag := flags.Lookup(name)
+			if givenOnCommandLine(flag) {
+				continue
+			}
+			value, err := configValue(flag, o.settings[key])
+			if err != nil {
+				return fmt.Errorf("%s: override %q: setting %q: %w", path, o.glob, key, err)
+			}
+			if value == nil {
+				continue
+			}
+			source := fmt.Sprintf("%s:%d override %s", path, overrideKeyLine(text, o.index, key), o.glob)
+			if err := setFlag(flags, name, *value, source); err != nil {
+				return fmt.Errorf("%s: override %q: setting %q: %w", path, o.glob, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// relativePath returns filename relative to dir with forward slashes, and
+// whether filename is inside dir at all. Stdin and URLs are inside no
+// directory.
+func relativePath(dir, filename string) (string, bool) {
+	if filename == "" || isURL(filename) {
+		return "", false
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// matchPath reports whether the slash-separated name matches glob, whose
+// segments are path.Match patterns and where a "**" segment matches any
+// number of directories.
+func matchPath(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+// checkPathGlob reports a syntax error in any segment of a matchPath glob.
+func checkPathGlob(glob string) error {
+	for _, segment := range strings.Split(glob, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSegments is matchPath over split paths.
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := range len(name) + 1 {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// globLiterals counts the characters of glob that match only themselves,
+// which ranks how specific a glob is.
+func globLiterals(glob string) int {
+	n := 0
+	class := false
+	for _, c := range glob {
+		switch {
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c != '*' && c != '?' && c != '\\':
+			n++
+		}
+	}
+	return n
+}
+
+// overrideKeyLine returns the line number of key in the index'th (from 0)
+// [[override]] table of the TOML text, or 0 when it cannot be found.
+func overrideKeyLine(text string, index int, key string) int {
+	table := -1
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") && strings.Trim(line, "[] ") == overrideTable {
+				table++
+			} else if table == index {
+				break
+			}
+			continue
+		}
+		if table != index {
+			continue
+		}
+		if name, _, ok := strings.Cut(line, "="); ok && strings.Trim(strings.TrimSpace(name), `"'`) == key {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// sectionConfigs reads the value of the section key of the config at path,
+// a table of per-section tables keyed by section name or glob.
+func sectionConfigs(path string, v any) ([]sectionConfig, error) {
+	tables, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf(`%s: setting %q: want tables such as [section."name"]`, path, sectionTable)
+	}
+	var sections []sectionConfig
+	for _, pattern := range sortedKeys(tables) {
+		settings, ok := tables[pattern].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf(`%s: setting "section.%s": want a table such as [section."%s"]`, path, pattern, pattern)
+		}
+		sections = append(sections, sectionConfig{path: path, pattern: pattern, settings: settings})
+	}
+	return sections, nil
+}
+
+// sectionOptions resolves per-section tables into the options their sections
+// are formatted with: the file's options in cfg with the table's settings
+// applied on top. Flags and cfg are left as they were.
+func sectionOptions(flags *pflag.FlagSet, cfg *config, sections []sectionConfig) ([]format.SectionOptions, error) {
+	base := *cfg
+	defer func() { *cfg = base }()
+	var result []format.SectionOptions
+	for _, sc := range sections {
+		*cfg = base
+		for _, key := range sortedKeys(sc.settings) {
+			name := strings.ReplaceAll(key, "_", "-")
+			if !slices.Contains(sectionSettings, name) {
+				return nil, fmt.Errorf("%s: section %q: setting %q cannot be set per section", sc.path, sc.pattern, key)
+			}
+			if err := setSectionValue(flags.Lookup(name), sc.settings[key]); err != nil {
+				return nil, fmt.Errorf("%s: section %q: setting %q: %w", sc.path, sc.pattern, key, err)
+			}
+		}
+		if err := validateConfig(*cfg); err != nil {
+			return nil, fmt.Errorf("%s: section %q: %w", sc.path, sc.pattern, err)
+		}
+		result = append(result, format.SectionOptions{Pattern: sc.pattern, Options: cfg.format})
+	}
+	return result, nil
+}
+
+// setSectionValue sets the value of flag from a per-section setting without
+// marking the flag as given. Unlike at the top level, false for a flag taking
+// an optional value turns it off, so a section can opt out of sort-keys.
+func setSectionValue(flag *pflag.Flag, v any) error {
+	value, err := configValue(flag, v)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		value = &flag.DefValue
+	}
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		var items []string
+		if *value != "" && *value != "[]" {
+			items = strings.Split(*value, ",")
+		}
+		return slice.Replace(items)
+	}
+	return flag.Value.Set(*value)
+}
+
+// configValue converts a TOML value to the flag value it stands for, or nil
+// when the flag should be left alone.
+func configValue(flag *pflag.Flag, v any) (*string, error) {
+	var s string
+	switch v := v.(type) {
+	case bool:
+		switch {
+		case flag.Value.Type() == "bool":
+			s = fmt.Sprint(v)
+		case v && flag.NoOptDefVal != "":
+			s = flag.NoOptDefVal
+		case !v && flag.NoOptDefVal != "":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("want a %s, not a boolean", flag.Value.Type())
+		}
+	case string:
+		s = v
+	case int64, float64:
+		s = fmt.Sprint(v)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("list items must be strings, not %T", item)
+			}
+			items[i] = str
+		}
+		s = strings.Join(items, ",")
+	default:
+		return nil, fmt.Errorf("unsupported value %T", v)
+	}
+	return &s, nil
+}
+
+// configKeyLine returns the line number of the top-level key in the TOML text,
+// or 0 when it cannot be found.
+func configKeyLine(text, key string) int {
+	for i, line := range strings.Split(text, "\n") {
+		name, _, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if strings.Trim(name, `"'`) == key {
+			return i + 1
+		}
+		if strings.HasPrefix(name, "[") {
+			break
+		}
+	}
+	return 0
+}
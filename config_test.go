@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(root, projectConfigName)
+	if err := os.WriteFile(want, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := findProjectConfig(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("findProjectConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), projectConfigName)
+	content := "sort_keys = [\"aliases\", \"hosts\"]\nper-section = true\nblank-lines = \"squeeze\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	if err := cmd.Flags().Parse([]string{"--blank-lines=keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyConfigFile(cmd.Flags(), path, ""); err != nil {
+		t.Fatal(err)
+	}
+	for flag, want := range map[string]string{
+		"sort-keys":   "[aliases,hosts]",
+		"per-section": "true",
+		"blank-lines": "keep", // given on the command line
+	} {
+		if got := cmd.Flags().Lookup(flag).Value.String(); got != want {
+			t.Errorf("--%s = %q, want %q", flag, got, want)
+		}
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key": "no-such-flag = true\n",
+		"bad type":    "blank-lines = true\n",
+		"bad syntax":  "sort-keys = [\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), projectConfigName)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := applyConfigFile(newRootCmd().Flags(), path, ""); err == nil {
+				t.Error("applyConfigFile() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestConfigBareSortKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), projectConfigName)
+	if err := os.WriteFile(path, []byte("sort-keys = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	if _, err := applyConfigFile(cmd.Flags(), path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmd.Flags().Lookup("sort-keys").Value.String(); got != "[*]" {
+		t.Errorf("sort-keys = true gave %q, want [*]", got)
+	}
+}
+
+func TestConfigSectionTables(t *testing.T) {
+	dir := t.TempDir()
+	config := `[section."env"]
+single_space = true
+
+[section."plugins*"]
+sort_keys = true
+
+[section."plugins.core"]
+sort_keys = false
+`
+	if err := os.WriteFile(filepath.Join(dir, projectConfigName), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.ini")
+	content := "[env]\nPATH=/bin\nHOME=/root\n\n[plugins]\nzeta=on\nalpha=off\n\n[plugins.core]\nzeta=on\nalpha=off\n\n[server]\nlisten_address=0.0.0.0\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[env]\nPATH = /bin\nHOME = /root\n\n[plugins]\nalpha = off\nzeta  = on\n\n[plugins.core]\nzeta  = on\nalpha = off\n\n[server]\nlisten_address = 0.0.0.0\n"
+	if string(got) != want {
+		t.Errorf("formatted file = %q, want %q", got, want)
+	}
+}
+
+func TestConfigSectionTableErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a table":       "section = 1\n",
+		"whole-file flag":   "[section.\"env\"]\nsort-sections = true\n",
+		"unknown flag":      "[section.\"env\"]\nno-such-flag = true\n",
+		"invalid value":     "[section.\"env\"]\ndedupe-keys = \"middle\"\n",
+		"setting not table": "[section]\nenv = 1\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, projectConfigName), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "app.ini")
+			if err := os.WriteFile(path, []byte("[env]\na=1\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{"-w", path})
+			cmd.SilenceErrors, cmd.SilenceUsage = true, true
+			if err := cmd.Execute(); err == nil {
+				t.Error("Execute() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		glob, name string
+		want       bool
+	}{
+		{"deploy/**/*.service", "deploy/web/app.service", true},
+		{"deploy/**/*.service", "deploy/app.service", true},
+		{"deploy/**/*.service", "deploy/a/b/c/app.service", true},
+		{"deploy/**/*.service", "other/app.service", false},
+		{"configs/*.ini", "configs/app.ini", true},
+		{"configs/*.ini", "configs/sub/app.ini", false},
+		{"**", "anything/at/all.ini", true},
+		{"*.ini", "configs/app.ini", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.glob, tt.name); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.glob, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, projectConfigName)
+	content := `blank-lines = "squeeze"
+sort-keys = ["a"]
+
+[[override]]
+path = "deploy/**"
+blank-lines = "sections"
+single-space = true
+
+[[override]]
+path = "deploy/**/*.service"
+dialect = "systemd"
+sort-keys = ["b", "c"]
+
+[[override]]
+path = "configs/*.ini"
+per-section = true
+`
+	if err := os.WriteFile(config, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		file     string
+		args     []string
+		settings map[string]string
+	}{
+		{
+			name: "most specific last",
+			file: "deploy/web/app.service",
+			settings: map[string]string{
+				"blank-lines":  "sections\t" + config + ":6 override deploy/**",
+				"single-space": "true\t" + config + ":7 override deploy/**",
+				"dialect":      "systemd\t" + config + ":11 override deploy/**/*.service",
+				"sort-keys":    "[b,c]\t" + config + ":12 override deploy/**/*.service",
+				"per-section":  "false\tdefault",
+			},
+		},
+		{
+			name: "flags win",
+			file: "deploy/web/app.service",
+			args: []string{"--single-space=false"},
+			settings: map[string]string{
+				"single-space": "false\tflag",
+				"blank-lines":  "sections\t" + config + ":6 override deploy/**",
+			},
+		},
+		{
+			name: "no match",
+			file: "configs/sub/app.ini",
+			settings: map[string]string{
+				"blank-lines": "squeeze\t" + config + ":1",
+				"per-section": "false\tdefault",
+			},
+		},
+		{
+			name: "other match",
+			file: "configs/app.ini",
+			settings: map[string]string{
+				"per-section": "true\t" + config + ":16 override configs/*.ini",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			if err := cmd.Flags().Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := applyConfigFile(cmd.Flags(), config, tt.file); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.settings {
+				flag := cmd.Flags().Lookup(name)
+				if got := flag.Value.String() + "\t" + flagSource(flag); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigOverrideErrors(t *testing.T) {
+	tests := map[string]string{
+		"no path":      "[[override]]\nper-section = true\n",
+		"bad glob":     "[[override]]\npath = \"[\"\n",
+		"unknown key":  "[[override]]\npath = \"*\"\nno-such-flag = 1\n",
+		"not an array": "override = 1\n",
+		"bad value":    "[[override]]\npath = \"*.ini\"\nblank-lines = true\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, projectConfigName)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := applyConfigFile(newRootCmd().Flags(), path, "app.ini"); err == nil {
+				t.Error("applyConfigFile() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestUserConfigPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fallback is %AppData% on Windows")
+	}
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got, want := userConfigPath(), filepath.Join("/xdg", "inifmt", "config.toml"); got != want {
+		t.Errorf("userConfigPath() = %q, want %q", got, want)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	t.Setenv("HOME", "/home/me")
+	if got, want := userConfigPath(), filepath.Join("/home/me", ".config", "inifmt", "config.toml"); got != want {
+		t.Errorf("userConfigPath() = %q, want %q", got, want)
+	}
+}
+
+func TestUserConfig(t *testing.T) {
+	xdg := t.TempDir()
+	user := filepath.Join(xdg, "inifmt", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(user), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, projectConfigName), []byte("per-section = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(project, "app.ini")
+
+	showConfig := func(args ...string) map[string]setting {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"--show-config=json", "--quiet"}, append(args, file)...))
+		cmd.SetOut(&out)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var settings []setting
+		if err := json.Unmarshal(out.Bytes(), &settings); err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]setting)
+		for _, s := range settings {
+			got[s.Name] = s
+		}
+		return got
+	}
+
+	if err := os.WriteFile(user, []byte("per-section = false\nper-block = true\n\n[[override]]\npath = \"**/app.ini\"\nsingle-space = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := showConfig()
+	for name, want := range map[string]string{
+		"per-section":  project + string(filepath.Separator) + projectConfigName + ":1", // project beats user
+		"per-block":    user + ":2",
+		"single-space": user + ":6 override **/app.ini",
+	} {
+		if got[name].Source != want {
+			t.Errorf("%s comes from %q, want %q", name, got[name].Source, want)
+		}
+	}
+	if err := os.WriteFile(user, []byte("per-block = true\ncolor = \"always\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got := showConfig(); got["color"].Value != "never" || got["color"].Source != "env NO_COLOR" {
+		t.Errorf("color = %v from %q, want never from NO_COLOR", got["color"].Value, got["color"].Source)
+	}
+	if got := showConfig("--no-config"); got["per-block"].Source != "default" {
+		t.Errorf("--no-config: per-block comes from %q, want default", got["per-block"].Source)
+	}
+
+	if err := os.WriteFile(user, []byte("per-block = true\nno-such-flag = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := showConfig(); got["per-block"].Source != "default" {
+		t.Errorf("malformed user config: per-block comes from %q, want default", got["per-block"].Source)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// conflict is a combination of flags that contradict each other, or of which
+// one would do nothing given the others. A flag that cannot be combined with
+// another declares it here, so that validateConfig rejects the combination
+// before any input is read.
+type conflict struct {
+	flags   []string          // the flags, as the error names them
+	example []string          // command line arguments combining them
+	when    func(config) bool // whether cfg combines them
+	why     string            // what goes wrong, and what to use instead
+}
+
+// err returns the usage error reporting c.
+func (c conflict) err() error {
+	names := strings.Join(c.flags[:len(c.flags)-1], ", ") + " and " + c.flags[len(c.flags)-1]
+	return optionError(c.flags[0], fmt.Errorf("%s cannot be combined: %s", names, c.why))
+}
+
+// conflicts are the flag combinations inifmt refuses.
+var conflicts = []conflict{
+	{
+		flags:   []string{"--write", "--output"},
+		example: []string{"--write", "--output=out.ini"},
+		when:    func(c config) bool { return c.write && c.output != "" },
+		why:     "--write replaces the file and --output writes somewhere else; use one of them",
+	},
+	{
+		flags:   []string{"--write", "--output-dir"},
+		example: []string{"--write", "--output-dir=build"},
+		when:    func(c config) bool { return c.write && c.outputDir != "" },
+		why:     "--write replaces the originals and --output-dir leaves them untouched; use one of them",
+	},
+	{
+		flags:   []string{"--output", "--output-dir"},
+		example: []string{"--output=out.ini", "--output-dir=build"},
+		when:    func(c config) bool { return c.output != "" && c.outputDir != "" },
+		why:     "--output names one file and --output-dir a tree to mirror the inputs into; use one of them",
+	},
+	{
+		flags:   []string{"--check", "--write"},
+		example: []string{"--check", "--write"},
+		when:    func(c config) bool { return c.check && c.write },
+		why:     "--check only reports whether files are formatted and writes nothing; drop --write to check, or --check to format",
+	},
+	{
+		flags:   []string{"--check", "--output"},
+		example: []string{"--check", "--output=out.ini"},
+		when:    func(c config) bool { return c.check && c.output != "" },
+		why:     "--check writes no output; drop --output",
+	},
+	{
+		flags:   []string{"--check", "--output-dir"},
+		example: []string{"--check", "--output-dir=build"},
+		when:    func(c config) bool { return c.check && c.outputDir != "" },
+		why:     "--check writes no output; drop --output-dir",
+	},
+	{
+		flags:   []string{"--check", "--hash"},
+		example: []string{"--check", "--hash"},
+		when:    func(c config) bool { return c.check && c.hash },
+		why:     "--check reports unformatted files and --hash prints digests; use one of them",
+	},
+	{
+		flags:   []string{"--check", "--hash-raw"},
+		example: []string{"--check", "--hash-raw"},
+		when:    func(c config) bool { return c.check && c.hashRaw },
+		why:     "--check reports unformatted files and --hash-raw prints digests; use one of them",
+	},
+	{
+		flags:   []string{"--check", "--to"},
+		example: []string{"--check", "--to=flat"},
+		when:    func(c config) bool { return c.check && c.to != "" && c.to != "ini" },
+		why:     "--check compares the formatted INI file with the input, which other output formats never match",
+	},
+	{
+		flags:   []string{"--check", "--to-utf8"},
+		example: []string{"--check", "--to-utf8"},
+		when:    func(c config) bool { return c.check && c.toUTF8 },
+		why:     "--check compares the output with the input as it is encoded, so a file --to-utf8 converts never passes",
+	},
+	{
+		flags:   []string{"--check", "--diff"},
+		example: []string{"--check", "--diff"},
+		when:    func(c config) bool { return c.diff && c.check },
+		why:     "--diff already exits 1 when a file is not formatted; drop --check",
+	},
+	{
+		flags:   []string{"--diff", "--write"},
+		example: []string{"--diff", "--write"},
+		when:    func(c config) bool { return c.diff && c.write },
+		why:     "--diff only shows what formatting would change; drop --diff to write the changes",
+	},
+	{
+		flags:   []string{"--diff", "--output"},
+		example: []string{"--diff", "--output=out.ini"},
+		when:    func(c config) bool { return c.diff && c.output != "" },
+		why:     "--diff prints the changes instead of the output; drop --output",
+	},
+	{
+		flags:   []string{"--diff", "--output-dir"},
+		example: []string{"--diff", "--output-dir=build"},
+		when:    func(c config) bool { return c.diff && c.outputDir != "" },
+		why:     "--diff prints the changes instead of the output; drop --output-dir",
+	},
+	{
+		flags:   []string{"--diff", "--hash"},
+		example: []string{"--diff", "--hash"},
+		when:    func(c config) bool { return c.diff && c.hash },
+		why:     "--diff prints the changes and --hash digests; use one of them",
+	},
+	{
+		flags:   []string{"--diff", "--hash-raw"},
+		example: []string{"--diff", "--hash-raw"},
+		when:    func(c config) bool { return c.diff && c.hashRaw },
+		why:     "--diff prints the changes and --hash-raw digests; use one of them",
+	},
+	{
+		flags:   []string{"--diff", "--to"},
+		example: []string{"--diff", "--to=flat"},
+		when:    func(c config) bool { return c.diff && c.to != "" && c.to != "ini" },
+		why:     "--diff compares the formatted INI file with the input, which other output formats never match",
+	},
+	{
+		flags:   []string{"--diff", "--to-utf8"},
+		example: []string{"--diff", "--to-utf8"},
+		when:    func(c config) bool { return c.diff && c.toUTF8 },
+		why:     "--diff compares the output with the input as it is encoded; drop --to-utf8",
+	},
+	{
+		flags:   []string{"--hash", "--hash-raw"},
+		example: []string{"--hash", "--hash-raw"},
+		when:    func(c config) bool { return c.hash && c.hashRaw },
+		why:     "--hash digests the canonical form and --hash-raw the formatted output; use one of them",
+	},
+	{
+		flags:   []string{"--hash", "--write"},
+		example: []string{"--hash", "--write"},
+		when:    func(c config) bool { return c.hash && c.write },
+		why:     "--hash prints digests instead of the output, so there is nothing to write; drop --write",
+	},
+	{
+		flags:   []string{"--hash", "--output"},
+		example: []string{"--hash", "--output=out.ini"},
+		when:    func(c config) bool { return c.hash && c.output != "" },
+		why:     "--hash prints digests instead of the output, so there is nothing to write; drop --output",
+	},
+	{
+		flags:   []string{"--hash", "--output-dir"},
+		example: []string{"--hash", "--output-dir=build"},
+		when:    func(c config) bool { return c.hash && c.outputDir != "" },
+		why:     "--hash prints digests instead of the output, so there is nothing to write; drop --output-dir",
+	},
+	{
+		flags:   []string{"--hash-raw", "--write"},
+		example: []string{"--hash-raw", "--write"},
+		when:    func(c config) bool { return c.hashRaw && c.write },
+		why:     "--hash-raw prints digests instead of the output, so there is nothing to write; drop --write",
+	},
+	{
+		flags:   []string{"--hash-raw", "--output"},
+		example: []string{"--hash-raw", "--output=out.ini"},
+		when:    func(c config) bool { return c.hashRaw && c.output != "" },
+		why:     "--hash-raw prints digests instead of the output, so there is nothing to write; drop --output",
+	},
+	{
+		flags:   []string{"--hash-raw", "--output-dir"},
+		example: []string{"--hash-raw", "--output-dir=build"},
+		when:    func(c config) bool { return c.hashRaw && c.outputDir != "" },
+		why:     "--hash-raw prints digests instead of the output, so there is nothing to write; drop --output-dir",
+	},
+	{
+		flags:   []string{"--write", "--to"},
+		example: []string{"--write", "--to=flat"},
+		when:    func(c config) bool { return c.write && c.to != "" && c.to != "ini" },
+		why:     "flat, CSV, Markdown and HTML output would replace the INI file; use --output or redirect stdout",
+	},
+	{
+		flags:   []string{"--embedded=markdown", "--to"},
+		example: []string{"--embedded=markdown", "--to=csv"},
+		when:    func(c config) bool { return c.embedded == "markdown" && c.to != "" && c.to != "ini" },
+		why:     "--to converts a whole INI file, not the INI blocks of a document; drop --embedded or --to",
+	},
+	{
+		flags:   []string{"--nest", "--flatten"},
+		example: []string{"--nest", "--flatten"},
+		when:    func(c config) bool { return c.nest != "" && c.format.Flatten },
+		why:     "each undoes the other; run them one at a time",
+	},
+	{
+		flags:   []string{"--no-lossy", "--force-lossy"},
+		example: []string{"--no-lossy", "--force-lossy"},
+		when:    func(c config) bool { return c.format.KeepLossy && c.forceLossy },
+		why:     "--no-lossy keeps the lines --force-lossy would rewrite; use one of them",
+	},
+	{
+		flags:   []string{"--single-space", "--per-section"},
+		example: []string{"--single-space", "--per-section"},
+		when:    func(c config) bool { return c.format.SingleSpace && c.format.PerSection },
+		why:     "--single-space aligns nothing, so there is nothing to align per section; drop --per-section",
+	},
+	{
+		flags:   []string{"--single-space", "--per-block"},
+		example: []string{"--single-space", "--per-block"},
+		when:    func(c config) bool { return c.format.SingleSpace && c.format.PerBlock },
+		why:     "--single-space aligns nothing, so there is nothing to align per block; drop --per-block",
+	},
+	{
+		flags:   []string{"--single-space", "--group-by-comments"},
+		example: []string{"--single-space", "--group-by-comments"},
+		when:    func(c config) bool { return c.format.SingleSpace && c.format.GroupByComments },
+		why:     "--single-space aligns nothing, so there are no groups to restart; drop --group-by-comments",
+	},
+	{
+		flags:   []string{"--since", "--output"},
+		example: []string{"--since=HEAD", "--output=out.ini"},
+		when:    func(c config) bool { return c.since != "" && c.output != "" },
+		why:     "--since may pick several files and --output holds one; use --write or stdout",
+	},
+	{
+		flags:   []string{"--since", "--stdin-filename"},
+		example: []string{"--since=HEAD", "--stdin-filename=app.ini"},
+		when:    func(c config) bool { return c.since != "" && c.stdinFilename != "" },
+		why:     "--since reads the changed files, never stdin; drop --stdin-filename",
+	},
+	{
+		flags:   []string{"--since", "--show-config"},
+		example: []string{"--since=HEAD", "--show-config"},
+		when:    func(c config) bool { return c.since != "" && c.showConfig != "" },
+		why:     "--show-config shows the settings of one file; name the file instead",
+	},
+	{
+		flags:   []string{"--output", "several files"},
+		example: []string{"--output=out.ini", "other.ini"},
+		when:    func(c config) bool { return c.files > 1 && c.to != "csv" && c.output != "" },
+		why:     "--output holds one file; use --write, --output-dir or stdout",
+	},
+	{
+		flags:   []string{"--stdin-filename", "several files"},
+		example: []string{"--stdin-filename=app.ini", "other.ini"},
+		when:    func(c config) bool { return c.files > 1 && c.to != "csv" && c.stdinFilename != "" },
+		why:     "the files are read, never stdin; drop --stdin-filename",
+	},
+	{
+		flags:   []string{"--show-config", "several files"},
+		example: []string{"--show-config", "other.ini"},
+		when:    func(c config) bool { return c.files > 1 && c.to != "csv" && c.showConfig != "" },
+		why:     "--show-config shows the settings of one file; name only that file",
+	},
+	{
+		flags:   []string{"--dedupe-keys", "--dialect"},
+		example: []string{"--dedupe-keys=last", "--dialect=systemd"},
+		when: func(c config) bool {
+			d, ok := format.LookupDialect(c.dialect)
+			return c.format.DedupeKeys != "" && ok && format.RepeatsKeys(d)
+		},
+		why: "repeated keys add up in this dialect, as each ExecStartPre= adds a command, so dropping them loses settings; leave out --dedupe-keys",
+	},
+}
+
+// checkConflicts returns the error of the first conflict cfg has, or nil.
+func checkConflicts(cfg config) error {
+	for _, c := range conflicts {
+		if c.when(cfg) {
+			return c.err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestConflicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("a=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conflicts {
+		t.Run(strings.Join(c.example, " "), func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetArgs(append(append([]string{"--no-config"}, c.example...), path))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+			err := cmd.Execute()
+			var oe *format.OptionError
+			if !errors.As(err, &oe) || exitCode(err) != 2 {
+				t.Fatalf("Execute() = %v, want a usage error", err)
+			}
+			if want := c.err().Error(); err.Error() != want {
+				t.Errorf("Execute() = %q, want %q", err, want)
+			}
+			for _, flag := range c.flags {
+				if !strings.Contains(err.Error(), flag) {
+					t.Errorf("error %q does not name %s", err, flag)
+				}
+			}
+		})
+	}
+	if got, want := mustRead(t, path), "a=1\n"; got != want {
+		t.Errorf("file = %q after refused runs, want it unchanged", got)
+	}
+}
+
+func TestWriteStdinRefused(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write"})
+	cmd.SetIn(strings.NewReader("a=1\n"))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); exitCode(err) != 2 || !strings.Contains(err.Error(), "--stdin-filename") {
+		t.Errorf("--write on stdin = %v, want a usage error suggesting --stdin-filename", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// csvHeader is the header row of --to=csv output; --csv-comments appends a
+// comment column.
+var csvHeader = []string{"file", "section", "key", "value", "line"}
+
+// writeCSV writes one CSV row per key of every file, or of stdin when files is
+// empty, as a single table. Files are formatted with cfg first, so line
+// numbers refer to the formatted file, and values lose their inline comments,
+// which --csv-comments puts in a column of their own.
+func writeCSV(ctx context.Context, w io.Writer, files []string, cfg config) error {
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	out := csv.NewWriter(w)
+	out.UseCRLF = cfg.lineEnding == "crlf"
+	header := csvHeader
+	if cfg.csvComments {
+		header = append(header[:len(header):len(header)], "comment")
+	}
+	if err := out.Write(header); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	for _, file := range files {
+		in, err := readInput(file, cfg.source)
+		if err != nil {
+			return err
+		}
+		lines, err := processLines(ctx, file, in.lines, cfg)
+		if err != nil {
+			return err
+		}
+		name := file
+		if name == "" {
+			name = "-"
+		}
+		for _, kv := range format.ParseKeyValues(lines, cfg.format) {
+			value, comment := format.SplitInlineComment(kv.Value)
+			row := []string{name, kv.Section, kv.Key, value, strconv.Itoa(kv.Line)}
+			if cfg.csvComments {
+				row = append(row, comment)
+			}
+			if err := out.Write(row); err != nil {
+				return fmt.Errorf("writing output: %w", err)
+			}
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.ini")
+	b := filepath.Join(dir, "b.ini")
+	if err := os.WriteFile(a, []byte("top = 1\n[db]\nhosts = a, b ; replicas\nquote = say \"hi\"\nflag\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("; comment\n[web]\nport=80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"file", "section", "key", "value", "line", "comment"},
+		{a, "", "top", "1", "1", ""},
+		{a, "db", "hosts", "a, b", "3", "replicas"},
+		{a, "db", "quote", `say "hi"`, "4", ""},
+		{a, "db", "flag", "", "5", ""},
+		{b, "web", "port", "80", "3", ""},
+	}
+	var buf bytes.Buffer
+	if err := writeCSV(context.Background(), &buf, []string{a, b}, config{csvComments: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV output: %v\n%s", err, buf.String())
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("writeCSV() rows = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := writeCSV(context.Background(), &buf, []string{b}, config{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "file,section,key,value,line\n" + b + ",web,port,80,3\n"; buf.String() != want {
+		t.Errorf("writeCSV() = %q, want %q", buf.String(), want)
+	}
+
+	if err := writeCSV(context.Background(), &buf, []string{filepath.Join(dir, "missing.ini")}, config{}); err == nil {
+		t.Error("writeCSV(missing file) expected error")
+	}
+}
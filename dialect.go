@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// dialectFiles maps file names to the dialect --dialect=auto picks for them.
+var dialectFiles = map[string]format.Dialect{
+	".gitconfig":         format.DialectGitConfig,
+	".gitmodules":        format.DialectGitConfig,
+	"setup.cfg":          format.DialectPyCfg,
+	"my.cnf":             format.DialectMyCnf,
+	".my.cnf":            format.DialectMyCnf,
+	"smb.conf":           format.DialectSmb,
+	"supervisord.conf":   format.DialectSupervisord,
+	"hgrc":               format.DialectHgrc,
+	".hgrc":              format.DialectHgrc,
+	"kdeglobals":         format.DialectKDE,
+	"plasmarc":           format.DialectKDE,
+	"plasmashellrc":      format.DialectKDE,
+	"kwinrc":             format.DialectKDE,
+	"kcminputrc":         format.DialectKDE,
+	"kglobalshortcutsrc": format.DialectKDE,
+	"kscreenlockerrc":    format.DialectKDE,
+	"dolphinrc":          format.DialectKDE,
+	"konsolerc":          format.DialectKDE,
+}
+
+// dialectExtensions maps file extensions to the dialect --dialect=auto picks
+// for them when the file name itself is not known.
+var dialectExtensions = map[string]format.Dialect{
+	".reg":        format.DialectReg,
+	".desktop":    format.DialectDesktop,
+	".service":    format.DialectSystemd,
+	".socket":     format.DialectSystemd,
+	".timer":      format.DialectSystemd,
+	".mount":      format.DialectSystemd,
+	".path":       format.DialectSystemd,
+	".target":     format.DialectSystemd,
+	".slice":      format.DialectSystemd,
+	".env":        format.DialectEnv,
+	".properties": format.DialectProperties,
+	".cnf":        format.DialectMyCnf,
+}
+
+// systemdSections are unit file sections that, next to [Unit], mark a file
+// as a systemd unit.
+var systemdSections = []string{"Service", "Socket", "Timer", "Mount", "Path", "Install"}
+
+// Reasons detectDialect gives for its choice.
+const (
+	dialectByFlag    = "--dialect"
+	dialectByName    = "file name"
+	dialectByContent = "content"
+	dialectByDefault = "default"
+)
+
+// detectDialect resolves a --dialect value for filename. Any value but "auto"
+// names a registered dialect. "auto" picks the dialect by the file name, then
+// its extension, looking through a .gz suffix, and failing that by the lines
+// of the file when they are given; anything else is plain INI. It also
+// returns what the choice was based on.
+func detectDialect(name, filename string, lines []string) (format.Dialect, string, error) {
+	if name != "" && name != "auto" {
+		if d, ok := format.LookupDialect(name); ok {
+			return d, dialectByFlag, nil
+		}
+		return nil, "", fmt.Errorf("invalid --dialect %q (want auto, %s)", name, strings.Join(format.DialectNames(), ", "))
+	}
+	path, _, _ := strings.Cut(filename, "?") // URL query
+	path = strings.TrimSuffix(strings.ToLower(filepath.ToSlash(path)), ".gz")
+	if d, ok := dialectFiles[filepath.Base(path)]; ok {
+		return d, dialectByName, nil
+	}
+	if strings.HasSuffix(path, "/.git/config") || path == ".git/config" {
+		return format.DialectGitConfig, dialectByName, nil
+	}
+	if d, ok := dialectExtensions[filepath.Ext(path)]; ok {
+		return d, dialectByName, nil
+	}
+	if d, ok := sniffDialect(lines); ok {
+		return d, dialectByContent, nil
+	}
+	return format.DialectINI, dialectByDefault, nil
+}
+
+// sniffDialect guesses the dialect of a file from its lines: a registry
+// version line, a [Unit] section next to another systemd section, a
+// [Desktop Entry] section, a nested [Group][SubGroup] header, a [global]
+// section setting Samba's workgroup, a [supervisord] or [program:name]
+// section, or keys mostly delimited by ':' rather than '='.
+func sniffDialect(lines []string) (format.Dialect, bool) {
+	for _, line := range lines {
+		if format.IsBlank(line) {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "Windows Registry Editor Version") || strings.HasPrefix(trimmed, "REGEDIT4") {
+			return format.DialectReg, true
+		}
+		break
+	}
+	sections := format.SectionNames(lines)
+	if slices.Contains(sections, "Unit") && slices.ContainsFunc(sections, func(s string) bool {
+		return slices.Contains(systemdSections, s)
+	}) {
+		return format.DialectSystemd, true
+	}
+	if slices.Contains(sections, "Desktop Entry") {
+		return format.DialectDesktop, true
+	}
+	if slices.ContainsFunc(sections, func(s string) bool { return strings.Contains(s, "][") }) {
+		return format.DialectKDE, true
+	}
+	if slices.Contains(sections, "global") && slices.ContainsFunc(format.ParseKeyValues(lines, format.Options{}), func(kv format.KeyValue) bool {
+		return kv.Section == "global" && strings.EqualFold(kv.Key, "workgroup")
+	}) {
+		return format.DialectSmb, true
+	}
+	if slices.ContainsFunc(sections, func(s string) bool { return s == "supervisord" || strings.HasPrefix(s, "program:") }) {
+		return format.DialectSupervisord, true
+	}
+	if c := analyzeLines(lines); c.colons > c.equals {
+		return format.DialectPyCfg, true
+	}
+	return nil, false
+}
+
+// applyDialect sets the dialect of cfg for filename along with the defaults it
+// implies for flags that were not given: the dialect's comment prefixes, for
+// .reg files, which are usually CRLF, keeping the input's line endings, and
+// for supervisord.conf, whose sections are long, aligning each section on its
+// own unless --single-space aligns nothing.
+func applyDialect(flags *pflag.FlagSet, cfg *config, filename string) error {
+	dialect, reason, err := detectDialect(cfg.dialect, filename, nil)
+	if err != nil {
+		return err
+	}
+	if reason == dialectByDefault && filename != "" && !isURL(filename) && embeddedFormat(*cfg, filename) == "" {
+		// Unreadable files are reported when they are formatted.
+		if in, err := readInput(filename, cfg.source); err == nil {
+			dialect, reason, _ = detectDialect(cfg.dialect, filename, in.lines)
+		}
+	}
+	cfg.logger().Info(fmt.Sprintf("%s: dialect %s (%s)", cfg.displayName(filename), dialect.Name(), reason),
+		"file", cfg.displayName(filename), "dialect", dialect.Name(), "reason", reason)
+	cfg.format.Dialect = dialect
+	source := "dialect " + dialect.Name()
+	if prefixes := dialect.CommentPrefixes(); !flags.Changed("comment-prefixes") && !slices.Equal(prefixes, format.DefaultCommentPrefixes) {
+		if err := setFlag(flags, "comment-prefixes", strings.Join(prefixes, ","), source); err != nil {
+			return err
+		}
+	}
+	if dialect == format.DialectReg && !flags.Changed("line-ending") {
+		if err := setFlag(flags, "line-ending", "auto", source); err != nil {
+			return err
+		}
+	}
+	if dialect == format.DialectSupervisord && !flags.Changed("per-section") && !cfg.format.SingleSpace {
+		if err := setFlag(flags, "per-section", "true", source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dialectOptions returns opts set up for the dialect of filename with the
+// given lines, as picked by --dialect=auto, for subcommands that have no
+// --dialect flag.
+func dialectOptions(opts format.Options, filename string, lines []string) format.Options {
+	opts.Dialect, _, _ = detectDialect("auto", filename, lines)
+	if slices.Equal(opts.CommentPrefixes, format.DefaultCommentPrefixes) {
+		opts.CommentPrefixes = nil
+	}
+	return opts
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestDetectDialect(t *testing.T) {
+	tests := []struct {
+		name, filename string
+		content        string
+		want           format.Dialect
+		reason         string
+	}{
+		{"auto", "export.reg", "", format.DialectReg, dialectByName},
+		{"auto", "EXPORT.REG", "", format.DialectReg, dialectByName},
+		{"auto", "export.reg.gz", "", format.DialectReg, dialectByName},
+		{"auto", "config.ini", "", format.DialectINI, dialectByDefault},
+		{"auto", "/home/me/.gitconfig", "", format.DialectGitConfig, dialectByName},
+		{"auto", "repo/.git/config", "", format.DialectGitConfig, dialectByName},
+		{"auto", ".gitmodules", "", format.DialectGitConfig, dialectByName},
+		{"auto", "app.desktop", "", format.DialectDesktop, dialectByName},
+		{"auto", "nginx.service", "", format.DialectSystemd, dialectByName},
+		{"auto", "backup.timer", "", format.DialectSystemd, dialectByName},
+		{"auto", "setup.cfg", "", format.DialectPyCfg, dialectByName},
+		{"auto", ".env", "", format.DialectEnv, dialectByName},
+		{"auto", "prod.env", "", format.DialectEnv, dialectByName},
+		{"auto", "messages.properties", "", format.DialectProperties, dialectByName},
+		{"auto", "/etc/mysql/my.cnf", "", format.DialectMyCnf, dialectByName},
+		{"auto", "https://example.com/app.desktop?raw=1", "", format.DialectDesktop, dialectByName},
+		{"auto", "export.txt", "Windows Registry Editor Version 5.00\n\n[HKEY_CURRENT_USER]\n", format.DialectReg, dialectByContent},
+		{"auto", "unit", "[Unit]\nDescription=x\n\n[Service]\nExecStart=/bin/true\n", format.DialectSystemd, dialectByContent},
+		{"auto", "-", "[Desktop Entry]\nName=App\n", format.DialectDesktop, dialectByContent},
+		{"auto", "/etc/samba/smb.conf", "", format.DialectSmb, dialectByName},
+		{"auto", "office.conf", "[global]\nworkgroup = EXAMPLE\n", format.DialectSmb, dialectByContent},
+		{"auto", "/home/jane/.hgrc", "", format.DialectHgrc, dialectByName},
+		{"auto", "repo/.hg/hgrc", "", format.DialectHgrc, dialectByName},
+		{"hgrc", "setup.cfg", "", format.DialectHgrc, dialectByFlag},
+		{"auto", "/etc/supervisor/supervisord.conf", "", format.DialectSupervisord, dialectByName},
+		{"auto", "conf.d/web.conf", "[program:web]\ncommand=/usr/bin/web\n", format.DialectSupervisord, dialectByContent},
+		{"auto", "sup.ini", "[unix_http_server]\nfile=/tmp/s.sock\n\n[supervisord]\nlogfile=/tmp/s.log\n", format.DialectSupervisord, dialectByContent},
+		{"auto", "/home/me/.config/kdeglobals", "", format.DialectKDE, dialectByName},
+		{"auto", "appletsrc", "[Containments][1]\nplugin=org.kde.panel\n", format.DialectKDE, dialectByContent},
+		{"auto", "tox.cfg", "[tox]\nenvlist: py3\nskip: true\nx = 1\n", format.DialectPyCfg, dialectByContent},
+		{"auto", "app.conf", "[Unit]\nname = x\n", format.DialectINI, dialectByDefault},
+		{"auto", "app.conf", "; a: comment\n[s]\na = 1\n", format.DialectINI, dialectByDefault},
+		{"", "", "", format.DialectINI, dialectByDefault},
+		{"reg", "", "", format.DialectReg, dialectByFlag},
+		{"ini", "export.reg", "", format.DialectINI, dialectByFlag},
+		{"systemd", "", "", format.DialectSystemd, dialectByFlag},
+	}
+	for _, tt := range tests {
+		lines, _ := format.SplitLines(tt.content)
+		got, reason, err := detectDialect(tt.name, tt.filename, lines)
+		if err != nil || got != tt.want || reason != tt.reason {
+			t.Errorf("detectDialect(%q, %q, %q) = %v, %q, %v; want %v, %q", tt.name, tt.filename, tt.content, got, reason, err, tt.want, tt.reason)
+		}
+	}
+	if _, _, err := detectDialect("toml", "", nil); err == nil {
+		t.Error("detectDialect(toml) expected error")
+	}
+}
+
+func TestDialectFromContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "launcher")
+	if err := os.WriteFile(path, []byte("[Desktop Entry]\nName=App\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--show-config", "--no-config", path})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	text := regexp.MustCompile(` +=`).ReplaceAllString(out.String(), " =")
+	if want := "\ncomment-prefixes = \"#\" ; dialect desktop\n"; !strings.Contains(text, want) {
+		t.Errorf("--show-config = %q, want it to contain %q", text, want)
+	}
+}
+
+func TestRegFileKeepsEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.reg")
+	content := "Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER\\Software\\Ex #1]\r\n\"a\"=\"1\"\r\n\"long\"=hex:00,\\\r\n  01\r\n# kept\r\n"
+	want := "Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER\\Software\\Ex #1]\r\n\"a\"    = \"1\"\r\n\"long\" = hex:00,\\\r\n  01\r\n# kept\r\n"
+	enc := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	data, err := enc.Bytes([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(want))
+	if !bytes.Equal(got, wantData) {
+		t.Errorf("output = %q, want %q", got, wantData)
+	}
+}
+
+func TestSupervisordPerSection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "supervisord.conf")
+	in := "[supervisord]\nlogfile=/var/log/supervisord.log\n\n[program:web]\ncommand=%(ENV_HOME)s/bin/web   --port 80\nautostart=true\n"
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "[supervisord]\nlogfile = /var/log/supervisord.log\n\n[program:web]\ncommand   = %(ENV_HOME)s/bin/web --port 80\nautostart = true\n"},
+		{[]string{"--per-section=false"}, "[supervisord]\nlogfile   = /var/log/supervisord.log\n\n[program:web]\ncommand   = %(ENV_HOME)s/bin/web --port 80\nautostart = true\n"},
+		{[]string{"--single-space"}, "[supervisord]\nlogfile = /var/log/supervisord.log\n\n[program:web]\ncommand = %(ENV_HOME)s/bin/web --port 80\nautostart = true\n"},
+	} {
+		if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"--no-config", "--quiet", "--force-lossy", "-w", path}, tt.args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := mustRead(t, path); got != tt.want {
+			t.Errorf("%v: file = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
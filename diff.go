@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// diffContext is how many unchanged lines a hunk shows around its changes.
+const diffContext = 3
+
+// diffOp is one line of an edit script: kept (' '), deleted from the old
+// text ('-') or inserted from the new one ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffLines returns the shortest edit script turning a into b, found with
+// Myers' algorithm. Only the part of each round's furthest reaching paths the
+// next round reads is kept for tracing the script back, so memory grows with
+// the square of the number of changes rather than with the file.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	off := n + m + 1
+	v := make([]int, 2*off+1) // furthest x reached on diagonal k, at v[off+k]
+	var trace [][]int         // v for k in [-d-1, d+1] at the start of round d
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v[off-d-1:off+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prev := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prev = k + 1
+		}
+		prevX := at(prev)
+		prevY := prevX - prev
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// diffSplit splits text into lines that keep their line endings.
+func diffSplit(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange renders the start and length of a hunk's lines in one file as
+// diff -u does: the length is left out when it is 1, and an empty range
+// starts at the line before it.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// writeDiff writes a unified diff turning before into after, the texts of
+// the file name, with diffContext lines of context. Line endings count: a
+// line that only changes from CRLF to LF is changed. Nothing is written when
+// the texts are the same.
+func writeDiff(w io.Writer, name, before, after string) error {
+	ops := diffLines(diffSplit(before), diffSplit(after))
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", name, name)
+	// aLine and bLine count the lines of each file before ops[i].
+	aLine, bLine, i := 0, 0, 0
+	for c := 0; c < len(changes); {
+		start := max(changes[c]-diffContext, i)
+		// Join the changes whose context would touch or overlap, as diff -u
+		// does: up to twice diffContext unchanged lines between them.
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext+1 {
+			last++
+		}
+		end := min(changes[last]+diffContext+1, len(ops))
+		for ; i < start; i++ {
+			aLine, bLine = aLine+countIf(ops[i].kind != '+'), bLine+countIf(ops[i].kind != '-')
+		}
+		var aLen, bLen int
+		for _, op := range ops[start:end] {
+			aLen, bLen = aLen+countIf(op.kind != '+'), bLen+countIf(op.kind != '-')
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(aLine, aLen), hunkRange(bLine, bLen))
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		aLine, bLine, i = aLine+aLen, bLine+bLen, end
+		c = last + 1
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// countIf returns 1 when ok holds and 0 otherwise.
+func countIf(ok bool) int {
+	if ok {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	long := "a = 1\nb = 2\nc = 3\nd = 4\ne = 5\nf = 6\ng = 7\nh = 8\ni = 9\n"
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{name: "no changes", before: "a = 1\n", after: "a = 1\n"},
+		{name: "empty", before: "", after: ""},
+		{
+			name:   "change",
+			before: "[s]\na=1\n",
+			after:  "[s]\na = 1\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -1,2 +1,2 @@\n [s]\n-a=1\n+a = 1\n",
+		},
+		{
+			name:   "insertion",
+			before: "a = 1\nb = 2\n",
+			after:  "a = 1\n\nb = 2\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -1,2 +1,3 @@\n a = 1\n+\n b = 2\n",
+		},
+		{
+			name:   "deletion",
+			before: "a = 1\n\n\nb = 2\n",
+			after:  "a = 1\n\nb = 2\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -1,4 +1,3 @@\n a = 1\n \n-\n b = 2\n",
+		},
+		{
+			name:   "insertion into empty file",
+			before: "",
+			after:  "a = 1\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -0,0 +1 @@\n+a = 1\n",
+		},
+		{
+			name:   "no newline at end of file",
+			before: "a = 1\nb=2",
+			after:  "a = 1\nb = 2\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -1,2 +1,2 @@\n a = 1\n-b=2\n\\ No newline at end of file\n+b = 2\n",
+		},
+		{
+			name:   "line endings",
+			before: "a = 1\r\n",
+			after:  "a = 1\n",
+			want:   "--- f.ini\n+++ f.ini\n@@ -1 +1 @@\n-a = 1\r\n+a = 1\n",
+		},
+		{
+			name:   "separate hunks",
+			before: strings.Replace(strings.Replace(long, "a = 1", "a=1", 1), "i = 9", "i=9", 1),
+			after:  long,
+			want: "--- f.ini\n+++ f.ini\n@@ -1,4 +1,4 @@\n-a=1\n+a = 1\n b = 2\n c = 3\n d = 4\n" +
+				"@@ -6,4 +6,4 @@\n f = 6\n g = 7\n h = 8\n-i=9\n+i = 9\n",
+		},
+		{
+			name:   "hunks six lines apart",
+			before: strings.Replace(strings.Replace(long+"j = 10\n", "a = 1", "a=1", 1), "h = 8", "h=8", 1),
+			after:  long + "j = 10\n",
+			want: "--- f.ini\n+++ f.ini\n@@ -1,10 +1,10 @@\n-a=1\n+a = 1\n b = 2\n c = 3\n d = 4\n e = 5\n f = 6\n g = 7\n" +
+				"-h=8\n+h = 8\n i = 9\n j = 10\n",
+		},
+		{
+			name:   "merged hunks",
+			before: strings.Replace(strings.Replace(long, "b = 2", "b=2", 1), "h = 8", "h=8", 1),
+			after:  long,
+			want: "--- f.ini\n+++ f.ini\n@@ -1,9 +1,9 @@\n a = 1\n-b=2\n+b = 2\n c = 3\n d = 4\n e = 5\n f = 6\n g = 7\n" +
+				"-h=8\n+h = 8\n i = 9\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeDiff(&b, "f.ini", tt.before, tt.after); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("writeDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	ops := diffLines(a, b)
+	var edits int
+	var gotA, gotB []string
+	for _, op := range ops {
+		if op.kind != '+' {
+			gotA = append(gotA, op.line)
+		}
+		if op.kind != '-' {
+			gotB = append(gotB, op.line)
+		}
+		if op.kind != ' ' {
+			edits++
+		}
+	}
+	if strings.Join(gotA, " ") != strings.Join(a, " ") || strings.Join(gotB, " ") != strings.Join(b, " ") {
+		t.Errorf("diffLines() = %q does not turn %q into %q", ops, a, b)
+	}
+	// The shortest edit script of Myers' example has five edits.
+	if edits != 5 {
+		t.Errorf("diffLines() has %d edits, want 5", edits)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GITHUB_ACTIONS", "")
+	files := map[string]string{"ok.ini": "[s]\na = 1\n", "bad.ini": "[s]\na=1\n"}
+	writeFiles(t, dir, files)
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		stdout string
+		code   int
+	}{
+		{name: "formatted", args: []string{"ok.ini"}},
+		{name: "not formatted", args: []string{"bad.ini"}, stdout: "--- bad.ini\n+++ bad.ini\n@@ -1,2 +1,2 @@\n [s]\n-a=1\n+a = 1\n", code: 1},
+		{name: "stdin", stdin: "a=1\n", stdout: "--- <stdin>\n+++ <stdin>\n@@ -1 +1 @@\n-a=1\n+a = 1\n", code: 1},
+		{name: "stdin filename", args: []string{"--stdin-filename", "app.ini"}, stdin: "a=1\n", stdout: "--- app.ini\n+++ app.ini\n@@ -1 +1 @@\n-a=1\n+a = 1\n", code: 1},
+		{name: "empty stdin", stdin: ""},
+		{name: "line endings ignored", args: []string{"--check-ignore-eol"}, stdin: "a = 1\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runDiff(t, dir, tt.stdin, tt.args...)
+			if code != tt.code {
+				t.Errorf("exit %d, want %d", code, tt.code)
+			}
+			if stdout != tt.stdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.stdout)
+			}
+			if stderr != "" {
+				t.Errorf("stderr = %q, want nothing", stderr)
+			}
+		})
+	}
+	for name, want := range files {
+		if got := mustRead(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("--diff changed %s to %q", name, got)
+		}
+	}
+	if _, stderr, code := runDiff(t, dir, "", "--write", "bad.ini"); code != 2 || !strings.Contains(stderr, "cannot be combined") {
+		t.Errorf("--diff --write = exit %d, %q; want a usage error", code, stderr)
+	}
+}
+
+// runDiff runs inifmt --diff with args on stdin, in dir, as runCheck runs
+// --check.
+func runDiff(t *testing.T, dir, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	return runInifmt(t, dir, stdin, append([]string{"--diff"}, args...)...)
+}
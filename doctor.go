@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// conventions are the layout habits of a file: what dialect detection reads
+// from its lines, and what doctor reports and turns into flags.
+type conventions struct {
+	// Read from the raw lines, before the dialect is known.
+	sections       int
+	colons, equals int // unindented key lines whose first delimiter is ':' or '='
+	hashes         int // comment lines starting with '#'
+	semicolons     int // comment lines starting with ';'
+	slashes        int // comment lines starting with "//"
+
+	// Read from the tokens of the lines in their dialect, by analyzeInput.
+	keys          int
+	longestKey    string
+	tight         []int  // lines of keys written key=value
+	single        []int  // lines of keys written key = value
+	padded        []int  // lines of keys padded before the delimiter
+	alignment     string // "file", "section" or "" when keys are not aligned
+	slack         int    // the least padding of any key before its delimiter
+	indented      []int  // lines of indented keys
+	continuations []int  // lines continuing the value of the line above
+	crlf, lf      int    // lines ending in CRLF and in LF
+}
+
+// analyzeLines counts what the raw lines show of a file's conventions.
+func analyzeLines(lines []string) conventions {
+	var c conventions
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case format.IsHeader(trimmed):
+			c.sections++
+		case strings.HasPrefix(trimmed, "//"):
+			c.slashes++
+		case trimmed[0] == '#':
+			c.hashes++
+		case trimmed[0] == ';':
+			c.semicolons++
+		case line[0] == ' ' || line[0] == '\t':
+		default:
+			switch i := strings.IndexAny(trimmed, ":="); {
+			case i == -1:
+			case trimmed[i] == ':':
+				c.colons++
+			default:
+				c.equals++
+			}
+		}
+	}
+	return c
+}
+
+// analyzeInput adds to analyzeLines what the lines of in show when tokenized
+// in the dialect of opts: spacing, alignment, indentation, continuations and
+// line endings.
+func analyzeInput(in *input, opts format.Options) conventions {
+	c := analyzeLines(in.lines)
+	for _, eol := range in.endings {
+		switch eol {
+		case "\r\n":
+			c.crlf++
+		case "\n":
+			c.lf++
+		}
+	}
+	// The columns of the delimiters of each section; -1 once they differ.
+	var columns []int
+	section := -1
+	longest := 0
+	c.slack = -1
+	for i, tokens := range format.TokenizeLines(in.lines, opts) {
+		n := i + 1
+		var indent, before, after string
+		key, delimited, column := "", false, 0
+		for j, t := range tokens {
+			switch t.Kind {
+			case format.TokenSectionName:
+				columns = append(columns, 0)
+				section = len(columns) - 1
+			case format.TokenWhitespace:
+				switch {
+				case j == 0:
+					indent = t.Text
+				case tokens[j-1].Kind == format.TokenKey:
+					before = t.Text
+				case tokens[j-1].Kind == format.TokenDelimiter:
+					after = t.Text
+				}
+			case format.TokenKey:
+				key = t.Text
+			case format.TokenDelimiter:
+				delimited, column = true, width(in.lines[i][:t.Offset])
+			case format.TokenValue:
+				if !delimited {
+					c.continuations = append(c.continuations, n)
+				}
+			}
+		}
+		if key == "" {
+			continue
+		}
+		c.keys++
+		if w := width(key); w > longest {
+			longest, c.longestKey = w, key
+		}
+		if indent != "" {
+			c.indented = append(c.indented, n)
+		}
+		if !delimited {
+			continue
+		}
+		if c.slack == -1 || len(before) < c.slack {
+			c.slack = len(before)
+		}
+		switch {
+		case before == "" && after == "":
+			c.tight = append(c.tight, n)
+		case len(before) > 1:
+			c.padded = append(c.padded, n)
+		default:
+			c.single = append(c.single, n)
+		}
+		if section == -1 {
+			columns = append(columns, 0)
+			section = 0
+		}
+		switch columns[section] {
+		case 0:
+			columns[section] = column
+		case column:
+		default:
+			columns[section] = -1
+		}
+	}
+	c.alignment = alignmentOf(columns, len(c.padded) > 0)
+	return c
+}
+
+// width measures s in columns, with tabs at their default stops.
+func width(s string) int {
+	return format.ExpandedWidth(s, format.DefaultTabWidth)
+}
+
+// alignmentOf tells from the delimiter columns of each section, -1 where a
+// section's columns differ, how a file is aligned: "file" when every section
+// shares a column, "section" when each has its own, and "" otherwise or when
+// no key was padded to reach its column.
+func alignmentOf(columns []int, padded bool) string {
+	if !padded {
+		return ""
+	}
+	shared := 0
+	for _, col := range columns {
+		switch {
+		case col == -1:
+			return ""
+		case col == 0:
+		case shared == 0:
+			shared = col
+		case shared != col:
+			shared = -1
+		}
+	}
+	if shared == -1 {
+		return "section"
+	}
+	return "file"
+}
+
+// doctorSetting is a setting doctor recommends: a flag and its value, a
+// bool, a string or a list.
+type doctorSetting struct {
+	flag  string
+	value any
+}
+
+// arg renders s as a command line argument.
+func (s doctorSetting) arg() string {
+	switch v := s.value.(type) {
+	case bool:
+		return "--" + s.flag
+	case []string:
+		return "--" + s.flag + "='" + strings.Join(v, ",") + "'"
+	}
+	return fmt.Sprintf("--%s=%v", s.flag, s.value)
+}
+
+// toml renders s as a line of the project config.
+func (s doctorSetting) toml() string {
+	switch v := s.value.(type) {
+	case string:
+		return s.flag + " = " + strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = strconv.Quote(item)
+		}
+		return s.flag + " = [" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprintf("%s = %v", s.flag, s.value)
+}
+
+// doctorReport is what doctor found in a file: the settings that keep its
+// conventions, and the warnings about what formatting would still change.
+type doctorReport struct {
+	settings []doctorSetting
+	warnings []diagnostic
+}
+
+// diagnose turns the conventions of a file, and the lines of in that
+// formatting would alter, into recommendations.
+func diagnose(c conventions, in *input, opts format.Options) doctorReport {
+	var r doctorReport
+	warn := func(line int, format string, args ...any) {
+		r.warnings = append(r.warnings, diagnostic{line: line, severity: severityWarning, message: fmt.Sprintf(format, args...)})
+	}
+	switch {
+	case c.alignment == "section":
+		r.settings = append(r.settings, doctorSetting{"per-section", true})
+	case c.alignment == "file":
+	case len(c.single) > len(c.padded) && len(c.single) >= len(c.tight):
+		r.settings = append(r.settings, doctorSetting{"single-space", true})
+	case len(c.tight) > len(c.padded):
+		r.settings = append(r.settings, doctorSetting{"single-space", true})
+		warn(c.tight[0], "%s written without spaces around the delimiter; inifmt always puts one on each side", count(len(c.tight), "key", "keys"))
+	}
+	if c.alignment != "" && c.slack > 1 {
+		warn(c.padded[0], "keys are padded %s more than needed; formatting leaves one space after the longest key", count(c.slack-1, "space", "spaces"))
+	}
+	switch {
+	case c.crlf > 0 && c.lf > 0:
+		fix := "lf"
+		if c.crlf > c.lf {
+			fix = "crlf"
+		}
+		r.settings = append(r.settings, doctorSetting{"line-ending", fix})
+		warn(1, "mixed line endings: %d LF and %d CRLF lines, all written as %s", c.lf, c.crlf, strings.ToUpper(fix))
+	case c.crlf > 0:
+		r.settings = append(r.settings, doctorSetting{"line-ending", "crlf"})
+	}
+	if c.slashes > 0 {
+		r.settings = append(r.settings, doctorSetting{"comment-prefixes", []string{"//", ";", "#"}})
+	}
+	if lossy := format.LossyLines(in.lines, opts); len(lossy) > 0 {
+		r.settings = append(r.settings, doctorSetting{"no-lossy", true})
+		warn(lossy[0], "%s with runs of whitespace that formatting would collapse; --no-lossy leaves those lines as they are", count(len(lossy), "value", "values"))
+	}
+	if len(c.indented) > 0 {
+		warn(c.indented[0], "%s; formatting moves keys to the start of the line", count(len(c.indented), "indented key", "indented keys"))
+	}
+	if len(c.continuations) > 0 {
+		warn(c.continuations[0], "%s of multi-line values; they are kept as written and only the first line of each value is aligned", count(len(c.continuations), "continuation line", "continuation lines"))
+	}
+	for _, kv := range format.UnbalancedQuotes(in.lines, opts) {
+		warn(kv.Line, "unbalanced quote in %s; the value is left as it is", kv.Path())
+	}
+	slices.SortStableFunc(r.warnings, func(a, b diagnostic) int { return a.line - b.line })
+	return r
+}
+
+// count formats n things, as in "1 key" or "3 keys".
+func count(n int, one, many string) string {
+	return strconv.Itoa(n) + " " + plural(n, one, many)
+}
+
+// newDoctorCmd builds the doctor subcommand, which works out the flags that
+// keep a file's conventions.
+func newDoctorCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor file",
+		Short: "Analyze a file and suggest the options that keep its conventions",
+		Long: `doctor reads a file and reports its conventions: its dialect, the delimiter
+its keys use, how they are spaced and aligned, indentation, comment markers,
+line endings, continued values, the number of sections and the longest key.
+
+It then prints the command line, and the same settings as ` + projectConfigName + `
+lines, that formats the file in the style it already has, and warns about
+what formatting would still change, such as indented keys or values whose
+whitespace would collapse.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeOneFile,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return err
+			}
+			opts := dialectOptions(cfg.format, args[0], in.lines)
+			_, reason, _ := detectDialect("auto", args[0], in.lines)
+			c := analyzeInput(in, opts)
+			return writeDoctor(cmd.OutOrStdout(), args[0], opts.Dialect.Name()+" ("+reason+")", c, diagnose(c, in, opts))
+		},
+	}
+}
+
+// writeDoctor prints what doctor found in file.
+func writeDoctor(w io.Writer, file, dialect string, c conventions, r doctorReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s, %s", file, count(c.sections, "section", "sections"), count(c.keys, "key", "keys"))
+	if c.longestKey != "" {
+		fmt.Fprintf(&b, ", longest key %q", c.longestKey)
+	}
+	b.WriteString("\n\n")
+	row := func(name, value string) { fmt.Fprintf(&b, "  %-15s %s\n", name+":", value) }
+	row("dialect", dialect)
+	row("delimiter", fmt.Sprintf("'=' on %s, ':' on %s", count(c.equals, "line", "lines"), count(c.colons, "line", "lines")))
+	switch {
+	case c.alignment == "file":
+		row("spacing", "aligned across the file")
+	case c.alignment == "section":
+		row("spacing", "aligned within each section")
+	default:
+		row("spacing", fmt.Sprintf("%d key=value, %d key = value, %d padded", len(c.tight), len(c.single), len(c.padded)))
+	}
+	row("indentation", count(len(c.indented), "indented key", "indented keys"))
+	row("comments", fmt.Sprintf("';' %d, '#' %d, '//' %d", c.semicolons, c.hashes, c.slashes))
+	row("line endings", fmt.Sprintf("LF %d, CRLF %d", c.lf, c.crlf))
+	row("continuations", count(len(c.continuations), "line", "lines"))
+
+	args := []string{"inifmt"}
+	for _, s := range r.settings {
+		args = append(args, s.arg())
+	}
+	fmt.Fprintf(&b, "\nRecommended:\n  %s %s\n", strings.Join(args, " "), file)
+	if len(r.settings) > 0 {
+		fmt.Fprintf(&b, "\nProject config (%s):\n", projectConfigName)
+		for _, s := range r.settings {
+			fmt.Fprintf(&b, "  %s\n", s.toml())
+		}
+	}
+	if len(r.warnings) > 0 {
+		b.WriteString("\nWarnings:\n")
+		for _, d := range r.warnings {
+			fmt.Fprintf(&b, "  line %d: %s\n", d.line, d.message)
+		}
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	tests := []struct {
+		file     string
+		args     []string
+		warnings []int // lines
+	}{
+		{"aligned.ini", nil, nil},
+		{"per-section.ini", []string{"--per-section", "--line-ending=crlf"}, nil},
+		{"single.ini", []string{"--single-space"}, nil},
+		{"wide.ini", nil, []int{2}},
+		{"setup.cfg", []string{"--single-space"}, []int{3}},
+		{"quirks.ini", []string{"--single-space", "--no-lossy"}, []int{2, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", "doctor", tt.file)
+			in, err := readInput(path, sourceOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := dialectOptions(config{}.format, path, in.lines)
+			r := diagnose(analyzeInput(in, opts), in, opts)
+			var args []string
+			for _, s := range r.settings {
+				args = append(args, s.arg())
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("recommended %q, want %q", args, tt.args)
+			}
+			var lines []int
+			for _, d := range r.warnings {
+				lines = append(lines, d.line)
+			}
+			if !slices.Equal(lines, tt.warnings) {
+				t.Errorf("warnings at lines %v, want %v", lines, tt.warnings)
+			}
+			if len(tt.warnings) > 0 {
+				return
+			}
+
+			// Formatting with the recommendation keeps the file as it is.
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			copied := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(copied, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := newRootCmd()
+			cmd.SetArgs(append(append([]string{"--no-config", "--write"}, tt.args...), copied))
+			cmd.SetErr(io.Discard)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if got := mustRead(t, copied); got != string(data) {
+				t.Errorf("formatted with %q = %q, want it unchanged", tt.args, got)
+			}
+		})
+	}
+}
+
+func TestDoctorOutput(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"doctor", filepath.Join("testdata", "doctor", "per-section.ini")})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2 sections, 4 keys, longest key \"max_idle\"",
+		"spacing:        aligned within each section",
+		"line endings:   LF 0, CRLF 7",
+		"inifmt --per-section --line-ending=crlf ",
+		"  line-ending = \"crlf\"\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("doctor output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSettingRendering(t *testing.T) {
+	s := doctorSetting{"comment-prefixes", []string{"//", ";", "#"}}
+	if got, want := s.arg(), "--comment-prefixes='//,;,#'"; got != want {
+		t.Errorf("arg() = %q, want %q", got, want)
+	}
+	if got, want := s.toml(), `comment-prefixes = ["//", ";", "#"]`; got != want {
+		t.Errorf("toml() = %q, want %q", got, want)
+	}
+}
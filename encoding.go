@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodings maps the names accepted by --encoding to their transformers.
+// UTF-8 is a pass-through so invalid bytes are never silently replaced.
+var encodings = map[string]encoding.Encoding{
+	"utf-8":        encoding.Nop,
+	"utf8":         encoding.Nop,
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"utf-16":       unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+}
+
+// lookupEncoding returns the encoding registered under name; an empty name means UTF-8.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return encoding.Nop, nil
+	}
+	enc, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %q (want latin1, windows-1252, utf-8 or utf-16)", name)
+	}
+	return enc, nil
+}
+
+// Byte order marks recognised at the start of input.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// detectBOM peeks at the start of r and returns the encoding announced by a
+// byte order mark, or nil when there is none. The returned encodings consume the
+// BOM when decoding and write it back when encoding, so output keeps it.
+func detectBOM(r *bufio.Reader) encoding.Encoding {
+	head, _ := r.Peek(3)
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return unicode.UTF8BOM
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+	return nil
+}
+
+// encodeLines terminates each line with eol and encodes the result with enc.
+// A character that cannot be represented in enc is a hard error naming the line.
+func encodeLines(lines []string, enc encoding.Encoding, eol string) ([]byte, error) {
+	var text strings.Builder
+	for _, line := range lines {
+		text.WriteString(line)
+		text.WriteString(eol)
+	}
+	if enc == encoding.Nop {
+		return []byte(text.String()), nil
+	}
+
+	// Encode line by line first so an unrepresentable character can be reported precisely.
+	for i, line := range lines {
+		if _, err := enc.NewEncoder().String(line); err != nil {
+			return nil, fmt.Errorf("line %d cannot be encoded: %w", i+1, err)
+		}
+	}
+	data, err := enc.NewEncoder().Bytes([]byte(text.String()))
+	if err != nil {
+		return nil, fmt.Errorf("encoding output: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestLookupEncoding(t *testing.T) {
+	for _, name := range []string{"utf-8", "UTF-8", "latin1", "windows-1252", "utf-16"} {
+		if _, err := lookupEncoding(name); err != nil {
+			t.Errorf("lookupEncoding(%q) unexpected error: %v", name, err)
+		}
+	}
+	if _, err := lookupEncoding("ebcdic"); err == nil {
+		t.Error("lookupEncoding(\"ebcdic\") expected error")
+	}
+}
+
+func TestEncodeLinesUnrepresentable(t *testing.T) {
+	_, err := encodeLines([]string{"key = value", "omega = Ω"}, charmap.ISO8859_1, "\n")
+	if err == nil {
+		t.Fatal("encodeLines() expected error for character outside latin1")
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("encodeLines() error should name the line: %v", err)
+	}
+}
+
+func TestRunLatin1RoundTrip(t *testing.T) {
+	input, err := charmap.ISO8859_1.NewEncoder().String("[général]\nclé=valeur\nlongue_clé=été\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "latin1.ini")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(context.Background(), config{write: true, source: sourceOptions{encoding: "latin1"}}, []string{path}); err != nil {
+		t.Fatalf("run() unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := charmap.ISO8859_1.NewEncoder().String("[général]\nclé        = valeur\nlongue_clé = été\n")
+	if string(got) != want {
+		t.Fatalf("latin1 output mismatch:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRunUTF16LERoundTrip(t *testing.T) {
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	input, err := utf16le.NewEncoder().String("[main]\nkey=value\nlonger_key=v\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(input, "\xff\xfe") {
+		t.Fatalf("fixture missing BOM: %q", input[:2])
+	}
+	path := filepath.Join(t.TempDir(), "utf16.ini")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(context.Background(), config{write: true, source: sourceOptions{encoding: "utf-8"}}, []string{path}); err != nil {
+		t.Fatalf("run() unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := utf16le.NewEncoder().String("[main]\nkey        = value\nlonger_key = v\n")
+	if string(got) != want {
+		t.Fatalf("UTF-16LE output mismatch:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestDetectBOM(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"utf-16le", "\xff\xfek\x00", true},
+		{"utf-16be", "\xfe\xff\x00k", true},
+		{"utf-8", "\xef\xbb\xbfkey=v", true},
+		{"none", "key=v", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectBOM(bufio.NewReader(strings.NewReader(tt.input)))
+			if (got != nil) != tt.want {
+				t.Fatalf("detectBOM(%q) = %v, want detected=%v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newEnsureCmd builds the ensure subcommand, which adds keys that are missing
+// from a file without touching the ones that are set.
+func newEnsureCmd(cfg *config) *cobra.Command {
+	var fromFile string
+	var write bool
+	cmd := &cobra.Command{
+		Use:   "ensure file [section.key=value...]",
+		Short: "Add missing keys with default values",
+		Long: `ensure adds each section.key=value that is not in the file yet, after the last
+key of its section (creating the section at the end of the file if needed)
+and aligned with the keys above it. Keys that exist keep their value, even
+an empty one. --from-file ensures every key of another INI file instead of,
+or as well as, the arguments.
+
+The result is printed, or written back to the file with -w; the keys added
+are listed on stderr.`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeOneFile,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			defer func() { usageFor(cmd, err) }()
+			var assigns []assignment
+			if fromFile != "" {
+				defaults, err := readInput(fromFile, cfg.source)
+				if err != nil {
+					return err
+				}
+				for _, kv := range format.ParseKeyValues(defaults.lines, dialectOptions(cfg.format, fromFile, defaults.lines)) {
+					assigns = append(assigns, assignment{path: kv.Path(), section: kv.Section, key: kv.Key, resolved: true, value: kv.Value})
+				}
+			}
+			for _, arg := range args[1:] {
+				a, err := parseEnsureArg(arg)
+				if err != nil {
+					return optionError("", err)
+				}
+				assigns = append(assigns, a)
+			}
+			if len(assigns) == 0 {
+				return optionError("", errors.New("nothing to ensure: give section.key=value arguments or --from-file"))
+			}
+
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return err
+			}
+			result, added := ensureKeys(in.lines, assigns, dialectOptions(cfg.format, args[0], in.lines))
+			out := *cfg
+			out.write = write
+			out.lineEnding = "auto"
+			if err := writeOutput(out, args[0], in, result); err != nil {
+				return err
+			}
+			return writeAdded(cmd.ErrOrStderr(), added)
+		},
+	}
+	cmd.Flags().StringVar(&fromFile, "from-file", "", "Ensure every key of this INI file exists, with its value as the default")
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result back to the file")
+	return cmd
+}
+
+// parseEnsureArg parses a section.key=value argument. The key is the part
+// after the last dot of the path; a path without a dot names a preamble key.
+func parseEnsureArg(arg string) (assignment, error) {
+	path, value, ok := strings.Cut(arg, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return assignment{}, fmt.Errorf("invalid key %q (want section.key=value)", arg)
+	}
+	section, key := splitPath(path)
+	if key == "" {
+		return assignment{}, fmt.Errorf("invalid key %q (want section.key=value)", arg)
+	}
+	return assignment{path: path, section: section, key: key, resolved: true, value: value}, nil
+}
+
+// ensureKeys adds every assignment whose key is not in lines and returns the
+// result with the assignments that were added. A key given twice is added
+// once, with its first value.
+func ensureKeys(lines []string, assigns []assignment, cfg format.Options) ([]string, []assignment) {
+	result := slices.Clone(lines)
+	kvs := format.ParseKeyValues(result, cfg)
+	var added []assignment
+	for _, a := range assigns {
+		exists := false
+		for _, kv := range kvs {
+			if a.matches(kv) {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		result = addKey(result, a.section, a.key, a.value, cfg)
+		kvs = format.ParseKeyValues(result, cfg)
+		added = append(added, a)
+	}
+	return result, added
+}
+
+// writeAdded lists the keys ensure added.
+func writeAdded(w io.Writer, added []assignment) error {
+	for _, a := range added {
+		if _, err := fmt.Fprintln(w, strings.TrimSpace("added "+a.path+" = "+a.value)); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestParseEnsureArg(t *testing.T) {
+	tests := []struct {
+		arg               string
+		section, key, val string
+		wantErr           bool
+	}{
+		{arg: "server.timeout=30", section: "server", key: "timeout", val: "30"},
+		{arg: "hosts.eu.port=a=b", section: "hosts.eu", key: "port", val: "a=b"},
+		{arg: "top=", key: "top"},
+		{arg: "server.timeout", wantErr: true},
+		{arg: "=1", wantErr: true},
+		{arg: "server.=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		a, err := parseEnsureArg(tt.arg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseEnsureArg(%q) expected error", tt.arg)
+			}
+			continue
+		}
+		if err != nil || a.section != tt.section || a.key != tt.key || a.value != tt.val {
+			t.Errorf("parseEnsureArg(%q) = %+v, %v", tt.arg, a, err)
+		}
+	}
+}
+
+func TestEnsureKeys(t *testing.T) {
+	lines := []string{"[server]", "host    = x", "timeout =", "", "[db]", "url = y"}
+	assigns := []assignment{
+		{path: "server.timeout", section: "server", key: "timeout", resolved: true, value: "30"},
+		{path: "server.retries", section: "server", key: "retries", resolved: true, value: "3"},
+		{path: "server.retries", section: "server", key: "retries", resolved: true, value: "5"},
+		{path: "cache.ttl", section: "cache", key: "ttl", resolved: true, value: "60"},
+	}
+	got, added := ensureKeys(lines, assigns, format.Options{})
+	want := []string{"[server]", "host    = x", "timeout =", "retries = 3", "", "[db]", "url = y", "", "[cache]", "ttl = 60"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ensureKeys() = %q, want %q", got, want)
+	}
+	var paths []string
+	for _, a := range added {
+		paths = append(paths, a.path+"="+a.value)
+	}
+	if want := []string{"server.retries=3", "cache.ttl=60"}; !slices.Equal(paths, want) {
+		t.Errorf("ensureKeys() added %q, want %q", paths, want)
+	}
+	if again, added := ensureKeys(got, assigns, format.Options{}); !slices.Equal(again, got) || len(added) != 0 {
+		t.Errorf("ensureKeys() second run changed the file: %q, added %v", again, added)
+	}
+}
+
+func TestEnsureKeysRealignsEqualKeys(t *testing.T) {
+	lines := []string{"[server]", "port = 80", "host = x"}
+	assigns := []assignment{{path: "server.timeout", section: "server", key: "timeout", resolved: true, value: "30"}}
+	got, _ := ensureKeys(lines, assigns, format.Options{})
+	want := []string{"[server]", "port    = 80", "host    = x", "timeout = 30"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ensureKeys() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureCommandFromFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.ini")
+	defaults := filepath.Join(dir, "defaults.ini")
+	if err := os.WriteFile(target, []byte("[server]\nhost = x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(defaults, []byte("[server]\nhost = default\nport = 80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"ensure", "-w", "--from-file", defaults, target, "log.level=info"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, target), "[server]\nhost = x\nport = 80\n\n[log]\nlevel = info\n"; got != want {
+		t.Errorf("ensure wrote %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "added server.port = 80\nadded log.level = info\n"; got != want {
+		t.Errorf("ensure reported %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newEnvCmd builds the env subcommand, which emits shell export statements.
+func newEnvCmd(cfg *config) *cobra.Command {
+	var noPrefix bool
+	var outFormat string
+	cmd := &cobra.Command{
+		Use:   "env file [section]",
+		Short: "Print keys as shell export statements",
+		Long: `env prints an export statement for every key of the given section, or of all
+sections when none is given. Variable names are the section name and key joined
+by '_', uppercased, with every other non-alphanumeric character mapped to '_'.
+Values are single-quoted for POSIX sh.
+
+Use --no-prefix to leave out the section name, and --format=github to write
+KEY=value lines suitable for appending to $GITHUB_ENV. Keys whose names collide
+after sanitization are reported as an error.`,
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeFileThen(cfg, completeSections),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			defer func() { usageFor(cmd, err) }()
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return err
+			}
+			section, all := "", true
+			if len(args) > 1 {
+				section, all = args[1], false
+				if !slices.Contains(format.SectionNames(in.lines), section) {
+					return fmt.Errorf("section %q not found", section)
+				}
+			}
+			vars, err := envVars(format.ParseKeyValues(in.lines, cfg.format), section, all, !noPrefix)
+			if err != nil {
+				return err
+			}
+			return writeEnv(cmd.OutOrStdout(), vars, outFormat)
+		},
+	}
+	cmd.Flags().BoolVar(&noPrefix, "no-prefix", false, "Do not prefix variable names with the section name")
+	cmd.Flags().StringVar(&outFormat, "format", "sh", "Output format: 'sh' for export statements or 'github' for $GITHUB_ENV lines")
+	return cmd
+}
+
+// envVar is a variable derived from a key.
+type envVar struct {
+	name  string
+	value string
+}
+
+// envName sanitizes parts into an environment variable name.
+func envName(parts ...string) string {
+	var b strings.Builder
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if i > 0 && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		for _, r := range strings.ToUpper(part) {
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+	name := b.String()
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// envVars converts the key/value pairs of section (or of every section when all
+// is set) into variables. A repeated key keeps its last value; two different
+// keys that sanitize to the same name are an error.
+func envVars(kvs []format.KeyValue, section string, all, prefix bool) ([]envVar, error) {
+	var vars []envVar
+	index := make(map[string]int)
+	owner := make(map[string]string)
+	for _, kv := range kvs {
+		if !kv.HasValue || (!all && kv.Section != section) {
+			continue
+		}
+		name := envName(kv.Key)
+		if prefix {
+			name = envName(kv.Section, kv.Key)
+		}
+		if prev, ok := owner[name]; ok && prev != kv.Path() {
+			return nil, fmt.Errorf("keys %q and %q both map to variable %s", prev, kv.Path(), name)
+		}
+		owner[name] = kv.Path()
+		if i, ok := index[name]; ok {
+			vars[i].value = kv.Value
+			continue
+		}
+		index[name] = len(vars)
+		vars = append(vars, envVar{name: name, value: kv.Value})
+	}
+	return vars, nil
+}
+
+// shellQuote single-quotes s for POSIX sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeEnv renders vars in the given format.
+func writeEnv(w io.Writer, vars []envVar, outFormat string) error {
+	for _, v := range vars {
+		var line string
+		switch outFormat {
+		case "sh":
+			line = "export " + v.name + "=" + shellQuote(v.value)
+		case "github":
+			line = v.name + "=" + v.value
+		default:
+			return optionError("--format", fmt.Errorf("invalid --format %q (want sh or github)", outFormat))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"database", "host"}, "DATABASE_HOST"},
+		{[]string{"my-app.cache", "max size"}, "MY_APP_CACHE_MAX_SIZE"},
+		{[]string{"", "port"}, "PORT"},
+		{[]string{"", "1st"}, "_1ST"},
+	}
+	for _, tt := range tests {
+		if got := envName(tt.parts...); got != tt.want {
+			t.Errorf("envName(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	lines := []string{
+		"[database]",
+		"host = db1",
+		"password = it's \"secret\"",
+		"host = db2",
+		"[cache]",
+		"host = c1",
+	}
+	kvs := format.ParseKeyValues(lines, format.Options{})
+
+	vars, err := envVars(kvs, "database", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := writeEnv(&out, vars, "sh"); err != nil {
+		t.Fatal(err)
+	}
+	want := "export DATABASE_HOST='db2'\nexport DATABASE_PASSWORD='it'\\''s \"secret\"'\n"
+	if out.String() != want {
+		t.Errorf("writeEnv(sh) =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	vars, _ = envVars(kvs, "cache", false, false)
+	if err := writeEnv(&out, vars, "github"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "HOST=c1\n" {
+		t.Errorf("writeEnv(github) = %q", out.String())
+	}
+
+	if _, err := envVars(kvs, "", true, false); err == nil {
+		t.Error("envVars(all, no prefix) expected collision error for database.host and cache.host")
+	}
+	collide := format.ParseKeyValues([]string{"[s]", "a-b = 1", "a_b = 2"}, format.Options{})
+	if _, err := envVars(collide, "s", false, true); err == nil {
+		t.Error("envVars() expected collision error for a-b and a_b")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// reasonEOLOnly is the reason given for a file whose output differs from it
+// only in line endings or the final newline.
+const reasonEOLOnly = "only line endings differ (--check-ignore-eol ignores them)"
+
+// outputStatus reports whether result, written with eol after every line,
+// differs from the input. The lines are compared one by one without their
+// endings, so a file whose lines all stay the same is told apart: it is
+// formatted for reasonEOLOnly, or unchanged with ignoreEOL.
+func outputStatus(in *input, result []string, eol string, ignoreEOL bool) (fileStatus, string) {
+	if sameOutput(in, result, eol) {
+		return statusUnchanged, ""
+	}
+	if !slices.Equal(in.lines, result) {
+		return statusFormatted, ""
+	}
+	if ignoreEOL {
+		return statusUnchanged, ""
+	}
+	return statusFormatted, reasonEOLOnly
+}
+
+// sameOutput reports whether result, written with eol after every line, is
+// the input byte for byte.
+func sameOutput(in *input, result []string, eol string) bool {
+	return outputText(result, eol) == in.text
+}
+
+// outputText returns result with eol after every line, as writeOutput writes
+// it before encoding.
+func outputText(result []string, eol string) string {
+	var b strings.Builder
+	for _, line := range result {
+		b.WriteString(line)
+		b.WriteString(eol)
+	}
+	return b.String()
+}
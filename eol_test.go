@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestOutputStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		result     []string
+		eol        string
+		ignoreEOL  bool
+		wantStatus fileStatus
+		wantReason string
+	}{
+		{"same", "a = 1\nb = 2\n", []string{"a = 1", "b = 2"}, "\n", false, statusUnchanged, ""},
+		{"realigned", "a=1\nb = 2\n", []string{"a = 1", "b = 2"}, "\n", false, statusFormatted, ""},
+		{"realigned, ignoring endings", "a=1\r\nb = 2\r\n", []string{"a = 1", "b = 2"}, "\n", true, statusFormatted, ""},
+		{"crlf to lf", "a = 1\r\nb = 2\r\n", []string{"a = 1", "b = 2"}, "\n", false, statusFormatted, reasonEOLOnly},
+		{"crlf to lf, ignoring endings", "a = 1\r\nb = 2\r\n", []string{"a = 1", "b = 2"}, "\n", true, statusUnchanged, ""},
+		{"mixed to crlf", "a = 1\r\nb = 2\n", []string{"a = 1", "b = 2"}, "\r\n", false, statusFormatted, reasonEOLOnly},
+		{"missing final newline", "a = 1\nb = 2", []string{"a = 1", "b = 2"}, "\n", false, statusFormatted, reasonEOLOnly},
+		{"missing final newline, ignoring endings", "a = 1\r\nb = 2", []string{"a = 1", "b = 2"}, "\n", true, statusUnchanged, ""},
+		{"line removed", "a = 1\n\n", []string{"a = 1"}, "\n", true, statusFormatted, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, eol := format.SplitLines(tt.text)
+			in := &input{text: tt.text, lines: lines, eol: eol, endings: lineEndings(tt.text)}
+			status, reason := outputStatus(in, tt.result, tt.eol, tt.ignoreEOL)
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Errorf("outputStatus() = %s, %q, want %s, %q", status, reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
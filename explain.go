@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// explainFile explains for --explain how the lines of filename are aligned.
+func explainFile(w io.Writer, cfg config, filename string, lines []string) error {
+	if cfg.from == "flat" {
+		var err error
+		if lines, err = unflattenLines(lines, cfg.format); err != nil {
+			return fmt.Errorf("reading flat input: %w", err)
+		}
+	}
+	groups, err := format.Explain(lines, cfg.format)
+	if err != nil {
+		return inputError(cfg.displayName(filename), err)
+	}
+	return writeExplanation(w, cfg.displayName(filename), groups, cfg.format)
+}
+
+// writeExplanation describes for --explain how each alignment group of the
+// file name was aligned.
+func writeExplanation(w io.Writer, name string, groups []format.AlignGroup, cfg format.Options) error {
+	if cfg.SingleSpace {
+		_, err := fmt.Fprintf(w, "inifmt: %s: --single-space: keys are not aligned\n", name)
+		return err
+	}
+	for _, g := range groups {
+		var err error
+		if g.Keys == 0 {
+			_, err = fmt.Fprintf(w, "inifmt: %s: %s (%s): no keys to align\n", name, lineRange(g.Start, g.End), g.Scope)
+		} else {
+			_, err = fmt.Fprintf(w, "inifmt: %s: %s (%s): %d %s padded to width %d set by line %d; %d padded\n",
+				name, lineRange(g.Start, g.End), g.Scope, g.Keys, plural(g.Keys, "key", "keys"), g.Width, g.WidestLine, g.Padded)
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range g.Excluded {
+			if _, err := fmt.Fprintf(w, "  line %d excluded: %s\n", e.Line, e.Reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lineRange names the lines start to end.
+func lineRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("line %d", start)
+	}
+	return fmt.Sprintf("lines %d-%d", start, end)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestExplainFile(t *testing.T) {
+	lines := []string{"; top", "[server]", "host=example.com", "port=80", "bare", "", "[empty]"}
+	tests := []struct {
+		cfg  format.Options
+		want string
+	}{
+		{format.Options{}, "inifmt: a.ini: lines 1-7 (file): 2 keys padded to width 4 set by line 3; 0 padded\n" +
+			"  line 1 excluded: comment\n" +
+			"  line 2 excluded: section header\n" +
+			"  line 5 excluded: bare key\n" +
+			"  line 6 excluded: blank line\n" +
+			"  line 7 excluded: section header\n"},
+		{format.Options{PerSection: true}, "inifmt: a.ini: line 1 (section): no keys to align\n" +
+			"  line 1 excluded: comment\n" +
+			"inifmt: a.ini: lines 3-6 (section): 2 keys padded to width 4 set by line 3; 0 padded\n" +
+			"  line 5 excluded: bare key\n" +
+			"  line 6 excluded: blank line\n"},
+		{format.Options{SingleSpace: true}, "inifmt: a.ini: --single-space: keys are not aligned\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := explainFile(&out, config{format: tt.cfg}, "a.ini", lines); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("explainFile(%+v) =\n%s\nwant:\n%s", tt.cfg, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceOptions says how input files are read.
+type sourceOptions struct {
+	encoding string   // character encoding name; "" means UTF-8
+	headers  []string // extra HTTP request headers for URL input, as "Name: value"
+	maxSize  string   // largest URL response or decompressed input accepted, e.g. "10M"; "" means defaultMaxSize
+}
+
+// defaultMaxSize limits URL responses unless --max-size says otherwise.
+const defaultMaxSize = 10 << 20
+
+// fetchTimeout bounds the whole request, redirects and body included.
+const fetchTimeout = 30 * time.Second
+
+// isURL reports whether an input argument names an http(s) URL rather than a file.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers of 1024).
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return defaultMaxSize, nil
+	}
+	digits, shift := strings.ToUpper(s), 0
+	for i, suffix := range []string{"K", "M", "G"} {
+		if trimmed, ok := strings.CutSuffix(strings.TrimSuffix(digits, "B"), suffix); ok {
+			digits, shift = trimmed, 10*(i+1)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(digits, "B"), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want a positive number of bytes, optionally with K, M or G)", s)
+	}
+	return n << shift, nil
+}
+
+// fetchURL downloads url, following redirects, and returns its body. Responses
+// other than 2xx and bodies larger than the --max-size limit are errors.
+func fetchURL(url string, src sourceOptions) ([]byte, error) {
+	limit, err := parseSize(src.maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("--max-size: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	for _, h := range src.headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --header %q (want 'Name: value')", h)
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("fetching %s: server returned %s", url, resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("fetching %s: response of %d bytes exceeds --max-size of %d bytes", url, resp.ContentLength, limit)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("fetching %s: response exceeds --max-size of %d bytes", url, limit)
+	}
+	return body, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadInputURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.ini":
+			w.Write([]byte("[s]\na=1\nlong=2\n"))
+		case "/old.ini":
+			http.Redirect(w, r, "/app.ini", http.StatusFound)
+		case "/private.ini":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "no", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("k=v\n"))
+		case "/big.ini":
+			w.Write([]byte(strings.Repeat("k=v\n", 1024)))
+		case "/stream.ini":
+			w.(http.Flusher).Flush() // no Content-Length
+			w.Write([]byte(strings.Repeat("k=v\n", 1024)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path    string
+		src     sourceOptions
+		want    string // first line, or error substring
+		wantErr bool
+	}{
+		{path: "/app.ini", want: "[s]"},
+		{path: "/old.ini", want: "[s]"},
+		{path: "/private.ini", src: sourceOptions{headers: []string{"Authorization: Bearer secret"}}, want: "k=v"},
+		{path: "/private.ini", want: "server returned 401 Unauthorized", wantErr: true},
+		{path: "/missing.ini", want: "server returned 404 Not Found", wantErr: true},
+		{path: "/big.ini", src: sourceOptions{maxSize: "1K"}, want: "exceeds --max-size of 1024 bytes", wantErr: true},
+		{path: "/stream.ini", src: sourceOptions{maxSize: "1K"}, want: "exceeds --max-size of 1024 bytes", wantErr: true},
+		{path: "/big.ini", src: sourceOptions{maxSize: "4K"}, want: "k=v"},
+		{path: "/app.ini", src: sourceOptions{headers: []string{"no colon"}}, want: "invalid --header", wantErr: true},
+	}
+	for _, tt := range tests {
+		in, err := readInput(srv.URL+tt.path, tt.src)
+		switch {
+		case tt.wantErr:
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readInput(%s) error = %v, want %q", tt.path, err, tt.want)
+			}
+		case err != nil:
+			t.Errorf("readInput(%s) unexpected error: %v", tt.path, err)
+		case len(in.lines) == 0 || in.lines[0] != tt.want:
+			t.Errorf("readInput(%s) = %q, want first line %q", tt.path, in.lines, tt.want)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "app.ini")
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "-o", out, srv.URL + "/app.ini"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "[s]\na    = 1\nlong = 2\n" {
+		t.Errorf("-o output = %q", got)
+	}
+	if err := run(context.Background(), config{write: true}, []string{srv.URL + "/app.ini"}); err == nil || !strings.Contains(err.Error(), "--write cannot write back to a URL") {
+		t.Errorf("run(--write URL) error = %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", defaultMaxSize},
+		{"512", 512},
+		{"2K", 2048},
+		{"10M", 10 << 20},
+		{"1GB", 1 << 30},
+		{"3kb", 3072},
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"x", "-1", "0", "1T"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("parseSize(%q) expected error", bad)
+		}
+	}
+}
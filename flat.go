@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// flattenLines renders formatted lines as one "section.key = value" line per
+// key, preamble keys bare, without headers, comments or blank lines. Inline
+// comments are dropped. Names that contain '.', '=' or '"', or that would not
+// read back verbatim, are double-quoted so that unflattenLines can restore the
+// document. Empty sections are not represented.
+func flattenLines(lines []string, cfg format.Options) []string {
+	var out []string
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		path := quoteFlatName(kv.Key, cfg)
+		if kv.Section != "" {
+			path = quoteFlatName(kv.Section, cfg) + "." + path
+		}
+		if !kv.HasValue {
+			out = append(out, path)
+			continue
+		}
+		value, _ := format.SplitInlineComment(kv.Value)
+		out = append(out, strings.TrimRight(path+" = "+value, " "))
+	}
+	return out
+}
+
+// quoteFlatName quotes a section or key name when it would be ambiguous in a
+// flat path.
+func quoteFlatName(name string, cfg format.Options) string {
+	if name == "" || strings.TrimSpace(name) != name || strings.ContainsAny(name, `.="`) ||
+		strings.HasPrefix(name, "[") || cfg.IsComment(name) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// unflattenLines rebuilds a sectioned INI document from flat lines written by
+// flattenLines. Sections appear in the order they are first named and keep
+// their keys in input order; preamble keys come first. Blank lines and
+// full-line comments are ignored. An unquoted path with several dots names the
+// key after the last one, as FindKey resolves it.
+func unflattenLines(lines []string, cfg format.Options) ([]string, error) {
+	var preamble []string
+	var order []string
+	sections := make(map[string][]string)
+	for i, line := range lines {
+		if format.IsBlank(line) || cfg.IsComment(line) {
+			continue
+		}
+		section, key, rest, err := parseFlatPath(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if strings.Contains(key, "=") || strings.HasPrefix(key, "[") || cfg.IsComment(key) {
+			return nil, fmt.Errorf("line %d: key %q cannot be written as INI", i+1, key)
+		}
+		entry := key
+		if value, ok := strings.CutPrefix(strings.TrimSpace(rest), "="); ok {
+			entry = strings.TrimRight(key+" = "+strings.TrimSpace(value), " ")
+		} else if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("line %d: unexpected %q after the key", i+1, strings.TrimSpace(rest))
+		}
+		if section == "" {
+			preamble = append(preamble, entry)
+			continue
+		}
+		if strings.Contains(section, "]") {
+			return nil, fmt.Errorf("line %d: section name %q cannot be written as INI", i+1, section)
+		}
+		if _, ok := sections[section]; !ok {
+			order = append(order, section)
+		}
+		sections[section] = append(sections[section], entry)
+	}
+	out := preamble
+	for _, name := range order {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, "["+name+"]")
+		out = append(out, sections[name]...)
+	}
+	return out, nil
+}
+
+// parseFlatPath splits a flat line into its section, key and the text after the
+// path, which is empty for a bare key or starts with '='. An empty key must be
+// quoted.
+func parseFlatPath(line string) (section, key, rest string, err error) {
+	var names []string
+	quoted := false
+	rest = strings.TrimLeft(line, " \t")
+	for {
+		var name string
+		quoted = strings.HasPrefix(rest, `"`)
+		if quoted {
+			end := quotedNameEnd(rest)
+			if end == -1 {
+				return "", "", "", fmt.Errorf("unterminated quoted name in %q", line)
+			}
+			if name, err = strconv.Unquote(rest[:end]); err != nil {
+				return "", "", "", fmt.Errorf("invalid quoted name %s", rest[:end])
+			}
+			rest = rest[end:]
+		} else {
+			end := strings.IndexAny(rest, ".=")
+			if end == -1 {
+				end = len(rest)
+			}
+			name, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		names = append(names, name)
+		if !strings.HasPrefix(rest, ".") {
+			break
+		}
+		rest = rest[1:]
+	}
+	key = names[len(names)-1]
+	if key == "" && !quoted {
+		return "", "", "", fmt.Errorf("missing key in %q", line)
+	}
+	return strings.Join(names[:len(names)-1], "."), key, rest, nil
+}
+
+// quotedNameEnd returns the index just past the double-quoted name at the
+// start of s, or -1 when it is not terminated.
+func quotedNameEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestFlattenLines(t *testing.T) {
+	lines := []string{
+		"; about the file",
+		"top = 1",
+		"",
+		"[server]",
+		"host = example.com ; primary",
+		"flag",
+		"empty =",
+		"[hosts.eu]",
+		"key.with.dots = \"quoted value\"",
+		"\"odd\" = x",
+		"[empty]",
+	}
+	want := []string{
+		"top = 1",
+		"server.host = example.com",
+		"server.flag",
+		"server.empty =",
+		`"hosts.eu"."key.with.dots" = "quoted value"`,
+		`"hosts.eu"."\"odd\"" = x`,
+	}
+	got := flattenLines(lines, format.Options{})
+	if !slices.Equal(got, want) {
+		t.Fatalf("flattenLines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUnflattenLines(t *testing.T) {
+	lines := []string{
+		"b.k = 1",
+		"# a comment",
+		"top = level",
+		"",
+		`"a.b".x = 2`,
+		"b.bare",
+		"a.b.c.y = 3",
+		"  spaced key  =  v  ",
+	}
+	want := []string{
+		"top = level",
+		"spaced key = v",
+		"",
+		"[b]",
+		"k = 1",
+		"bare",
+		"",
+		"[a.b]",
+		"x = 2",
+		"",
+		"[a.b.c]",
+		"y = 3",
+	}
+	got, err := unflattenLines(lines, format.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unflattenLines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, bad := range []string{`"unterminated.k = 1`, "s. = 1", `s."a=b" = 1`, `"a]b".k = 1`, `s."k" junk`} {
+		if _, err := unflattenLines([]string{bad}, format.Options{}); err == nil {
+			t.Errorf("unflattenLines(%q) expected error", bad)
+		}
+	}
+}
+
+func TestFlatRoundTrip(t *testing.T) {
+	data, err := readInput("test.ini", sourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []format.Options{{}, {SortSections: true, SortKeys: []string{"*"}}} {
+		formatted, err := format.Lines(data.lines, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flat := flattenLines(formatted, cfg)
+		restored, err := unflattenLines(flat, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again := flattenLines(restored, cfg); !slices.Equal(again, flat) {
+			t.Errorf("flat round trip changed the data:\n%s\nwant:\n%s", strings.Join(again, "\n"), strings.Join(flat, "\n"))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package format
+
+import (
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Collations Options.Collate selects.
+const (
+	CollateBytes   = "bytes"   // byte order: reproducible, upper case before lower case
+	CollateUnicode = "unicode" // case-insensitive Unicode collation of CollateLocale
+)
+
+// Case handling Options.SortCase selects.
+const (
+	SortCaseSensitive   = "sensitive"   // upper and lower case sort apart
+	SortCaseInsensitive = "insensitive" // names equal but for case tie, keeping their order
+)
+
+// compareFunc returns the comparison the sorting passes order sections, keys
+// and list items with. Names that the Unicode collation ranks equal, such as
+// "Port" and "port", fall back to byte order so the result stays
+// deterministic. With SortCaseInsensitive, names equal but for case compare
+// equal instead, and the stable sorts keep them in their original order.
+func (c Options) compareFunc() func(a, b string) int {
+	fold := c.SortCase == SortCaseInsensitive
+	if c.Collate != CollateUnicode {
+		if fold {
+			return compareFold
+		}
+		return strings.Compare
+	}
+	tag, err := language.Parse(c.CollateLocale)
+	if err != nil {
+		tag = language.Und
+	}
+	col := collate.New(tag, collate.IgnoreCase)
+	return func(a, b string) int {
+		if r := col.CompareString(a, b); r != 0 || fold {
+			return r
+		}
+		return strings.Compare(a, b)
+	}
+}
+
+// compareFold compares a and b in byte order of their lower-case forms.
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,158 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCollate(t *testing.T) {
+	lines := []string{
+		"[zebra]",
+		"Zulu = 1",
+		"apple = 2",
+		"Éclair = 3",
+		"eagle = 4",
+		"Apple = 5",
+		"items = Zeta, élan, alpha, Beta",
+		"[Übersicht]",
+		"[apple]",
+		"[Banana]",
+	}
+	tests := []struct {
+		collate, locale string
+		want            []string
+	}{
+		{"", "", []string{
+			"[Banana]",
+			"[apple]",
+			"[zebra]",
+			"Apple  = 5",
+			"Zulu   = 1",
+			"apple  = 2",
+			"eagle  = 4",
+			"items  = Beta, Zeta, alpha, élan",
+			"Éclair = 3",
+			"[Übersicht]",
+		}},
+		{CollateUnicode, "", []string{
+			"[apple]",
+			"[Banana]",
+			"[Übersicht]",
+			"[zebra]",
+			"Apple  = 5",
+			"apple  = 2",
+			"eagle  = 4",
+			"Éclair = 3",
+			"items  = alpha, Beta, élan, Zeta",
+			"Zulu   = 1",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := Options{
+			SortSections:   true,
+			SortKeys:       []string{"*"},
+			SortListValues: []string{"items"},
+			Collate:        tt.collate,
+			CollateLocale:  tt.locale,
+		}
+		got, err := Lines(lines, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(collate %q %q) =\n%s\nwant:\n%s", tt.collate, tt.locale, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestCollateLocale(t *testing.T) {
+	lines := []string{"zon = 1", "öl = 2", "Ånger = 3"}
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"", []string{"Ånger = 3", "öl    = 2", "zon   = 1"}},
+		{"sv", []string{"zon   = 1", "Ånger = 3", "öl    = 2"}},
+	}
+	for _, tt := range tests {
+		got, err := Lines(lines, Options{SortKeys: []string{"*"}, Collate: CollateUnicode, CollateLocale: tt.locale})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(locale %q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestSortCase(t *testing.T) {
+	lines := []string{
+		"[zebra]",
+		"timeout = 1",
+		"Zebra = 2",
+		"apple = 3",
+		"Timeout = 4",
+		"items = b, A, a, B",
+		"[Apple]",
+		"[banana]",
+	}
+	tests := []struct {
+		sortCase, collate string
+		want              []string
+	}{
+		{SortCaseSensitive, "", []string{
+			"[Apple]",
+			"[banana]",
+			"[zebra]",
+			"Timeout = 4",
+			"Zebra   = 2",
+			"apple   = 3",
+			"items   = A, B, a, b",
+			"timeout = 1",
+		}},
+		{SortCaseInsensitive, "", []string{
+			"[Apple]",
+			"[banana]",
+			"[zebra]",
+			"apple   = 3",
+			"items   = A, a, b, B",
+			"timeout = 1",
+			"Timeout = 4",
+			"Zebra   = 2",
+		}},
+		{SortCaseInsensitive, CollateUnicode, []string{
+			"[Apple]",
+			"[banana]",
+			"[zebra]",
+			"apple   = 3",
+			"items   = A, a, b, B",
+			"timeout = 1",
+			"Timeout = 4",
+			"Zebra   = 2",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := Options{
+			SortSections:   true,
+			SortKeys:       []string{"*"},
+			SortListValues: []string{"items"},
+			SortCase:       tt.sortCase,
+			Collate:        tt.collate,
+		}
+		got, err := Lines(lines, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(sort case %q, collate %q) =\n%s\nwant:\n%s", tt.sortCase, tt.collate, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+		again, err := Lines(got, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(again, got) {
+			t.Errorf("Lines(sort case %q) is not idempotent:\n%s", tt.sortCase, strings.Join(again, "\n"))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"strings"
+	"unicode"
+)
+
+// alignCommentIndent re-indents full-line comments inside sections to match the
+// key/value line they document, i.e. the first non-comment line below them.
+// Comments followed by a blank line, a header or the end of the file are
+// section-level and move to column 0, as do preamble comments. Banner comments
+// are left untouched.
+func alignCommentIndent(lines []string, cfg Options) []string {
+	result := make([]string, len(lines))
+	copy(result, lines)
+	inSection := false
+	for i := 0; i < len(result); i++ {
+		line := result[i]
+		if IsHeader(line) {
+			inSection = true
+			continue
+		}
+		if !cfg.IsComment(line) {
+			continue
+		}
+		// Find the end of this run of comments and the line that follows it.
+		end := i
+		for end < len(result) && cfg.IsComment(result[end]) {
+			end++
+		}
+		indent := ""
+		if inSection && end < len(result) && !IsBlank(result[end]) && !IsHeader(result[end]) {
+			indent = leadingWhitespace(result[end])
+		}
+		for j := i; j < end; j++ {
+			if prefix, _ := cfg.commentPrefix(result[j]); !isBannerComment(result[j], prefix) {
+				result[j] = indent + strings.TrimLeft(result[j], " \t")
+			}
+		}
+		i = end - 1
+	}
+	return result
+}
+
+// leadingWhitespace returns the run of spaces and tabs that starts line.
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// isBannerComment reports whether a comment starting with prefix is
+// decorative, such as a row of dashes or a "### Title ###" banner, and should
+// keep its layout.
+func isBannerComment(line, prefix string) bool {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, strings.Repeat(prefix, 3)) {
+		return true
+	}
+	text := strings.TrimSpace(trimmed[len(prefix):])
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package format
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAlignCommentIndent(t *testing.T) {
+	lines := []string{
+		"  ; preamble note",
+		"[server]",
+		"    ; section-level note",
+		"",
+		"; documents port",
+		"\t# and continues",
+		"    port = 80",
+		"        ; documents host",
+		"  host = example.com",
+		"  ; ------------",
+		"  ;;; Banner ;;;",
+		"    ; trailing note",
+	}
+	want := []string{
+		"; preamble note",
+		"[server]",
+		"; section-level note",
+		"",
+		"    ; documents port",
+		"    # and continues",
+		"    port = 80",
+		"  ; documents host",
+		"  host = example.com",
+		"  ; ------------",
+		"  ;;; Banner ;;;",
+		"; trailing note",
+	}
+	got := alignCommentIndent(lines, Options{})
+	if !slices.Equal(got, want) {
+		t.Fatalf("alignCommentIndent() =\n%q\nwant\n%q", got, want)
+	}
+	if again := alignCommentIndent(got, Options{}); !slices.Equal(again, got) {
+		t.Fatalf("alignCommentIndent() not idempotent:\n%q\nthen\n%q", got, again)
+	}
+}
+
+func TestIsBannerComment(t *testing.T) {
+	tests := map[string]bool{
+		"; ----------":    true,
+		"#=====":          true,
+		"### Section ###": true,
+		"; note":          false,
+		"# 80 is default": false,
+		";":               false,
+	}
+	for line, want := range tests {
+		if got := isBannerComment(line, line[:1]); got != want {
+			t.Errorf("isBannerComment(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
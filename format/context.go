@@ -0,0 +1,80 @@
+package format
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ErrCanceled is returned, wrapping the context's own error, by the Context
+// variants when their context is done before they finish. errors.Is reports
+// both ErrCanceled and context.Canceled or context.DeadlineExceeded for it.
+var ErrCanceled = errors.New("formatting canceled")
+
+// canceled returns the error the Context variants return once c.ctx is done,
+// or nil while formatting may go on.
+func (c Options) canceled() error {
+	if c.ctx == nil {
+		return nil
+	}
+	if err := c.ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	return nil
+}
+
+// LinesContext is Lines, giving up with an error wrapping ErrCanceled when
+// ctx is done. The context is checked between sections and passes.
+func LinesContext(ctx context.Context, lines []string, cfg Options) ([]string, error) {
+	cfg.ctx = ctx
+	if err := cfg.canceled(); err != nil {
+		return nil, err
+	}
+	return Lines(lines, cfg)
+}
+
+// SourceContext is Source, giving up when ctx is done like LinesContext.
+func SourceContext(ctx context.Context, src []byte, opts Options) ([]byte, error) {
+	lines, _ := SplitLines(string(src))
+	result, err := LinesContext(ctx, lines, opts)
+	if err != nil {
+		return nil, err
+	}
+	return appendLines(nil, result), nil
+}
+
+// Format reads an INI file from r and writes it to w formatted like Source.
+func Format(r io.Reader, w io.Writer, opts Options) error {
+	return FormatContext(context.Background(), r, w, opts)
+}
+
+// FormatContext is Format, giving up when ctx is done like LinesContext.
+// Nothing is written to w unless the whole file was formatted.
+func FormatContext(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	out, err := SourceContext(ctx, src, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// FormatFSContext is FormatFS, giving up when ctx is done like LinesContext.
+// The context is also checked before each file.
+func FormatFSContext(ctx context.Context, fsys fs.FS, match func(path string) bool, opts Options) (map[string][]byte, error) {
+	opts.ctx = ctx
+	return FormatFS(fsys, match, opts)
+}
+
+// ChangedFSContext is ChangedFS, giving up when ctx is done like
+// FormatFSContext.
+func ChangedFSContext(ctx context.Context, fsys fs.FS, match func(path string) bool, opts Options) ([]string, error) {
+	opts.ctx = ctx
+	return ChangedFS(fsys, match, opts)
+}
@@ -0,0 +1,132 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// countdownContext is a context that reports itself canceled once Err has
+// been called after more than limit times, and counts the calls.
+type countdownContext struct {
+	context.Context
+	limit int64
+	calls atomic.Int64
+}
+
+func (c *countdownContext) Err() error {
+	if c.calls.Add(1) > c.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+// largeFile returns an INI file with the given number of sections.
+func largeFile(sections int) []string {
+	lines := make([]string, 0, sections*4)
+	for i := range sections {
+		lines = append(lines, fmt.Sprintf("[section%d]", i), "key=value", "longer_key =  other  value", "")
+	}
+	return lines
+}
+
+func TestLinesContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := LinesContext(ctx, []string{"a=1"}, Options{})
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("LinesContext(canceled) = %v, want ErrCanceled wrapping context.Canceled", err)
+	}
+}
+
+func TestLinesContextMidFormat(t *testing.T) {
+	lines := largeFile(1000)
+	tests := []struct {
+		name string
+		cfg  Options
+	}{
+		{"per section", Options{PerSection: true}},
+		{"sorted keys", Options{SortKeys: []string{"*"}}},
+		{"only sections", Options{OnlySections: []string{"*"}}},
+		{"section options", Options{Sections: []SectionOptions{{Pattern: "section1*", Options: Options{SingleSpace: true}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &countdownContext{Context: context.Background(), limit: 100}
+			_, err := LinesContext(ctx, lines, tt.cfg)
+			if !errors.Is(err, ErrCanceled) {
+				t.Fatalf("LinesContext() = %v, want ErrCanceled", err)
+			}
+			if extra := ctx.calls.Load() - ctx.limit; extra > 3 {
+				t.Errorf("LinesContext() went on for %d checks after the context was canceled", extra)
+			}
+		})
+	}
+}
+
+func TestLinesContextTimely(t *testing.T) {
+	lines := largeFile(50000)
+	cfg := Options{PerSection: true, SortKeys: []string{"*"}}
+	start := time.Now()
+	if _, err := Lines(lines, cfg); err != nil {
+		t.Fatal(err)
+	}
+	full := time.Since(start)
+
+	ctx, cancel := context.WithTimeout(context.Background(), full/20)
+	defer cancel()
+	start = time.Now()
+	_, err := LinesContext(ctx, lines, cfg)
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LinesContext() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > full/2 {
+		t.Errorf("LinesContext() took %v with a deadline of %v, a full run takes %v", elapsed, full/20, full)
+	}
+}
+
+func TestFormatContext(t *testing.T) {
+	src := "[s]\na=1\nlong=2\n"
+	var out bytes.Buffer
+	if err := Format(strings.NewReader(src), &out, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[s]\na    = 1\nlong = 2\n"; out.String() != want {
+		t.Errorf("Format() wrote %q, want %q", out.String(), want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	if err := FormatContext(ctx, strings.NewReader(src), &out, Options{}); !errors.Is(err, ErrCanceled) {
+		t.Errorf("FormatContext(canceled) = %v, want ErrCanceled", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("FormatContext(canceled) wrote %q, want nothing", out.String())
+	}
+}
+
+func TestFormatFSContext(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.ini": {Data: []byte("a=1\n")},
+		"b.ini": {Data: []byte("b = 2\n")},
+	}
+	ctx := &countdownContext{Context: context.Background(), limit: 1}
+	if _, err := FormatFSContext(ctx, fsys, nil, Options{}); !errors.Is(err, ErrCanceled) {
+		t.Errorf("FormatFSContext() = %v, want ErrCanceled", err)
+	}
+	changed, err := ChangedFSContext(context.Background(), fsys, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0] != "a.ini" {
+		t.Errorf("ChangedFSContext() = %q, want [a.ini]", changed)
+	}
+}
@@ -0,0 +1,49 @@
+package format
+
+import "strings"
+
+// commentSkipper is implemented by dialects that skip comment lines inside a
+// value continued by a trailing backslash.
+type commentSkipper interface {
+	SkipsCommentsInContinuations() bool
+}
+
+// skipsCommentsInContinuations reports whether d reads on past comment lines
+// to find the continuation of a value.
+func skipsCommentsInContinuations(d Dialect) bool {
+	cs, ok := d.(commentSkipper)
+	return ok && cs.SkipsCommentsInContinuations()
+}
+
+// DanglingContinuations returns the 1-based numbers of the lines ending in a
+// continuation backslash with nothing to continue into: the last line of the
+// file, or one followed by a blank line, a section header or, unless the
+// dialect skips comments inside continued values, a comment. Dialects without
+// backslash continuations have none. The formatter leaves these lines as they
+// are, since parsers disagree on what they mean.
+func DanglingContinuations(lines []string, cfg Options) []int {
+	d := cfg.dialect()
+	kinds := cfg.classifyLines(lines)
+	var numbers []int
+	for i, line := range lines {
+		if kinds[i] != LineKeyValue && kinds[i] != LineContinuation {
+			continue
+		}
+		if !strings.HasSuffix(strings.TrimRight(line, " \t"), `\`) {
+			continue
+		}
+		// Ask the dialect whether a line after this one would continue it.
+		ctx := LineContext{Index: i + 1, Prev: line, PrevKind: kinds[i]}
+		if d.Classify("x", ctx, cfg) != LineContinuation {
+			continue
+		}
+		next := i + 1
+		for next < len(lines) && cfg.IsComment(lines[next]) && skipsCommentsInContinuations(d) {
+			next++
+		}
+		if next == len(lines) || IsBlank(lines[next]) || IsHeader(lines[next]) || cfg.IsComment(lines[next]) {
+			numbers = append(numbers, i+1)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,43 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDanglingContinuations(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		lines   []string
+		want    []int
+	}{
+		{"end of file", DialectGitConfig, []string{"[a]", "k = v \\"}, []int{2}},
+		{"before a header", DialectGitConfig, []string{"[a]", "k = v \\", "[b]", "x = 1"}, []int{2}},
+		{"before a blank line", DialectProperties, []string{"k = v \\", "", "x = 1"}, []int{1}},
+		{"before a comment", DialectGitConfig, []string{"[a]", "k = v \\", "# note", "  more"}, []int{2}},
+		{"comment skipped", DialectSystemd, []string{"[Service]", "ExecStart=/bin/a \\", "# note", "  --flag"}, nil},
+		{"comment then end of file", DialectSystemd, []string{"[Service]", "ExecStart=/bin/a \\", "# note"}, []int{2}},
+		{"continued", DialectGitConfig, []string{"[a]", "k = v \\", "  w \\", "  x"}, nil},
+		{"last of a run", DialectGitConfig, []string{"[a]", "k = v \\", "  w \\"}, []int{3}},
+		{"plain ini", DialectINI, []string{"[a]", "path = C:\\"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Options{Dialect: tt.dialect}
+			if got := DanglingContinuations(tt.lines, cfg); !slices.Equal(got, tt.want) {
+				t.Errorf("DanglingContinuations() = %v, want %v", got, tt.want)
+			}
+			got, err := Lines(tt.lines, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range tt.want {
+				if !strings.HasSuffix(got[n-1], `\`) {
+					t.Errorf("Lines() dropped the backslash of line %d: %q", n, got[n-1])
+				}
+			}
+		})
+	}
+}
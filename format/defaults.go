@@ -0,0 +1,76 @@
+package format
+
+// defaultText returns what a key of the defaults and a key of the file must
+// share for the key to restate its default: the value without its inline
+// comment and enclosing quotes, normalized, or nothing for a bare key.
+func defaultText(kv KeyValue) string {
+	if !kv.HasValue {
+		return ""
+	}
+	return "=" + normalizeValue(ValueText(kv.Value))
+}
+
+// DefaultLines returns the key/value lines PruneDefaults removes from lines:
+// those whose section, key and value match the last occurrence of the key in
+// opts.Defaults. A key given more than once in a section is removed only when
+// every occurrence matches, so that no earlier value takes effect. Only the
+// sections selected by OnlySections count.
+func DefaultLines(lines []string, opts Options) []KeyValue {
+	if len(opts.Defaults) == 0 {
+		return nil
+	}
+	defaults := make(map[[2]string]string)
+	for _, kv := range opts.Defaults {
+		defaults[[2]string{kv.Section, kv.Key}] = defaultText(kv)
+	}
+	kvs := ParseKeyValues(lines, opts)
+	differs := make(map[[2]string]bool)
+	for _, kv := range kvs {
+		id := [2]string{kv.Section, kv.Key}
+		if text, ok := defaults[id]; !ok || text != defaultText(kv) {
+			differs[id] = true
+		}
+	}
+	var removed []KeyValue
+	for _, kv := range kvs {
+		if len(opts.OnlySections) > 0 && !sectionSelected(opts.OnlySections, kv.Section) {
+			continue
+		}
+		if !differs[[2]string{kv.Section, kv.Key}] {
+			removed = append(removed, kv)
+		}
+	}
+	return removed
+}
+
+// pruneDefaults drops the key/value lines that restate their default, with
+// their continuation lines, and then the sections that held keys before and
+// hold none after, as PruneEmptySections would. Comments above removed keys
+// stay unless their section goes.
+func pruneDefaults(lines []string, cfg Options) []string {
+	removed := DefaultLines(lines, cfg)
+	if len(removed) == 0 {
+		return lines
+	}
+	kinds := cfg.classifyLines(lines)
+	drop := make(map[int]bool)
+	emptied := make(map[string]bool)
+	for _, kv := range removed {
+		i := kv.Line - 1
+		drop[i] = true
+		for i+1 < len(lines) && kinds[i+1] == LineContinuation {
+			i++
+			drop[i] = true
+		}
+		emptied[kv.Section] = true
+	}
+	result := make([]string, 0, len(lines)-len(drop))
+	for i, line := range lines {
+		if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	return pruneSections(result, cfg, func(s *section) bool {
+		return emptied[s.name()] && isEmptySection(s, cfg)
+	})
+}
@@ -0,0 +1,99 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPruneDefaults(t *testing.T) {
+	defaults := ParseKeyValues([]string{
+		"debug = false",
+		"[server]",
+		"host = localhost",
+		`motd = "hello   world"`,
+		"port = 80",
+		"port = 8080",
+		"[log]",
+		"level = info ; default",
+		"verbose",
+	}, Options{})
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "restated keys removed",
+			in:   []string{"debug=false", "[server]", "host = example.com", "port = 8080", "extra = 1"},
+			want: []string{"[server]", "host  = example.com", "extra = 1"},
+		},
+		{
+			name: "values compared unquoted and normalized",
+			in:   []string{"[server]", "motd = 'hello world' ; greeting", "host = \"localhost\"", "port = 81"},
+			want: []string{"[server]", "port = 81"},
+		},
+		{
+			name: "sections left empty are pruned",
+			in:   []string{"[log]", "; from the defaults", "level = info", "verbose", "", "[server]", "port = 81"},
+			want: []string{"[server]", "port = 81"},
+		},
+		{
+			name: "sections already empty stay",
+			in:   []string{"[empty]", "", "[log]", "level = info"},
+			want: []string{"[empty]"},
+		},
+		{
+			name: "a repeated key goes only when every occurrence matches",
+			in:   []string{"[server]", "port = 81", "port = 8080", "host = localhost", "host = localhost"},
+			want: []string{"[server]", "port = 81", "port = 8080"},
+		},
+		{
+			name: "bare keys match bare defaults only",
+			in:   []string{"[log]", "verbose = x", "level = debug"},
+			want: []string{"[log]", "verbose = x", "level   = debug"},
+		},
+		{
+			name: "bare keys",
+			in:   []string{"[log]", "verbose", "level = debug"},
+			want: []string{"[log]", "level = debug"},
+		},
+		{
+			name: "only sections",
+			cfg:  Options{OnlySections: []string{"log"}},
+			in:   []string{"[server]", "host = localhost", "[log]", "level = info", "x = 1"},
+			want: []string{"[server]", "host = localhost", "[log]", "x = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Defaults = defaults
+			got, err := Lines(tt.in, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if _, err := Verify(tt.in, got, cfg); err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func TestDefaultLines(t *testing.T) {
+	cfg := Options{Defaults: ParseKeyValues([]string{"[a]", "x = 1", "y = 2"}, Options{})}
+	var got []string
+	for _, kv := range DefaultLines([]string{"[a]", "x = 1", "y = 3", "[b]", "x = 1"}, cfg) {
+		got = append(got, kv.Path())
+	}
+	if want := []string{"a.x"}; !slices.Equal(got, want) {
+		t.Errorf("DefaultLines() = %q, want %q", got, want)
+	}
+	if got := DefaultLines([]string{"[a]", "x = 1"}, Options{}); got != nil {
+		t.Errorf("DefaultLines() without defaults = %v, want none", got)
+	}
+}
@@ -0,0 +1,60 @@
+package format
+
+import "strings"
+
+// EqualsLookalikes are the look-alikes of '=' that CJK input methods and word
+// processors put in config files: the full-width, small, superscript and
+// subscript equals signs. Parsers do not accept them as delimiters.
+var EqualsLookalikes = []string{"＝", "﹦", "⁼", "₌"}
+
+// unicodeDelimiter returns the position and length of the Unicode equals sign
+// standing where a key/value line's delimiter belongs: before any '=' (with
+// SplitOn "last", only in lines without one). ok is false when there is none.
+func (c Options) unicodeDelimiter(line string) (idx, size int, ok bool) {
+	limit := strings.IndexByte(line, '=')
+	if limit != -1 && c.SplitOn == "last" {
+		return 0, 0, false
+	}
+	if limit == -1 {
+		limit = len(line)
+	}
+	idx = -1
+	for _, eq := range EqualsLookalikes {
+		if i := strings.Index(line[:limit], eq); i != -1 && (idx == -1 || i < idx) {
+			idx, size = i, len(eq)
+		}
+	}
+	return idx, size, idx != -1
+}
+
+// UnicodeDelimiterLines returns the 1-based numbers of the key/value lines
+// whose delimiter is a Unicode equals sign, which Options.UnicodeEquals
+// replaces with '='.
+func UnicodeDelimiterLines(lines []string, cfg Options) []int {
+	var numbers []int
+	for i, kind := range cfg.classifyLines(lines) {
+		if _, _, ok := cfg.unicodeDelimiter(lines[i]); ok && kind == LineKeyValue {
+			numbers = append(numbers, i+1)
+		}
+	}
+	return numbers
+}
+
+// NormalizeUnicodeDelimiters returns lines with the Unicode equals signs
+// UnicodeDelimiterLines reports replaced by '=' and every other byte kept.
+func NormalizeUnicodeDelimiters(lines []string, cfg Options) []string {
+	return normalizeDelimiters(lines, cfg)
+}
+
+// normalizeDelimiters replaces the Unicode equals sign delimiting a key/value
+// line with '=' so the line aligns like any other.
+func normalizeDelimiters(lines []string, cfg Options) []string {
+	result := make([]string, len(lines))
+	copy(result, lines)
+	for _, n := range UnicodeDelimiterLines(lines, cfg) {
+		line := result[n-1]
+		idx, size, _ := cfg.unicodeDelimiter(line)
+		result[n-1] = line[:idx] + "=" + line[idx+size:]
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package format
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnicodeDelimiterLines(t *testing.T) {
+	lines := []string{"[s]", "name＝demo", "url = a＝b", "; note＝x", "size ﹦ 10", "flag", "k⁼v＝w"}
+	if got, want := UnicodeDelimiterLines(lines, Options{}), []int{2, 5, 7}; !slices.Equal(got, want) {
+		t.Errorf("UnicodeDelimiterLines() = %v, want %v", got, want)
+	}
+	if got, want := UnicodeDelimiterLines([]string{"a＝b=c", "d＝e"}, Options{SplitOn: "last"}), []int{2}; !slices.Equal(got, want) {
+		t.Errorf("UnicodeDelimiterLines(split on last) = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeUnicodeDelimiters(t *testing.T) {
+	lines := []string{"[s]", "name＝demo", "url = a＝b", "; note＝x", "size  ﹦ 10"}
+	want := []string{"[s]", "name=demo", "url = a＝b", "; note＝x", "size  = 10"}
+	if got := NormalizeUnicodeDelimiters(lines, Options{}); !slices.Equal(got, want) {
+		t.Errorf("NormalizeUnicodeDelimiters() = %q, want %q", got, want)
+	}
+}
+
+func TestUnicodeEqualsFormatting(t *testing.T) {
+	lines := []string{"[s]", "name＝demo", "longer_key = x", "size﹦ 10 ; bytes", "url = a＝b", "; note＝x"}
+	want := []string{"[s]", "name       = demo", "longer_key = x", "size       = 10 ; bytes", "url        = a＝b", "; note＝x"}
+	got, err := Lines(lines, Options{UnicodeEquals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() =\n%q\nwant\n%q", got, want)
+	}
+	if again, _ := Lines(got, Options{UnicodeEquals: true}); !slices.Equal(again, got) {
+		t.Errorf("Lines() is not idempotent: %q", again)
+	}
+	if got, _ := Lines(lines, Options{}); got[1] != "name＝demo" {
+		t.Errorf("Lines() without UnicodeEquals changed %q to %q", lines[1], got[1])
+	}
+}
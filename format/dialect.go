@@ -0,0 +1,238 @@
+package format
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Dialect is a flavor of INI. The formatter asks Options.Dialect how to
+// classify, split and render lines, so a dialect, whether built in or
+// registered with RegisterDialect, gets alignment, sorting and the other
+// passes for free. Embed INIDialect to
+// change only some of its rules.
+type Dialect interface {
+	// Name is the name the dialect is selected by, such as "ini".
+	Name() string
+	// CommentPrefixes are the full-line comment prefixes used when
+	// Options.CommentPrefixes is nil.
+	CommentPrefixes() []string
+	// Classify reports what kind of line line is, given the lines before it.
+	Classify(line string, ctx LineContext, cfg Options) LineKind
+	// Cut splits a key/value line at its delimiter; ok is false for a bare key.
+	Cut(line string, cfg Options) (before, after string, ok bool)
+	// SplitHeader splits a header line into the header and any trailing text.
+	SplitHeader(line string) (header, rest string)
+	// FormatHeader renders a header line.
+	FormatHeader(line string, cfg Options) string
+	// FormatKeyValue renders a key/value line from its trimmed key, the
+	// padding that aligns it and its formatted value.
+	FormatKeyValue(key, padding, value string) string
+	// JoinContinuation appends a continuation line to the value it continues.
+	JoinContinuation(value, line string) string
+	// Continuation returns the marker ending a line whose value continues
+	// on the next line, "" when continuation lines are told by their
+	// indentation. ok is false when values cannot be wrapped onto
+	// continuation lines without changing them.
+	Continuation() (marker string, ok bool)
+}
+
+// LineKind is the kind of a line, as reported by Dialect.Classify.
+type LineKind int
+
+// Line kinds.
+const (
+	LineBlank        LineKind = iota
+	LineComment               // a full-line comment
+	LineHeader                // a [section] header
+	LineKeyValue              // a key/value line or a bare key
+	LineContinuation          // a line continuing the value above it
+	LineDirective             // a line kept verbatim, such as a version line
+)
+
+// LineContext is what Dialect.Classify knows about the lines before the one it
+// classifies.
+type LineContext struct {
+	Index    int      // 0-based index of the line, or -1 when unknown
+	Prev     string   // the previous line
+	PrevKind LineKind // the kind of the previous line; LineBlank for the first
+}
+
+// INIDialect is plain INI: ';' and '#' comments, [section] headers and
+// key = value lines. It is the dialect of Options with a nil Dialect.
+type INIDialect struct{}
+
+// Built-in dialects.
+var (
+	DialectINI Dialect = INIDialect{}
+	DialectReg Dialect = RegDialect{}
+)
+
+// Name returns "ini".
+func (INIDialect) Name() string { return "ini" }
+
+// CommentPrefixes returns DefaultCommentPrefixes.
+func (INIDialect) CommentPrefixes() []string { return DefaultCommentPrefixes }
+
+// Classify tells blank lines, comments, headers and key/value lines apart.
+func (INIDialect) Classify(line string, _ LineContext, cfg Options) LineKind {
+	switch {
+	case IsBlank(line):
+		return LineBlank
+	case cfg.IsComment(line):
+		return LineComment
+	case IsHeader(line):
+		return LineHeader
+	}
+	return LineKeyValue
+}
+
+// Cut splits line at the first '=', or the last one when cfg.SplitOn is "last".
+func (INIDialect) Cut(line string, cfg Options) (before, after string, ok bool) {
+	if cfg.SplitOn == "last" {
+		if idx := strings.LastIndex(line, "="); idx != -1 {
+			return line[:idx], line[idx+1:], true
+		}
+		return line, "", false
+	}
+	return strings.Cut(line, "=")
+}
+
+// SplitHeader splits line after the first ']' and the bracketed segments
+// directly following it, so [Group][SubGroup] is one header.
+func (INIDialect) SplitHeader(line string) (header, rest string) {
+	end := headerEnd(line)
+	return line[:end], line[end:]
+}
+
+// FormatHeader trims a header line and puts exactly one space between the
+// header and a trailing comment marker and between the marker and its text.
+// Other trailing text is kept verbatim after a single space.
+func (d INIDialect) FormatHeader(line string, _ Options) string {
+	t := tokenizer{}
+	t.addHeader(d.SplitHeader(line))
+	var b strings.Builder
+	for _, tok := range t.tokens {
+		switch tok.Kind {
+		case TokenSectionName, TokenCommentText:
+			b.WriteString(tok.Text)
+		case TokenCommentMarker:
+			b.WriteString(" " + tok.Text + " ")
+		case TokenText:
+			b.WriteString(" " + tok.Text)
+		}
+	}
+	return b.String()
+}
+
+// FormatKeyValue returns "key = value" with the padding after the key.
+func (INIDialect) FormatKeyValue(key, padding, value string) string {
+	return key + padding + " = " + value
+}
+
+// JoinContinuation joins line to value with a newline; plain INI has no
+// continuation lines of its own.
+func (INIDialect) JoinContinuation(value, line string) string {
+	return value + "\n" + line
+}
+
+// Continuation reports that plain INI has no continuation lines to wrap
+// values onto.
+func (INIDialect) Continuation() (string, bool) { return "", false }
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{}
+)
+
+func init() {
+	for _, d := range []Dialect{DialectINI, DialectReg, DialectGitConfig, DialectDesktop, DialectSystemd,
+		DialectPyCfg, DialectEnv, DialectProperties, DialectMyCnf, DialectKDE,
+		DialectSmb, DialectHgrc, DialectSupervisord} {
+		RegisterDialect(d)
+	}
+}
+
+// RegisterDialect makes d available by its name to LookupDialect. It panics
+// if a dialect of the same name is already registered.
+func RegisterDialect(d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if _, dup := dialects[d.Name()]; dup {
+		panic(fmt.Sprintf("format: RegisterDialect called twice for dialect %q", d.Name()))
+	}
+	dialects[d.Name()] = d
+}
+
+// LookupDialect returns the registered dialect called name.
+func LookupDialect(name string) (Dialect, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	d, ok := dialects[name]
+	return d, ok
+}
+
+// DialectNames returns the names of the registered dialects, sorted.
+func DialectNames() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// dialect returns the dialect of c, plain INI when none is set.
+func (c Options) dialect() Dialect {
+	if c.Dialect == nil {
+		return DialectINI
+	}
+	return c.Dialect
+}
+
+// classifyLines classifies every line with the dialect of c.
+func (c Options) classifyLines(lines []string) []LineKind {
+	d := c.dialect()
+	kinds := make([]LineKind, len(lines))
+	ctx := LineContext{}
+	for i, line := range lines {
+		ctx.Index = i
+		kinds[i] = d.Classify(line, ctx, c)
+		ctx.Prev, ctx.PrevKind = line, kinds[i]
+	}
+	return kinds
+}
+
+// joinContinuations joins every continuation line to the line it continues,
+// separated by a newline, so that the passes move and align a multi-line
+// value as one line. splitContinuations undoes it.
+func (c Options) joinContinuations(lines []string) ([]string, bool) {
+	kinds := c.classifyLines(lines)
+	if !slices.Contains(kinds, LineContinuation) {
+		return lines, false
+	}
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if kinds[i] == LineContinuation && len(result) > 0 {
+			result[len(result)-1] += "\n" + line
+		} else {
+			result = append(result, line)
+		}
+	}
+	return result, true
+}
+
+// splitContinuations splits lines joined by joinContinuations, trimming the
+// whitespace that formatting left before a line break.
+func splitContinuations(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		for part := range strings.SplitSeq(line, "\n") {
+			result = append(result, strings.TrimRight(part, " \t"))
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// colonDialect is a custom dialect for the tests: "key: value" lines, '//'
+// comments and '%' directives.
+type colonDialect struct{ INIDialect }
+
+func (colonDialect) Name() string              { return "colon-test" }
+func (colonDialect) CommentPrefixes() []string { return []string{"//"} }
+
+func (d colonDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	if strings.HasPrefix(line, "%") {
+		return LineDirective
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+func (colonDialect) Cut(line string, _ Options) (before, after string, ok bool) {
+	return strings.Cut(line, ":")
+}
+
+func (colonDialect) FormatKeyValue(key, padding, value string) string {
+	return key + ":" + padding + " " + value
+}
+
+func TestCustomDialect(t *testing.T) {
+	RegisterDialect(colonDialect{})
+	d, ok := LookupDialect("colon-test")
+	if !ok || !slices.Contains(DialectNames(), "colon-test") {
+		t.Fatal("registered dialect not found")
+	}
+	lines := []string{
+		"%include base.conf",
+		"[server]",
+		"port:80",
+		"// a comment: with a colon",
+		"hostname :   example.com",
+		"# not a comment",
+	}
+	want := []string{
+		"%include base.conf",
+		"[server]",
+		"# not a comment",
+		"// a comment: with a colon",
+		"hostname: example.com",
+		"port:     80",
+	}
+	cfg := Options{Dialect: d, SortKeys: []string{"*"}}
+	got, err := Lines(lines, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines(colon) =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var keys []string
+	for _, kv := range ParseKeyValues(lines, cfg) {
+		keys = append(keys, kv.Path()+"="+kv.Value)
+	}
+	if wantKeys := []string{"server.port=80", "server.hostname=example.com", "server.# not a comment="}; !slices.Equal(keys, wantKeys) {
+		t.Errorf("ParseKeyValues(colon) = %q, want %q", keys, wantKeys)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a dialect twice did not panic")
+		}
+	}()
+	RegisterDialect(colonDialect{})
+}
+
+func TestDefaultDialectIsINI(t *testing.T) {
+	sample, err := os.ReadFile("../test.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, _ := SplitLines(string(sample))
+	for mode := range uint16(1 << 12) {
+		cfg := fuzzConfig(mode)
+		explicit := cfg
+		explicit.Dialect = DialectINI
+		a, errA := Lines(lines, cfg)
+		b, errB := Lines(lines, explicit)
+		if (errA == nil) != (errB == nil) || !slices.Equal(a, b) {
+			t.Fatalf("mode %#x: Dialect nil and DialectINI differ", mode)
+		}
+	}
+}
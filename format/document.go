@@ -0,0 +1,688 @@
+package format
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// section is a header line and the body lines that follow it. The first
+// section of a document is the preamble, which has no header.
+type section struct {
+	header string
+	lines  []string
+}
+
+// name returns the section name between the brackets of the header.
+func (s *section) name() string {
+	return HeaderName(s.header)
+}
+
+// entry is a line that participates in key ordering together with the
+// comment lines directly above it, which travel with it when reordered.
+type entry struct {
+	comments []string
+	line     string
+	key      string
+}
+
+// IsBlank reports whether line contains only whitespace.
+func IsBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// DefaultCommentPrefixes start full-line comments unless Options.CommentPrefixes
+// says otherwise.
+var DefaultCommentPrefixes = []string{";", "#"}
+
+// commentPrefix returns the prefix that makes line a full-line comment. Only
+// the start of the trimmed line counts, so "path = C://thing" is never a
+// comment, and a prefix ending in a letter or digit, such as REM, must be
+// followed by whitespace or the end of the line.
+func (c Options) commentPrefix(line string) (string, bool) {
+	prefixes := c.CommentPrefixes
+	if prefixes == nil {
+		prefixes = c.dialect().CommentPrefixes()
+	}
+	trimmed := strings.TrimSpace(line)
+	for _, p := range prefixes {
+		if p == "" || !strings.HasPrefix(trimmed, p) {
+			continue
+		}
+		last, _ := utf8.DecodeLastRuneInString(p)
+		next, _ := utf8.DecodeRuneInString(trimmed[len(p):])
+		if (unicode.IsLetter(last) || unicode.IsDigit(last)) && len(trimmed) > len(p) && !unicode.IsSpace(next) {
+			continue
+		}
+		return p, true
+	}
+	return "", false
+}
+
+// IsComment reports whether line is a full-line comment.
+func (c Options) IsComment(line string) bool {
+	_, ok := c.commentPrefix(line)
+	return ok
+}
+
+// CommentText returns the text of a full-line comment without its prefix,
+// repeated prefixes such as ";;" included.
+func (c Options) CommentText(line string) string {
+	p, _ := c.commentPrefix(line)
+	text := strings.TrimSpace(line)
+	for p != "" && strings.HasPrefix(text, p) {
+		text = text[len(p):]
+	}
+	return strings.TrimSpace(text)
+}
+
+// IsHeader reports whether line is a [section] header.
+func IsHeader(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "[") && strings.Contains(trimmed, "]")
+}
+
+// HeaderName returns the text between the brackets of a header line. The
+// name of a nested group header such as [Group][SubGroup], as KDE writes them,
+// runs from the first bracket to the last: "Group][SubGroup".
+func HeaderName(header string) string {
+	trimmed := strings.TrimSpace(header)
+	if end := headerEnd(trimmed); strings.HasPrefix(trimmed, "[") && end > 0 {
+		return strings.TrimSpace(trimmed[1 : end-1])
+	}
+	return ""
+}
+
+// headerEnd returns the index just past the ']' closing the header at the
+// start of line, taking in the bracketed segments that directly follow it, or
+// 0 when there is no ']'.
+func headerEnd(line string) int {
+	end := strings.Index(line, "]") + 1
+	if end == 0 {
+		return 0
+	}
+	for end < len(line) && line[end] == '[' {
+		next := strings.Index(line[end:], "]")
+		if next == -1 {
+			break
+		}
+		end += next + 1
+	}
+	return end
+}
+
+// Cut splits line at its key/value delimiter as the dialect defines it: by
+// default the first '=', or the last one when SplitOn is "last". Every pass
+// uses it so they agree on the key. With Operators, a line is cut at the '='
+// of its assignment operator and before keeps the rest of it, as in "k +".
+func (c Options) Cut(line string) (before, after string, ok bool) {
+	before, after, ok = c.dialect().Cut(line, c)
+	if ok && len(c.Operators) > 0 {
+		before, after = c.cutOperator(line, before, after)
+	}
+	return before, after, ok
+}
+
+// lineKey returns the key of a key/value line, or the trimmed line for a bare key.
+func (c Options) lineKey(line string) string {
+	if before, _, ok := c.Cut(line); ok {
+		key, _ := c.splitOperator(before)
+		return strings.TrimSpace(key)
+	}
+	return strings.TrimSpace(line)
+}
+
+// InlineCommentIndex returns the index of the ';' or '#' starting an inline
+// comment in value, or -1. A comment marker must follow whitespace and lie
+// outside quotes, in which backslash escapes the quote, so a value that starts
+// with '#', such as a color, is not a comment.
+func InlineCommentIndex(value string) int {
+	var quote byte
+	seen := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == ' ' || c == '\t':
+			continue
+		case c == '"' || c == '\'':
+			quote = c
+		case (c == ';' || c == '#') && seen && (value[i-1] == ' ' || value[i-1] == '\t'):
+			return i
+		}
+		seen = true
+	}
+	return -1
+}
+
+// SplitInlineComment splits a value from its trailing inline comment. Both
+// parts are trimmed and the comment is returned without its marker.
+func SplitInlineComment(value string) (string, string) {
+	if idx := InlineCommentIndex(value); idx != -1 {
+		return strings.TrimSpace(value[:idx]), strings.TrimSpace(value[idx+1:])
+	}
+	return strings.TrimSpace(value), ""
+}
+
+// splitSections splits lines into the preamble followed by one section per header.
+func splitSections(lines []string) []*section {
+	sections := []*section{{}}
+	for _, line := range lines {
+		if IsHeader(line) {
+			sections = append(sections, &section{header: line})
+			continue
+		}
+		cur := sections[len(sections)-1]
+		cur.lines = append(cur.lines, line)
+	}
+	return sections
+}
+
+// joinSections flattens sections back into lines.
+func joinSections(sections []*section) []string {
+	var lines []string
+	for _, s := range sections {
+		if s.header != "" {
+			lines = append(lines, s.header)
+		}
+		lines = append(lines, s.lines...)
+	}
+	return lines
+}
+
+// preambleName addresses the keys before the first section header where
+// options take section names.
+const preambleName = "@preamble"
+
+// sectionSelected reports whether the section name matches one of patterns,
+// which are exact names or path.Match globs. The preamble, named "", is
+// selected by "*" and by preambleName.
+func sectionSelected(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if name == "" && p == preambleName {
+			return true
+		}
+		if ok, _ := path.Match(p, name); ok || p == name || p == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// restructure applies the structural passes selected in cfg: comment stripping,
+// removal of repeated lines, empty values, defaults and empty sections,
+// nesting of dotted keys, duplicate-key resolution, key and section sorting,
+// and blank-line handling.
+func restructure(lines []string, cfg Options) []string {
+	if cfg.StripComments {
+		lines = stripComments(lines, cfg)
+	}
+	if cfg.Unique {
+		lines = uniqueLines(lines, cfg)
+	}
+	if cfg.RemoveEmptyValues {
+		lines = removeEmptyValues(lines, cfg)
+	}
+	if len(cfg.Defaults) > 0 {
+		lines = pruneDefaults(lines, cfg)
+	}
+	if cfg.PruneEmptySections {
+		lines = pruneEmptySections(lines, cfg)
+	}
+	if cfg.Nest != 0 || cfg.DefaultSection != "" || cfg.DedupeKeys != "" || len(cfg.SortKeys) > 0 || cfg.SortSections {
+		sections := splitSections(lines)
+		if cfg.Nest != 0 {
+			sections = nestKeys(sections, cfg.Nest, cfg)
+		}
+		if cfg.DefaultSection != "" {
+			sections = moveToDefaultSection(sections, cfg.DefaultSection, cfg)
+		}
+		for _, s := range sections {
+			if cfg.canceled() != nil {
+				return lines // the caller reports it
+			}
+			if cfg.DedupeKeys != "" {
+				s.lines = dedupeKeys(s.lines, cfg)
+			}
+			if sectionSelected(cfg.SortKeys, s.name()) {
+				if cfg.GroupByPrefix {
+					s.lines = sortBetweenPinned(s.lines, cfg, groupByPrefix)
+				} else {
+					s.lines = sortBetweenPinned(s.lines, cfg, sortKeys)
+				}
+			}
+		}
+		if cfg.SortSections && !KeepsSectionOrder(cfg.dialect()) {
+			sortSections(sections, cfg.PinnedSections, cfg.compareFunc())
+		}
+		lines = joinSections(sections)
+	}
+	switch cfg.BlankLines {
+	case "squeeze":
+		lines = squeezeBlankLines(lines)
+	case "sections":
+		lines = sectionBlankLines(lines)
+	}
+	return lines
+}
+
+// moveToDefaultSection moves the keys of the preamble, with the comments
+// attached to them, into the section called name: to the top of its body when
+// it exists, or into a new section inserted after the preamble. Comments
+// standing on their own stay in the preamble, as does everything up to its
+// last directive. Blank-line-delimited blocks of keys stay separate blocks.
+func moveToDefaultSection(sections []*section, name string, cfg Options) []*section {
+	fixed, rest := afterLastDirective(sections[0].lines, cfg)
+	blocks, trailing, _ := splitEntries(rest, cfg)
+	var moved, kept []string
+	for i, block := range blocks {
+		if len(block) > 0 {
+			if len(moved) > 0 {
+				moved = append(moved, "")
+			}
+			for _, e := range block {
+				moved = append(moved, e.comments...)
+				moved = append(moved, e.line)
+			}
+		}
+		if len(trailing[i]) > 0 {
+			if len(kept) > 0 {
+				kept = append(kept, "")
+			}
+			kept = append(kept, trailing[i]...)
+		}
+	}
+	if len(moved) == 0 {
+		return sections
+	}
+	if len(kept) > 0 || len(fixed) > 0 {
+		kept = append(kept, "")
+	}
+	sections[0].lines = append(fixed, kept...)
+
+	for _, s := range sections[1:] {
+		if s.name() == name {
+			if len(s.lines) > 0 && !IsBlank(s.lines[0]) {
+				moved = append(moved, "")
+			}
+			s.lines = append(moved, s.lines...)
+			return sections
+		}
+	}
+	if len(sections) > 1 {
+		moved = append(moved, "")
+	}
+	return slices.Insert(sections, 1, &section{header: "[" + name + "]", lines: moved})
+}
+
+// afterLastDirective splits a preamble after its last directive, such as
+// %include in an hgrc, so that the keys before it stay where they are: moving
+// them below it would change what it overrides. fixed is empty when the
+// preamble has no directive.
+func afterLastDirective(lines []string, cfg Options) (fixed, rest []string) {
+	kinds := cfg.classifyLines(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if kinds[i] == LineDirective {
+			return slices.Clip(lines[:i+1]), lines[i+1:]
+		}
+	}
+	return nil, lines
+}
+
+// stripComments removes full-line comments and trailing text after section headers.
+func stripComments(lines []string, cfg Options) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		switch {
+		case cfg.IsComment(line):
+			continue
+		case IsHeader(line):
+			header, _ := cfg.dialect().SplitHeader(line)
+			result = append(result, strings.TrimSpace(header))
+		default:
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// splitEntries groups body lines into blocks separated by blank lines. Each
+// block holds its entries and any comments trailing the last entry.
+func splitEntries(body []string, cfg Options) (blocks [][]entry, trailing [][]string, blanks []string) {
+	var cur []entry
+	var pending []string
+	flush := func() {
+		blocks = append(blocks, cur)
+		trailing = append(trailing, pending)
+		cur, pending = nil, nil
+	}
+	for _, line := range body {
+		switch {
+		case IsBlank(line):
+			flush()
+			blanks = append(blanks, line)
+		case cfg.IsComment(line):
+			pending = append(pending, line)
+		default:
+			cur = append(cur, entry{comments: pending, line: line, key: cfg.lineKey(line)})
+			pending = nil
+		}
+	}
+	flush()
+	return blocks, trailing, blanks
+}
+
+// pinnedLines reports which lines of body stay where they are when keys are
+// sorted: directives, such as !include in my.cnf, and the keys the dialect
+// pins, such as include in smb.conf, whose position matters.
+func pinnedLines(body []string, cfg Options) []bool {
+	d := cfg.dialect()
+	pinned := make([]bool, len(body))
+	for i, kind := range cfg.classifyLines(body) {
+		pinned[i] = kind == LineDirective || kind == LineKeyValue && pinsKey(d, cfg.lineKey(body[i]))
+	}
+	return pinned
+}
+
+// sortBetweenPinned applies sort to each run of body lines between pinned
+// lines, which keep their place.
+func sortBetweenPinned(body []string, cfg Options, sort func([]string, Options) []string) []string {
+	pinned := pinnedLines(body, cfg)
+	if !slices.Contains(pinned, true) {
+		return sort(body, cfg)
+	}
+	result := make([]string, 0, len(body))
+	start := 0
+	for i, line := range body {
+		if pinned[i] {
+			result = append(result, sort(slices.Clip(body[start:i]), cfg)...)
+			result = append(result, line)
+			start = i + 1
+		}
+	}
+	return append(result, sort(slices.Clip(body[start:]), cfg)...)
+}
+
+// sortKeys sorts the entries of each blank-line-delimited block by key, in the
+// order of cfg.Collate. Comments
+// directly above a key move with it; blank lines stay where they are.
+func sortKeys(body []string, cfg Options) []string {
+	blocks, trailing, blanks := splitEntries(body, cfg)
+	result := make([]string, 0, len(body))
+	compare := cfg.compareFunc()
+	for i, block := range blocks {
+		slices.SortStableFunc(block, func(a, b entry) int {
+			return compare(a.key, b.key)
+		})
+		for _, e := range block {
+			result = append(result, e.comments...)
+			result = append(result, e.line)
+		}
+		result = append(result, trailing[i]...)
+		if i < len(blanks) {
+			result = append(result, blanks[i])
+		}
+	}
+	return result
+}
+
+// groupByPrefix sorts all entries of a section body by key, ignoring the
+// blank lines between them, and puts one blank line between runs of keys
+// whose prefix up to the first of cfg.GroupSeparators differs. Consecutive
+// keys that share their prefix with no neighbour stay together instead of
+// each standing alone. Comments trailing a block move to the end of the body;
+// the blank lines ending the body stay.
+func groupByPrefix(body []string, cfg Options) []string {
+	end := len(body)
+	for end > 0 && IsBlank(body[end-1]) {
+		end--
+	}
+	blocks, trailing, _ := splitEntries(body[:end], cfg)
+	var entries []entry
+	var comments []string
+	for i, block := range blocks {
+		entries = append(entries, block...)
+		comments = append(comments, trailing[i]...)
+	}
+	compare := cfg.compareFunc()
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return compare(a.key, b.key)
+	})
+
+	separators := cfg.GroupSeparators
+	if separators == "" {
+		separators = DefaultGroupSeparators
+	}
+	prefix := func(key string) string {
+		if i := strings.IndexAny(key, separators); i >= 0 {
+			return key[:i]
+		}
+		return key
+	}
+	var groups [][]entry
+	for _, e := range entries {
+		if n := len(groups); n > 0 && prefix(groups[n-1][0].key) == prefix(e.key) {
+			groups[n-1] = append(groups[n-1], e)
+			continue
+		}
+		groups = append(groups, []entry{e})
+	}
+
+	result := make([]string, 0, len(body)+len(groups))
+	for i, group := range groups {
+		if i > 0 && (len(group) > 1 || len(groups[i-1]) > 1) {
+			result = append(result, "")
+		}
+		for _, e := range group {
+			result = append(result, e.comments...)
+			result = append(result, e.line)
+		}
+	}
+	if len(comments) > 0 {
+		if len(result) > 0 {
+			result = append(result, "")
+		}
+		result = append(result, comments...)
+	}
+	return append(result, body[end:]...)
+}
+
+// dedupeKeys removes repeated key/value lines within a section body, keeping
+// either the first or the last occurrence (per cfg.DedupeKeys) together with
+// its comments.
+func dedupeKeys(body []string, cfg Options) []string {
+	keepLast := cfg.DedupeKeys == "last"
+	// Locate each key/value line and the start of the comments attached to it.
+	type span struct {
+		start, end int
+		key        string
+	}
+	var spans []span
+	kinds := cfg.classifyLines(body)
+	commentStart := -1
+	for i, line := range body {
+		switch {
+		case IsBlank(line):
+			commentStart = -1
+		case cfg.IsComment(line):
+			if commentStart == -1 {
+				commentStart = i
+			}
+		default:
+			start := i
+			if commentStart != -1 {
+				start = commentStart
+			}
+			if _, _, ok := cfg.Cut(line); ok && kinds[i] == LineKeyValue && !pinsKey(cfg.dialect(), cfg.lineKey(line)) {
+				spans = append(spans, span{start: start, end: i, key: cfg.lineKey(line)})
+			}
+			commentStart = -1
+		}
+	}
+
+	keep := make(map[string]int)
+	for i, sp := range spans {
+		if _, seen := keep[sp.key]; !seen || keepLast {
+			keep[sp.key] = i
+		}
+	}
+	drop := make([]bool, len(body))
+	for i, sp := range spans {
+		if keep[sp.key] != i {
+			for j := sp.start; j <= sp.end; j++ {
+				drop[j] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(body))
+	for i, line := range body {
+		if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// sortSections sorts all sections after the preamble by name with compare,
+// except that sections named in pinned (case-insensitively) come first, in
+// pinned order.
+// The blank lines trailing each section stay at their position so the file's
+// spacing is kept.
+func sortSections(sections []*section, pinned []string, compare func(a, b string) int) {
+	if len(sections) < 3 {
+		return
+	}
+	named := sections[1:]
+	gaps := make([][]string, len(named))
+	for i, s := range named {
+		end := len(s.lines)
+		for end > 0 && IsBlank(s.lines[end-1]) {
+			end--
+		}
+		gaps[i] = s.lines[end:]
+		s.lines = s.lines[:end]
+	}
+	rank := func(s *section) int {
+		for i, name := range pinned {
+			if strings.EqualFold(s.name(), name) {
+				return i
+			}
+		}
+		return len(pinned)
+	}
+	slices.SortStableFunc(named, func(a, b *section) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+			return c
+		}
+		return compare(a.name(), b.name())
+	})
+	for i, s := range named {
+		s.lines = append(s.lines, gaps[i]...)
+	}
+}
+
+// squeezeBlankLines collapses runs of blank lines into one and drops blank
+// lines at the start and end of the file.
+func squeezeBlankLines(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if IsBlank(line) && (len(result) == 0 || result[len(result)-1] == "") {
+			continue
+		}
+		if IsBlank(line) {
+			line = ""
+		}
+		result = append(result, line)
+	}
+	for len(result) > 0 && result[len(result)-1] == "" {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// sectionBlankLines removes all blank lines and puts exactly one blank line
+// before every section header that is not at the start of the file.
+func sectionBlankLines(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if IsBlank(line) {
+			continue
+		}
+		if IsHeader(line) && len(result) > 0 {
+			result = append(result, "")
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// KeyValue is a key line as seen by the parser: a key/value pair, or a bare
+// key when the line has no delimiter.
+type KeyValue struct {
+	Section  string
+	Key      string
+	Value    string
+	HasValue bool
+	Line     int // 1-based line number
+}
+
+// Path returns the key addressed as section.key, or just key in the preamble.
+func (kv KeyValue) Path() string {
+	if kv.Section == "" {
+		return kv.Key
+	}
+	return kv.Section + "." + kv.Key
+}
+
+// ParseKeyValues returns every key line in file order, classified with the same
+// rules the formatter uses. Duplicate keys appear once per occurrence.
+// Directives, such as the version line of a .reg file, are not keys, and
+// continuation lines are joined to the value they continue. It walks lines
+// as Walk does.
+func ParseKeyValues(lines []string, cfg Options) []KeyValue {
+	var kvs []KeyValue
+	w := newWalker(cfg, Visitor{})
+	w.onKey = func(kv KeyValue) error {
+		kvs = append(kvs, kv)
+		return nil
+	}
+	for _, line := range lines {
+		w.line(line)
+	}
+	w.end()
+	return kvs
+}
+
+// SectionNames returns the name of every section header in file order.
+func SectionNames(lines []string) []string {
+	var names []string
+	for _, line := range lines {
+		if IsHeader(line) {
+			names = append(names, HeaderName(line))
+		}
+	}
+	return names
+}
+
+// FindKey resolves a section.key path against kvs. Section names may contain
+// dots, so every split point is tried. When a key occurs more than once the last
+// occurrence wins, as it does for most INI parsers.
+func FindKey(kvs []KeyValue, path string) (KeyValue, bool) {
+	var found KeyValue
+	ok := false
+	for _, kv := range kvs {
+		if kv.Path() == path {
+			found, ok = kv, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,248 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSortKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		body []string
+		want []string
+	}{
+		{
+			name: "comments travel with keys",
+			body: []string{"; about b", "b = 2", "a = 1"},
+			want: []string{"a = 1", "; about b", "b = 2"},
+		},
+		{
+			name: "blocks sorted independently",
+			body: []string{"d = 4", "c = 3", "", "b = 2", "a = 1", "; trailing"},
+			want: []string{"c = 3", "d = 4", "", "a = 1", "b = 2", "; trailing"},
+		},
+		{
+			name: "stable for equal keys",
+			body: []string{"k = 2", "a = 0", "k = 1"},
+			want: []string{"a = 0", "k = 2", "k = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sortKeys(tt.body, Options{}); !slices.Equal(got, tt.want) {
+				t.Errorf("sortKeys() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Options
+		body []string
+		want []string
+	}{
+		{
+			name: "clusters by prefix",
+			body: []string{"db_user = u", "cache.size = 1", "", "db_host = h", "name = x", "", "db_port = 5", "cache.ttl = 2"},
+			want: []string{"cache.size = 1", "cache.ttl = 2", "", "db_host = h", "db_port = 5", "db_user = u", "", "name = x"},
+		},
+		{
+			name: "lone keys stay together",
+			body: []string{"b = 2", "log_file = f", "a = 1", "log_level = l", "z = 9", "c = 3"},
+			want: []string{"a = 1", "b = 2", "c = 3", "", "log_file = f", "log_level = l", "", "z = 9"},
+		},
+		{
+			name: "comments and trailing blanks",
+			body: []string{"; the port", "db_port = 5", "; loose", "", "db_host = h", "x = 1", "", ""},
+			want: []string{"db_host = h", "; the port", "db_port = 5", "", "x = 1", "", "; loose", "", ""},
+		},
+		{
+			name: "custom separators",
+			cfg:  Options{GroupSeparators: "-"},
+			body: []string{"db_host = h", "db-port = 5", "db-user = u"},
+			want: []string{"db-port = 5", "db-user = u", "", "db_host = h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupByPrefix(tt.body, tt.cfg)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("groupByPrefix() = %q, want %q", got, tt.want)
+			}
+			if again := groupByPrefix(got, tt.cfg); !slices.Equal(again, got) {
+				t.Errorf("groupByPrefix() is not idempotent: %q, then %q", got, again)
+			}
+		})
+	}
+}
+
+func TestDedupeKeys(t *testing.T) {
+	body := []string{"; first host", "host = a", "port = 1", "; second host", "host = b"}
+	if got, want := dedupeKeys(body, Options{DedupeKeys: "first"}), []string{"; first host", "host = a", "port = 1"}; !slices.Equal(got, want) {
+		t.Errorf("dedupeKeys(keep first) = %q, want %q", got, want)
+	}
+	if got, want := dedupeKeys(body, Options{DedupeKeys: "last"}), []string{"port = 1", "; second host", "host = b"}; !slices.Equal(got, want) {
+		t.Errorf("dedupeKeys(keep last) = %q, want %q", got, want)
+	}
+}
+
+func TestSortSectionsKeepsGaps(t *testing.T) {
+	lines := []string{"top = 1", "", "[b]", "x = 1", "", "", "[a]", "y = 2"}
+	sections := splitSections(lines)
+	sortSections(sections, nil, strings.Compare)
+	want := []string{"top = 1", "", "[a]", "y = 2", "", "", "[b]", "x = 1"}
+	if got := joinSections(sections); !slices.Equal(got, want) {
+		t.Errorf("sortSections() = %q, want %q", got, want)
+	}
+}
+
+func TestBlankLineModes(t *testing.T) {
+	lines := []string{"", "a = 1", "", "", "b = 2", "[s]", "", "c = 3", "  ", ""}
+	if got, want := squeezeBlankLines(lines), []string{"a = 1", "", "b = 2", "[s]", "", "c = 3"}; !slices.Equal(got, want) {
+		t.Errorf("squeezeBlankLines() = %q, want %q", got, want)
+	}
+	if got, want := sectionBlankLines(lines), []string{"a = 1", "b = 2", "", "[s]", "c = 3"}; !slices.Equal(got, want) {
+		t.Errorf("sectionBlankLines() = %q, want %q", got, want)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	lines := []string{"; top", "[s] ; note", "# hash", "k = v"}
+	if got, want := stripComments(lines, Options{}), []string{"[s]", "k = v"}; !slices.Equal(got, want) {
+		t.Errorf("stripComments() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitInlineComment(t *testing.T) {
+	tests := []struct {
+		value, wantValue, wantComment string
+	}{
+		{" 80 ; port", "80", "port"},
+		{" 80 # port", "80", "port"},
+		{" a;b", "a;b", ""},
+		{` "a ; b" ; c`, `"a ; b"`, "c"},
+		{` "a \" ; b" ; c`, `"a \" ; b"`, "c"},
+		{" #fff", "#fff", ""},
+	}
+	for _, tt := range tests {
+		value, comment := SplitInlineComment(tt.value)
+		if value != tt.wantValue || comment != tt.wantComment {
+			t.Errorf("SplitInlineComment(%q) = %q, %q; want %q, %q", tt.value, value, comment, tt.wantValue, tt.wantComment)
+		}
+	}
+}
+
+func TestSortKeysSelectedSections(t *testing.T) {
+	lines := []string{"z = 1", "a = 2", "[aliases]", "b = 1", "a = 2", "[hosts.eu]", "y = 1", "x = 2", "[pipeline]", "step2 = b", "step1 = a"}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "exact and glob",
+			patterns: []string{"aliases", "hosts*"},
+			want:     []string{"z = 1", "a = 2", "[aliases]", "a = 2", "b = 1", "[hosts.eu]", "x = 2", "y = 1", "[pipeline]", "step2 = b", "step1 = a"},
+		},
+		{
+			name:     "everything",
+			patterns: []string{"*"},
+			want:     []string{"a = 2", "z = 1", "[aliases]", "a = 2", "b = 1", "[hosts.eu]", "x = 2", "y = 1", "[pipeline]", "step1 = a", "step2 = b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restructure(slices.Clone(lines), Options{SortKeys: tt.patterns}); !slices.Equal(got, tt.want) {
+				t.Errorf("restructure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortSectionsPinned(t *testing.T) {
+	lines := []string{"; preamble", "[zeta]", "[general]", "[alpha]", "[default]"}
+	tests := []struct {
+		name   string
+		pinned []string
+		want   []string
+	}{
+		{"DEFAULT first", []string{"DEFAULT"}, []string{"; preamble", "[default]", "[alpha]", "[general]", "[zeta]"}},
+		{"head group in order", []string{"DEFAULT", "general"}, []string{"; preamble", "[default]", "[general]", "[alpha]", "[zeta]"}},
+		{"nothing pinned", nil, []string{"; preamble", "[alpha]", "[default]", "[general]", "[zeta]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := splitSections(lines)
+			sortSections(sections, tt.pinned, strings.Compare)
+			if got := joinSections(sections); !slices.Equal(got, tt.want) {
+				t.Errorf("sortSections() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultSection(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "new section at the top",
+			lines: []string{"; file header", "", "; about debug", "debug = true", "level = 2", "", "", "[server]", "port = 80"},
+			want:  []string{"; file header", "", "[general]", "; about debug", "debug = true", "level = 2", "", "[server]", "port = 80"},
+		},
+		{
+			name:  "merged into an existing section",
+			lines: []string{"debug = true", "[server]", "port = 80", "[general]", "name = x"},
+			want:  []string{"[server]", "port = 80", "[general]", "debug = true", "", "name = x"},
+		},
+		{
+			name:  "nothing to move",
+			lines: []string{"; only a comment", "", "[server]", "port = 80"},
+			want:  []string{"; only a comment", "", "[server]", "port = 80"},
+		},
+		{
+			name:  "only keys",
+			lines: []string{"a = 1"},
+			want:  []string{"[general]", "a = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restructure(tt.lines, Options{DefaultSection: "general"}); !slices.Equal(got, tt.want) {
+				t.Errorf("restructure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentPrefixes(t *testing.T) {
+	cfg := Options{CommentPrefixes: []string{"//", ";", "REM"}}
+	tests := []struct {
+		line     string
+		want     bool
+		wantText string
+	}{
+		{"// note", true, "note"},
+		{"  ;; twice", true, "twice"},
+		{"REM old tooling", true, "old tooling"},
+		{"REM", true, ""},
+		{"REMOTE = 1", false, ""},
+		{"path = C://thing", false, ""},
+		{"# not configured", false, ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsComment(tt.line); got != tt.want {
+			t.Errorf("isComment(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+		if tt.want {
+			if got := cfg.CommentText(tt.line); got != tt.wantText {
+				t.Errorf("commentText(%q) = %q, want %q", tt.line, got, tt.wantText)
+			}
+		}
+	}
+}
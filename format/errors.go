@@ -0,0 +1,125 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The errors of this package fall into five kinds, told apart with
+// errors.As and errors.Is:
+//
+//   - *ParseError: the input cannot be formatted or decoded as asked;
+//   - *OptionError: the Options are invalid or contradict each other;
+//   - *TypeError: Marshal or Unmarshal was given a Go value it cannot handle;
+//   - *VerifyError: Verify found formatted output whose data differs from
+//     its input;
+//   - ErrCanceled: a Context variant's context was done.
+//
+// Errors reading input, from an io.Reader or an fs.FS, are returned wrapped
+// with %w, so errors.Is finds fs.ErrNotExist and the like.
+
+// ParseError reports input that cannot be formatted or decoded as asked.
+type ParseError struct {
+	File   string // file name, when known
+	Line   int    // 1-based line number, or 0 when not tied to one line
+	Key    string // section.key path of the key at fault, if any
+	Reason string // what is wrong, if not only Err
+	Err    error  // underlying error, if any
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File + ": ")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Reason)
+	if e.Err != nil {
+		if e.Reason != "" {
+			b.WriteString(": ")
+		}
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// inFile returns err with its file set to name when it is a ParseError that
+// does not name one yet, and err itself otherwise.
+func inFile(err error, name string) error {
+	if pe, ok := err.(*ParseError); ok && pe.File == "" {
+		named := *pe
+		named.File = name
+		return &named
+	}
+	return err
+}
+
+// OptionError reports an invalid option value or combination of options.
+type OptionError struct {
+	Option string // the option at fault: an Options field name, or a flag of a command using this package
+	Reason string // the message, naming the option; "" when Err is the whole message
+	Err    error  // underlying error, if any
+}
+
+func (e *OptionError) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Reason
+	case e.Reason == "":
+		return e.Err.Error()
+	}
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *OptionError) Unwrap() error { return e.Err }
+
+// TypeError reports a Go value Marshal cannot encode, or a Go value
+// Unmarshal cannot store into.
+type TypeError struct {
+	Path   string // section or section.key of the value, if any
+	Reason string
+	Err    error // underlying error, if any
+}
+
+func (e *TypeError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
+	}
+	b.WriteString(e.Reason)
+	if e.Err != nil {
+		if e.Reason != "" {
+			b.WriteString(": ")
+		}
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *TypeError) Unwrap() error { return e.Err }
+
+// VerifyError reports the first entry, such as a key and its value, in which
+// formatted output differs from its input beyond what the options explain.
+type VerifyError struct {
+	InputLine  int    // 1-based line of the input's entry, or 0 when the input has no more
+	Input      string // the input's entry, or ""
+	OutputLine int    // 1-based line of the output's entry, or 0 when the output has no more
+	Output     string // the output's entry, or ""
+}
+
+func (e *VerifyError) Error() string {
+	switch {
+	case e.OutputLine == 0:
+		return fmt.Sprintf("formatted output lost %q (input line %d)", e.Input, e.InputLine)
+	case e.InputLine == 0:
+		return fmt.Sprintf("formatted output added %q (output line %d)", e.Output, e.OutputLine)
+	}
+	return fmt.Sprintf("formatted output has %q at line %d where the input has %q at line %d", e.Output, e.OutputLine, e.Input, e.InputLine)
+}
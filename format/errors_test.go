@@ -0,0 +1,183 @@
+package format
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+// TestErrorKinds locks in which kind of error each failure is reported as, so
+// callers can rely on errors.As and errors.Is.
+func TestErrorKinds(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	type target struct {
+		Name    string
+		Integer int
+	}
+	tests := []struct {
+		name    string
+		run     func() error
+		check   func(error) bool
+		message string
+	}{
+		{
+			name: "unset variable",
+			run: func() error {
+				_, err := Lines([]string{"[s]", "a = 1", "b = ${INIFMT_TEST_MISSING}"}, Options{ExpandEnv: true})
+				return err
+			},
+			check: func(err error) bool {
+				var pe *ParseError
+				return errors.As(err, &pe) && pe.Line == 3
+			},
+			message: "line 3: environment variable INIFMT_TEST_MISSING is not set",
+		},
+		{
+			name: "flatten collision",
+			run: func() error {
+				_, err := Lines([]string{"a.b = 1", "[a]", "b = 2"}, Options{Flatten: true})
+				return err
+			},
+			check: func(err error) bool {
+				var pe *ParseError
+				return errors.As(err, &pe)
+			},
+		},
+		{
+			name: "file name",
+			run: func() error {
+				_, err := FormatFS(fstest.MapFS{"conf/app.ini": {Data: []byte("a = $INIFMT_TEST_MISSING\n")}}, nil, Options{ExpandEnv: true})
+				return err
+			},
+			check: func(err error) bool {
+				var pe *ParseError
+				return errors.As(err, &pe) && pe.File == "conf/app.ini" && pe.Line == 1
+			},
+			message: "conf/app.ini: line 1: environment variable INIFMT_TEST_MISSING is not set",
+		},
+		{
+			name: "invalid option",
+			run: func() error {
+				_, err := Lines([]string{"a = 1"}, Options{DedupeKeys: "middle"})
+				return err
+			},
+			check: func(err error) bool {
+				var oe *OptionError
+				return errors.As(err, &oe) && oe.Option == "DedupeKeys"
+			},
+			message: `invalid DedupeKeys "middle" (want first, last)`,
+		},
+		{
+			name: "invalid redact pattern",
+			run: func() error {
+				_, err := RedactPatterns([]string{"("}, false)
+				return err
+			},
+			check: func(err error) bool {
+				var oe *OptionError
+				return errors.As(err, &oe) && oe.Option == "Redact"
+			},
+		},
+		{
+			name: "marshal unsupported type",
+			run: func() error {
+				_, err := Marshal(map[string]map[string]chan int{"s": {"k": nil}}, Options{})
+				return err
+			},
+			check: func(err error) bool {
+				var te *TypeError
+				return errors.As(err, &te) && te.Path == "s.k"
+			},
+		},
+		{
+			name: "unmarshal into non-pointer",
+			run: func() error {
+				return Unmarshal([]byte("a = 1\n"), target{})
+			},
+			check: func(err error) bool {
+				var te *TypeError
+				return errors.As(err, &te)
+			},
+		},
+		{
+			name: "unmarshal invalid value",
+			run: func() error {
+				var v target
+				return Unmarshal([]byte("name = x\ninteger = many\n"), &v)
+			},
+			check: func(err error) bool {
+				var pe *ParseError
+				return errors.As(err, &pe) && pe.Line == 2 && pe.Key == "integer"
+			},
+			message: `line 2: integer: invalid int "many"`,
+		},
+		{
+			name: "canceled",
+			run: func() error {
+				_, err := LinesContext(canceled, []string{"a = 1"}, Options{})
+				return err
+			},
+			check: func(err error) bool {
+				return errors.Is(err, ErrCanceled) && errors.Is(err, context.Canceled)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if !tt.check(err) {
+				t.Errorf("got %T %v, not the expected kind", err, err)
+			}
+			if tt.message != "" && err.Error() != tt.message {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.message)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Options
+		option string // "" when valid
+	}{
+		{"zero value", Options{}, ""},
+		{"valid values", Options{DedupeKeys: "last", SplitOn: "last", ListSeparator: "space", BlankLines: "squeeze", Collate: CollateUnicode, CollateLocale: "de", Nest: NestAll}, ""},
+		{"split on", Options{SplitOn: "middle"}, "SplitOn"},
+		{"sort case", Options{SortCase: "upper"}, "SortCase"},
+		{"list separator", Options{ListSeparator: "|"}, "ListSeparator"},
+		{"trailing comma", Options{ListTrailingComma: "add"}, "ListTrailingComma"},
+		{"blank lines", Options{BlankLines: "none"}, "BlankLines"},
+		{"collate", Options{Collate: "locale"}, "Collate"},
+		{"collate locale", Options{CollateLocale: "not a locale"}, "CollateLocale"},
+		{"wrap values", Options{WrapValues: -1}, "WrapValues"},
+		{"tab width", Options{TabWidth: -4}, "TabWidth"},
+		{"nest", Options{Nest: -2}, "Nest"},
+		{"flatten and nest", Options{Flatten: true, Nest: 1}, "Flatten"},
+		{"comment prefix", Options{CommentPrefixes: []string{"#", " ;"}}, "CommentPrefixes"},
+		{"sort keys pattern", Options{SortKeys: []string{"["}}, "SortKeys"},
+		{"only sections pattern", Options{OnlySections: []string{"a[", "b"}}, "OnlySections"},
+		{"section pattern", Options{Sections: []SectionOptions{{Pattern: "[x"}}}, "Sections"},
+		{"section options", Options{Sections: []SectionOptions{{Pattern: "x", Options: Options{SplitOn: "both"}}}}, "Sections"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.option == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var oe *OptionError
+			if !errors.As(err, &oe) || oe.Option != tt.option {
+				t.Errorf("Validate() = %v, want an OptionError for %s", err, tt.option)
+			}
+		})
+	}
+}
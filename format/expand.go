@@ -0,0 +1,86 @@
+package format
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandEnvLines substitutes environment variables into the values of key/value
+// lines. Keys, section headers and comments are never expanded. An unset variable
+// is an error unless cfg.EmptyUnset is true, in which case it expands to "".
+func expandEnvLines(lines []string, cfg Options) ([]string, error) {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = line
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || cfg.IsComment(line) || strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		before, after, ok := cfg.Cut(line)
+		if !ok {
+			continue
+		}
+		value, err := expandEnv(after, cfg.EmptyUnset)
+		if err != nil {
+			return nil, &ParseError{Line: i + 1, Err: err}
+		}
+		result[i] = before + "=" + value
+	}
+	return result, nil
+}
+
+// expandEnv replaces ${VAR} and $VAR references in s with values from the
+// environment. $$ is an escape for a literal dollar sign, and a $ that does not
+// start a reference is kept as-is.
+func expandEnv(s string, emptyUnset bool) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		var name string
+		var width int
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				b.WriteByte(s[i])
+				continue
+			}
+			name, width = s[i+2:i+2+end], end+3
+		case isEnvNameStart(next):
+			j := i + 2
+			for j < len(s) && isEnvNameChar(s[j]) {
+				j++
+			}
+			name, width = s[i+1:j], j-i
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok && !emptyUnset {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(val)
+		i += width - 1
+	}
+	return b.String(), nil
+}
+
+func isEnvNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isEnvNameChar(c byte) bool {
+	return isEnvNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,72 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("INIFMT_HOST", "db.example.com")
+	t.Setenv("INIFMT_EMPTY", "")
+
+	tests := []struct {
+		name       string
+		value      string
+		emptyUnset bool
+		want       string
+		wantErr    bool
+	}{
+		{name: "braced", value: "${INIFMT_HOST}:5432", want: "db.example.com:5432"},
+		{name: "bare", value: "$INIFMT_HOST/db", want: "db.example.com/db"},
+		{name: "escaped dollar", value: "cost $$5", want: "cost $5"},
+		{name: "lone dollar", value: "100$", want: "100$"},
+		{name: "dollar before digit", value: "$5", want: "$5"},
+		{name: "set but empty", value: "x${INIFMT_EMPTY}y", want: "xy"},
+		{name: "unset is error", value: "${INIFMT_MISSING}", wantErr: true},
+		{name: "unset with empty-unset", value: "a${INIFMT_MISSING}b", emptyUnset: true, want: "ab"},
+		{name: "unterminated brace", value: "${INIFMT_HOST", want: "${INIFMT_HOST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv(tt.value, tt.emptyUnset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnv(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatLinesExpandEnvBeforeAlignment(t *testing.T) {
+	t.Setenv("INIFMT_LONG", "a-much-longer-value")
+
+	lines := []string{
+		"[$INIFMT_LONG]",
+		"; ${INIFMT_MISSING} in a comment is left alone",
+		"$INIFMT_LONG=$INIFMT_LONG",
+		"k=v",
+	}
+	got, err := Lines(lines, Options{ExpandEnv: true})
+	if err != nil {
+		t.Fatalf("Lines() unexpected error: %v", err)
+	}
+	want := []string{
+		"[$INIFMT_LONG]",
+		"; ${INIFMT_MISSING} in a comment is left alone",
+		"$INIFMT_LONG = a-much-longer-value",
+		"k            = v",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Lines() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if _, err := Lines([]string{"k = ${INIFMT_MISSING}"}, Options{ExpandEnv: true}); err == nil {
+		t.Error("Lines() expected error for unset variable")
+	}
+}
@@ -0,0 +1,142 @@
+package format
+
+import "strings"
+
+// AlignGroup records how one group of lines was aligned.
+type AlignGroup struct {
+	Start, End int    // 1-based line numbers of the group's first and last line
+	Scope      string // "file", "section" or "block", per PerSection, PerBlock and GroupByComments
+	Keys       int    // key lines aligned in the group
+	Width      int    // display width every key was padded to
+	WidestLine int    // line of the first key that is Width wide; 0 when Keys is 0
+	Padded     int    // key lines that got padding
+	Excluded   []Exclusion
+}
+
+// Exclusion is a line of an alignment group that was not aligned.
+type Exclusion struct {
+	Line   int
+	Reason string // e.g. "comment", "blank line" or "bare key"
+}
+
+// Explain formats lines like Lines and reports how each alignment group was
+// aligned, numbering lines as in the output. Lines added by WrapValues are
+// not counted. With SingleSpace nothing is aligned and there are no groups.
+func Explain(lines []string, cfg Options) ([]AlignGroup, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.SingleSpace {
+		return nil, nil
+	}
+	lines, _ = cfg.joinContinuations(lines)
+	if len(cfg.OnlySections) == 0 {
+		prepared, err := prepareLines(lines, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return explainLines(prepared, 0, cfg), nil
+	}
+
+	sub := cfg
+	sub.OnlySections, sub.SortSections, sub.DefaultSection = nil, false, ""
+	var groups []AlignGroup
+	offset := 0
+	for _, s := range splitSections(lines) {
+		chunk := s.lines
+		if s.header != "" {
+			chunk = append([]string{s.header}, s.lines...)
+		}
+		if sectionSelected(cfg.OnlySections, s.name()) {
+			prepared, err := prepareLines(chunk, sub)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, explainLines(prepared, offset, sub)...)
+			chunk = prepared
+		}
+		offset += outputLineCount(chunk)
+	}
+	return groups, nil
+}
+
+// outputLineCount returns how many lines lines are once their joined
+// continuation lines are split again.
+func outputLineCount(lines []string) int {
+	n := len(lines)
+	for _, line := range lines {
+		n += strings.Count(line, "\n")
+	}
+	return n
+}
+
+// explainLines explains the alignment of prepared lines, the first of which
+// is output line offset+1.
+func explainLines(lines []string, offset int, cfg Options) []AlignGroup {
+	numbers := make([]int, len(lines))
+	n := offset
+	for i, line := range lines {
+		numbers[i] = n + 1
+		n += 1 + strings.Count(line, "\n")
+	}
+	scope := "file"
+	switch {
+	case cfg.PerBlock || cfg.GroupByComments:
+		scope = "block"
+	case cfg.PerSection:
+		scope = "section"
+	}
+
+	var groups []AlignGroup
+	for _, sp := range alignSpans(lines, cfg) {
+		if sp.start == sp.end {
+			continue
+		}
+		g := AlignGroup{Start: numbers[sp.start], End: numbers[sp.end-1], Scope: scope}
+		var widths []int
+		for i := sp.start; i < sp.end; i++ {
+			line := strings.TrimRight(lines[i], " \t")
+			key, op, after, ok := cfg.keyValue(line)
+			if !ok || cfg.keepLossy(key, after) {
+				g.Excluded = append(g.Excluded, Exclusion{Line: numbers[i], Reason: cfg.exclusionReason(line, ok)})
+				widths = append(widths, -1)
+				continue
+			}
+			w := cfg.keyWidth(key, op)
+			widths = append(widths, w)
+			g.Keys++
+			if g.WidestLine == 0 || w > g.Width {
+				g.Width, g.WidestLine = w, numbers[i]
+			}
+		}
+		for _, w := range widths {
+			if w >= 0 && w < g.Width {
+				g.Padded++
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// exclusionReason says why line, which is not aligned, is left out; isKey
+// reports whether it is a key line that Options.KeepLossy keeps.
+func (c Options) exclusionReason(line string, isKey bool) string {
+	switch {
+	case isKey:
+		return "value kept as is (lossy)"
+	case IsBlank(line):
+		return "blank line"
+	case IsHeader(line):
+		return "section header"
+	case c.IsComment(line):
+		return "comment"
+	}
+	switch c.dialect().Classify(line, LineContext{Index: -1}, c) {
+	case LineKeyValue:
+		return "bare key"
+	case LineDirective:
+		return "directive"
+	}
+	return "no delimiter"
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var explainInput = []string{
+	"; top",
+	"name=app",
+	"[server]",
+	"host=example.com",
+	"port=80",
+	"",
+	"timeout_seconds=30",
+	"bare",
+	"[db]",
+	"user=admin",
+}
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Options
+		want []AlignGroup
+	}{
+		{"file", Options{}, []AlignGroup{{
+			Start: 1, End: 10, Scope: "file", Keys: 5, Width: 15, WidestLine: 7, Padded: 4,
+			Excluded: []Exclusion{{1, "comment"}, {3, "section header"}, {6, "blank line"}, {8, "bare key"}, {9, "section header"}},
+		}}},
+		{"per-section", Options{PerSection: true}, []AlignGroup{
+			{Start: 1, End: 2, Scope: "section", Keys: 1, Width: 4, WidestLine: 2, Excluded: []Exclusion{{1, "comment"}}},
+			{Start: 4, End: 8, Scope: "section", Keys: 3, Width: 15, WidestLine: 7, Padded: 2, Excluded: []Exclusion{{6, "blank line"}, {8, "bare key"}}},
+			{Start: 10, End: 10, Scope: "section", Keys: 1, Width: 4, WidestLine: 10},
+		}},
+		{"per-block", Options{PerSection: true, PerBlock: true}, []AlignGroup{
+			{Start: 1, End: 2, Scope: "block", Keys: 1, Width: 4, WidestLine: 2, Excluded: []Exclusion{{1, "comment"}}},
+			{Start: 4, End: 5, Scope: "block", Keys: 2, Width: 4, WidestLine: 4},
+			{Start: 6, End: 8, Scope: "block", Keys: 1, Width: 15, WidestLine: 7, Excluded: []Exclusion{{6, "blank line"}, {8, "bare key"}}},
+			{Start: 10, End: 10, Scope: "block", Keys: 1, Width: 4, WidestLine: 10},
+		}},
+		{"only-sections", Options{OnlySections: []string{"db"}}, []AlignGroup{
+			{Start: 9, End: 10, Scope: "file", Keys: 1, Width: 4, WidestLine: 10, Excluded: []Exclusion{{9, "section header"}}},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Explain(explainInput, tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Explain() =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+	if got, _ := Explain(explainInput, Options{SingleSpace: true}); got != nil {
+		t.Errorf("Explain(single-space) = %+v, want none", got)
+	}
+}
+
+// TestExplainMatchesOutput checks the explanation against the formatted
+// lines: every key line of a group has its '=' right after Width columns of
+// key and padding.
+func TestExplainMatchesOutput(t *testing.T) {
+	input := []string{
+		"[a]", "k=1", "longer_key=2", "; note", "x=3",
+		"[b]", "# c", "short=1", "", "naïve_key=2",
+		`"q"=hex:00,\`, "  01", "after=1",
+	}
+	for _, cfg := range []Options{{}, {PerSection: true}, {PerBlock: true}, {GroupByComments: true, SortKeys: []string{"*"}}, {Dialect: DialectReg}} {
+		out, err := Lines(input, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		groups, err := Explain(input, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range groups {
+			for n := g.Start; n <= g.End; n++ {
+				if slices.ContainsFunc(g.Excluded, func(e Exclusion) bool { return e.Line == n }) {
+					continue
+				}
+				line := out[n-1]
+				before, _, ok := strings.Cut(line, " = ")
+				if !ok {
+					continue // a continuation line
+				}
+				if w := DisplayWidth(before); w != g.Width {
+					t.Errorf("%+v: line %d %q: key width %d, explained %d", cfg, n, line, w, g.Width)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flattenSections removes the section headers of lines and prefixes the keys
+// of each section with its name and a dot, the inverse of nestKeys:
+// "port = 8080" in [server] becomes "server.port = 8080". Comments and blank
+// lines stay where they are; the comment of a header line becomes a comment
+// line in its place, above the section's first key. Keys that flatten to the
+// same name as a key of another section are an error.
+func flattenSections(lines []string, cfg Options) ([]string, error) {
+	type origin struct{ section, key string }
+	seen := make(map[string]origin)
+	var result []string
+	for _, s := range splitSections(lines) {
+		if s.header == "" {
+			for _, line := range s.lines {
+				if cfg.isKeyLine(line) {
+					seen[cfg.lineKey(line)] = origin{"", cfg.lineKey(line)}
+				}
+			}
+			result = append(result, s.lines...)
+			continue
+		}
+		if _, rest := cfg.dialect().SplitHeader(strings.TrimSpace(s.header)); strings.TrimSpace(rest) != "" {
+			rest = strings.TrimSpace(rest)
+			if !cfg.IsComment(rest) {
+				rest = cfg.firstCommentPrefix() + " " + rest
+			}
+			result = append(result, rest)
+		}
+		name := s.name()
+		for _, line := range s.lines {
+			if !cfg.isKeyLine(line) {
+				result = append(result, line)
+				continue
+			}
+			key := cfg.lineKey(line)
+			path := name + "." + key
+			if o, ok := seen[path]; ok && o != (origin{name, key}) {
+				return nil, &ParseError{Reason: fmt.Sprintf("cannot flatten: key %q of [%s] and key %q of %s both become %s", key, name, o.key, sectionLabel(o.section), path)}
+			}
+			seen[path] = origin{name, key}
+			i := strings.Index(line, key)
+			result = append(result, line[:i]+path+line[i+len(key):])
+		}
+	}
+	return result, nil
+}
+
+// isKeyLine reports whether line is a key line, with or without a value.
+func (c Options) isKeyLine(line string) bool {
+	return !IsBlank(line) && !IsHeader(line) && !c.IsComment(line) &&
+		c.dialect().Classify(line, LineContext{Index: -1}, c) == LineKeyValue
+}
+
+// firstCommentPrefix returns the comment prefix new comment lines get.
+func (c Options) firstCommentPrefix() string {
+	prefixes := c.CommentPrefixes
+	if prefixes == nil {
+		prefixes = c.dialect().CommentPrefixes()
+	}
+	if len(prefixes) == 0 {
+		return ";"
+	}
+	return prefixes[0]
+}
+
+// sectionLabel names a section in messages, "the preamble" for "".
+func sectionLabel(name string) string {
+	if name == "" {
+		return "the preamble"
+	}
+	return "[" + name + "]"
+}
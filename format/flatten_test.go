@@ -0,0 +1,112 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	lines := []string{
+		"; top",
+		"name = app",
+		"",
+		"[server] ; web front end",
+		"# listen port",
+		"port = 8080",
+		"debug",
+		"",
+		"[server.tls]",
+		"cert = a.pem",
+		"",
+		"[empty]",
+	}
+	want := []string{
+		"; top",
+		"name = app",
+		"",
+		"; web front end",
+		"# listen port",
+		"server.port = 8080",
+		"server.debug",
+		"",
+		"server.tls.cert = a.pem",
+		"",
+	}
+	got, err := Lines(lines, Options{SingleSpace: true, Flatten: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines(flatten) =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFlattenCollision(t *testing.T) {
+	for _, lines := range [][]string{
+		{"[server]", "http.port = 1", "[server.http]", "port = 2"},
+		{"server.port = 1", "[server]", "port = 2"},
+	} {
+		if _, err := Lines(lines, Options{Flatten: true}); err == nil || !strings.Contains(err.Error(), "both become") {
+			t.Errorf("Lines(flatten %q) error = %v, want a collision", lines, err)
+		}
+	}
+	// A key repeated within its section is not a collision.
+	if _, err := Lines([]string{"[s]", "a = 1", "a = 2"}, Options{Flatten: true}); err != nil {
+		t.Errorf("Lines(flatten duplicate) error = %v", err)
+	}
+}
+
+func TestNestFlattenRoundTrip(t *testing.T) {
+	flat := []string{
+		"name = app",
+		"",
+		"# listen port",
+		"server.port = 8080",
+		"server.host = 0.0.0.0",
+		"db.user = admin",
+		"db.pool = 5",
+	}
+	sectioned := []string{
+		"name = app",
+		"",
+		"[server]",
+		"# listen port",
+		"port = 8080",
+		"host = 0.0.0.0",
+		"",
+		"[db]",
+		"user = admin",
+		"pool = 5",
+	}
+	for _, depth := range []int{NestAll, 1} {
+		nested, err := Lines(flat, Options{SingleSpace: true, Nest: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(nested, sectioned) {
+			t.Errorf("nest %d =\n%s\nwant:\n%s", depth, strings.Join(nested, "\n"), strings.Join(sectioned, "\n"))
+		}
+		back, err := Lines(nested, Options{SingleSpace: true, Flatten: true, BlankLines: "squeeze"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"name = app", "", "# listen port", "server.port = 8080", "server.host = 0.0.0.0", "", "db.user = admin", "db.pool = 5"}
+		if !slices.Equal(back, want) {
+			t.Errorf("flatten(nest %d) =\n%s\nwant:\n%s", depth, strings.Join(back, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// Flattening and nesting again gives back the sections.
+	flattened, err := Lines(sectioned, Options{SingleSpace: true, Flatten: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Lines(flattened, Options{SingleSpace: true, Nest: NestAll, BlankLines: "sections"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(again, sectioned) {
+		t.Errorf("nest(flatten) =\n%s\nwant:\n%s", strings.Join(again, "\n"), strings.Join(sectioned, "\n"))
+	}
+}
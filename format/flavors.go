@@ -0,0 +1,272 @@
+package format
+
+import "strings"
+
+// Dialects of common INI-like files. Each differs from plain INI only where
+// the programs reading it do.
+var (
+	DialectGitConfig   Dialect = GitConfigDialect{}
+	DialectDesktop     Dialect = DesktopDialect{}
+	DialectSystemd     Dialect = SystemdDialect{}
+	DialectPyCfg       Dialect = PyCfgDialect{}
+	DialectEnv         Dialect = EnvDialect{}
+	DialectProperties  Dialect = PropertiesDialect{}
+	DialectMyCnf       Dialect = MyCnfDialect{}
+	DialectKDE         Dialect = KDEDialect{}
+	DialectSmb         Dialect = SmbDialect{}
+	DialectHgrc        Dialect = HgrcDialect{}
+	DialectSupervisord Dialect = SupervisordDialect{}
+)
+
+// hashCommentPrefixes are the comment prefixes of formats with only '#'
+// comments.
+var hashCommentPrefixes = []string{"#"}
+
+// backslashContinued reports whether the line after ctx continues a value
+// whose line ends in a backslash.
+func backslashContinued(ctx LineContext) bool {
+	return (ctx.PrevKind == LineKeyValue || ctx.PrevKind == LineContinuation) &&
+		strings.HasSuffix(strings.TrimRight(ctx.Prev, " \t"), `\`)
+}
+
+// cutAny splits line at the first of the delimiter bytes in delims, or the
+// last one when cfg.SplitOn is "last".
+func cutAny(line, delims string, cfg Options) (before, after string, ok bool) {
+	idx := strings.IndexAny(line, delims)
+	if cfg.SplitOn == "last" {
+		idx = strings.LastIndexAny(line, delims)
+	}
+	if idx == -1 {
+		return line, "", false
+	}
+	return line[:idx], line[idx+1:], true
+}
+
+// GitConfigDialect is git's config format: [section "subsection"] headers and
+// values continued by a trailing backslash.
+type GitConfigDialect struct{ INIDialect }
+
+// Name returns "gitconfig".
+func (GitConfigDialect) Name() string { return "gitconfig" }
+
+// Classify treats the lines after a value ending in a backslash as its
+// continuation.
+func (d GitConfigDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	if backslashContinued(ctx) {
+		return LineContinuation
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+// JoinContinuation appends line to value in place of its trailing backslash.
+// The indentation of line is part of the value, so wrapped values continue at
+// the start of the line.
+func (GitConfigDialect) JoinContinuation(value, line string) string {
+	return strings.TrimSuffix(value, `\`) + line
+}
+
+// Continuation returns a trailing backslash.
+func (GitConfigDialect) Continuation() (string, bool) { return `\`, true }
+
+// DesktopDialect is the freedesktop.org desktop entry format, which has only
+// '#' comments.
+type DesktopDialect struct{ INIDialect }
+
+// Name returns "desktop".
+func (DesktopDialect) Name() string { return "desktop" }
+
+// CommentPrefixes returns "#".
+func (DesktopDialect) CommentPrefixes() []string { return hashCommentPrefixes }
+
+// KDEDialect is the format of KDE and Plasma config files such as kdeglobals:
+// desktop entries whose group headers may nest, as in [Group][SubGroup], and
+// whose keys may carry a locale or flags, as in Name[de] and Name[$e].
+// Nested headers are one header in every dialect; this one also sets '#' as
+// the only comment prefix.
+type KDEDialect struct{ DesktopDialect }
+
+// Name returns "kde".
+func (KDEDialect) Name() string { return "kde" }
+
+// SystemdDialect is the format of systemd unit files, whose lines ending in a
+// backslash continue on the next line.
+type SystemdDialect struct{ INIDialect }
+
+// Name returns "systemd".
+func (SystemdDialect) Name() string { return "systemd" }
+
+// Classify treats the lines after a value ending in a backslash as its
+// continuation.
+func (d SystemdDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	if backslashContinued(ctx) {
+		return LineContinuation
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+// EmptyValuesMatter reports true: an empty value resets a list setting, as
+// "ExecStart=" does.
+func (SystemdDialect) EmptyValuesMatter() bool { return true }
+
+// SkipsCommentsInContinuations reports true: systemd ignores comment lines
+// between the lines of a continued value.
+func (SystemdDialect) SkipsCommentsInContinuations() bool { return true }
+
+// RepeatsKeys reports true: each "ExecStartPre=" line adds a command, even
+// one identical to the line before.
+func (SystemdDialect) RepeatsKeys() bool { return true }
+
+// JoinContinuation replaces the trailing backslash of value with a space
+// before line, as systemd does.
+func (SystemdDialect) JoinContinuation(value, line string) string {
+	return strings.TrimSuffix(value, `\`) + " " + strings.TrimSpace(line)
+}
+
+// Continuation returns a trailing backslash, which systemd reads as a space.
+func (SystemdDialect) Continuation() (string, bool) { return `\`, true }
+
+// PyCfgDialect is the format Python's configparser reads, as in setup.cfg:
+// ':' or '=' delimiters and values continued by indented lines.
+type PyCfgDialect struct{ INIDialect }
+
+// Name returns "pycfg".
+func (PyCfgDialect) Name() string { return "pycfg" }
+
+// Classify treats indented lines after a value as its continuation.
+// Indented comments stay comments.
+func (d PyCfgDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	kind := d.INIDialect.Classify(line, ctx, cfg)
+	if kind == LineKeyValue && (ctx.PrevKind == LineKeyValue || ctx.PrevKind == LineContinuation) &&
+		(line[0] == ' ' || line[0] == '\t') {
+		return LineContinuation
+	}
+	return kind
+}
+
+// Cut splits line at the first ':' or '='.
+func (PyCfgDialect) Cut(line string, cfg Options) (before, after string, ok bool) {
+	return cutAny(line, ":=", cfg)
+}
+
+// JoinContinuation appends line to value on a line of its own, as
+// configparser does.
+func (PyCfgDialect) JoinContinuation(value, line string) string {
+	return value + "\n" + strings.TrimSpace(line)
+}
+
+// Continuation reports that continuation lines are told by their indentation
+// alone. Each one starts a new line of the value.
+func (PyCfgDialect) Continuation() (string, bool) { return "", true }
+
+// EnvDialect is the format of .env files: KEY=value lines and '#' comments.
+type EnvDialect struct{ INIDialect }
+
+// Name returns "env".
+func (EnvDialect) Name() string { return "env" }
+
+// CommentPrefixes returns "#".
+func (EnvDialect) CommentPrefixes() []string { return hashCommentPrefixes }
+
+// PropertiesDialect is the format of Java .properties files: '#' and '!'
+// comments, '=' or ':' delimiters and values continued by a trailing
+// backslash.
+type PropertiesDialect struct{ INIDialect }
+
+// propertiesCommentPrefixes are the comment prefixes of .properties files.
+var propertiesCommentPrefixes = []string{"#", "!"}
+
+// Name returns "properties".
+func (PropertiesDialect) Name() string { return "properties" }
+
+// CommentPrefixes returns "#" and "!".
+func (PropertiesDialect) CommentPrefixes() []string { return propertiesCommentPrefixes }
+
+// Classify treats the lines after a value ending in a backslash as its
+// continuation.
+func (d PropertiesDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	if backslashContinued(ctx) {
+		return LineContinuation
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+// Cut splits line at the first ':' or '='.
+func (PropertiesDialect) Cut(line string, cfg Options) (before, after string, ok bool) {
+	return cutAny(line, ":=", cfg)
+}
+
+// JoinContinuation appends line, without its indentation, to value in place
+// of its trailing backslash.
+func (PropertiesDialect) JoinContinuation(value, line string) string {
+	return strings.TrimSuffix(value, `\`) + strings.TrimLeft(line, " \t")
+}
+
+// Continuation returns a trailing backslash.
+func (PropertiesDialect) Continuation() (string, bool) { return `\`, true }
+
+// MyCnfDialect is the format of MySQL option files such as my.cnf, whose
+// !include and !includedir lines are directives.
+type MyCnfDialect struct{ INIDialect }
+
+// Name returns "mycnf".
+func (MyCnfDialect) Name() string { return "mycnf" }
+
+// Classify treats !include and !includedir lines as directives.
+func (d MyCnfDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	if strings.HasPrefix(strings.TrimSpace(line), "!include") {
+		return LineDirective
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+// SmbDialect is the format of Samba's smb.conf: keys with spaces in them,
+// such as "read only", and include lines that read another file at their
+// place in the file, so neither they nor the sections move.
+type SmbDialect struct{ INIDialect }
+
+// Name returns "smb".
+func (SmbDialect) Name() string { return "smb" }
+
+// PinsKey reports whether key is include, which sorting leaves in place.
+func (SmbDialect) PinsKey(key string) bool { return strings.EqualFold(key, "include") }
+
+// KeepsSectionOrder reports true: an include may hold sections, so the
+// sections around it stay in order.
+func (SmbDialect) KeepsSectionOrder() bool { return true }
+
+// HgrcDialect is the format of Mercurial's hgrc: '=' delimiters, values
+// continued by indented lines as in configparser, and %include and %unset
+// lines that are directives.
+type HgrcDialect struct{ PyCfgDialect }
+
+// Name returns "hgrc".
+func (HgrcDialect) Name() string { return "hgrc" }
+
+// Classify treats %include and %unset lines as directives. An indented line
+// after a value continues it, as Mercurial reads it, even when it looks like
+// a directive.
+func (d HgrcDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	kind := d.PyCfgDialect.Classify(line, ctx, cfg)
+	if kind == LineContinuation {
+		return kind
+	}
+	if fields := strings.Fields(line); len(fields) > 0 && (fields[0] == "%include" || fields[0] == "%unset") {
+		return LineDirective
+	}
+	return kind
+}
+
+// Cut splits line at the first '='.
+func (d HgrcDialect) Cut(line string, cfg Options) (before, after string, ok bool) {
+	return d.INIDialect.Cut(line, cfg)
+}
+
+// SupervisordDialect is the format of supervisord.conf, which supervisord
+// reads with configparser: ':' or '=' delimiters, values continued by
+// indented lines and %(ENV_HOME)s style expansions, which value formatting
+// keeps whole. The colon of a [program:myapp] header is part of the section
+// name. inifmt aligns these files per section.
+type SupervisordDialect struct{ PyCfgDialect }
+
+// Name returns "supervisord".
+func (SupervisordDialect) Name() string { return "supervisord" }
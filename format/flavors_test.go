@@ -0,0 +1,131 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFlavorDialects(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		in      []string
+		want    []string
+	}{
+		{
+			DialectGitConfig,
+			[]string{`[alias "co"]`, `lg=log --graph \`, `	--oneline`, `st=status`},
+			[]string{`[alias "co"]`, `lg = log --graph \`, `	--oneline`, `st = status`},
+		},
+		{
+			DialectDesktop,
+			[]string{"[Desktop Entry]", "; not a comment", "Name=App", "# comment"},
+			[]string{"[Desktop Entry]", "; not a comment", "Name = App", "# comment"},
+		},
+		{
+			DialectSystemd,
+			[]string{"[Service]", `ExecStart=/bin/app \`, "  --flag", "Type=simple"},
+			[]string{"[Service]", `ExecStart = /bin/app \`, "  --flag", "Type      = simple"},
+		},
+		{
+			DialectPyCfg,
+			[]string{"[options]", "packages: find:", "install_requires =", "    requests", "    click", "zip_safe: false"},
+			[]string{"[options]", "packages         = find:", "install_requires =", "    requests", "    click", "zip_safe         = false"},
+		},
+		{
+			DialectEnv,
+			[]string{"# db", "DB_HOST=localhost", "PORT=5432"},
+			[]string{"# db", "DB_HOST = localhost", "PORT    = 5432"},
+		},
+		{
+			DialectProperties,
+			[]string{"! comment", "greeting: hello \\", "    world", "a.b=c"},
+			[]string{"! comment", "greeting = hello \\", "    world", "a.b      = c"},
+		},
+		{
+			DialectMyCnf,
+			[]string{"!includedir /etc/mysql/conf.d/", "[mysqld]", "port=3306", "skip-networking"},
+			[]string{"!includedir /etc/mysql/conf.d/", "[mysqld]", "port = 3306", "skip-networking"},
+		},
+		{
+			DialectKDE,
+			[]string{"[Containments][1][Applets][5]", "plugin=org.kde.panel", "Name[$e]=$HOME/x", "Name[de]=y", "  [General]   # note", "a=1"},
+			[]string{"[Containments][1][Applets][5]", "plugin   = org.kde.panel", "Name[$e] = $HOME/x", "Name[de] = y", "[General] # note", "a        = 1"},
+		},
+		{
+			DialectHgrc,
+			[]string{"%include ~/.hgrc.d/base.rc", "[ui]", "username=Jane <j@example.com>", "%unset verbose", "ignore:x = ~/.hgignore"},
+			[]string{"%include ~/.hgrc.d/base.rc", "[ui]", "username = Jane <j@example.com>", "%unset verbose", "ignore:x = ~/.hgignore"},
+		},
+	}
+	for _, tt := range tests {
+		cfg := Options{Dialect: tt.dialect}
+		got, err := Lines(tt.in, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(%s) =\n%s\nwant:\n%s", tt.dialect.Name(), strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			continue
+		}
+		if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+			t.Errorf("Lines(%s) is not idempotent:\n%s", tt.dialect.Name(), strings.Join(again, "\n"))
+		}
+	}
+}
+
+func TestFlavorJoinContinuation(t *testing.T) {
+	tests := []struct {
+		dialect     Dialect
+		value, line string
+		want        string
+	}{
+		{DialectGitConfig, `log \`, "\t--oneline", "log \t--oneline"},
+		{DialectSystemd, `/bin/app \`, "  --flag", "/bin/app  --flag"},
+		{DialectPyCfg, "", "    requests", "\nrequests"},
+		{DialectProperties, `hello \`, "    world", "hello world"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.JoinContinuation(tt.value, tt.line); got != tt.want {
+			t.Errorf("%s.JoinContinuation(%q, %q) = %q, want %q", tt.dialect.Name(), tt.value, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDialectNamesIncludeFlavors(t *testing.T) {
+	names := DialectNames()
+	for _, want := range []string{"gitconfig", "desktop", "systemd", "pycfg", "env", "properties", "mycnf", "kde", "smb", "hgrc", "supervisord"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("DialectNames() = %v, missing %q", names, want)
+		}
+	}
+}
+
+func TestNestedHeaders(t *testing.T) {
+	tests := []struct {
+		line, name, header, rest string
+	}{
+		{"[Group][SubGroup][SubSub]", "Group][SubGroup][SubSub", "[Group][SubGroup][SubSub]", ""},
+		{"[Group][$i]", "Group][$i", "[Group][$i]", ""},
+		{"[Group] [Not nested]", "Group", "[Group]", " [Not nested]"},
+		{"[Group][open", "Group", "[Group]", "[open"},
+		{"[plain] ; note", "plain", "[plain]", " ; note"},
+	}
+	for _, tt := range tests {
+		if got := HeaderName(tt.line); got != tt.name {
+			t.Errorf("HeaderName(%q) = %q, want %q", tt.line, got, tt.name)
+		}
+		if header, rest := DialectINI.SplitHeader(tt.line); header != tt.header || rest != tt.rest {
+			t.Errorf("SplitHeader(%q) = %q, %q, want %q, %q", tt.line, header, rest, tt.header, tt.rest)
+		}
+	}
+
+	in := []string{"[Group][A]", "long_key=1", "[Group][B]", "k=2"}
+	got, err := Lines(in, Options{Dialect: DialectKDE, PerSection: true, SortSections: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"[Group][A]", "long_key = 1", "[Group][B]", "k = 2"}; !slices.Equal(got, want) {
+		t.Errorf("Lines(per section) = %q, want %q", got, want)
+	}
+}
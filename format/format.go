@@ -0,0 +1,385 @@
+// Package format formats INI-style configuration files: it aligns '=' signs,
+// normalizes values and optionally sorts, deduplicates and tidies sections and
+// keys. It is the engine behind the inifmt command and has no dependency on
+// its command-line interface.
+package format
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Options selects how a file is formatted. The zero value aligns '=' across
+// the whole file and changes nothing but whitespace.
+type Options struct {
+	PerSection            bool             // align within each section independently
+	PerBlock              bool             // restart alignment after every blank line
+	GroupByComments       bool             // restart alignment at every full-line comment
+	SingleSpace           bool             // exactly one space around '=' instead of aligning
+	ExpandEnv             bool             // substitute ${VAR} and $VAR in values
+	EmptyUnset            bool             // with ExpandEnv, unset variables expand to ""
+	SortSections          bool             // sort sections by name after the preamble
+	PinnedSections        []string         // with SortSections, sections kept first in this order
+	DefaultSection        string           // move preamble keys into this section
+	Nest                  int              // nest this many dot-separated preamble key components into sections; NestAll for all but the last
+	Flatten               bool             // remove section headers, prefixing keys with "section."
+	OnlySections          []string         // format only these sections (names or globs)
+	Sections              []SectionOptions // options of their own for the sections matching a pattern
+	SortKeys              []string         // sort keys in these sections (names or globs; "*" for all)
+	GroupByPrefix         bool             // with SortKeys, sort whole sections and blank-line-separate key prefixes
+	GroupSeparators       string           // characters ending a key prefix; "" means DefaultGroupSeparators
+	Collate               string           // sort order: CollateBytes (or "") or CollateUnicode
+	CollateLocale         string           // BCP 47 locale of CollateUnicode, e.g. "de" or "sv"; "" is the root collation
+	SortCase              string           // SortCaseSensitive (or "") or SortCaseInsensitive
+	DedupeKeys            string           // "first" or "last" to drop repeated keys
+	Unique                bool             // drop key lines repeating an earlier line of their section exactly
+	StripComments         bool             // remove full-line comments
+	RemoveEmptyValues     bool             // remove key lines whose value is empty
+	RemoveEmptyQuoted     bool             // with RemoveEmptyValues, "" and '' count as empty too
+	RemoveWithComments    bool             // with RemoveEmptyValues, also remove the comments directly above
+	Defaults              []KeyValue       // remove key lines restating these defaults, and the sections left empty
+	PruneEmptySections    bool             // remove sections without keys and the comments above their headers
+	KeepCommentedSections bool             // with PruneEmptySections, keep sections that hold comments
+	BlankLines            string           // "keep" (or ""), "squeeze" or "sections"
+	AlignCommentIndent    bool             // indent comments like the key below them
+	AlignPairs            bool             // align the "Name: value;" pairs of Inno Setup style lines
+	SplitOn               string           // "first" (or "") or "last" '=' separates key and value
+	Operators             []string         // assignment operators such as "+=" kept whole and aligned on their '='
+	TabWidth              int              // columns between tab stops when measuring keys; 0 means DefaultTabWidth
+	WrapValues            int              // wrap values past this column onto continuation lines; 0 never wraps
+	InlineCommentGap      int              // spaces between a value and its inline comment; 0 leaves one
+	JoinContinuations     bool             // put values continued over several lines back on one line
+	JoinSeparator         string           // with JoinContinuations, "space" (or "") or "none" between the parts
+	Retab                 string           // "spaces" or "tabs" to rewrite leading whitespace; "" leaves it
+	UnicodeEquals         bool             // rewrite full-width and other Unicode equals sign delimiters as '='
+	KeepLossy             bool             // leave lines untouched whose value formatting would change
+	CommentPrefixes       []string         // full-line comment prefixes; nil means the dialect's default
+	Dialect               Dialect          // nil means DialectINI
+	NormalizeNumbers      bool             // strip leading zeros from integer values and lowercase hex prefixes
+	HexCase               string           // with NormalizeNumbers, "lower" (or "") or "upper" hex digits
+	NormalizeLists        bool             // rewrite list values as "a, b, c"
+	ListSeparator         string           // ",", ";" or "space"
+	ListTrailingComma     string           // "keep" (or "") or "drop"
+	SortListValues        []string         // keys whose list items are sorted
+	UniqueListValues      bool             // with SortListValues, drop duplicate items
+	MatchKeys             *regexp.Regexp   // format only key/value lines whose key matches; nil matches all
+	IgnoreKeys            *regexp.Regexp   // leave key/value lines whose key matches untouched
+	Redact                []*regexp.Regexp
+	RedactReveal          bool // keep the first and last two characters of redacted values
+
+	ctx context.Context // set by the Context variants; nil never cancels
+}
+
+// DefaultGroupSeparators end the key prefix GroupByPrefix groups by.
+const DefaultGroupSeparators = "_."
+
+// SplitLines splits text into lines the way bufio.ScanLines does, dropping the
+// line terminators. It also returns the terminator of the first line ("\n" when
+// the input has none) so output can keep the input's line ending style.
+func SplitLines(text string) ([]string, string) {
+	eol := "\n"
+	if idx := strings.IndexByte(text, '\n'); idx > 0 && text[idx-1] == '\r' {
+		eol = "\r\n"
+	}
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return make([]string, 0), eol
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, eol
+}
+
+// readLines reads all lines from the scanner.
+func readLines(scanner *bufio.Scanner) ([]string, error) {
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	return lines, nil
+}
+
+// Lines applies the value pre-processing and structural passes and then
+// formats lines in either aligned or single-space style. The input slice is
+// not modified.
+func Lines(lines []string, cfg Options) ([]string, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.filtersKeys() {
+		return formatSelectedKeys(lines, cfg)
+	}
+	// Continued values travel and align as one line; so do values that
+	// WrapValues wraps, until they are split here.
+	joined, ok := cfg.joinContinuations(lines)
+	if !ok && !cfg.wraps() {
+		return formatLines(lines, cfg)
+	}
+	result, err := formatLines(joined, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return splitContinuations(result), nil
+}
+
+// formatLines is Lines without the handling of continuation lines.
+func formatLines(lines []string, cfg Options) ([]string, error) {
+	if len(cfg.OnlySections) > 0 {
+		return formatSelectedSections(lines, cfg)
+	}
+	if len(cfg.Sections) > 0 {
+		return formatSectionOverrides(lines, cfg)
+	}
+	lines, err := prepareLines(lines, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.canceled(); err != nil {
+		return nil, err
+	}
+	lines = finishLines(lines, cfg)
+	if err := cfg.canceled(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// wraps reports whether WrapValues is set for the file or any of its
+// sections.
+func (c Options) wraps() bool {
+	if c.WrapValues > 0 {
+		return true
+	}
+	for _, s := range c.Sections {
+		if s.Options.WrapValues > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// finishLines aligns or single-spaces prepared lines and applies the passes
+// that come after alignment.
+func finishLines(lines []string, cfg Options) []string {
+	if cfg.SingleSpace {
+		lines = singleSpaceLines(lines, cfg)
+	} else {
+		lines = alignLines(lines, cfg)
+	}
+	if cfg.AlignPairs {
+		lines = alignPairs(lines, cfg)
+	}
+	if cfg.AlignCommentIndent {
+		lines = alignCommentIndent(lines, cfg)
+	}
+	if cfg.WrapValues > 0 {
+		lines = wrapValues(lines, cfg)
+	}
+	if cfg.Retab != "" {
+		lines = retabLines(lines, cfg)
+	}
+	return lines
+}
+
+// prepareLines applies the value pre-processing and structural passes that
+// come before alignment.
+func prepareLines(lines []string, cfg Options) ([]string, error) {
+	if cfg.JoinContinuations {
+		lines = joinValues(lines, cfg)
+	}
+	if cfg.UnicodeEquals {
+		lines = normalizeDelimiters(lines, cfg)
+	}
+	if cfg.ExpandEnv {
+		expanded, err := expandEnvLines(lines, cfg)
+		if err != nil {
+			return nil, err
+		}
+		lines = expanded
+	}
+	if len(cfg.SortListValues) > 0 {
+		lines = sortListValues(lines, cfg)
+	}
+	lines = restructure(lines, cfg)
+	if cfg.Flatten {
+		return flattenSections(lines, cfg)
+	}
+	return lines, nil
+}
+
+// formatSelectedSections formats each section selected by cfg.OnlySections on
+// its own and copies every other line through untouched. Sections keep their
+// place in the file, so SortSections and DefaultSection do not apply.
+func formatSelectedSections(lines []string, cfg Options) ([]string, error) {
+	sub := cfg
+	sub.OnlySections, sub.SortSections, sub.DefaultSection = nil, false, ""
+	var result []string
+	for _, s := range splitSections(lines) {
+		chunk := s.lines
+		if s.header != "" {
+			chunk = append([]string{s.header}, s.lines...)
+		}
+		if err := cfg.canceled(); err != nil {
+			return nil, err
+		}
+		if !sectionSelected(cfg.OnlySections, s.name()) {
+			result = append(result, chunk...)
+			continue
+		}
+		formatted, err := formatLines(chunk, sub)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, formatted...)
+	}
+	return result, nil
+}
+
+// alignIni aligns INI content according to the given configuration.
+func alignIni(scanner *bufio.Scanner, cfg Options) ([]string, error) {
+	lines, err := readLines(scanner)
+	if err != nil {
+		return nil, err
+	}
+	return alignLines(lines, cfg), nil
+}
+
+// alignLines aligns already-read INI lines according to the given configuration.
+func alignLines(lines []string, cfg Options) []string {
+	if len(lines) == 0 { // If all lines were consumed by scanner error or input was empty
+		return make([]string, 0)
+	}
+	lines = slices.Clone(lines)
+
+	for i, line := range lines {
+		if IsHeader(line) {
+			lines[i] = cfg.dialect().FormatHeader(line, cfg)
+			continue
+		}
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	for _, sp := range alignSpans(lines, cfg) {
+		if cfg.canceled() != nil {
+			break // the caller reports it
+		}
+		copy(lines[sp.start:sp.end], AlignSection(lines[sp.start:sp.end], cfg))
+	}
+	return lines
+}
+
+// alignSpan is a group of lines aligned together, lines[start:end].
+type alignSpan struct{ start, end int }
+
+// alignSpans splits lines into the groups that are aligned independently:
+// the whole file, or each section's body with PerSection. Within those, a
+// blank line ends a group with PerBlock, and a full-line comment starts a new
+// one with GroupByComments. With PerSection, headers belong to no group.
+func alignSpans(lines []string, cfg Options) []alignSpan {
+	var spans []alignSpan
+	split := func(start, end int) {
+		for i := start; i < end; i++ {
+			if (cfg.PerBlock && IsBlank(lines[i])) || (cfg.GroupByComments && cfg.IsComment(lines[i])) {
+				spans = append(spans, alignSpan{start, i})
+				start = i
+			}
+		}
+		spans = append(spans, alignSpan{start, end})
+	}
+	if !cfg.PerSection {
+		split(0, len(lines))
+		return spans
+	}
+	start := 0
+	for i, line := range lines {
+		if IsHeader(line) {
+			if i > start {
+				split(start, i)
+			}
+			start = i + 1
+		}
+	}
+	if start < len(lines) {
+		split(start, len(lines))
+	}
+	return spans
+}
+
+// AlignSection aligns the equals signs in the given lines.
+func AlignSection(lines []string, cfg Options) []string {
+	if len(lines) == 0 {
+		return make([]string, 0)
+	}
+
+	// First pass – determine the maximum key length (excluding indentation) among lines with '='.
+	maxKeyLen := 0
+	for _, line := range lines {
+		key, op, after, ok := cfg.keyValue(line)
+		if !ok || cfg.keepLossy(key, after) {
+			continue
+		}
+		if l := cfg.keyWidth(key, op); l > maxKeyLen {
+			maxKeyLen = l
+		}
+	}
+
+	result := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		original := strings.TrimRight(line, " \t") // drop trailing whitespace
+
+		// Comment, blank and header lines and lines without '=' are kept as-is
+		// (after trimming trailing whitespace).
+		key, op, after, ok := cfg.keyValue(original)
+		if !ok {
+			result = append(result, original)
+			continue
+		}
+		if cfg.keepLossy(key, after) {
+			result = append(result, line)
+			continue
+		}
+
+		// Normalize internal whitespace in value
+		right := cfg.formatValue(key, after)
+
+		spacesNeeded := max(maxKeyLen-cfg.keyWidth(key, op), 0)
+		result = append(result, cfg.formatKeyValue(key, op, strings.Repeat(" ", spacesNeeded), right))
+	}
+
+	return result
+}
+
+// singleSpaceFormat formats lines to have single spaces around '=' and trims trailing whitespace.
+func singleSpaceFormat(scanner *bufio.Scanner) ([]string, error) {
+	lines, err := readLines(scanner)
+	if err != nil {
+		return nil, err
+	}
+	return singleSpaceLines(lines, Options{}), nil
+}
+
+// singleSpaceLines is singleSpaceFormat over already-read lines.
+func singleSpaceLines(lines []string, cfg Options) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t") // remove trailing spaces
+		if left, op, after, ok := cfg.keyValue(line); ok && !cfg.keepLossy(left, after) {
+			// Normalize internal whitespace in value
+			right := cfg.formatValue(left, after)
+			result = append(result, cfg.formatKeyValue(left, op, "", right))
+		} else {
+			result = append(result, line)
+		}
+	}
+	return result
+}
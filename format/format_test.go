@@ -0,0 +1,654 @@
+package format
+
+import (
+	"bufio"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAlignSection(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{
+			name:  "basic alignment",
+			lines: []string{"key1=val1", "longerkey=val2", "k=v"},
+		},
+		{
+			name:  "with comments and blanks",
+			lines: []string{"; comment", "key1=val1", "", "longerkey=val2"},
+		},
+		{
+			name:  "no equals sign",
+			lines: []string{"key1", "key2"},
+		},
+		{
+			name:  "empty input",
+			lines: []string{},
+		},
+		{
+			name:  "mixed with and without equals",
+			lines: []string{"key1=val1", "noequals", "key2=val2"},
+		},
+		{
+			name:  "pre-aligned",
+			lines: []string{"key1 = val1", "key2 = val2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AlignSection(tt.lines, Options{})
+			assertAligned(t, got)
+		})
+	}
+}
+
+func TestSingleSpaceFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{
+			name:  "basic conversion",
+			lines: []string{"key1=val1", "key2  =  val2", "key3= val3"},
+		},
+		{
+			name:  "no equals sign",
+			lines: []string{"key1", "key2"},
+		},
+		{
+			name:  "already correct",
+			lines: []string{"key1 = val1"},
+		},
+		{
+			name:  "empty input",
+			lines: []string{},
+		},
+		{
+			name:  "comments and blanks",
+			lines: []string{"; comment", "key1=val1", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// singleSpaceFormat expects a scanner
+			input := strings.Join(tt.lines, "\n")
+			if len(tt.lines) > 0 {
+				input += "\n"
+			}
+			scanner := bufio.NewScanner(strings.NewReader(input))
+			got, err := singleSpaceFormat(scanner)
+			if err != nil {
+				t.Fatalf("singleSpaceFormat() unexpected error: %v", err)
+			}
+			assertSingleSpace(t, got)
+		})
+	}
+}
+
+func TestAlignIni(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Options
+		inputLines []string
+	}{
+		{
+			name: "global alignment",
+			cfg:  Options{PerSection: false},
+			inputLines: []string{
+				"key1=val1",
+				"[section1]",
+				"longkey=val2",
+				"k=v",
+			},
+		},
+		{
+			name: "per-section alignment",
+			cfg:  Options{PerSection: true},
+			inputLines: []string{
+				"global_key=global_value",
+				"[section1]",
+				"s1key1=val1",
+				"s1longerkey=val2",
+				"[section2]",
+				"s2key=val3",
+				"s2lk=val4",
+				"; comment in section 2",
+			},
+		},
+		{
+			name: "per-section with comments included",
+			cfg:  Options{PerSection: true},
+			inputLines: []string{
+				"[section1]",
+				"; comment1",
+				"key1=val1",
+				"[section2]",
+				"longkey=val2",
+				"; comment2",
+			},
+		},
+		{
+			name:       "empty input",
+			cfg:        Options{PerSection: false},
+			inputLines: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.Join(tt.inputLines, "\n")
+			if len(tt.inputLines) > 0 {
+				input += "\n"
+			}
+			scanner := bufio.NewScanner(strings.NewReader(input))
+			got, err := alignIni(scanner, tt.cfg)
+			if err != nil {
+				t.Fatalf("alignIni() unexpected error: %v", err)
+			}
+			if tt.cfg.PerSection {
+				var block []string
+				for _, l := range got {
+					trimmed := strings.TrimSpace(l)
+					if strings.HasPrefix(trimmed, "[") {
+						if len(block) > 0 {
+							assertAligned(t, block)
+							block = nil
+						}
+						continue
+					}
+					block = append(block, l)
+				}
+				if len(block) > 0 {
+					assertAligned(t, block)
+				}
+			} else {
+				assertAligned(t, got)
+			}
+		})
+	}
+}
+
+func TestAlignGroups(t *testing.T) {
+	lines := []string{
+		"; network settings",
+		"listen_address_for_clients=0.0.0.0",
+		"port=80",
+		"# timeouts",
+		"read=5",
+		"write=10",
+		"",
+		"idle_timeout_seconds=60",
+		"x=1",
+	}
+	tests := []struct {
+		name string
+		cfg  Options
+		want []string
+	}{
+		{
+			name: "group by comments",
+			cfg:  Options{GroupByComments: true},
+			want: []string{
+				"; network settings",
+				"listen_address_for_clients = 0.0.0.0",
+				"port                       = 80",
+				"# timeouts",
+				"read                 = 5",
+				"write                = 10",
+				"",
+				"idle_timeout_seconds = 60",
+				"x                    = 1",
+			},
+		},
+		{
+			name: "group by comments and blocks",
+			cfg:  Options{GroupByComments: true, PerBlock: true},
+			want: []string{
+				"; network settings",
+				"listen_address_for_clients = 0.0.0.0",
+				"port                       = 80",
+				"# timeouts",
+				"read  = 5",
+				"write = 10",
+				"",
+				"idle_timeout_seconds = 60",
+				"x                    = 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alignLines(slices.Clone(lines), tt.cfg)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("alignLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	// Groups compose with per-section alignment.
+	got := alignLines([]string{"[a]", "; g1", "k=1", "; g2", "longer=2", "[b]", "kk=3"}, Options{PerSection: true, GroupByComments: true})
+	want := []string{"[a]", "; g1", "k = 1", "; g2", "longer = 2", "[b]", "kk = 3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("alignLines(per-section) = %q, want %q", got, want)
+	}
+}
+
+func TestSplitOn(t *testing.T) {
+	lines := []string{"a=b=c", "filter = name=value,other=thing", "k=v"}
+	tests := []struct {
+		name        string
+		splitOn     string
+		wantAligned []string
+		wantSingle  []string
+	}{
+		{
+			name:        "first",
+			splitOn:     "first",
+			wantAligned: []string{"a      = b=c", "filter = name=value,other=thing", "k      = v"},
+			wantSingle:  []string{"a = b=c", "filter = name=value,other=thing", "k = v"},
+		},
+		{
+			name:        "last",
+			splitOn:     "last",
+			wantAligned: []string{"a=b                       = c", "filter = name=value,other = thing", "k                         = v"},
+			wantSingle:  []string{"a=b = c", "filter = name=value,other = thing", "k = v"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Options{SplitOn: tt.splitOn}
+			if got := AlignSection(lines, cfg); !slices.Equal(got, tt.wantAligned) {
+				t.Errorf("AlignSection() = %q, want %q", got, tt.wantAligned)
+			}
+			if got := singleSpaceLines(lines, cfg); !slices.Equal(got, tt.wantSingle) {
+				t.Errorf("singleSpaceLines() = %q, want %q", got, tt.wantSingle)
+			}
+		})
+	}
+}
+
+func assertAligned(t *testing.T, lines []string) {
+	t.Helper()
+	eqMin, eqMax := -1, -1
+	for i, l := range lines {
+		if !strings.Contains(l, "=") {
+			// skip non key/value lines
+			continue
+		}
+		if !strings.Contains(l, " = ") {
+			t.Fatalf("line %d not normalized around '=': %q", i, l)
+		}
+		if strings.HasSuffix(l, " ") {
+			t.Fatalf("line %d has trailing spaces: %q", i, l)
+		}
+		pos := strings.Index(l, "=")
+		leading := len(l) - len(strings.TrimLeft(l, " \t"))
+		col := pos - leading
+		if eqMin == -1 {
+			eqMin, eqMax = col, col
+		} else {
+			if col < eqMin {
+				eqMin = col
+			}
+			if col > eqMax {
+				eqMax = col
+			}
+		}
+	}
+	if eqMax-eqMin > 1 {
+		t.Fatalf("alignment columns vary more than 1 space: min %d max %d", eqMin, eqMax)
+	}
+}
+
+// assertSingleSpace verifies each line containing '=' has exactly one space on each side and no trailing whitespace.
+func assertSingleSpace(t *testing.T, lines []string) {
+	t.Helper()
+	for i, l := range lines {
+		if !strings.Contains(l, "=") {
+			continue
+		}
+		if !strings.Contains(l, " = ") {
+			t.Fatalf("line %d does not contain ' = ' delimiter: %q", i, l)
+		}
+		if strings.Count(l, " = ") != 1 {
+			t.Fatalf("line %d contains multiple ' = ' sequences: %q", i, l)
+		}
+		if strings.HasSuffix(l, " ") {
+			t.Fatalf("line %d has trailing space: %q", i, l)
+		}
+	}
+}
+
+// fuzzConfig derives a formatter configuration from the bits of mode so the
+// fuzzer explores every combination of options.
+func fuzzConfig(mode uint16) Options {
+	bit := func(n uint) bool { return mode&(1<<n) != 0 }
+	cfg := Options{
+		PerSection:         bit(0),
+		SingleSpace:        bit(1),
+		PerBlock:           bit(2),
+		GroupByComments:    bit(3),
+		SortSections:       bit(4),
+		StripComments:      bit(6),
+		AlignCommentIndent: bit(7),
+		NormalizeLists:     bit(8),
+		ExpandEnv:          bit(9),
+		GroupByPrefix:      bit(15),
+		EmptyUnset:         true,
+	}
+	if bit(5) {
+		cfg.SortKeys = []string{"*"}
+	}
+	if bit(10) {
+		cfg.SplitOn = "last"
+	}
+	if bit(11) {
+		cfg.DedupeKeys = "last"
+	}
+	cfg.BlankLines = []string{"keep", "squeeze", "sections", "keep"}[mode>>12&3]
+	if bit(14) {
+		cfg.SortListValues = []string{"a", "s.b"}
+		cfg.UniqueListValues = true
+	}
+	return cfg
+}
+
+func FuzzFormat(f *testing.F) {
+	f.Add([]byte("[s] ; note\nkey=value\nlonger_key = v\n"), uint16(0))
+	// Regression: text after a header was split after its first byte,
+	// cutting multi-byte characters in half.
+	f.Add([]byte("[s] \xc3\xa9t\xc3\xa9\nk=v\n"), uint16(0))
+	f.Add([]byte("; c\n\n[b]\nx=1\n[a]\ny = %(x)s, ${Y:-${Z}}\n"), uint16(0xffff))
+	f.Add([]byte("a = 1,,2\r\n[s]\r\nb= c;d ; e\r\n"), uint16(0x4100))
+	f.Add([]byte("[\n]\n=\n==\n [x]y\n"), uint16(0x0403))
+	// Regression: --expand-env turns $$ into $, which a second pass expands,
+	// so it is left out of the idempotence check.
+	f.Add([]byte("=$$A0"), uint16(0xffff))
+	f.Fuzz(func(t *testing.T, data []byte, mode uint16) {
+		cfg := fuzzConfig(mode)
+		lines, _ := SplitLines(string(data))
+		in := slices.Clone(lines)
+		out, err := Lines(lines, cfg)
+		if err != nil {
+			return
+		}
+		if utf8.Valid(data) {
+			for _, line := range out {
+				if !utf8.ValidString(line) {
+					t.Fatalf("valid UTF-8 input produced invalid output line %q", line)
+				}
+			}
+		}
+		checkRoundTrip(t, in, out, cfg)
+		lossy := cfg.StripComments || cfg.DedupeKeys != "" || cfg.BlankLines != "keep"
+		switch {
+		case cfg.GroupByPrefix && len(cfg.SortKeys) > 0:
+			// Grouping inserts blank lines, but a second pass adds no more.
+			if cfg.ExpandEnv {
+				break
+			}
+			if again, _ := Lines(out, cfg); !slices.Equal(again, out) {
+				t.Fatalf("grouping is not idempotent:\n%q\nthen\n%q", out, again)
+			}
+		case !lossy && len(out) != len(in):
+			t.Fatalf("formatting changed the line count from %d to %d", len(in), len(out))
+		case len(out) > len(in):
+			t.Fatalf("formatting added lines: %d to %d", len(in), len(out))
+		}
+	})
+}
+
+func TestFormatHeader(t *testing.T) {
+	tests := []struct{ line, want string }{
+		{"  [s]  ", "[s]"},
+		{"[s];note", "[s] ; note"},
+		{"[s]   #   note  ", "[s] # note"},
+		{"[s] été", "[s] été"},
+		{"[s]x", "[s] x"},
+	}
+	for _, tt := range tests {
+		if got := DialectINI.FormatHeader(tt.line, Options{}); got != tt.want {
+			t.Errorf("FormatHeader(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// iniTuple is a key as a downstream parser reads it. Bare keys have no value.
+type iniTuple struct {
+	section, key, value string
+	bare                bool
+}
+
+// iniData is everything a downstream parser reads from a file: the section
+// headers and the keys in file order. Comments and layout are not data.
+type iniData struct {
+	headers []string
+	tuples  []iniTuple
+}
+
+// extractData is the reference extractor for the round-trip invariant. Values
+// are compared after normalizeValue: collapsing whitespace runs outside quotes
+// and placeholders is a deliberate, long-standing normalization of inifmt and
+// the one carve-out from "a parser reads the same data".
+func extractData(lines []string, cfg Options) iniData {
+	d := iniData{headers: SectionNames(lines)}
+	for _, kv := range ParseKeyValues(lines, cfg) {
+		d.tuples = append(d.tuples, iniTuple{kv.Section, kv.Key, normalizeValue(kv.Value), !kv.HasValue})
+	}
+	return d
+}
+
+// withoutValues drops the values, for options that rewrite them.
+func (d iniData) withoutValues() iniData {
+	tuples := slices.Clone(d.tuples)
+	for i := range tuples {
+		tuples[i].value = ""
+	}
+	return iniData{headers: d.headers, tuples: tuples}
+}
+
+// sorted orders headers and keys, for options that reorder them.
+func (d iniData) sorted() iniData {
+	headers, tuples := slices.Clone(d.headers), slices.Clone(d.tuples)
+	slices.Sort(headers)
+	slices.SortFunc(tuples, func(a, b iniTuple) int {
+		return strings.Compare(a.section+"\x00"+a.key+"\x00"+a.value, b.section+"\x00"+b.key+"\x00"+b.value)
+	})
+	return iniData{headers: headers, tuples: tuples}
+}
+
+// resolved maps every section and key to the value a parser settles on when
+// keys repeat: the last occurrence, or the first with keepFirst.
+func (d iniData) resolved(keepFirst bool) map[[2]string]iniTuple {
+	m := make(map[[2]string]iniTuple)
+	for _, t := range d.tuples {
+		k := [2]string{t.section, t.key}
+		if _, ok := m[k]; ok && keepFirst {
+			continue
+		}
+		m[k] = t
+	}
+	return m
+}
+
+// checkRoundTrip enforces the round-trip invariant between the input and the
+// formatted output. Layout options must preserve the data exactly. The lossy
+// options get a deliberately smaller invariant:
+//   - value rewriting (--expand-env, --normalize-lists, --sort-list-values,
+//     --redact) preserves headers and keys but not values;
+//   - sorting (--sort-sections, --sort-keys) preserves the data up to order;
+//   - --dedupe-keys preserves what a parser resolves duplicate keys to;
+//   - --strip-comments and --blank-lines only touch non-data lines, so they
+//     preserve the data exactly.
+func checkRoundTrip(t *testing.T, in, out []string, cfg Options) {
+	t.Helper()
+	before, after := extractData(in, cfg), extractData(out, cfg)
+	if cfg.ExpandEnv || cfg.NormalizeLists || len(cfg.SortListValues) > 0 || len(cfg.Redact) > 0 {
+		before, after = before.withoutValues(), after.withoutValues()
+	}
+	if cfg.DedupeKeys != "" {
+		keepFirst := cfg.DedupeKeys == "first"
+		if !maps.Equal(before.resolved(keepFirst), after.resolved(keepFirst)) {
+			t.Fatalf("--dedupe-keys changed resolved keys:\n%v\n%v", before.tuples, after.tuples)
+		}
+		before, after = iniData{headers: before.headers}, iniData{headers: after.headers}
+	}
+	if cfg.SortSections || len(cfg.SortKeys) > 0 {
+		before, after = before.sorted(), after.sorted()
+	}
+	if !slices.Equal(before.headers, after.headers) {
+		t.Fatalf("formatting changed the headers from %q to %q", before.headers, after.headers)
+	}
+	if !slices.Equal(before.tuples, after.tuples) {
+		t.Fatalf("formatting changed the data from\n%v\nto\n%v", before.tuples, after.tuples)
+	}
+}
+
+func TestRoundTripPreservesData(t *testing.T) {
+	sample, err := os.ReadFile("../test.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus := []string{
+		string(sample),
+		"top=1\n[s] trailing text\nk = \"  spaced   out  \"\nq='a  b'  ;  note\n",
+		"[a]\nk=1\nk=2\n\n; about b\nb = %(k)s  ,  x\n[a]\nk=3\n",
+		"[s]\n=empty key\nbare\n  indented = v\nurl = http://x/?a=b\n",
+	}
+	// Every combination of the boolean options, --split-on and --dedupe-keys;
+	// FuzzFormat covers the rest.
+	for mode := range uint16(1 << 12) {
+		cfg := fuzzConfig(mode)
+		cfg.ExpandEnv = false
+		for _, content := range corpus {
+			lines, _ := SplitLines(content)
+			in := slices.Clone(lines)
+			out, err := Lines(lines, cfg)
+			if err != nil {
+				t.Fatalf("Lines(mode %#x) unexpected error: %v", mode, err)
+			}
+			checkRoundTrip(t, in, out, cfg)
+			if _, err := Verify(in, out, cfg); err != nil {
+				t.Fatalf("Verify(mode %#x) = %v", mode, err)
+			}
+		}
+	}
+}
+
+func TestOnlySections(t *testing.T) {
+	lines := []string{
+		"owner=platform",
+		"[other]",
+		"a   =  quirky",
+		"longer=x",
+		"[mysvc]",
+		"port=80",
+		"hostname   =  example.com",
+		"[mysvc.cache]",
+		"ttl=60",
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "glob",
+			patterns: []string{"mysvc*"},
+			want: []string{
+				"owner=platform",
+				"[other]",
+				"a   =  quirky",
+				"longer=x",
+				"[mysvc]",
+				"port     = 80",
+				"hostname = example.com",
+				"[mysvc.cache]",
+				"ttl = 60",
+			},
+		},
+		{
+			name:     "preamble",
+			patterns: []string{"@preamble"},
+			want:     append([]string{"owner = platform"}, lines[1:]...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(slices.Clone(lines), Options{OnlySections: tt.patterns})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentPrefixesSkipAlignment(t *testing.T) {
+	lines := []string{"// x = y", "key = 1", "path=C://thing"}
+	cfg := Options{CommentPrefixes: []string{"//"}}
+	want := []string{"// x = y", "key  = 1", "path = C://thing"}
+	got, err := Lines(slices.Clone(lines), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	cfg.SingleSpace = true
+	want = []string{"// x = y", "key = 1", "path = C://thing"}
+	if got, _ := Lines(slices.Clone(lines), cfg); !slices.Equal(got, want) {
+		t.Errorf("Lines(single-space) = %q, want %q", got, want)
+	}
+}
+
+func TestLinesTabWidth(t *testing.T) {
+	lines := []string{
+		"[paths]",
+		"\troot=/srv",
+		"    cache=/var/cache",
+		"log\tdir=/var/log",
+		"tmp=/tmp",
+	}
+	for _, tabWidth := range []int{2, 4, 8} {
+		got, err := Lines(slices.Clone(lines), Options{TabWidth: tabWidth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		column := -1
+		for _, line := range got[1:] {
+			expanded := expandTabs(line, tabWidth)
+			eq := strings.Index(expanded, "=")
+			if column == -1 {
+				column = eq
+			}
+			if eq != column {
+				t.Errorf("tab width %d: %q has '=' at column %d, want %d (in %q)", tabWidth, expanded, eq, column, got)
+			}
+		}
+	}
+}
+
+// expandTabs replaces the tabs of line with spaces up to the next multiple of
+// tabWidth, the way an editor displays them.
+func expandTabs(line string, tabWidth int) string {
+	var b strings.Builder
+	for _, r := range line {
+		if r == '\t' {
+			b.WriteString(strings.Repeat(" ", tabWidth-b.Len()%tabWidth))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package format
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// A Formatter formats INI files with fixed Options. It is safe for
+// concurrent use and reuses its line buffers between calls, which makes it
+// cheaper than Source when many files are formatted.
+type Formatter struct {
+	opts  Options
+	lines sync.Pool // *[]string
+}
+
+// NewFormatter returns a Formatter formatting with opts. Changing the slices
+// and patterns of opts afterwards changes the Formatter as well.
+func NewFormatter(opts Options) *Formatter {
+	return &Formatter{opts: opts, lines: sync.Pool{
+		New: func() any { return new([]string) },
+	}}
+}
+
+// Format formats the INI file src like Source and appends the result to dst.
+func (f *Formatter) Format(dst, src []byte) ([]byte, error) {
+	buf := f.lines.Get().(*[]string)
+	lines := splitLinesInto((*buf)[:0], string(src))
+	result, err := Lines(lines, f.opts)
+	if err == nil {
+		dst = appendLines(dst, result)
+	}
+	clear(lines) // let the strings be collected while pooled
+	*buf = lines
+	f.lines.Put(buf)
+	return dst, err
+}
+
+// FormatReader reads an INI file from r and writes it to w formatted like
+// Format. Nothing is written to w unless the whole file was formatted.
+func (f *Formatter) FormatReader(r io.Reader, w io.Writer) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	out, err := f.Format(nil, src)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// FormatString formats the INI file s like Format.
+func (f *Formatter) FormatString(s string) (string, error) {
+	out, err := f.Format(nil, []byte(s))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// splitLinesInto splits text into lines like SplitLines, appending them to
+// lines.
+func splitLinesInto(lines []string, text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return lines
+	}
+	for line := range strings.SplitSeq(text, "\n") {
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+	}
+	return lines
+}
+
+// appendLines appends lines to dst, each ending in LF.
+func appendLines(dst []byte, lines []string) []byte {
+	for _, line := range lines {
+		dst = append(dst, line...)
+		dst = append(dst, '\n')
+	}
+	return dst
+}
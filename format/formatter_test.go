@@ -0,0 +1,140 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+var formatterInputs = []string{
+	"",
+	"\n",
+	"a=1\nlong=2\n",
+	"[s]\r\nk = v\r\n\r\n[t]\r\nx=y",
+	"; comment\n[db]\nhost = example.com   ; primary\nport=5432\n",
+}
+
+func TestFormatterMatchesSource(t *testing.T) {
+	opts := Options{PerSection: true, SortKeys: []string{"*"}}
+	f := NewFormatter(opts)
+	for _, in := range formatterInputs {
+		want, err := Source([]byte(in), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Format([]byte("prefix\n"), []byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "prefix\n"+string(want) {
+			t.Errorf("Format(%q) = %q, want %q", in, got, "prefix\n"+string(want))
+		}
+	}
+}
+
+func TestFormatterError(t *testing.T) {
+	f := NewFormatter(Options{ExpandEnv: true})
+	if _, err := f.Format(nil, []byte("a = ${INIFMT_FORMATTER_UNSET}\n")); err == nil {
+		t.Error("Format() expected error for unset variable")
+	}
+	if got, err := f.Format(nil, []byte("a=1\n")); err != nil || string(got) != "a = 1\n" {
+		t.Errorf("Format() after error = %q, %v", got, err)
+	}
+}
+
+func TestFormatterReader(t *testing.T) {
+	for _, opts := range []Options{{}, {PerSection: true}, {SingleSpace: true}, {GroupByComments: true}} {
+		f := NewFormatter(opts)
+		for _, in := range formatterInputs {
+			want, err := Source([]byte(in), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := f.FormatReader(iotest.OneByteReader(strings.NewReader(in)), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("%+v: FormatReader(%q) wrote %q, want %q", opts, in, out.String(), want)
+			}
+			if got, err := f.FormatString(in); err != nil || got != string(want) {
+				t.Errorf("%+v: FormatString(%q) = %q, %v; want %q", opts, in, got, err, want)
+			}
+		}
+	}
+
+	f := NewFormatter(Options{ExpandEnv: true})
+	var out bytes.Buffer
+	if err := f.FormatReader(strings.NewReader("a = 1\nb = ${INIFMT_FORMATTER_UNSET}\n"), &out); err == nil || out.Len() > 0 {
+		t.Errorf("FormatReader() = %v and wrote %q, want an error and nothing written", err, out.String())
+	}
+	if err := f.FormatReader(iotest.ErrReader(iotest.ErrTimeout), &out); !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("FormatReader() = %v, want the read error", err)
+	}
+	if got, err := f.FormatString("a = ${INIFMT_FORMATTER_UNSET}\n"); err == nil || got != "" {
+		t.Errorf("FormatString() = %q, %v; want an error", got, err)
+	}
+}
+
+func TestFormatterConcurrent(t *testing.T) {
+	f := NewFormatter(Options{PerSection: true})
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Go(func() {
+			var dst []byte
+			for j := range 50 {
+				in := formatterInputs[(i+j)%len(formatterInputs)]
+				want, _ := Source([]byte(in), Options{PerSection: true})
+				got, err := f.Format(dst[:0], []byte(in))
+				if err != nil || !bytes.Equal(got, want) {
+					t.Errorf("Format(%q) = %q, %v; want %q", in, got, err, want)
+					return
+				}
+				dst = got
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// benchmarkInput is a config of a few hundred lines.
+var benchmarkInput = func() []byte {
+	var b strings.Builder
+	for s := range 20 {
+		fmt.Fprintf(&b, "[section%d]\n; settings\n", s)
+		for k := range 15 {
+			fmt.Fprintf(&b, "key_%d=value %d, %d\n", k, s, k)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}()
+
+func BenchmarkSource(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := Source(benchmarkInput, Options{PerSection: true}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkFormatter(b *testing.B) {
+	f := NewFormatter(Options{PerSection: true})
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var dst []byte
+		for pb.Next() {
+			var err error
+			if dst, err = f.Format(dst[:0], benchmarkInput); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package format
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Extensions are the extensions, without the dot, of the UTF-8 INI files
+// FormatFS formats by default.
+var Extensions = []string{"ini", "cfg", "conf", "inf"}
+
+// HasExtension reports whether name ends in one of Extensions, ignoring case.
+func HasExtension(name string) bool {
+	return slices.Contains(Extensions, strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")))
+}
+
+// Source formats the INI file src and returns the result with LF line
+// endings and a final newline, as the inifmt command writes it by default.
+func Source(src []byte, opts Options) ([]byte, error) {
+	lines, _ := SplitLines(string(src))
+	result, err := Lines(lines, opts)
+	if err != nil {
+		return nil, err
+	}
+	return appendLines(nil, result), nil
+}
+
+// FormatFS formats the files of fsys for which match returns true, or those
+// with one of Extensions when match is nil, and returns their formatted
+// contents keyed by path. Nothing is written. Files are visited in lexical
+// path order; files containing NUL bytes are binary and skipped.
+func FormatFS(fsys fs.FS, match func(path string) bool, opts Options) (map[string][]byte, error) {
+	formatted := make(map[string][]byte)
+	err := walkFS(fsys, match, opts, func(name string, _, out []byte) {
+		formatted[name] = out
+	})
+	if err != nil {
+		return nil, err
+	}
+	return formatted, nil
+}
+
+// ChangedFS returns, in lexical order, the paths of the files FormatFS would
+// format whose formatted contents differ from the files, i.e. the files that
+// are not formatted.
+func ChangedFS(fsys fs.FS, match func(path string) bool, opts Options) ([]string, error) {
+	var changed []string
+	err := walkFS(fsys, match, opts, func(name string, in, out []byte) {
+		if !bytes.Equal(in, out) {
+			changed = append(changed, name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// walkFS formats the files FormatFS selects and hands each one's contents
+// before and after formatting to visit.
+func walkFS(fsys fs.FS, match func(path string) bool, opts Options, visit func(name string, in, out []byte)) error {
+	if match == nil {
+		match = HasExtension
+	}
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := opts.canceled(); err != nil {
+			return err
+		}
+		if d.IsDir() || !match(name) {
+			return nil
+		}
+		in, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(in, 0) != -1 {
+			return nil
+		}
+		out, err := Source(in, opts)
+		if err != nil {
+			return inFile(err, name)
+		}
+		visit(name, in, out)
+		return nil
+	})
+}
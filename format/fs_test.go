@@ -0,0 +1,61 @@
+package format
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFormatFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.ini":           {Data: []byte("a=1\nlong=2\n")},
+		"conf/db.CFG":       {Data: []byte("[db]\nhost = x\nport = 5\n")},
+		"conf/crlf.conf":    {Data: []byte("k=v\r\n")},
+		"conf/notes.txt":    {Data: []byte("a=1\n")},
+		"conf/binary.ini":   {Data: []byte("a=\x00\n")},
+		"deep/x/y/unit.inf": {Data: []byte("")},
+	}
+	got, err := FormatFS(fsys, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"app.ini":           "a    = 1\nlong = 2\n",
+		"conf/db.CFG":       "[db]\nhost = x\nport = 5\n",
+		"conf/crlf.conf":    "k = v\n",
+		"deep/x/y/unit.inf": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("FormatFS() paths = %v, want %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+	}
+	for name, w := range want {
+		if string(got[name]) != w {
+			t.Errorf("FormatFS()[%q] = %q, want %q", name, got[name], w)
+		}
+	}
+
+	changed, err := ChangedFS(fsys, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app.ini", "conf/crlf.conf"}; !slices.Equal(changed, want) {
+		t.Errorf("ChangedFS() = %v, want %v", changed, want)
+	}
+
+	only, err := FormatFS(fsys, func(name string) bool { return name == "conf/notes.txt" }, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(only["conf/notes.txt"]) != "a = 1\n" || len(only) != 1 {
+		t.Errorf("FormatFS(match) = %q", only)
+	}
+}
+
+func TestFormatFSError(t *testing.T) {
+	fsys := fstest.MapFS{"a.ini": {Data: []byte("a = ${INIFMT_FS_UNSET_VAR}\n")}}
+	if _, err := FormatFS(fsys, nil, Options{ExpandEnv: true}); err == nil || !strings.HasPrefix(err.Error(), "a.ini: ") {
+		t.Errorf("FormatFS() error = %v, want one naming a.ini", err)
+	}
+}
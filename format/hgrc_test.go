@@ -0,0 +1,61 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHgrcDialect(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "sort keys between directives",
+			cfg:  Options{SortKeys: []string{"*"}},
+			in:   []string{"[extensions]", "rebase =", "histedit =", "%include ext.rc", "%unset strip", "evolve =", "absorb ="},
+			want: []string{"[extensions]", "histedit = ", "rebase   = ", "%include ext.rc", "%unset strip", "absorb   = ", "evolve   = "},
+		},
+		{
+			name: "dedupe keys",
+			cfg:  Options{DedupeKeys: "last"},
+			in:   []string{"[ui]", "%unset verbose", "verbose = true", "%unset verbose", "verbose = false"},
+			want: []string{"[ui]", "%unset verbose", "%unset verbose", "verbose = false"},
+		},
+		{
+			name: "default section",
+			cfg:  Options{DefaultSection: "ui"},
+			in:   []string{"editor = vim", "%include site.rc", "verbose = true", "[paths]", "default = https://example.com/repo"},
+			want: []string{"editor  = vim", "%include site.rc", "", "[ui]", "verbose = true", "", "[paths]", "default = https://example.com/repo"},
+		},
+		{
+			name: "indented continuation",
+			cfg:  Options{},
+			in:   []string{"[hooks]", "changegroup=", "  hg update", "  %include not.rc", "x=1"},
+			want: []string{"[hooks]", "changegroup =", "  hg update", "  %include not.rc", "x           = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Dialect = DialectHgrc
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestHgrcClassify(t *testing.T) {
+	lines := []string{"%include base.rc", "[ui]", "%unset\tverbose", "a = b", "  %unset x", "%includes = 1"}
+	want := []LineKind{LineDirective, LineHeader, LineDirective, LineKeyValue, LineContinuation, LineKeyValue}
+	if got := (Options{Dialect: DialectHgrc}).classifyLines(lines); !slices.Equal(got, want) {
+		t.Errorf("classifyLines() = %v, want %v", got, want)
+	}
+}
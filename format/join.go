@@ -0,0 +1,100 @@
+package format
+
+import "strings"
+
+// joinValues puts every value continued over several lines back on the line
+// of its key, for JoinContinuations. lines may hold continuation lines joined
+// by newlines, as after joinContinuations. The fragments of a value lose the
+// continuation marker and the indentation and are joined with a space, or
+// with nothing when JoinSeparator is "none". Comment lines between them, which
+// configparser and systemd skip, move above the joined line.
+//
+// With WrapValues, values in dialects that wrap with a marker are left to
+// wrapValues, which rejoins them as the dialect reads them before wrapping
+// them again, so that the two passes never undo each other.
+func joinValues(lines []string, cfg Options) []string {
+	d := cfg.dialect()
+	if marker, wraps := d.Continuation(); wraps && marker != "" && cfg.WrapValues > 0 {
+		return lines
+	}
+	var split []string
+	for _, line := range lines {
+		split = append(split, strings.Split(line, "\n")...)
+	}
+	lines = split
+	kinds := cfg.classifyLines(lines)
+
+	result := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if kinds[i] != LineKeyValue {
+			result = append(result, lines[i])
+			i++
+			continue
+		}
+		value, prev := lines[i], LineContext{Prev: lines[i], PrevKind: LineKeyValue}
+		var comments []string
+		j := i + 1
+		for j < len(lines) {
+			prev.Index = j
+			kind := d.Classify(lines[j], prev, cfg)
+			if kind == LineContinuation && !cfg.skippedComment(lines[j]) {
+				value = cfg.joinFragment(value, lines[j])
+				prev.Prev, prev.PrevKind = lines[j], LineContinuation
+				j++
+				continue
+			}
+			if kind != LineComment && kind != LineContinuation {
+				break
+			}
+			// Comments continue the value when the line after them does.
+			k := j
+			for k < len(lines) && !IsBlank(lines[k]) && cfg.IsComment(strings.TrimSpace(lines[k])) {
+				k++
+			}
+			prev.Index = k
+			if k == len(lines) || d.Classify(lines[k], prev, cfg) != LineContinuation {
+				break
+			}
+			indent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+			for _, comment := range lines[j:k] {
+				comments = append(comments, indent+strings.TrimLeft(comment, " \t"))
+			}
+			j = k
+		}
+		result = append(result, comments...)
+		result = append(result, value)
+		i = j
+	}
+	return result
+}
+
+// skippedComment reports whether line, a continuation line, is a comment the
+// dialect skips inside continued values rather than a part of the value.
+func (c Options) skippedComment(line string) bool {
+	return skipsCommentsInContinuations(c.dialect()) && c.IsComment(strings.TrimSpace(line))
+}
+
+// joinFragment appends the continuation line to value, in place of the
+// marker ending value when the marker is what continues it.
+func (c Options) joinFragment(value, line string) string {
+	trimmed := strings.TrimRight(value, " \t")
+	if strings.HasSuffix(trimmed, `\`) {
+		// A backslash that a line would continue anyway is part of the
+		// value, as in an indented pycfg continuation.
+		without := strings.TrimSuffix(trimmed, `\`)
+		ctx := LineContext{Index: -1, Prev: without, PrevKind: LineKeyValue}
+		if c.dialect().Classify(line, ctx, c) != LineContinuation {
+			value = without
+		}
+	}
+	fragment := strings.TrimLeft(line, " \t")
+	if c.JoinSeparator == "none" {
+		return value + fragment
+	}
+	value = strings.TrimRight(value, " \t")
+	fragment = strings.TrimRight(fragment, " \t")
+	if fragment == "" {
+		return value
+	}
+	return value + " " + fragment
+}
@@ -0,0 +1,156 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJoinContinuations(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		sep     string
+		in      string
+		want    string
+	}{
+		{
+			name:    "pycfg indented lines",
+			dialect: DialectPyCfg,
+			in:      "[options]\ninstall_requires =\n    requests\n    click>=8\nname = app\n",
+			want:    "[options]\ninstall_requires = requests click>=8\nname             = app\n",
+		},
+		{
+			name:    "pycfg comments hoisted",
+			dialect: DialectPyCfg,
+			in:      "[options]\npackages = a\n    # pinned below\n    ; and here\n    b\nzip_safe = false\n",
+			want:    "[options]\n# pinned below\n; and here\npackages = a b\nzip_safe = false\n",
+		},
+		{
+			name:    "pycfg comment after the value stays",
+			dialect: DialectPyCfg,
+			in:      "[options]\npackages = a\n    b\n# about zip_safe\nzip_safe = false\n",
+			want:    "[options]\npackages = a b\n# about zip_safe\nzip_safe = false\n",
+		},
+		{
+			name:    "pycfg backslash in the value",
+			dialect: DialectPyCfg,
+			in:      "[paths]\nroots = C:\\dir\\\n    D:\\\n",
+			want:    "[paths]\nroots = C:\\dir\\ D:\\\n",
+		},
+		{
+			name:    "systemd backslashes",
+			dialect: DialectSystemd,
+			in:      "[Service]\nExecStart=/usr/bin/app \\\n    --port 80 \\\n    --verbose\nType=simple\n",
+			want:    "[Service]\nExecStart = /usr/bin/app --port 80 --verbose\nType      = simple\n",
+		},
+		{
+			name:    "systemd comment hoisted",
+			dialect: DialectSystemd,
+			in:      "[Service]\nExecStart=/usr/bin/app \\\n# the port\n    --port 80\nType=simple\n",
+			want:    "[Service]\n# the port\nExecStart = /usr/bin/app --port 80\nType      = simple\n",
+		},
+		{
+			name:    "properties with nothing between",
+			dialect: DialectProperties,
+			sep:     "none",
+			in:      "list = one,\\\n       two,\\\n       three\nk = v\n",
+			want:    "list = one,two,three\nk    = v\n",
+		},
+		{
+			name:    "properties with a space",
+			dialect: DialectProperties,
+			in:      "list = one,\\\n       two,\\\n       three\n",
+			want:    "list = one, two, three\n",
+		},
+		{
+			name:    "properties comment is part of the value",
+			dialect: DialectProperties,
+			in:      "text = a \\\n# b\n",
+			want:    "text = a # b\n",
+		},
+		{
+			name:    "gitconfig",
+			dialect: DialectGitConfig,
+			in:      "[alias]\n\tlg = log \\\n\t\t--oneline\n",
+			want:    "[alias]\nlg = log --oneline\n",
+		},
+		{
+			name:    "reg hex values",
+			dialect: DialectReg,
+			sep:     "none",
+			in:      "Windows Registry Editor Version 5.00\n\n[HKEY_CURRENT_USER\\X]\n\"Bin\"=hex:01,02,\\\n  03,04\n",
+			want:    "Windows Registry Editor Version 5.00\n\n[HKEY_CURRENT_USER\\X]\n\"Bin\" = hex:01,02,03,04\n",
+		},
+		{
+			name:    "nothing to join",
+			dialect: DialectPyCfg,
+			in:      "[a]\nk = v\n",
+			want:    "[a]\nk = v\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Options{Dialect: tt.dialect, JoinContinuations: true, JoinSeparator: tt.sep}
+			lines, _ := SplitLines(tt.in)
+			got, err := Lines(lines, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := strings.Join(got, "\n") + "\n"; s != tt.want {
+				t.Fatalf("Lines() =\n%s\nwant\n%s", s, tt.want)
+			}
+			if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+				t.Errorf("joining is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+		})
+	}
+}
+
+func TestJoinThenWrap(t *testing.T) {
+	lines := []string{
+		"servers = alpha.example.com,\\",
+		"          beta.example.com, gamma.example.com, delta.example.com",
+		"short = a,\\",
+		"        b",
+	}
+	join := Options{Dialect: DialectProperties, JoinContinuations: true, JoinSeparator: "none"}
+	wrap := Options{Dialect: DialectProperties, WrapValues: 40}
+	joined, err := Lines(lines, join)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{
+		"servers = alpha.example.com,beta.example.com, gamma.example.com, delta.example.com",
+		"short   = a,b",
+	}; !slices.Equal(joined, want) {
+		t.Fatalf("joined =\n%s\nwant\n%s", strings.Join(joined, "\n"), strings.Join(want, "\n"))
+	}
+	wrapped, err := Lines(joined, wrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Lines(wrapped, join); !slices.Equal(again, joined) {
+		t.Errorf("joining the wrapped lines =\n%s\nwant\n%s", strings.Join(again, "\n"), strings.Join(joined, "\n"))
+	}
+
+	// Both at once wrap, and a second run changes nothing.
+	both := join
+	both.WrapValues = 40
+	once, err := Lines(lines, both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(once, wrapped) {
+		t.Errorf("joined and wrapped =\n%s\nwant\n%s", strings.Join(once, "\n"), strings.Join(wrapped, "\n"))
+	}
+	if twice, _ := Lines(once, both); !slices.Equal(twice, once) {
+		t.Errorf("joining and wrapping is not idempotent:\n%s", strings.Join(twice, "\n"))
+	}
+}
+
+func TestJoinSeparatorInvalid(t *testing.T) {
+	if _, err := Lines([]string{"a = b"}, Options{JoinContinuations: true, JoinSeparator: "tab"}); err == nil {
+		t.Error("Lines() with JoinSeparator tab: expected an error")
+	}
+}
@@ -0,0 +1,48 @@
+package format
+
+import "strings"
+
+// lossyValue reports whether normalizing value changes more than the
+// whitespace around it, i.e. collapses whitespace inside an unquoted value.
+// Continuation lines joined to the value are kept verbatim and do not count,
+// nor, with InlineCommentGap, does the gap before an inline comment.
+func (c Options) lossyValue(value string) bool {
+	head, _, _ := strings.Cut(value, "\n")
+	if idx := InlineCommentIndex(head); idx != -1 && c.InlineCommentGap > 0 {
+		return c.lossyValue(head[:idx]) || c.lossyValue(head[idx:])
+	}
+	return normalizeValue(head) != strings.TrimSpace(head)
+}
+
+// keepLossy reports whether the key/value line with key and value is left
+// untouched under Options.KeepLossy. Redacted values are always formatted.
+func (c Options) keepLossy(key, value string) bool {
+	return c.KeepLossy && c.lossyValue(value) && !(len(c.Redact) > 0 && matchesAny(c.Redact, key))
+}
+
+// LossyLines returns the 1-based numbers of the key/value lines whose value
+// formatting would change, not just re-pad: runs of whitespace inside an
+// unquoted value collapse to one space. Only the sections selected by
+// OnlySections count, and only the keys selected by MatchKeys and
+// IgnoreKeys. Options.KeepLossy leaves these lines untouched.
+func LossyLines(lines []string, cfg Options) []int {
+	var numbers []int
+	section := ""
+	for i, kind := range cfg.classifyLines(lines) {
+		switch kind {
+		case LineHeader:
+			section = HeaderName(lines[i])
+			continue
+		case LineKeyValue:
+		default:
+			continue
+		}
+		if len(cfg.OnlySections) > 0 && !sectionSelected(cfg.OnlySections, section) {
+			continue
+		}
+		if key, _, after, ok := cfg.keyValue(lines[i]); ok && cfg.keySelected(key) && cfg.lossyValue(after) {
+			numbers = append(numbers, i+1)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,51 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLossyLines(t *testing.T) {
+	lines := []string{
+		"a = one  two",
+		"b =   padded   ",
+		`c = "quoted  spaces"`,
+		"[s]",
+		"d = tab\there",
+		"; e = not  a key",
+		"f = ${HOME}  /x",
+	}
+	if got, want := LossyLines(lines, Options{}), []int{1, 5, 7}; !slices.Equal(got, want) {
+		t.Errorf("LossyLines = %v, want %v", got, want)
+	}
+	if got, want := LossyLines(lines, Options{OnlySections: []string{preambleName}}), []int{1}; !slices.Equal(got, want) {
+		t.Errorf("LossyLines(only preamble) = %v, want %v", got, want)
+	}
+}
+
+func TestKeepLossy(t *testing.T) {
+	lines := []string{"name=x", "motd=Hello,  world", "longer_key = y"}
+	motd, err := RedactPatterns([]string{"motd"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cfg  Options
+		want []string
+	}{
+		{Options{}, []string{"name       = x", "motd       = Hello, world", "longer_key = y"}},
+		{Options{KeepLossy: true}, []string{"name       = x", "motd=Hello,  world", "longer_key = y"}},
+		{Options{KeepLossy: true, SingleSpace: true}, []string{"name = x", "motd=Hello,  world", "longer_key = y"}},
+		{Options{KeepLossy: true, Redact: motd}, []string{"name       = x", "motd       = ********", "longer_key = y"}},
+	}
+	for _, tt := range tests {
+		got, err := Lines(lines, tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(%+v) =\n%s\nwant:\n%s", tt.cfg, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
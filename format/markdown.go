@@ -0,0 +1,126 @@
+package format
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MarkdownLanguages are the info strings of the fenced code blocks Markdown
+// formats.
+var MarkdownLanguages = []string{"ini", "cfg"}
+
+// BlockError reports an embedded code block that could not be formatted and
+// was left as it was.
+type BlockError struct {
+	Line int // 1-based line number of the opening fence
+	Err  error
+}
+
+func (e BlockError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+
+func (e BlockError) Unwrap() error { return e.Err }
+
+// codeBlock is a fenced code block of a Markdown document: the indices of
+// its opening and closing fence lines, the indentation of the opening fence
+// and the language named by its info string.
+type codeBlock struct {
+	open, close int
+	indent      string
+	lang        string
+}
+
+// fenceRun returns the fence character and length of the run of at least
+// three backticks or tildes that line starts with after its indentation.
+func fenceRun(line string) (indent string, char byte, n int) {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent = line[:len(line)-len(trimmed)]
+	if trimmed == "" || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return indent, 0, 0
+	}
+	char = trimmed[0]
+	for n < len(trimmed) && trimmed[n] == char {
+		n++
+	}
+	if n < 3 {
+		return indent, 0, 0
+	}
+	return indent, char, n
+}
+
+// markdownCodeBlocks returns the closed fenced code blocks of a Markdown
+// document. A block that is never closed is not returned.
+func markdownCodeBlocks(lines []string) []codeBlock {
+	var blocks []codeBlock
+	for i := 0; i < len(lines); i++ {
+		indent, char, n := fenceRun(lines[i])
+		if n == 0 {
+			continue
+		}
+		info := strings.TrimSpace(strings.TrimLeft(lines[i], " \t")[n:])
+		if char == '`' && strings.Contains(info, "`") {
+			continue // an inline code span, not a fence
+		}
+		lang, _, _ := strings.Cut(info, " ")
+		block := codeBlock{open: i, close: -1, indent: indent, lang: strings.ToLower(lang)}
+		for j := i + 1; j < len(lines); j++ {
+			_, c, m := fenceRun(lines[j])
+			rest := strings.TrimLeft(lines[j], " \t")
+			if c == char && m >= n && strings.TrimSpace(rest[m:]) == "" {
+				block.close = j
+				break
+			}
+		}
+		if block.close == -1 {
+			break
+		}
+		blocks = append(blocks, block)
+		i = block.close
+	}
+	return blocks
+}
+
+// Markdown formats the content of the fenced code blocks of a Markdown
+// document whose info string names one of MarkdownLanguages, and leaves every
+// other line, the fences included, untouched. The content of an indented
+// fence is formatted without the fence's indentation and indented again
+// afterwards. Blocks that fail to format are kept as they were and reported.
+func Markdown(lines []string, cfg Options) ([]string, []BlockError) {
+	result := make([]string, 0, len(lines))
+	var errs []BlockError
+	next := 0
+	for _, b := range markdownCodeBlocks(lines) {
+		if !slices.Contains(MarkdownLanguages, b.lang) {
+			continue
+		}
+		result = append(result, lines[next:b.open+1]...)
+		content := make([]string, 0, b.close-b.open-1)
+		for _, line := range lines[b.open+1 : b.close] {
+			content = append(content, trimIndent(line, b.indent))
+		}
+		formatted, err := Lines(content, cfg)
+		if err != nil {
+			errs = append(errs, BlockError{Line: b.open + 1, Err: err})
+			formatted = nil
+			result = append(result, lines[b.open+1:b.close]...)
+		}
+		for _, line := range formatted {
+			if line != "" {
+				line = b.indent + line
+			}
+			result = append(result, line)
+		}
+		next = b.close
+	}
+	return append(result, lines[next:]...), errs
+}
+
+// trimIndent removes indent, or as much of it as line starts with, from the
+// start of line.
+func trimIndent(line, indent string) string {
+	n := 0
+	for n < len(indent) && n < len(line) && line[n] == indent[n] {
+		n++
+	}
+	return line[n:]
+}
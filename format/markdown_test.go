@@ -0,0 +1,92 @@
+package format
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	lines := []string{
+		"# Config",
+		"",
+		"a=b outside a block",
+		"```ini",
+		"[server]",
+		"host=example.com",
+		"port   =  80",
+		"```",
+		"",
+		"```python",
+		"x=1",
+		"```",
+		"",
+		"1. List item:",
+		"",
+		"   ~~~~ CFG title=app.cfg",
+		"   name=app",
+		"   long_name=x",
+		"",
+		"   ~~~~",
+		"",
+		"````markdown",
+		"```ini",
+		"a=1",
+		"```",
+		"````",
+		"",
+		"```ini",
+		"never=closed",
+	}
+	want := []string{
+		"# Config",
+		"",
+		"a=b outside a block",
+		"```ini",
+		"[server]",
+		"host = example.com",
+		"port = 80",
+		"```",
+		"",
+		"```python",
+		"x=1",
+		"```",
+		"",
+		"1. List item:",
+		"",
+		"   ~~~~ CFG title=app.cfg",
+		"   name      = app",
+		"   long_name = x",
+		"",
+		"   ~~~~",
+		"",
+		"````markdown",
+		"```ini",
+		"a=1",
+		"```",
+		"````",
+		"",
+		"```ini",
+		"never=closed",
+	}
+	got, errs := Markdown(lines, Options{PerSection: true})
+	if len(errs) != 0 {
+		t.Fatalf("Markdown() errors = %v", errs)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Markdown() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMarkdownBlockError(t *testing.T) {
+	lines := []string{"text", "```ini", "a  =  ${INIFMT_MISSING_VAR}", "```", "```ini", "b=1", "```"}
+	got, errs := Markdown(lines, Options{ExpandEnv: true})
+	want := []string{"text", "```ini", "a  =  ${INIFMT_MISSING_VAR}", "```", "```ini", "b = 1", "```"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Markdown() = %q, want %q", got, want)
+	}
+	if len(errs) != 1 || errs[0].Line != 2 || errors.Unwrap(errs[0]) == nil {
+		t.Errorf("Markdown() errors = %v, want one for line 2", errs)
+	}
+}
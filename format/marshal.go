@@ -0,0 +1,291 @@
+package format
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal encodes v as an INI file formatted with opts, producing exactly what
+// formatting the same content with Lines would. v may be a
+// map[string]map[string]string, a map[string]map[string]any, or a struct (or
+// pointer to one).
+//
+// Struct fields tagged `ini:"name"` (or named like the field when untagged)
+// become sections when they are structs or maps, and keys of the preamble
+// otherwise. A tag of "-" skips the field. Keys follow struct field order;
+// map keys are sorted, and the "" section of a map holds preamble keys.
+//
+// Values are strings, booleans, numbers, time.Duration, encoding.TextMarshaler
+// implementations, or slices of those, which are joined with ", ". Values that
+// would not read back unchanged, such as ones with surrounding or repeated
+// whitespace or an inline comment marker, are double-quoted. Values with
+// newlines and keys that cannot be written are rejected.
+func Marshal(v any, opts Options) ([]byte, error) {
+	doc, err := marshalDocument(reflect.ValueOf(v), opts)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, s := range doc {
+		if s.name != "" || len(s.keys) > 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+		}
+		if s.name != "" {
+			lines = append(lines, "["+s.name+"]")
+		}
+		for _, kv := range s.keys {
+			lines = append(lines, kv[0]+" = "+kv[1])
+		}
+	}
+	lines, err = Lines(lines, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// marshalSection is a section to encode; the preamble has no name.
+type marshalSection struct {
+	name string
+	keys [][2]string
+}
+
+// marshalDocument collects the sections of v, the preamble first.
+func marshalDocument(v reflect.Value, opts Options) ([]marshalSection, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, &TypeError{Reason: fmt.Sprintf("cannot marshal nil %s", v.Type())}
+		}
+		v = v.Elem()
+	}
+	doc := []marshalSection{{}}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, &TypeError{Reason: fmt.Sprintf("cannot marshal %s: keys must be strings", v.Type())}
+		}
+		for _, name := range sortedMapKeys(v) {
+			keys, err := marshalKeys(name, v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())), opts)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				doc[0].keys = keys
+				continue
+			}
+			if err := checkSectionName(name); err != nil {
+				return nil, err
+			}
+			doc = append(doc, marshalSection{name: name, keys: keys})
+		}
+	case reflect.Struct:
+		for _, f := range iniFields(v) {
+			if isSectionValue(f.value) {
+				if err := checkSectionName(f.name); err != nil {
+					return nil, err
+				}
+				keys, err := marshalKeys(f.name, f.value, opts)
+				if err != nil {
+					return nil, err
+				}
+				doc = append(doc, marshalSection{name: f.name, keys: keys})
+				continue
+			}
+			kv, err := marshalKey("", f.name, f.value, opts)
+			if err != nil {
+				return nil, err
+			}
+			doc[0].keys = append(doc[0].keys, kv)
+		}
+	default:
+		return nil, &TypeError{Reason: fmt.Sprintf("cannot marshal %s", v.Type())}
+	}
+	return doc, nil
+}
+
+// marshalKeys encodes the keys of a section given as a map or struct.
+func marshalKeys(section string, v reflect.Value, opts Options) ([][2]string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	var keys [][2]string
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, &TypeError{Path: section, Reason: fmt.Sprintf("keys must be strings, not %s", v.Type().Key())}
+		}
+		for _, key := range sortedMapKeys(v) {
+			kv, err := marshalKey(section, key, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), opts)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, kv)
+		}
+	case reflect.Struct:
+		for _, f := range iniFields(v) {
+			kv, err := marshalKey(section, f.name, f.value, opts)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, kv)
+		}
+	default:
+		return nil, &TypeError{Path: section, Reason: fmt.Sprintf("cannot marshal %s as a section", v.Type())}
+	}
+	return keys, nil
+}
+
+// marshalKey encodes one key and its value, rejecting either when the line
+// would not read back as the same pair.
+func marshalKey(section, key string, v reflect.Value, opts Options) ([2]string, error) {
+	path := key
+	if section != "" {
+		path = section + "." + key
+	}
+	if key == "" || strings.TrimSpace(key) != key || strings.ContainsAny(key, "=\r\n") ||
+		strings.HasPrefix(key, "[") || opts.IsComment(key) {
+		return [2]string{}, &TypeError{Path: path, Reason: fmt.Sprintf("key %q cannot be written", key)}
+	}
+	value, err := scalarText(v)
+	if err != nil {
+		return [2]string{}, &TypeError{Path: path, Err: err}
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return [2]string{}, &TypeError{Path: path, Reason: "values cannot contain newlines"}
+	}
+	if opts.SplitOn == "last" && strings.Contains(value, "=") {
+		return [2]string{}, &TypeError{Path: path, Reason: "values cannot contain '=' when splitting on the last one"}
+	}
+	return [2]string{key, quoteValue(value)}, nil
+}
+
+// checkSectionName rejects names that would not read back as the same header.
+func checkSectionName(name string) error {
+	if strings.TrimSpace(name) != name || strings.ContainsAny(name, "]\r\n") {
+		return &TypeError{Reason: fmt.Sprintf("section name %q cannot be written", name)}
+	}
+	return nil
+}
+
+// iniField is an encodable struct field and its INI name.
+type iniField struct {
+	name  string
+	value reflect.Value
+}
+
+// iniFields returns the exported fields of the struct v that are not skipped
+// with `ini:"-"`, in declaration order.
+func iniFields(v reflect.Value) []iniField {
+	var fields []iniField
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("ini"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields = append(fields, iniField{name: name, value: v.Field(i)})
+	}
+	return fields
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// isSectionValue reports whether a struct field holds a section rather than
+// a key: a map or struct that does not marshal itself as text.
+func isSectionValue(v reflect.Value) bool {
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) || t == reflect.TypeFor[time.Time]() {
+		return false
+	}
+	return t.Kind() == reflect.Map || t.Kind() == reflect.Struct
+}
+
+// scalarText renders a value as INI value text.
+func scalarText(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		if v.Kind() == reflect.Pointer && v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for i := range items {
+			item, err := scalarText(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(item, ",") {
+				return "", fmt.Errorf("list item %q contains a comma", item)
+			}
+			items[i] = item
+		}
+		return strings.Join(items, ", "), nil
+	}
+	return "", fmt.Errorf("cannot marshal %s as a value", v.Type())
+}
+
+// quoteValue double-quotes value when formatting or parsing would not give it
+// back unchanged: surrounding or repeated whitespace, an inline comment marker,
+// or a leading quote. Backslashes and quotes inside are escaped.
+func quoteValue(value string) string {
+	if value == normalizeValue(value) && InlineCommentIndex(value) == -1 &&
+		!strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// sortedMapKeys returns the string keys of the map v in sorted order.
+func sortedMapKeys(v reflect.Value) []string {
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,198 @@
+package format
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type marshalServer struct {
+	Host    string        `ini:"host"`
+	Port    int           `ini:"port"`
+	Timeout time.Duration `ini:"timeout"`
+	Tags    []string      `ini:"tags"`
+	Addr    netip.Addr    `ini:"addr"`
+	secret  string
+}
+
+type marshalConfig struct {
+	Name     string            `ini:"name"`
+	Debug    bool              `ini:"debug"`
+	Server   marshalServer     `ini:"server"`
+	Limits   map[string]any    `ini:"limits"`
+	Internal string            `ini:"-"`
+	Extra    map[string]string // untagged: named like the field
+}
+
+func TestMarshal(t *testing.T) {
+	cfg := marshalConfig{
+		Name:  "demo",
+		Debug: true,
+		Server: marshalServer{
+			Host:    "example.com",
+			Port:    8080,
+			Timeout: 90 * time.Second,
+			Tags:    []string{"a", "b"},
+			Addr:    netip.MustParseAddr("10.0.0.1"),
+			secret:  "hidden",
+		},
+		Limits:   map[string]any{"ratio": 0.5, "max_connections": uint16(100)},
+		Internal: "skipped",
+		Extra:    map[string]string{"note": "a  b", "color": "#fff", "motto": "x ; y"},
+	}
+	want := `name  = demo
+debug = true
+
+[server]
+host    = example.com
+port    = 8080
+timeout = 1m30s
+tags    = a, b
+addr    = 10.0.0.1
+
+[limits]
+max_connections = 100
+ratio           = 0.5
+
+[Extra]
+color = #fff
+motto = "x ; y"
+note  = "a  b"
+`
+	for _, v := range []any{cfg, &cfg} {
+		got, err := Marshal(v, Options{PerSection: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Marshal(%T) =\n%s\nwant:\n%s", v, got, want)
+		}
+	}
+}
+
+func TestMarshalMaps(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		opts Options
+		want string
+	}{
+		{
+			name: "strings",
+			v: map[string]map[string]string{
+				"b":   {"z": "1", "long_key": "2"},
+				"a":   {"k": "v"},
+				"":    {"top": "level"},
+				"c d": {},
+			},
+			want: "top      = level\n\n[a]\nk        = v\n\n[b]\nlong_key = 2\nz        = 1\n\n[c d]\n",
+		},
+		{
+			name: "any",
+			v:    map[string]map[string]any{"s": {"n": 3, "ok": false, "f": 1.25, "list": []int{1, 2}}},
+			want: "[s]\nf    = 1.25\nlist = 1, 2\nn    = 3\nok   = false\n",
+		},
+		{
+			name: "options",
+			v:    map[string]map[string]string{"s": {"a": "1", "bbb": "2"}},
+			opts: Options{SingleSpace: true},
+			want: "[s]\na = 1\nbbb = 2\n",
+		},
+		{
+			name: "empty",
+			v:    map[string]map[string]string{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.v, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: Marshal() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalMatchesFormatter(t *testing.T) {
+	v := map[string]map[string]string{
+		"":       {"x": "1"},
+		"server": {"host": "example.com", "port": "80", "path": "/a b"},
+	}
+	for _, opts := range []Options{{}, {PerBlock: true}, {SortSections: true}, {SingleSpace: true}} {
+		got, err := Marshal(v, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, _ := SplitLines(string(got))
+		formatted, err := Lines(lines, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(lines, formatted) {
+			t.Errorf("Marshal(%+v) is not formatted: %q, formatter gives %q", opts, lines, formatted)
+		}
+		kvs := ParseKeyValues(lines, opts)
+		for section, keys := range v {
+			for key, value := range keys {
+				path := key
+				if section != "" {
+					path = section + "." + key
+				}
+				if kv, ok := FindKey(kvs, path); !ok || kv.Value != value {
+					t.Errorf("Marshal(%+v): %s = %q, want %q", opts, path, kv.Value, value)
+				}
+			}
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		opts Options
+		want string
+	}{
+		{name: "newline", v: map[string]map[string]string{"s": {"k": "a\nb"}}, want: "s.k: values cannot contain newlines"},
+		{name: "delimiter key", v: map[string]map[string]string{"s": {"a=b": "1"}}, want: `s.a=b: key "a=b" cannot be written`},
+		{name: "comment key", v: map[string]map[string]string{"": {"; k": "1"}}, want: `; k: key "; k" cannot be written`},
+		{name: "header key", v: map[string]map[string]string{"s": {"[k]": "1"}}, want: "cannot be written"},
+		{name: "empty key", v: map[string]map[string]string{"s": {"": "1"}}, want: "cannot be written"},
+		{name: "section", v: map[string]map[string]string{"a]b": {}}, want: `section name "a]b" cannot be written`},
+		{name: "split on last", v: map[string]map[string]string{"s": {"k": "a=b"}}, opts: Options{SplitOn: "last"}, want: "s.k: values cannot contain '='"},
+		{name: "list comma", v: map[string]map[string]any{"s": {"k": []string{"a,b"}}}, want: `s.k: list item "a,b" contains a comma`},
+		{name: "value type", v: map[string]map[string]any{"s": {"k": struct{}{}}}, want: "s.k: cannot marshal struct {} as a value"},
+		{name: "top level", v: []string{"a"}, want: "cannot marshal []string"},
+		{name: "nil", v: (*marshalConfig)(nil), want: "cannot marshal nil"},
+	}
+	for _, tt := range tests {
+		_, err := Marshal(tt.v, tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Marshal() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	tests := []struct{ value, want string }{
+		{"plain", "plain"},
+		{"", ""},
+		{"#fff", "#fff"},
+		{"a b", "a b"},
+		{"a  b", `"a  b"`},
+		{" lead", `" lead"`},
+		{"x # y", `"x # y"`},
+		{`"quoted"`, `"\"quoted\""`},
+		{`back\slash and  space`, `"back\\slash and  space"`},
+	}
+	for _, tt := range tests {
+		if got := quoteValue(tt.value); got != tt.want {
+			t.Errorf("quoteValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
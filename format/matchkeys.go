@@ -0,0 +1,69 @@
+package format
+
+import (
+	"slices"
+	"strings"
+)
+
+// filtersKeys reports whether MatchKeys or IgnoreKeys limit formatting to
+// some of the keys.
+func (c Options) filtersKeys() bool {
+	return c.MatchKeys != nil || c.IgnoreKeys != nil
+}
+
+// keySelected reports whether the key/value line with key is formatted under
+// MatchKeys and IgnoreKeys. The key is matched as written, trimmed.
+func (c Options) keySelected(key string) bool {
+	key = strings.TrimSpace(key)
+	if c.MatchKeys != nil && !c.MatchKeys.MatchString(key) {
+		return false
+	}
+	return c.IgnoreKeys == nil || !c.IgnoreKeys.MatchString(key)
+}
+
+// formatSelectedKeys realigns the key/value lines whose key MatchKeys and
+// IgnoreKeys select and copies every other line through byte for byte. The
+// selected lines of each alignment group form a column of their own, as if
+// the other lines were not there. Only alignment and value formatting apply:
+// the structural passes would move or drop lines that are not selected.
+func formatSelectedKeys(lines []string, cfg Options) ([]string, error) {
+	result := slices.Clone(lines)
+	selected := make([]bool, len(lines))
+	section := ""
+	for i, line := range lines {
+		if IsHeader(line) {
+			section = HeaderName(line)
+			continue
+		}
+		if len(cfg.OnlySections) > 0 && !sectionSelected(cfg.OnlySections, section) {
+			continue
+		}
+		if key, _, _, ok := cfg.keyValue(line); ok && cfg.keySelected(key) {
+			selected[i] = true
+		}
+	}
+	for _, sp := range alignSpans(lines, cfg) {
+		if err := cfg.canceled(); err != nil {
+			return nil, err
+		}
+		var picked []int
+		for i := sp.start; i < sp.end; i++ {
+			if selected[i] {
+				picked = append(picked, i)
+			}
+		}
+		chunk := make([]string, len(picked))
+		for j, i := range picked {
+			chunk[j] = lines[i]
+		}
+		if cfg.SingleSpace {
+			chunk = singleSpaceLines(chunk, cfg)
+		} else {
+			chunk = AlignSection(chunk, cfg)
+		}
+		for j, i := range picked {
+			result[i] = chunk[j]
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchKeys(t *testing.T) {
+	mine := regexp.MustCompile(`^myapp_`)
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "only matching keys realigned",
+			cfg:  Options{MatchKeys: mine},
+			in:   []string{"[shared]  ", "other=1", "myapp_port=80", "a_very_long_foreign_key   =  x", "myapp_x =  2  ", "  # note  ", ""},
+			want: []string{"[shared]  ", "other=1", "myapp_port = 80", "a_very_long_foreign_key   =  x", "myapp_x    = 2", "  # note  ", ""},
+		},
+		{
+			name: "ignore keys",
+			cfg:  Options{IgnoreKeys: mine},
+			in:   []string{"a=1", "myapp_long_key=2", "bb = 3"},
+			want: []string{"a  = 1", "myapp_long_key=2", "bb = 3"},
+		},
+		{
+			name: "both",
+			cfg:  Options{MatchKeys: regexp.MustCompile(`^db`), IgnoreKeys: regexp.MustCompile(`pass`)},
+			in:   []string{"db_host=h", "db_password=p", "port=1", "db=x"},
+			want: []string{"db_host = h", "db_password=p", "port=1", "db      = x"},
+		},
+		{
+			name: "per section",
+			cfg:  Options{MatchKeys: mine, PerSection: true},
+			in:   []string{"[a]", "myapp_a=1", "[b]", "myapp_long=2", "myapp_b=3"},
+			want: []string{"[a]", "myapp_a = 1", "[b]", "myapp_long = 2", "myapp_b    = 3"},
+		},
+		{
+			name: "single space",
+			cfg:  Options{MatchKeys: mine, SingleSpace: true},
+			in:   []string{"myapp_a   =   1", "b   =   2"},
+			want: []string{"myapp_a = 1", "b   =   2"},
+		},
+		{
+			name: "matched against the trimmed key as written",
+			cfg:  Options{MatchKeys: regexp.MustCompile(`^Port$`)},
+			in:   []string{"  Port   =8080", "port=1"},
+			want: []string{"Port = 8080", "port=1"},
+		},
+		{
+			name: "only sections",
+			cfg:  Options{MatchKeys: mine, OnlySections: []string{"b"}},
+			in:   []string{"[a]", "myapp_a=1", "[b]", "myapp_b=2"},
+			want: []string{"[a]", "myapp_a=1", "[b]", "myapp_b = 2"},
+		},
+		{
+			name: "structure is left alone",
+			cfg:  Options{MatchKeys: mine, SortSections: true, StripComments: true},
+			in:   []string{"[b]", "; c", "myapp_b=2", "[a]", "myapp_a=1"},
+			want: []string{"[b]", "; c", "myapp_b = 2", "[a]", "myapp_a = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if again, _ := Lines(got, tt.cfg); !slices.Equal(again, got) {
+				t.Errorf("formatting is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+		})
+	}
+}
+
+func TestMatchKeysLossy(t *testing.T) {
+	cfg := Options{MatchKeys: regexp.MustCompile(`^a$`)}
+	if got := LossyLines([]string{"a = x  y", "b = x  y"}, cfg); !slices.Equal(got, []int{1}) {
+		t.Errorf("LossyLines() = %v, want [1]", got)
+	}
+}
@@ -0,0 +1,94 @@
+package format
+
+import (
+	"slices"
+	"strings"
+)
+
+// NestAll is the Options.Nest depth that nests every dot-separated component
+// of a key but the last.
+const NestAll = -1
+
+// nestKeys moves the preamble keys whose names contain dots, with the comments
+// attached to them, into sections named by their first depth components (all
+// but the last with NestAll), keeping the rest of the name as the key:
+// "server.http.port = 8080" becomes "port = 8080" in [server.http]. Sections
+// follow the preamble in the order their keys first occur; keys for a
+// section the file already has are appended to it. Keys without a dot, and
+// comments standing on their own, stay in the preamble, as does everything up
+// to its last directive.
+func nestKeys(sections []*section, depth int, cfg Options) []*section {
+	fixed, rest := afterLastDirective(sections[0].lines, cfg)
+	blocks, trailing, _ := splitEntries(rest, cfg)
+	var kept, order []string
+	nested := make(map[string][]string)
+	for i, block := range blocks {
+		var keep []string
+		for _, e := range block {
+			name, line, ok := nestEntry(e, depth)
+			if !ok {
+				keep = append(keep, e.comments...)
+				keep = append(keep, e.line)
+				continue
+			}
+			if _, seen := nested[name]; !seen {
+				order = append(order, name)
+			}
+			nested[name] = append(append(nested[name], e.comments...), line)
+		}
+		keep = append(keep, trailing[i]...)
+		if len(keep) > 0 {
+			if len(kept) > 0 {
+				kept = append(kept, "")
+			}
+			kept = append(kept, keep...)
+		}
+	}
+	if len(order) == 0 {
+		return sections
+	}
+	if len(kept) > 0 || len(fixed) > 0 {
+		kept = append(kept, "")
+	}
+	sections[0].lines = append(fixed, kept...)
+
+	var added []*section
+	for _, name := range order {
+		i := slices.IndexFunc(sections[1:], func(s *section) bool { return s.name() == name })
+		if i == -1 {
+			added = append(added, &section{header: "[" + name + "]", lines: append(nested[name], "")})
+			continue
+		}
+		s := sections[i+1]
+		end := len(s.lines)
+		for end > 0 && IsBlank(s.lines[end-1]) {
+			end--
+		}
+		s.lines = slices.Insert(s.lines, end, nested[name]...)
+	}
+	if len(added) > 0 && len(sections) == 1 {
+		last := added[len(added)-1]
+		last.lines = last.lines[:len(last.lines)-1]
+	}
+	return slices.Insert(sections, 1, added...)
+}
+
+// nestEntry splits the key of e after its first depth dot-separated
+// components into a section name and the line with the rest of the key. ok
+// is false for keys without a dot and for names with empty components.
+func nestEntry(e entry, depth int) (name, line string, ok bool) {
+	parts := strings.Split(e.key, ".")
+	if len(parts) < 2 || slices.Contains(parts, "") || strings.Contains(e.key, "]") {
+		return "", "", false
+	}
+	n := len(parts) - 1
+	if depth != NestAll && depth < n {
+		n = depth
+	}
+	i := strings.Index(e.line, e.key)
+	if i == -1 {
+		return "", "", false
+	}
+	rest := strings.Join(parts[n:], ".")
+	return strings.Join(parts[:n], "."), e.line[:i] + rest + e.line[i+len(e.key):], true
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNest(t *testing.T) {
+	lines := []string{
+		"; inherited flat file",
+		"name = app",
+		"",
+		"# listen port",
+		"server.http.port = 8080",
+		"server.http.host = 0.0.0.0",
+		"db.user = admin",
+		"server.debug",
+		"",
+		".hidden = 1",
+		"[db]",
+		"pool = 5",
+		"",
+	}
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+	}{
+		{"all", NestAll, []string{
+			"; inherited flat file",
+			"name = app",
+			"",
+			".hidden = 1",
+			"",
+			"[server.http]",
+			"# listen port",
+			"port = 8080",
+			"host = 0.0.0.0",
+			"",
+			"[server]",
+			"debug",
+			"",
+			"[db]",
+			"pool = 5",
+			"user = admin",
+			"",
+		}},
+		{"depth 1", 1, []string{
+			"; inherited flat file",
+			"name = app",
+			"",
+			".hidden = 1",
+			"",
+			"[server]",
+			"# listen port",
+			"http.port = 8080",
+			"http.host = 0.0.0.0",
+			"debug",
+			"",
+			"[db]",
+			"pool = 5",
+			"user = admin",
+			"",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Lines(lines, Options{SingleSpace: true, Nest: tt.depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Lines(nest) =\n%s\nwant:\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestNestDefaultSection(t *testing.T) {
+	lines := []string{"x = 0", "a.b = 1", "a.c = 2"}
+	want := []string{"[main]", "x = 0", "", "[a]", "b = 1", "c = 2"}
+	got, err := Lines(lines, Options{SingleSpace: true, Nest: NestAll, DefaultSection: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines(nest) = %q, want %q", got, want)
+	}
+}
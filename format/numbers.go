@@ -0,0 +1,124 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// modeKeyWords mark the keys whose values NormalizeNumbers leaves alone, as
+// their leading zeros may make them octal: file modes and umasks.
+var modeKeyWords = []string{"mode", "umask", "perm"}
+
+// isModeKey reports whether key names a value that may be read as octal.
+func isModeKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, w := range modeKeyWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// identifierKeyWords mark the keys whose values NormalizeNumbers leaves
+// alone, as they are identifiers whose leading zeros belong to them: postal
+// codes, IDs and the like. One of them must be a whole word of the key.
+var identifierKeyWords = []string{
+	"id", "zip", "zipcode", "postcode", "postal", "code", "pin", "phone",
+	"serial", "account", "sku", "isbn", "ean", "upc",
+}
+
+// isIdentifierKey reports whether key names a value that may be an
+// identifier padded with zeros, such as zip, user_id or postalCode.
+func isIdentifierKey(key string) bool {
+	for _, w := range keyWords(key) {
+		if slices.Contains(identifierKeyWords, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWords splits key into its lowercased words, at characters other than
+// letters and digits and where a lowercase letter or digit is followed by an
+// uppercase one.
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	prev := rune(0)
+	for _, r := range key {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = 0
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			words, word = appendWord(words, word), nil
+		}
+		if r == 0 {
+			words, word = appendWord(words, word), nil
+		} else {
+			word = append(word, unicode.ToLower(r))
+		}
+		prev = r
+	}
+	return appendWord(words, word)
+}
+
+// appendWord appends word to words unless it is empty.
+func appendWord(words []string, word []rune) []string {
+	if len(word) == 0 {
+		return words
+	}
+	return append(words, string(word))
+}
+
+// normalizeNumber rewrites value, the normalized value of key, when it is a
+// plain integer: a decimal loses its redundant leading zeros, keeping a
+// single 0, and a hexadecimal number gets a lowercase 0x prefix and digits in
+// hexCase ("lower", or "", or "upper"). The zeros padding a hexadecimal number
+// to a width are kept. An inline comment is kept; anything else, quoted
+// values, decimals such as 1.10, versions, 0o17 and the values of mode and
+// identifier keys included, is returned unchanged.
+func normalizeNumber(key, value, hexCase string) string {
+	if isModeKey(key) || isIdentifierKey(key) {
+		return value
+	}
+	number, comment := value, ""
+	if idx := InlineCommentIndex(value); idx != -1 {
+		number = strings.TrimRight(value[:idx], " \t")
+		comment = value[len(number):]
+	}
+	sign := ""
+	if number != "" && (number[0] == '-' || number[0] == '+') {
+		sign, number = number[:1], number[1:]
+	}
+	switch {
+	case isDigits(number, "0123456789"):
+		number = strings.TrimLeft(number, "0")
+		if number == "" {
+			number = "0"
+		}
+	case len(number) > 2 && (number[:2] == "0x" || number[:2] == "0X") && isDigits(number[2:], "0123456789abcdefABCDEF"):
+		digits := strings.ToLower(number[2:])
+		if hexCase == "upper" {
+			digits = strings.ToUpper(digits)
+		}
+		number = "0x" + digits
+	default:
+		return value
+	}
+	return sign + number + comment
+}
+
+// isDigits reports whether s is made of one or more of the bytes in digits.
+func isDigits(s, digits string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(digits, s[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
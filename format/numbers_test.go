@@ -0,0 +1,75 @@
+package format
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		key, value, hexCase string
+		want                string
+	}{
+		{"port", "007", "", "7"},
+		{"port", "0008", "", "8"},
+		{"port", "8", "", "8"},
+		{"port", "0", "", "0"},
+		{"port", "000", "", "0"},
+		{"port", "-007", "", "-7"},
+		{"port", "+010", "", "+10"},
+		{"port", "0x1A", "", "0x1a"},
+		{"port", "0X1a", "", "0x1a"},
+		{"port", "0X1a", "lower", "0x1a"},
+		{"port", "0x1a", "upper", "0x1A"},
+		{"port", "0x00FF", "", "0x00ff"},
+		{"port", "007 ; seven", "", "7 ; seven"},
+		{"port", "1.10", "", "1.10"},
+		{"port", "1.02.3", "", "1.02.3"},
+		{"port", "0o17", "", "0o17"},
+		{"port", "0b101", "", "0b101"},
+		{"port", "0x", "", "0x"},
+		{"port", "0xG1", "", "0xG1"},
+		{"port", "007a", "", "007a"},
+		{"port", `"007"`, "", `"007"`},
+		{"port", "'0x1A'", "", "'0x1A'"},
+		{"port", "0 7", "", "0 7"},
+		{"port", "-", "", "-"},
+		{"port", "", "", ""},
+		{"port", "; only a comment", "", "; only a comment"},
+		{"mode", "0755", "", "0755"},
+		{"UMask", "0022", "", "0022"},
+		{"file_perms", "0644", "", "0644"},
+		{"zip", "02134", "", "02134"},
+		{"id", "007", "", "007"},
+		{"user_id", "0042", "", "0042"},
+		{"postalCode", "01001", "", "01001"},
+		{"userID", "0x00FF", "", "0x00FF"},
+		{"Account-Number", "000123", "", "000123"},
+		{"width", "007", "", "7"},
+		{"valid", "010", "", "10"},
+		{"codec", "010", "", "10"},
+	}
+	for _, tt := range tests {
+		if got := normalizeNumber(tt.key, tt.value, tt.hexCase); got != tt.want {
+			t.Errorf("normalizeNumber(%q, %q, %q) = %q, want %q", tt.key, tt.value, tt.hexCase, got, tt.want)
+		}
+	}
+}
+
+func TestLinesNormalizeNumbers(t *testing.T) {
+	in := []string{"[a]", "port = 0080", "mask=0X1F", "version = 1.02.3", `id = "007"`, "mode = 0755", "zip = 02134", "agent_id = 007"}
+	want := []string{"[a]", "port     = 80", "mask     = 0x1f", "version  = 1.02.3", `id       = "007"`, "mode     = 0755", "zip      = 02134", "agent_id = 007"}
+	got, err := Lines(in, Options{NormalizeNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	if got, _ := Lines(in[:2], Options{}); got[1] != "port = 0080" {
+		t.Errorf("Lines() without NormalizeNumbers = %q, want the number kept", got[1])
+	}
+	if err := (Options{HexCase: "mixed"}).Validate(); err == nil {
+		t.Error("Validate() accepted HexCase mixed")
+	}
+}
@@ -0,0 +1,67 @@
+package format
+
+import "strings"
+
+// cutOperator moves the cut of line, split by the dialect into before and
+// after, to the '=' of the assignment operator at the delimiter, so that
+// before ends with the rest of the operator: "k += v" is cut into "k +" and
+// " v", and a pycfg "k := v", which the dialect cuts at the ':', likewise.
+func (c Options) cutOperator(line, before, after string) (string, string) {
+	at := len(before)
+	for _, op := range c.Operators {
+		prefix := op[:len(op)-1]
+		switch {
+		case line[at] == '=' && strings.HasSuffix(before, prefix):
+			return before, after
+		case strings.HasPrefix(line[at:], op):
+			return line[:at+len(prefix)], line[at+len(op):]
+		}
+	}
+	return before, after
+}
+
+// splitOperator splits before, the text before the '=' of a key/value line,
+// into the text of the key and the rest of the assignment operator it ends
+// with, "" for a plain '='. The operator must end before: in "k + = v" the
+// key is "k +". A key that would be empty without it keeps the operator text.
+func (c Options) splitOperator(before string) (key, prefix string) {
+	for _, op := range c.Operators {
+		prefix = op[:len(op)-1]
+		if name, ok := strings.CutSuffix(before, prefix); ok && strings.TrimSpace(name) != "" {
+			return name, prefix
+		}
+	}
+	return before, ""
+}
+
+// validOperator reports whether op can be an entry of Operators: an '='
+// following one or more other characters, none of them whitespace.
+func validOperator(op string) bool {
+	prefix, ok := strings.CutSuffix(op, "=")
+	return ok && prefix != "" && !strings.ContainsAny(prefix, "=[ \t")
+}
+
+// keyWidth returns the columns key and the rest of op, its delimiter, take
+// before the '='.
+func (c Options) keyWidth(key, op string) int {
+	return c.width(key) + len(op) - 1
+}
+
+// formatKeyValue renders a key/value line like Dialect.FormatKeyValue with op,
+// the delimiter returned by keyValue, in place of '='. The rest of an
+// assignment operator takes the columns before the '=', borrowing them from
+// the padding, so the '=' stays in the column of the other lines.
+func (c Options) formatKeyValue(key, op, padding, value string) string {
+	d := c.dialect()
+	prefix := strings.TrimSuffix(op, "=")
+	if prefix == "" {
+		return d.FormatKeyValue(key, padding, value)
+	}
+	line := d.FormatKeyValue(key, padding+strings.Repeat(" ", len(prefix)), value)
+	at := len(key) + len(padding)
+	if i := strings.IndexByte(line[at:], '='); i != -1 && i >= len(prefix) && strings.Trim(line[at:at+i], " ") == "" {
+		at += i
+		return line[:at-len(prefix)] + op + line[at+1:]
+	}
+	return line
+}
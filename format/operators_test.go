@@ -0,0 +1,122 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOperators(t *testing.T) {
+	ops := []string{"+=", "?=", ":="}
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "append",
+			cfg:  Options{Operators: ops},
+			in:   []string{"[a]", "name=app", "DEPENDS+=zlib", "x = 1"},
+			want: []string{"[a]", "name     = app", "DEPENDS += zlib", "x        = 1"},
+		},
+		{
+			name: "default",
+			cfg:  Options{Operators: ops},
+			in:   []string{"PREFIX ?= /usr", "CC=gcc"},
+			want: []string{"PREFIX ?= /usr", "CC      = gcc"},
+		},
+		{
+			name: "immediate",
+			cfg:  Options{Operators: ops},
+			in:   []string{"a:=1", "longer = 2"},
+			want: []string{"a     := 1", "longer = 2"},
+		},
+		{
+			name: "operator borrows the padding",
+			cfg:  Options{Operators: ops},
+			in:   []string{"long = 2", "a += 1", "b ?= 3", "c := 4"},
+			want: []string{"long = 2", "a   += 1", "b   ?= 3", "c   := 4"},
+		},
+		{
+			name: "longest key with an operator",
+			cfg:  Options{Operators: ops},
+			in:   []string{"long += 2", "a = 1"},
+			want: []string{"long += 2", "a     = 1"},
+		},
+		{
+			name: "single space",
+			cfg:  Options{Operators: ops, SingleSpace: true},
+			in:   []string{"DEPENDS  +=  zlib", "PREFIX?=/usr", "a:= 1", "b  =  2"},
+			want: []string{"DEPENDS += zlib", "PREFIX ?= /usr", "a := 1", "b = 2"},
+		},
+		{
+			name: "values containing operators",
+			cfg:  Options{Operators: ops},
+			in:   []string{"flags = -O2 CFLAGS+=-g", "x += a?=b:=c"},
+			want: []string{"flags = -O2 CFLAGS+=-g", "x    += a?=b:=c"},
+		},
+		{
+			name: "operator split by a space is part of the key",
+			cfg:  Options{Operators: ops},
+			in:   []string{"k + = v", "+= v"},
+			want: []string{"k + = v", "+   = v"},
+		},
+		{
+			name: "not configured",
+			cfg:  Options{Operators: []string{"+="}},
+			in:   []string{"a ?= 1", "b += 2"},
+			want: []string{"a ? = 1", "b  += 2"},
+		},
+		{
+			name: "off by default",
+			cfg:  Options{},
+			in:   []string{"a += 1", "bb = 2"},
+			want: []string{"a + = 1", "bb  = 2"},
+		},
+		{
+			name: "pycfg cuts at the colon",
+			cfg:  Options{Dialect: DialectPyCfg, Operators: ops},
+			in:   []string{"[a]", "k := v", "long: 2"},
+			want: []string{"[a]", "k   := v", "long = 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if again, _ := Lines(got, tt.cfg); !slices.Equal(again, got) {
+				t.Errorf("formatting is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+		})
+	}
+}
+
+func TestOperatorKeys(t *testing.T) {
+	cfg := Options{Operators: []string{"+="}}
+	kvs := ParseKeyValues([]string{"[a]", "DEPENDS += zlib", "x = a+=b"}, cfg)
+	var got []string
+	for _, kv := range kvs {
+		got = append(got, kv.Key+"|"+kv.Value)
+	}
+	if want := []string{"DEPENDS|zlib", "x|a+=b"}; !slices.Equal(got, want) {
+		t.Errorf("ParseKeyValues() = %q, want %q", got, want)
+	}
+	toks := Tokenize("DEPENDS += zlib", cfg)
+	if len(toks) != 5 || toks[2].Kind != TokenDelimiter || toks[2].Text != "+=" {
+		t.Errorf("Tokenize() = %+v, want the delimiter %q", toks, "+=")
+	}
+}
+
+func TestOperatorsInvalid(t *testing.T) {
+	for _, op := range []string{"", "=", "+", "=+", "+ =", "==", "[="} {
+		if _, err := Lines([]string{"a = b"}, Options{Operators: []string{op}}); err == nil {
+			t.Errorf("Lines() with Operators %q: expected an error", op)
+		}
+	}
+}
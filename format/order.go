@@ -0,0 +1,27 @@
+package format
+
+// keyPinner is implemented by dialects with keys whose position matters.
+type keyPinner interface {
+	PinsKey(key string) bool
+}
+
+// pinsKey reports whether d keeps lines of key in place when keys are sorted
+// and leaves them out of DedupeKeys.
+func pinsKey(d Dialect, key string) bool {
+	kp, ok := d.(keyPinner)
+	return ok && kp.PinsKey(key)
+}
+
+// sectionOrderKeeper is implemented by dialects in which the order of the
+// sections matters.
+type sectionOrderKeeper interface {
+	KeepsSectionOrder() bool
+}
+
+// KeepsSectionOrder reports whether the order of the sections matters in d,
+// as in smb.conf, where an include may hold sections of its own.
+// Options.SortSections does nothing in such a dialect.
+func KeepsSectionOrder(d Dialect) bool {
+	so, ok := d.(sectionOrderKeeper)
+	return ok && so.KeepsSectionOrder()
+}
@@ -0,0 +1,145 @@
+package format
+
+import "strings"
+
+// SectionOptions are the options the sections matching Pattern are formatted
+// with instead of the file's. Pattern is an exact section name, a path.Match
+// glob, or "@preamble" for the keys before the first header.
+type SectionOptions struct {
+	Pattern string
+	Options Options
+}
+
+// sectionOptions returns the options of the most specific entry of c.Sections
+// whose pattern matches the section name, and whether there is one. Exact
+// names beat globs and globs with more literal characters beat those with
+// fewer; patterns that tie are ranked in byte order so the choice does not
+// depend on the order they were given in. Options that only make sense for a
+// whole file are cleared.
+func (c Options) sectionOptions(name string) (Options, bool) {
+	best := -1
+	for i, s := range c.Sections {
+		if !sectionSelected([]string{s.Pattern}, name) {
+			continue
+		}
+		if best == -1 || moreSpecific(s.Pattern, c.Sections[best].Pattern) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return Options{}, false
+	}
+	sub := sectionOnly(c.Sections[best].Options)
+	sub.ctx = c.ctx
+	return sub, true
+}
+
+// moreSpecific reports whether pattern a is more specific than pattern b.
+func moreSpecific(a, b string) bool {
+	exactA, exactB := !isGlob(a), !isGlob(b)
+	if exactA != exactB {
+		return exactA
+	}
+	if la, lb := literalLen(a), literalLen(b); la != lb {
+		return la > lb
+	}
+	return a < b
+}
+
+// isGlob reports whether pattern contains path.Match metacharacters.
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// literalLen counts the characters of pattern that match only themselves.
+func literalLen(pattern string) int {
+	n := 0
+	class := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '\\':
+			i++
+			n++
+		case c != '*' && c != '?':
+			n++
+		}
+	}
+	return n
+}
+
+// sectionOnly clears the options of cfg that rearrange or measure the file as
+// a whole, leaving those that apply within a single section.
+func sectionOnly(cfg Options) Options {
+	cfg.Sections, cfg.OnlySections = nil, nil
+	cfg.SortSections, cfg.PinnedSections = false, nil
+	cfg.DefaultSection, cfg.Nest, cfg.Flatten = "", 0, false
+	cfg.BlankLines, cfg.PruneEmptySections = "", false
+	return cfg
+}
+
+// formatSectionOverrides formats lines when some sections have options of
+// their own. The passes over the whole file (section sorting and moving,
+// nesting, flattening and blank lines) run first with cfg; then every
+// section is prepared with its own options. Sections without options of their
+// own are aligned together as usual, while each section with options of its
+// own is aligned on its own.
+func formatSectionOverrides(lines []string, cfg Options) ([]string, error) {
+	whole := cfg
+	whole.Sections = nil
+	whole.SortKeys, whole.DedupeKeys, whole.StripComments = nil, "", false
+	whole.ExpandEnv, whole.SortListValues, whole.RemoveEmptyValues = false, nil, false
+	whole.Unique = false
+	lines, err := prepareLines(lines, whole)
+	if err != nil {
+		return nil, err
+	}
+
+	type part struct {
+		lines []string
+		own   bool
+	}
+	var parts []part
+	var rest []string
+	for _, s := range splitSections(lines) {
+		chunk := s.lines
+		if s.header != "" {
+			chunk = append([]string{s.header}, s.lines...)
+		}
+		if err := cfg.canceled(); err != nil {
+			return nil, err
+		}
+		if sub, ok := cfg.sectionOptions(s.name()); ok {
+			formatted, err := formatLines(chunk, sub)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part{formatted, true})
+			continue
+		}
+		prepared, err := prepareLines(chunk, sectionOnly(cfg))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part{prepared, false})
+		rest = append(rest, prepared...)
+	}
+
+	rest = finishLines(rest, cfg)
+	if err := cfg.canceled(); err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, p := range parts {
+		if p.own {
+			result = append(result, p.lines...)
+			continue
+		}
+		result = append(result, rest[:len(p.lines)]...)
+		rest = rest[len(p.lines):]
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package format
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSectionOptionsMostSpecific(t *testing.T) {
+	cfg := Options{Sections: []SectionOptions{
+		{Pattern: "*", Options: Options{DedupeKeys: "star"}},
+		{Pattern: "plugins.*", Options: Options{DedupeKeys: "plugins-glob"}},
+		{Pattern: "plugin?.*", Options: Options{DedupeKeys: "plugin-glob"}},
+		{Pattern: "plugins.extra", Options: Options{DedupeKeys: "exact"}},
+		{Pattern: "a*", Options: Options{DedupeKeys: "a-star"}},
+		{Pattern: "*b", Options: Options{DedupeKeys: "star-b"}},
+		{Pattern: "@preamble", Options: Options{DedupeKeys: "preamble"}},
+	}}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"plugins.extra", "exact"},
+		{"plugins.core", "plugins-glob"},
+		{"ab", "star-b"}, // ties with "a*" and sorts first
+		{"env", "star"},
+		{"", "preamble"},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.sectionOptions(tt.name)
+		if !ok || got.DedupeKeys != tt.want {
+			t.Errorf("sectionOptions(%q) = %q, %v; want %q", tt.name, got.DedupeKeys, ok, tt.want)
+		}
+	}
+	if _, ok := (Options{Sections: cfg.Sections[3:4]}).sectionOptions("env"); ok {
+		t.Error("sectionOptions(env) matched plugins.extra")
+	}
+}
+
+func TestSectionOptionsOrderIndependent(t *testing.T) {
+	sections := []SectionOptions{
+		{Pattern: "x*", Options: Options{DedupeKeys: "x-star"}},
+		{Pattern: "*y", Options: Options{DedupeKeys: "star-y"}},
+	}
+	first, _ := Options{Sections: sections}.sectionOptions("xy")
+	second, _ := Options{Sections: []SectionOptions{sections[1], sections[0]}}.sectionOptions("xy")
+	if first.DedupeKeys != second.DedupeKeys {
+		t.Errorf("sectionOptions(xy) depends on order: %q, then %q", first.DedupeKeys, second.DedupeKeys)
+	}
+}
+
+func TestLinesSectionOverrides(t *testing.T) {
+	lines := []string{
+		"name=app",
+		"",
+		"[env]",
+		"PATH=/bin",
+		"HOME=/root",
+		"",
+		"[plugins]",
+		"zeta=on",
+		"alpha=off",
+		"",
+		"[server]",
+		"listen_address=0.0.0.0",
+	}
+	cfg := Options{Sections: []SectionOptions{
+		{Pattern: "env", Options: Options{SingleSpace: true}},
+		{Pattern: "plugins", Options: Options{SortKeys: []string{"*"}}},
+	}}
+	want := []string{
+		"name           = app",
+		"",
+		"[env]",
+		"PATH = /bin",
+		"HOME = /root",
+		"",
+		"[plugins]",
+		"alpha = off",
+		"zeta  = on",
+		"",
+		"[server]",
+		"listen_address = 0.0.0.0",
+	}
+	got, err := Lines(lines, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestLinesSectionOverridesWholeFile(t *testing.T) {
+	lines := []string{"[b]", "y=2", "x=1", "", "", "[a]", "k=v"}
+	cfg := Options{
+		SortSections: true,
+		SortKeys:     []string{"*"},
+		BlankLines:   "squeeze",
+		Sections:     []SectionOptions{{Pattern: "b", Options: Options{SingleSpace: true}}},
+	}
+	want := []string{"[a]", "k = v", "", "[b]", "y = 2", "x = 1"}
+	got, err := Lines(lines, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package format
+
+import (
+	"strings"
+	"unicode"
+)
+
+// pair is one "Name: value" pair of a multi-pair line.
+type pair struct{ name, value string }
+
+// text renders p with one space after its colon.
+func (p pair) text() string {
+	if p.value == "" {
+		return p.name + ":"
+	}
+	return p.name + ": " + p.value
+}
+
+// splitPairs splits an Inno Setup style line such as
+// `Source: "app.exe"; DestDir: "{app}"; Flags: ignoreversion` into its pairs,
+// separated by ';' outside double quotes. trailing reports a ';' after the
+// last pair. ok is false unless the line holds at least two pairs and
+// nothing else, so a lone "key: value" line is never taken for one.
+func splitPairs(line string) (pairs []pair, trailing, ok bool) {
+	line = strings.TrimSpace(line)
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted // "" inside a string toggles twice
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if quoted {
+		return nil, false, false
+	}
+	if last := line[start:]; strings.TrimSpace(last) != "" {
+		parts = append(parts, last)
+	} else if len(parts) > 0 {
+		trailing = true
+	}
+	if len(parts) < 2 {
+		return nil, false, false
+	}
+	for _, part := range parts {
+		name, value, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found || !isPairName(name) {
+			return nil, false, false
+		}
+		pairs = append(pairs, pair{name, strings.TrimSpace(value)})
+	}
+	return pairs, trailing, true
+}
+
+// isPairName reports whether name is the name of a pair: letters, digits and
+// underscores.
+func isPairName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// isPairLine reports whether line holds several "Name: value" pairs, which
+// AlignPairs aligns instead of the key/value alignment.
+func (c Options) isPairLine(line string) bool {
+	if IsBlank(line) || IsHeader(line) || c.IsComment(line) {
+		return false
+	}
+	_, _, ok := splitPairs(line)
+	return ok
+}
+
+// alignPairs pads the pairs of the multi-pair lines of each section so that
+// the pairs in the same position start at the same column. A line with fewer
+// pairs than others aligns the ones it has; quoted strings are never split.
+func alignPairs(lines []string, cfg Options) []string {
+	result := make([]string, 0, len(lines))
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !IsHeader(lines[i]) {
+			continue
+		}
+		result = append(result, alignPairSection(lines[start:i], cfg)...)
+		if i < len(lines) {
+			result = append(result, lines[i])
+		}
+		start = i + 1
+	}
+	return result
+}
+
+// alignPairSection aligns the multi-pair lines of one section body.
+func alignPairSection(lines []string, cfg Options) []string {
+	type pairLine struct {
+		indent   string
+		pairs    []pair
+		trailing bool
+	}
+	parsed := make([]*pairLine, len(lines))
+	var widths []int
+	for i, line := range lines {
+		if !cfg.isPairLine(line) {
+			continue
+		}
+		pairs, trailing, _ := splitPairs(line)
+		parsed[i] = &pairLine{line[:len(line)-len(strings.TrimLeft(line, " \t"))], pairs, trailing}
+		// The last pair of a line is never padded, so it does not count.
+		for j, p := range pairs[:len(pairs)-1] {
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], cfg.width(p.text()))
+		}
+	}
+
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		pl := parsed[i]
+		if pl == nil {
+			result = append(result, line)
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(pl.indent)
+		for j, p := range pl.pairs {
+			text := p.text()
+			b.WriteString(text)
+			if j < len(pl.pairs)-1 {
+				b.WriteString(";")
+				b.WriteString(strings.Repeat(" ", widths[j]-cfg.width(text)+1))
+			} else if pl.trailing {
+				b.WriteString(";")
+			}
+		}
+		result = append(result, b.String())
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAlignPairs(t *testing.T) {
+	in := []string{
+		"[Setup]",
+		"AppName=My App",
+		"DefaultDirName={autopf}\\My App",
+		"",
+		"[Files]",
+		`Source: "app.exe"; DestDir: "{app}"; Flags: ignoreversion`,
+		`Source: "README.txt";   DestDir: "{app}";  Flags: isreadme`,
+		`Source: "lib\*.dll"; DestDir: "{app}\lib"`,
+		`Source: "x; y.ini"; DestDir: "{app}"; Flags: onlyifdoesntexist uninsneveruninstall;`,
+		"",
+		"[Run]",
+		`Filename: "{app}\app.exe"; Parameters: "/mode=quiet"; Description: "Launch"`,
+		`Filename: "{app}\setup.exe"; Flags: nowait`,
+	}
+	want := []string{
+		"[Setup]",
+		"AppName        = My App",
+		"DefaultDirName = {autopf}\\My App",
+		"",
+		"[Files]",
+		`Source: "app.exe";    DestDir: "{app}"; Flags: ignoreversion`,
+		`Source: "README.txt"; DestDir: "{app}"; Flags: isreadme`,
+		`Source: "lib\*.dll";  DestDir: "{app}\lib"`,
+		`Source: "x; y.ini";   DestDir: "{app}"; Flags: onlyifdoesntexist uninsneveruninstall;`,
+		"",
+		"[Run]",
+		`Filename: "{app}\app.exe";   Parameters: "/mode=quiet"; Description: "Launch"`,
+		`Filename: "{app}\setup.exe"; Flags: nowait`,
+	}
+	cfg := Options{AlignPairs: true}
+	got, err := Lines(in, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+		t.Errorf("Lines() is not idempotent:\n%s", strings.Join(again, "\n"))
+	}
+
+	// Without AlignPairs the '=' in a quoted parameter is taken for a delimiter.
+	if got, _ := Lines(in[10:], Options{}); got[1] == want[11] {
+		t.Errorf("Lines() without AlignPairs = %q, want the line left to key/value alignment", got[1])
+	}
+}
+
+func TestSplitPairs(t *testing.T) {
+	tests := []struct {
+		line     string
+		pairs    []pair
+		trailing bool
+		ok       bool
+	}{
+		{`Name: "{group}\App"; Filename: "{app}\App.exe"`, []pair{{"Name", `"{group}\App"`}, {"Filename", `"{app}\App.exe"`}}, false, true},
+		{`  Source: "a ""b""; c"; Flags: x;`, []pair{{"Source", `"a ""b""; c"`}, {"Flags", "x"}}, true, true},
+		{`Name: "App"`, nil, false, false},
+		{`url: http://example.com`, nil, false, false},
+		{`key = a: b; c: d`, nil, false, false},
+		{`Source: "open; Flags: x`, nil, false, false},
+		{`Source: a;; Flags: x`, nil, false, false},
+	}
+	for _, tt := range tests {
+		pairs, trailing, ok := splitPairs(tt.line)
+		if !slices.Equal(pairs, tt.pairs) || trailing != tt.trailing || ok != tt.ok {
+			t.Errorf("splitPairs(%q) = %v, %v, %v; want %v, %v, %v", tt.line, pairs, trailing, ok, tt.pairs, tt.trailing, tt.ok)
+		}
+	}
+}
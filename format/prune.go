@@ -0,0 +1,147 @@
+package format
+
+import "strings"
+
+// isEmptySection reports whether s has a header and nothing in its body but
+// blank lines and, unless cfg.KeepCommentedSections, full-line comments.
+func isEmptySection(s *section, cfg Options) bool {
+	if s.header == "" {
+		return false
+	}
+	for _, line := range s.lines {
+		switch {
+		case IsBlank(line):
+		case cfg.IsComment(line):
+			if cfg.KeepCommentedSections {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// EmptySections returns the 1-based line numbers of the headers of the
+// sections PruneEmptySections removes from lines.
+func EmptySections(lines []string, opts Options) []int {
+	var headers []int
+	n := 0
+	for _, s := range splitSections(lines) {
+		if s.header != "" {
+			n++
+			if isEmptySection(s, opts) {
+				headers = append(headers, n)
+			}
+		}
+		n += len(s.lines)
+	}
+	return headers
+}
+
+// RemoveEmptySections returns lines without the sections EmptySections
+// reports, removed as PruneEmptySections removes them, and nothing else
+// changed.
+func RemoveEmptySections(lines []string, cfg Options) []string {
+	return pruneEmptySections(lines, cfg)
+}
+
+// pruneEmptySections removes the sections without keys, along with the
+// comments directly above their headers. A file that did not end in a blank
+// line does not end in one after its last section is removed.
+func pruneEmptySections(lines []string, cfg Options) []string {
+	return pruneSections(lines, cfg, func(s *section) bool { return isEmptySection(s, cfg) })
+}
+
+// pruneSections is pruneEmptySections for the sections prune reports.
+func pruneSections(lines []string, cfg Options, prune func(*section) bool) []string {
+	sections := splitSections(lines)
+	kept := sections[:1]
+	pruned := false
+	for _, s := range sections[1:] {
+		if !prune(s) {
+			kept = append(kept, s)
+			continue
+		}
+		prev := kept[len(kept)-1]
+		end := len(prev.lines)
+		for end > 0 && cfg.IsComment(prev.lines[end-1]) {
+			end--
+		}
+		prev.lines = prev.lines[:end]
+		// The blank lines that ended the pruned section now separate the
+		// previous one from the next.
+		if end > 0 && !IsBlank(prev.lines[end-1]) {
+			blanks := len(s.lines)
+			for blanks > 0 && IsBlank(s.lines[blanks-1]) {
+				blanks--
+			}
+			prev.lines = append(prev.lines, s.lines[blanks:]...)
+		}
+		pruned = true
+	}
+	if !pruned {
+		return lines
+	}
+	result := joinSections(kept)
+	if len(lines) > 0 && !IsBlank(lines[len(lines)-1]) {
+		for len(result) > 0 && IsBlank(result[len(result)-1]) {
+			result = result[:len(result)-1]
+		}
+	}
+	return result
+}
+
+// emptyValuer is implemented by dialects in which an empty value means
+// something, so RemoveEmptyValues must not drop it.
+type emptyValuer interface {
+	EmptyValuesMatter() bool
+}
+
+// EmptyValuesMatter reports whether an empty value means something in d, as
+// in systemd units, where "ExecStart=" resets the list of commands before it.
+// Options.RemoveEmptyValues cannot be used with such a dialect.
+func EmptyValuesMatter(d Dialect) bool {
+	ev, ok := d.(emptyValuer)
+	return ok && ev.EmptyValuesMatter()
+}
+
+// isEmptyValue reports whether kv has a delimiter and nothing after it but an
+// inline comment, or, with cfg.RemoveEmptyQuoted, an empty quoted string.
+func isEmptyValue(kv KeyValue, cfg Options) bool {
+	if !kv.HasValue {
+		return false
+	}
+	if cfg.RemoveEmptyQuoted {
+		return ValueText(kv.Value) == ""
+	}
+	value, _ := SplitInlineComment(kv.Value)
+	return strings.TrimSpace(value) == ""
+}
+
+// removeEmptyValues drops the key lines with empty values and, with
+// cfg.RemoveWithComments, the comment lines directly above them.
+func removeEmptyValues(lines []string, cfg Options) []string {
+	drop := make(map[int]bool)
+	for _, kv := range ParseKeyValues(lines, cfg) {
+		if !isEmptyValue(kv, cfg) {
+			continue
+		}
+		i := kv.Line - 1
+		drop[i] = true
+		for cfg.RemoveWithComments && i > 0 && cfg.IsComment(lines[i-1]) {
+			i--
+			drop[i] = true
+		}
+	}
+	if len(drop) == 0 {
+		return lines
+	}
+	result := make([]string, 0, len(lines)-len(drop))
+	for i, line := range lines {
+		if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package format
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPruneEmptySections(t *testing.T) {
+	lines := []string{
+		"; top",
+		"name = app",
+		"",
+		"[server]",
+		"port = 8080",
+		"; about the cache",
+		"[cache]",
+		"",
+		"[docs]",
+		"; fill in later",
+		"",
+		"[db] ; primary",
+		"host = db",
+		"",
+		"[last]",
+	}
+	tests := []struct {
+		name string
+		cfg  Options
+		want []string
+	}{
+		{
+			name: "prune",
+			cfg:  Options{SingleSpace: true, PruneEmptySections: true},
+			want: []string{"; top", "name = app", "", "[server]", "port = 8080", "", "[db] ; primary", "host = db"},
+		},
+		{
+			name: "keep commented",
+			cfg:  Options{SingleSpace: true, PruneEmptySections: true, KeepCommentedSections: true},
+			want: []string{"; top", "name = app", "", "[server]", "port = 8080", "", "[docs]", "; fill in later", "", "[db] ; primary", "host = db"},
+		},
+		{
+			name: "after stripping comments",
+			cfg:  Options{SingleSpace: true, PruneEmptySections: true, KeepCommentedSections: true, StripComments: true},
+			want: []string{"name = app", "", "[server]", "port = 8080", "", "[db]", "host = db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(lines, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			again, err := Lines(got, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(again, got) {
+				t.Errorf("Lines() is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+		})
+	}
+}
+
+func TestEmptySections(t *testing.T) {
+	lines := []string{"[a]", "k = v", "[b]", "", "[c]", "# note", "[d] ; trailing", "x = 1"}
+	if got, want := EmptySections(lines, Options{}), []int{3, 5}; !slices.Equal(got, want) {
+		t.Errorf("EmptySections() = %v, want %v", got, want)
+	}
+	if got, want := EmptySections(lines, Options{KeepCommentedSections: true}), []int{3}; !slices.Equal(got, want) {
+		t.Errorf("EmptySections(KeepCommentedSections) = %v, want %v", got, want)
+	}
+}
+
+func TestRemoveEmptySections(t *testing.T) {
+	lines := []string{"[a]", "k  =  v", "", "; about b", "[b]", "", "[c]", "# note", "[d] ; trailing", "x=1"}
+	want := []string{"[a]", "k  =  v", "", "[c]", "# note", "[d] ; trailing", "x=1"}
+	if got := RemoveEmptySections(lines, Options{KeepCommentedSections: true}); !slices.Equal(got, want) {
+		t.Errorf("RemoveEmptySections() = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveEmptyValues(t *testing.T) {
+	lines := []string{
+		"[server]",
+		"host = example.com",
+		"; the proxy, if any",
+		"proxy =",
+		"very_long_option =   ",
+		"name = \"\"",
+		"flag",
+		"port = 80",
+	}
+	tests := []struct {
+		name string
+		cfg  Options
+		want []string
+	}{
+		{
+			name: "empty",
+			cfg:  Options{RemoveEmptyValues: true},
+			want: []string{"[server]", "host = example.com", "; the proxy, if any", `name = ""`, "flag", "port = 80"},
+		},
+		{
+			name: "quoted",
+			cfg:  Options{RemoveEmptyValues: true, RemoveEmptyQuoted: true},
+			want: []string{"[server]", "host = example.com", "; the proxy, if any", "flag", "port = 80"},
+		},
+		{
+			name: "with comments",
+			cfg:  Options{RemoveEmptyValues: true, RemoveWithComments: true},
+			want: []string{"[server]", "host = example.com", `name = ""`, "flag", "port = 80"},
+		},
+		{
+			name: "then prune",
+			cfg:  Options{RemoveEmptyValues: true, RemoveEmptyQuoted: true, PruneEmptySections: true, OnlySections: []string{"@preamble"}},
+			want: lines,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(lines, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	got, err := Lines([]string{"[a]", "x =", "[b]", "k = v"}, Options{RemoveEmptyValues: true, PruneEmptySections: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"[b]", "k = v"}; !slices.Equal(got, want) {
+		t.Errorf("Lines(remove and prune) = %q, want %q", got, want)
+	}
+
+	var oe *OptionError
+	if _, err := Lines(lines, Options{RemoveEmptyValues: true, Dialect: DialectSystemd}); !errors.As(err, &oe) {
+		t.Errorf("Lines(systemd) error = %v, want an OptionError", err)
+	}
+}
@@ -0,0 +1,38 @@
+package format
+
+// UnbalancedQuote reports whether value opens a quote it never closes: it
+// starts with a quote character that is not closed, or it holds an odd number
+// of double quotes. Backslash escapes a quote, so \" does not count. Single
+// quotes past the start are apostrophes, as in "don't", and do not count
+// either.
+func UnbalancedQuote(value string) bool {
+	if value == "" {
+		return false
+	}
+	if (value[0] == '"' || value[0] == '\'') && quotedEnd(value, 0) == -1 {
+		return true
+	}
+	n := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			n++
+		}
+	}
+	return n%2 == 1
+}
+
+// UnbalancedQuotes returns the key/value lines whose value has an unbalanced
+// quote. The formatter treats such values as opaque text, but some parsers
+// read on past the end of the line looking for the closing quote.
+func UnbalancedQuotes(lines []string, cfg Options) []KeyValue {
+	var kvs []KeyValue
+	for _, kv := range ParseKeyValues(lines, cfg) {
+		if value, _ := SplitInlineComment(kv.Value); kv.HasValue && UnbalancedQuote(value) {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,43 @@
+package format
+
+import "testing"
+
+func TestUnbalancedQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"plain", false},
+		{`"quoted"`, false},
+		{`'quoted'`, false},
+		{`"C:\Program Files\App`, true},
+		{`'open`, true},
+		{`say "hi`, true},
+		{`say \"hi`, false},
+		{`"a \" b"`, false},
+		{`"a \"`, true},
+		{`don't`, false},
+		{`"a" and "b"`, false},
+	}
+	for _, tt := range tests {
+		if got := UnbalancedQuote(tt.value); got != tt.want {
+			t.Errorf("UnbalancedQuote(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUnbalancedQuotesKeepFormatting(t *testing.T) {
+	lines := []string{"[s]", `path="C:\Program Files\App`, "ok = 1 ; say \"hi"}
+	kvs := UnbalancedQuotes(lines, Options{})
+	if len(kvs) != 1 || kvs[0].Line != 2 {
+		t.Errorf("UnbalancedQuotes() = %v, want line 2 only", kvs)
+	}
+	got, err := Lines(lines, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `path = "C:\Program Files\App`; got[1] != want {
+		t.Errorf("Lines()[1] = %q, want %q", got[1], want)
+	}
+}
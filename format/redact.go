@@ -0,0 +1,60 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactMask replaces the value of a secret-looking key.
+const redactMask = "********"
+
+// defaultRedactPatterns match the keys of secret values unless replaced.
+var defaultRedactPatterns = []string{"password", "passwd", "secret", "token", "api_key", "private_key"}
+
+// RedactPatterns builds the case-insensitive key patterns for Options.Redact.
+// Default patterns are plain substrings; extra patterns are regular expressions.
+func RedactPatterns(extra []string, withDefaults bool) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	if withDefaults {
+		for _, p := range defaultRedactPatterns {
+			patterns = append(patterns, regexp.MustCompile("(?i)"+regexp.QuoteMeta(p)))
+		}
+	}
+	for _, p := range extra {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, &OptionError{Option: "Redact", Reason: fmt.Sprintf("invalid redact pattern %q", p), Err: err}
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// redactValue masks value when key matches one of the patterns. Surrounding
+// quotes are kept, and with reveal the first and last two characters too.
+func redactValue(patterns []*regexp.Regexp, reveal bool, key, value string) string {
+	if value == "" || !matchesAny(patterns, key) {
+		return value
+	}
+	quote := ""
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		quote, value = value[:1], value[1:len(value)-1]
+	}
+	masked := redactMask
+	if r := []rune(value); reveal && len(r) > 4 {
+		masked = string(r[:2]) + redactMask + string(r[len(r)-2:])
+	}
+	return quote + masked + quote
+}
+
+// matchesAny reports whether key matches any of the patterns.
+func matchesAny(patterns []*regexp.Regexp, key string) bool {
+	key = strings.TrimSpace(key)
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
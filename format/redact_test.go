@@ -0,0 +1,50 @@
+package format
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRedactValue(t *testing.T) {
+	patterns, err := RedactPatterns([]string{"^dsn$"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key, value string
+		reveal     bool
+		want       string
+	}{
+		{key: "db_password", value: "hunter2", want: "********"},
+		{key: "GITHUB_TOKEN", value: "ghp_abcdef", want: "********"},
+		{key: "Api_Key", value: `"quoted secret"`, want: `"********"`},
+		{key: "dsn", value: "postgres://x", want: "********"},
+		{key: "dsn_backup", value: "postgres://x", want: "postgres://x"},
+		{key: "host", value: "example.com", want: "example.com"},
+		{key: "secret", value: "", want: ""},
+		{key: "token", value: "abcdefgh", reveal: true, want: "ab********gh"},
+		{key: "token", value: "abcd", reveal: true, want: "********"},
+	}
+	for _, tt := range tests {
+		if got := redactValue(patterns, tt.reveal, tt.key, tt.value); got != tt.want {
+			t.Errorf("redactValue(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := RedactPatterns([]string{"("}, true); err == nil {
+		t.Error("RedactPatterns() expected error for invalid regex")
+	}
+}
+
+func TestRedactPreservesStructure(t *testing.T) {
+	patterns, _ := RedactPatterns(nil, true)
+	lines := []string{"; credentials", "[db]", "user=app", "password=s3cr3t value"}
+	want := []string{"; credentials", "[db]", "user     = app", "password = ********"}
+	got, err := Lines(lines, Options{Redact: patterns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines(redact) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package format
+
+import "strings"
+
+// RegDialect is the format of Windows registry export (.reg) files: a version
+// line, [HKEY_...] key headers kept verbatim, quoted value names that may
+// contain '=', only ';' comments, and hex values continued over several lines
+// by a trailing backslash.
+type RegDialect struct{ INIDialect }
+
+// regCommentPrefixes are the comment prefixes of .reg files, which have no
+// '#' comments.
+var regCommentPrefixes = []string{";"}
+
+// regSignatures start the mandatory first line of a .reg file.
+var regSignatures = []string{"Windows Registry Editor Version", "REGEDIT4"}
+
+// Name returns "reg".
+func (RegDialect) Name() string { return "reg" }
+
+// CommentPrefixes returns ";".
+func (RegDialect) CommentPrefixes() []string { return regCommentPrefixes }
+
+// Classify treats the version line as a directive and the lines after a value
+// ending in a backslash as its continuation.
+func (d RegDialect) Classify(line string, ctx LineContext, cfg Options) LineKind {
+	switch {
+	case backslashContinued(ctx):
+		return LineContinuation
+	case ctx.Index == 0 && isRegSignature(line):
+		return LineDirective
+	}
+	return d.INIDialect.Classify(line, ctx, cfg)
+}
+
+// Cut splits a value line after its quoted name, which may itself contain
+// '='. Other lines are cut as in plain INI.
+func (d RegDialect) Cut(line string, cfg Options) (before, after string, ok bool) {
+	if before, after, found, quoted := regCut(line); quoted {
+		return before, after, found
+	}
+	return d.INIDialect.Cut(line, cfg)
+}
+
+// SplitHeader returns the whole line: registry key paths may contain ';',
+// '#' and ']'.
+func (RegDialect) SplitHeader(line string) (header, rest string) {
+	return line, ""
+}
+
+// FormatHeader keeps registry key headers verbatim.
+func (RegDialect) FormatHeader(line string, _ Options) string {
+	return line
+}
+
+// JoinContinuation appends the trimmed line to value in place of its trailing
+// backslash.
+func (RegDialect) JoinContinuation(value, line string) string {
+	return strings.TrimSuffix(value, `\`) + strings.TrimSpace(line)
+}
+
+// Continuation returns a trailing backslash.
+func (RegDialect) Continuation() (string, bool) { return `\`, true }
+
+// isRegSignature reports whether line is the version line of a .reg file.
+func isRegSignature(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, s := range regSignatures {
+		if strings.HasPrefix(trimmed, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// regCut splits a .reg value line after its quoted name, which may itself
+// contain '='. ok is false when line does not start with a quoted name.
+func regCut(line string) (before, after string, found, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(trimmed, `"`) {
+		return "", "", false, false
+	}
+	end := quotedEnd(trimmed, 0)
+	if end == -1 {
+		return "", "", false, false
+	}
+	end += len(line) - len(trimmed)
+	idx := strings.IndexByte(line[end:], '=')
+	if idx == -1 {
+		return line, "", false, true
+	}
+	return line[:end+idx], line[end+idx+1:], true, true
+}
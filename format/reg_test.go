@@ -0,0 +1,110 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var regInput = []string{
+	"Windows Registry Editor Version 5.00",
+	"",
+	`[HKEY_LOCAL_MACHINE\Software\Example;Corp #1]`,
+	`@="default"`,
+	`"Name"="value ; not a comment"`,
+	`"a=b"=dword:00000001`,
+	`"Binary"=hex:00,01,02,03,04,05,06,07,08,09,0a,0b,0c,0d,0e,0f,10,11,12,13,\`,
+	`  14,15,16,17,\`,
+	`  18,19`,
+	"# not a comment either",
+	"; a comment",
+	`"Path"="C:\\Program Files\\Example"`,
+	"",
+	`[-HKEY_CURRENT_USER\Software\Old]`,
+}
+
+func TestRegDialect(t *testing.T) {
+	want := []string{
+		"Windows Registry Editor Version 5.00",
+		"",
+		`[HKEY_LOCAL_MACHINE\Software\Example;Corp #1]`,
+		`@        = "default"`,
+		`"Name"   = "value ; not a comment"`,
+		`"a=b"    = dword:00000001`,
+		`"Binary" = hex:00,01,02,03,04,05,06,07,08,09,0a,0b,0c,0d,0e,0f,10,11,12,13,\`,
+		`  14,15,16,17,\`,
+		`  18,19`,
+		"# not a comment either",
+		"; a comment",
+		`"Path"   = "C:\\Program Files\\Example"`,
+		"",
+		`[-HKEY_CURRENT_USER\Software\Old]`,
+	}
+	cfg := Options{Dialect: DialectReg}
+	got, err := Lines(regInput, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines(reg) =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	again, _ := Lines(got, cfg)
+	if !slices.Equal(again, got) {
+		t.Errorf("Lines(reg) is not idempotent:\n%s", strings.Join(again, "\n"))
+	}
+}
+
+func TestRegDialectSortKeepsContinuations(t *testing.T) {
+	lines := []string{
+		`[HKEY_CURRENT_USER\Software\Example]`,
+		`"z"=hex:00,\`,
+		`  01`,
+		`"a"="first"`,
+	}
+	want := []string{
+		`[HKEY_CURRENT_USER\Software\Example]`,
+		`"a" = "first"`,
+		`"z" = hex:00,\`,
+		`  01`,
+	}
+	got, err := Lines(lines, Options{Dialect: DialectReg, SortKeys: []string{"*"}, StripComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines(reg, sort) = %q, want %q", got, want)
+	}
+}
+
+func TestRegParseKeyValues(t *testing.T) {
+	cfg := Options{Dialect: DialectReg}
+	kvs := ParseKeyValues(regInput, cfg)
+	var got []string
+	for _, kv := range kvs {
+		got = append(got, kv.Key+"="+kv.Value)
+	}
+	want := []string{
+		`@="default"`,
+		`"Name"="value ; not a comment"`,
+		`"a=b"=dword:00000001`,
+		`"Binary"=hex:00,01,02,03,04,05,06,07,08,09,0a,0b,0c,0d,0e,0f,10,11,12,13,14,15,16,17,18,19`,
+		"# not a comment either=",
+		`"Path"="C:\\Program Files\\Example"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseKeyValues(reg) =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRegRedactDropsContinuations(t *testing.T) {
+	patterns, _ := RedactPatterns([]string{"^\"key\"$"}, false)
+	lines := []string{`[HKEY_CURRENT_USER\Software\Example]`, `"key"=hex:00,\`, `  01`}
+	want := []string{`[HKEY_CURRENT_USER\Software\Example]`, `"key" = ********`}
+	got, err := Lines(lines, Options{Dialect: DialectReg, Redact: patterns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines(reg, redact) = %q, want %q", got, want)
+	}
+}
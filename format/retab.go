@@ -0,0 +1,49 @@
+package format
+
+import "strings"
+
+// retabLines rewrites the leading whitespace of every line in the style
+// cfg.Retab names: "spaces" expands tabs to cfg.TabWidth, "tabs" uses a tab
+// for every full tab stop and spaces for the rest. Whitespace after the first
+// character that is not a space or tab is never touched. lines may hold
+// continuation lines joined by newlines; their indentation is left alone in
+// dialects where it is part of the value, as in gitconfig.
+func retabLines(lines []string, cfg Options) []string {
+	keepValue := indentIsValue(cfg.dialect())
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		parts := strings.Split(line, "\n")
+		for j, part := range parts {
+			if j > 0 && keepValue {
+				continue
+			}
+			parts[j] = cfg.retab(part)
+		}
+		result[i] = strings.Join(parts, "\n")
+	}
+	return result
+}
+
+// retab rewrites the leading whitespace of line in the style of c.Retab.
+func (c Options) retab(line string) string {
+	text := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(text)]
+	if indent == "" || text == "" {
+		return line
+	}
+	columns := c.width(indent)
+	if c.Retab == "tabs" {
+		tabWidth := c.TabWidth
+		if tabWidth <= 0 {
+			tabWidth = DefaultTabWidth
+		}
+		return strings.Repeat("\t", columns/tabWidth) + strings.Repeat(" ", columns%tabWidth) + text
+	}
+	return strings.Repeat(" ", columns) + text
+}
+
+// indentIsValue reports whether d keeps the indentation of a continuation
+// line in the value it continues, so that changing it changes the value.
+func indentIsValue(d Dialect) bool {
+	return d.JoinContinuation("v", " x") != d.JoinContinuation("v", "x")
+}
@@ -0,0 +1,104 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRetabFixtures(t *testing.T) {
+	read := func(name string) []string {
+		data, err := os.ReadFile("testdata/retab/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, _ := SplitLines(string(data))
+		return lines
+	}
+	mixed := read("mixed.cfg")
+	for _, style := range []string{"spaces", "tabs"} {
+		t.Run(style, func(t *testing.T) {
+			cfg := Options{Dialect: DialectPyCfg, Retab: style, TabWidth: 4}
+			want := read("mixed." + style + ".cfg")
+			got, err := Lines(mixed, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+				t.Errorf("retab is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+			// Converting the other style's output gives the same lines.
+			other := "tabs"
+			if style == "tabs" {
+				other = "spaces"
+			}
+			if back, _ := Lines(read("mixed."+other+".cfg"), cfg); !slices.Equal(back, want) {
+				t.Errorf("Lines(mixed.%s.cfg) =\n%s\nwant\n%s", other, strings.Join(back, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRetab(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "tab stops",
+			cfg:  Options{Retab: "tabs", TabWidth: 4},
+			in:   []string{"  \t; a", "      ; b", "   ; c"},
+			want: []string{"\t; a", "\t  ; b", "   ; c"},
+		},
+		{
+			name: "default tab width",
+			cfg:  Options{Retab: "spaces"},
+			in:   []string{"\t; a", " \t; b"},
+			want: []string{"        ; a", "        ; b"},
+		},
+		{
+			name: "padding and values untouched",
+			cfg:  Options{Retab: "spaces", KeepLossy: true},
+			in:   []string{"\t; a\tb", "k\t=\tx  \ty"},
+			want: []string{"        ; a\tb", "k\t=\tx  \ty"},
+		},
+		{
+			name: "backslash continuations",
+			cfg:  Options{Dialect: DialectProperties, Retab: "tabs", TabWidth: 4},
+			in:   []string{"list = a,\\", "        b"},
+			want: []string{"list = a,\\", "\t\tb"},
+		},
+		{
+			name: "gitconfig continuation indentation is value",
+			cfg:  Options{Dialect: DialectGitConfig, Retab: "tabs", TabWidth: 4},
+			in:   []string{"[alias]", "    ; comment", "lg = log \\", "        --oneline"},
+			want: []string{"[alias]", "\t; comment", "lg = log \\", "        --oneline"},
+		},
+		{
+			name: "wrapped values",
+			cfg:  Options{Dialect: DialectProperties, Retab: "tabs", TabWidth: 4, WrapValues: 20},
+			in:   []string{"servers = alpha, beta, gamma"},
+			want: []string{"servers = alpha, \\", "\t\t  beta, \\", "\t\t  gamma"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines() = %q, want %q", got, tt.want)
+			}
+			if again, _ := Lines(got, tt.cfg); !slices.Equal(again, got) {
+				t.Errorf("retab is not idempotent: %q", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// smbParams reads lines as testparm does: parameter names ignore case and
+// spaces, and a later setting of a parameter replaces an earlier one.
+func smbParams(lines []string) (map[string]string, []string) {
+	params := make(map[string]string)
+	var includes []string
+	for _, kv := range ParseKeyValues(lines, Options{Dialect: DialectSmb}) {
+		name := strings.ToLower(strings.ReplaceAll(kv.Key, " ", ""))
+		if name == "include" {
+			includes = append(includes, kv.Value)
+			continue
+		}
+		params[kv.Section+"."+name] = kv.Value
+	}
+	return params, includes
+}
+
+func TestSmbDialect(t *testing.T) {
+	data, err := os.ReadFile("testdata/smb.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, _ := SplitLines(string(data))
+	opts := Options{Dialect: DialectSmb, SortKeys: []string{"*"}, SortSections: true}
+	out, err := Lines(in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Lines(out, opts); !slices.Equal(again, out) {
+		t.Errorf("Lines(smb) is not idempotent:\n%s", strings.Join(again, "\n"))
+	}
+
+	if got, want := SectionNames(out), []string{"global", "homes", "printers", "shared"}; !slices.Equal(got, want) {
+		t.Errorf("sections = %q, want them in their original order %q", got, want)
+	}
+	for _, want := range []string{
+		"workgroup     = EXAMPLE",
+		"server string = Samba %v on %h",
+		"load printers = Yes",
+		"read only     = No",
+		"guest ok      = true",
+	} {
+		if !slices.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, strings.Join(out, "\n"))
+		}
+	}
+	// Each include stays between the keys it was between: the keys above it
+	// are sorted among themselves, and so are those below.
+	global := out[slices.Index(out, "[global]"):slices.Index(out, "[homes]")]
+	first := slices.Index(global, "include       = /etc/samba/global-extra.conf")
+	last := slices.Index(global, "include       = /etc/samba/%m.conf")
+	if first == -1 || last == -1 || !strings.HasPrefix(global[first-1], "workgroup") || strings.TrimSpace(global[last-1]) != "; per-machine overrides come last so they win" {
+		t.Errorf("includes moved:\n%s", strings.Join(global, "\n"))
+	}
+
+	wantParams, wantIncludes := smbParams(in)
+	gotParams, gotIncludes := smbParams(out)
+	if !slices.Equal(gotIncludes, wantIncludes) {
+		t.Errorf("includes = %q, want %q", gotIncludes, wantIncludes)
+	}
+	for name, want := range wantParams {
+		if got := gotParams[name]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if gotParams["homes.readonly"] != "No" || gotParams["printers.guestok"] != "true" {
+		t.Errorf("boolean spellings changed: %v", gotParams)
+	}
+
+	includes := []string{"[global]", "include = a.conf", "include = b.conf"}
+	if got, _ := Lines(includes, Options{Dialect: DialectSmb, DedupeKeys: "last"}); !slices.Equal(got, includes) {
+		t.Errorf("Lines(DedupeKeys) = %q, want every include kept", got)
+	}
+}
+
+func TestSortKeysKeepsDirectives(t *testing.T) {
+	in := []string{"[mysqld]", "port = 3306", "bind = x", "!include /etc/mysql/extra.cnf", "user = mysql", "datadir = /var"}
+	want := []string{"[mysqld]", "bind    = x", "port    = 3306", "!include /etc/mysql/extra.cnf", "datadir = /var", "user    = mysql"}
+	got, err := Lines(in, Options{Dialect: DialectMyCnf, SortKeys: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
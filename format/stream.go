@@ -0,0 +1,140 @@
+package format
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// A Reader reads the formatted form of the INI file read from another
+// reader: the same bytes Source returns for the whole file. It also
+// implements io.WriterTo, so io.Copy(w, NewReader(r, opts)) streams the
+// result without it ever being held as a whole.
+//
+// Aligning a file as a whole needs all of its lines, so by default a Reader
+// holds the whole file in memory before its first byte can be read. When
+// each section is formatted on its own, that is with PerSection or
+// SingleSpace and without the options that move lines between sections
+// (SortSections, DefaultSection, Nest, Flatten, PruneEmptySections and
+// BlankLines other than "keep"), it holds one section at a time: a section
+// is formatted and made available once the header of the next one is read.
+// An error in a later section is then returned only after the sections
+// before it have been read; a caller that must not act on partial output
+// reads the whole file before using it.
+type Reader struct {
+	src     *bufio.Reader
+	opts    Options
+	stream  bool
+	pending []string // the lines of the section being read
+	read    int      // lines read so far
+	done    int      // lines formatted so far, before those of pending
+	newline bool     // the first line read was a lone "\n"
+	out     []byte   // formatted bytes not read yet
+	err     error    // returned once out is drained
+}
+
+// NewReader returns a Reader formatting the INI file read from r with opts.
+func NewReader(r io.Reader, opts Options) *Reader {
+	return &Reader{src: bufio.NewReader(r), opts: opts, stream: streams(opts)}
+}
+
+// streams reports whether opts format each section independently of the
+// others, so a Reader can format a file one section at a time.
+func streams(opts Options) bool {
+	return (opts.PerSection || opts.SingleSpace) && !opts.SortSections &&
+		opts.DefaultSection == "" && opts.Nest == 0 && !opts.Flatten &&
+		!opts.PruneEmptySections && (opts.BlankLines == "" || opts.BlankLines == "keep")
+}
+
+// Read reads formatted bytes into p.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 && r.err == nil {
+		r.fill()
+	}
+	if len(r.out) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// WriteTo writes the formatted file to w, a section at a time when the
+// options allow it.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		for len(r.out) == 0 && r.err == nil {
+			r.fill()
+		}
+		if len(r.out) > 0 {
+			n, err := w.Write(r.out)
+			total += int64(n)
+			r.out = r.out[n:]
+			if err != nil {
+				return total, err
+			}
+			continue
+		}
+		if errors.Is(r.err, io.EOF) {
+			return total, nil
+		}
+		return total, r.err
+	}
+}
+
+// fill reads lines until a section is complete, or the whole file unless
+// r streams, and formats it into r.out. It sets r.err at the end of the
+// input or on an error.
+func (r *Reader) fill() {
+	for {
+		line, err := r.src.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			r.err = err
+			return
+		}
+		if line != "" {
+			r.read++
+			if r.read == 1 {
+				r.newline = line == "\n"
+			}
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if r.stream && IsHeader(line) && len(r.pending) > 0 {
+				section := r.pending
+				r.pending = []string{line}
+				r.format(section)
+				return
+			}
+			r.pending = append(r.pending, line)
+		}
+		if err != nil {
+			// A lone newline is an empty file, as SplitLines has it.
+			if r.read == 1 && r.newline {
+				r.pending = nil
+			}
+			r.format(r.pending)
+			r.pending = nil
+			if r.err == nil {
+				r.err = io.EOF
+			}
+			return
+		}
+	}
+}
+
+// format formats lines into r.out, or records the error doing so. The line
+// of a ParseError is counted from the start of the file, as Source has it.
+func (r *Reader) format(lines []string) {
+	result, err := Lines(lines, r.opts)
+	if err != nil {
+		var pe *ParseError
+		if errors.As(err, &pe) && pe.Line > 0 {
+			pe.Line += r.done
+		}
+		r.err = err
+		return
+	}
+	r.done += len(lines)
+	r.out = appendLines(r.out, result)
+}
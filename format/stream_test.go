@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReaderMatchesSource(t *testing.T) {
+	smb, err := os.ReadFile("testdata/smb.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []string{
+		"",
+		"\n",
+		"\r\n",
+		"a=1\nlong_key = 2",
+		"; top\nk=v\n\n[b]\nx=1\nlonger=2\n; about c\n\n[c] ; note\ny = 1\n\n\n",
+		"[s]\r\nk=v\r\n[s]\r\nk=v\r\nk  =  w\r\n",
+		"[a]\nlist = b, a,, c\nname=${HOME}\n[b]\n  indented=1\nk=\n",
+		string(smb),
+	}
+	for mode := 0; mode < 1<<16; mode += 37 {
+		cfg := fuzzConfig(uint16(mode))
+		for _, in := range inputs {
+			want, wantErr := Source([]byte(in), cfg)
+
+			got, err := io.ReadAll(iotest.OneByteReader(NewReader(strings.NewReader(in), cfg)))
+			if (err != nil) != (wantErr != nil) || !bytes.Equal(got, want) {
+				t.Fatalf("mode %#x: Read(%q) = %q, %v; want %q, %v", mode, in, got, err, want, wantErr)
+			}
+
+			var buf bytes.Buffer
+			n, err := io.Copy(&buf, NewReader(iotest.HalfReader(strings.NewReader(in)), cfg))
+			if (err != nil) != (wantErr != nil) || !bytes.Equal(buf.Bytes(), want) || n != int64(buf.Len()) {
+				t.Fatalf("mode %#x: WriteTo(%q) = %q, %d, %v; want %q, %v", mode, in, buf.Bytes(), n, err, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestReaderStreamsSections(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := NewReader(pr, Options{PerSection: true})
+	go func() {
+		io.WriteString(pw, "[a]\nk=1\nlong=2\n[b]\n")
+		// The rest is only written once the first section was read.
+	}()
+	buf := make([]byte, 64)
+	n, err := io.ReadAtLeast(r, buf, len("[a]\nk    = 1\nlong = 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), "[a]\nk    = 1\nlong = 2\n"; got != want {
+		t.Fatalf("first section = %q, want %q", got, want)
+	}
+	go func() {
+		io.WriteString(pw, "x=1\n")
+		pw.Close()
+	}()
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(rest), "[b]\nx = 1\n"; got != want {
+		t.Errorf("rest = %q, want %q", got, want)
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	cfg := Options{ExpandEnv: true}
+	_, err := io.ReadAll(NewReader(strings.NewReader("a = $INIFMT_TEST_MISSING\n"), cfg))
+	if err == nil {
+		t.Fatal("Read() expected a ParseError")
+	}
+	// A streamed section reports the line of the file, not of the section.
+	for name, opts := range map[string]Options{
+		"whole file":   {ExpandEnv: true},
+		"per section":  {ExpandEnv: true, PerSection: true},
+		"single space": {ExpandEnv: true, SingleSpace: true},
+	} {
+		src := "[a]\nx = 1\n\n[b]\ny = $INIFMT_TEST_MISSING\n"
+		_, want := Source([]byte(src), opts)
+		var out strings.Builder
+		_, err := io.Copy(&out, NewReader(strings.NewReader(src), opts))
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Line != 5 || err.Error() != want.Error() {
+			t.Errorf("%s: Read() error = %v, want %v on line 5", name, err, want)
+		}
+		if opts.PerSection && out.String() != "[a]\nx = 1\n\n" {
+			t.Errorf("%s: output before the error = %q, want the first section", name, out.String())
+		}
+	}
+	_, err = io.Copy(io.Discard, NewReader(iotest.ErrReader(io.ErrUnexpectedEOF), Options{}))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("WriteTo() = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
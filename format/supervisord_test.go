@@ -0,0 +1,63 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSupervisordDialect(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{
+			name: "program sections",
+			cfg:  Options{PerSection: true},
+			in:   []string{"[program:web]", "command=%(ENV_HOME)s/bin/web", "autostart:true", "", "[group:site]", "programs=web,worker"},
+			want: []string{"[program:web]", "command   = %(ENV_HOME)s/bin/web", "autostart = true", "", "[group:site]", "programs = web,worker"},
+		},
+		{
+			name: "sort sections by the whole name",
+			cfg:  Options{SortSections: true},
+			in:   []string{"[program:web]", "a=1", "[supervisord]", "b=2", "[program:api]", "c=3"},
+			want: []string{"[program:api]", "c = 3", "[program:web]", "a = 1", "[supervisord]", "b = 2"},
+		},
+		{
+			name: "expansions kept whole",
+			cfg:  Options{NormalizeLists: true, SortListValues: []string{"environment"}},
+			in:   []string{"[program:web]", "environment=PATH=\"%(ENV_PATH)s\",HOME=\"%(here)s\"", "command=%(program_name)s  --name  %(process_num)02d"},
+			want: []string{"[program:web]", "environment = HOME=\"%(here)s\", PATH=\"%(ENV_PATH)s\"", "command     = %(program_name)s --name %(process_num)02d"},
+		},
+		{
+			name: "indented continuation",
+			in:   []string{"[program:web]", "environment=", "    A=\"1\",", "    B=\"2\"", "user=www"},
+			want: []string{"[program:web]", "environment =", "    A=\"1\",", "    B=\"2\"", "user        = www"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Dialect = DialectSupervisord
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if again, _ := Lines(got, tt.cfg); !slices.Equal(again, got) {
+				t.Errorf("Lines() is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+		})
+	}
+}
+
+func TestSupervisordKeys(t *testing.T) {
+	lines := []string{"[supervisord]", "logfile=/tmp/s.log", "[program:web]", "command=%(ENV_HOME)s/web"}
+	kv, ok := FindKey(ParseKeyValues(lines, Options{Dialect: DialectSupervisord}), "program:web.command")
+	if !ok || kv.Value != "%(ENV_HOME)s/web" {
+		t.Errorf("FindKey(program:web.command) = %+v, %v", kv, ok)
+	}
+}
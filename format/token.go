@@ -0,0 +1,176 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TokenKind classifies a piece of a line.
+type TokenKind int
+
+// Token kinds, as assigned by Tokenize.
+const (
+	TokenWhitespace    TokenKind = iota
+	TokenSectionName             // a [section] header, brackets included
+	TokenKey                     // the key of a key/value line, or a bare key
+	TokenDelimiter               // the '=', or assignment operator, between key and value
+	TokenValue                   // a value without its inline comment
+	TokenCommentMarker           // the prefix of a full-line or inline comment
+	TokenCommentText             // the text of a comment after its marker
+	TokenText                    // trailing text after a header that is not a comment
+	TokenDirective               // a line the dialect keeps verbatim
+)
+
+var tokenKindNames = [...]string{
+	TokenWhitespace:    "Whitespace",
+	TokenSectionName:   "SectionName",
+	TokenKey:           "Key",
+	TokenDelimiter:     "Delimiter",
+	TokenValue:         "Value",
+	TokenCommentMarker: "CommentMarker",
+	TokenCommentText:   "CommentText",
+	TokenText:          "Text",
+	TokenDirective:     "Directive",
+}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return "TokenKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Token is a classified piece of a line. Concatenating the texts of the tokens
+// of a line gives back the line exactly.
+type Token struct {
+	Kind   TokenKind
+	Text   string
+	Line   int // 1-based line number
+	Offset int // byte offset of Text within its line
+}
+
+// Tokenize splits line into tokens using the rules the formatter itself uses:
+// the line is classified by cfg's dialect, full-line comments by cfg's comment
+// prefixes, headers may end in a comment, and key/value lines are split by Cut
+// and their values may end in an inline comment. A line without a delimiter
+// is a bare key. The tokens' Line is 1.
+func Tokenize(line string, cfg Options) []Token {
+	return cfg.tokenize(1, line, cfg.dialect().Classify(line, LineContext{}, cfg))
+}
+
+// TokenizeLines tokenizes each of lines, numbering them from 1. Unlike
+// Tokenize it knows the lines before each line, so continuation lines, such
+// as those of multi-line .reg values, are values.
+func TokenizeLines(lines []string, cfg Options) [][]Token {
+	result := make([][]Token, len(lines))
+	for i, kind := range cfg.classifyLines(lines) {
+		result[i] = cfg.tokenize(i+1, lines[i], kind)
+	}
+	return result
+}
+
+// tokenizer accumulates the tokens of one line.
+type tokenizer struct {
+	tokens []Token
+	line   int
+	offset int
+}
+
+// add appends text as a token unless it is empty.
+func (t *tokenizer) add(kind TokenKind, text string) {
+	if text != "" {
+		t.tokens = append(t.tokens, Token{Kind: kind, Text: text, Line: t.line, Offset: t.offset})
+		t.offset += len(text)
+	}
+}
+
+// addSpaced adds text with its surrounding whitespace as separate tokens.
+func (t *tokenizer) addSpaced(kind TokenKind, text string) {
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	t.add(TokenWhitespace, text[:len(text)-len(trimmed)])
+	core := strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	t.add(kind, core)
+	t.add(TokenWhitespace, trimmed[len(core):])
+}
+
+// addComment adds a comment: its marker, the first len(marker) bytes of the
+// trimmed text, and the rest as comment text.
+func (t *tokenizer) addComment(text string, marker int) {
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	t.add(TokenWhitespace, text[:len(text)-len(trimmed)])
+	t.add(TokenCommentMarker, trimmed[:marker])
+	t.addSpaced(TokenCommentText, trimmed[marker:])
+}
+
+// addHeader adds a header line split by Dialect.SplitHeader. The trailing
+// text is a comment when it starts with ';' or '#'.
+func (t *tokenizer) addHeader(header, rest string) {
+	t.addSpaced(TokenSectionName, header)
+	if trimmed := strings.TrimSpace(rest); trimmed != "" && (trimmed[0] == ';' || trimmed[0] == '#') {
+		t.addComment(rest, 1)
+	} else {
+		t.addSpaced(TokenText, rest)
+	}
+}
+
+// tokenize splits line number n, of the given kind, into tokens.
+func (c Options) tokenize(n int, line string, kind LineKind) []Token {
+	t := tokenizer{line: n}
+	switch kind {
+	case LineBlank:
+		t.add(TokenWhitespace, line)
+	case LineContinuation:
+		t.addSpaced(TokenValue, line)
+	case LineDirective:
+		t.addSpaced(TokenDirective, line)
+	case LineComment:
+		p, _ := c.commentPrefix(line)
+		trimmed := strings.TrimSpace(line)
+		marker := 0
+		for p != "" && strings.HasPrefix(trimmed[marker:], p) {
+			marker += len(p)
+		}
+		t.addComment(line, marker)
+	case LineHeader:
+		t.addHeader(c.dialect().SplitHeader(line))
+	default:
+		before, after, ok := c.Cut(line)
+		if !ok {
+			t.addSpaced(TokenKey, line)
+			break
+		}
+		key, prefix := c.splitOperator(before)
+		t.addSpaced(TokenKey, key)
+		t.add(TokenDelimiter, prefix+"=")
+		if idx := InlineCommentIndex(after); idx != -1 {
+			t.addSpaced(TokenValue, after[:idx])
+			t.addComment(after[idx:], 1)
+		} else {
+			t.addSpaced(TokenValue, after)
+		}
+	}
+	return t.tokens
+}
+
+// keyValue returns the key of a key/value line, its delimiter and the text
+// after it, as classified by Tokenize; ok is false for any other line,
+// including the multi-pair lines AlignPairs aligns.
+func (c Options) keyValue(line string) (key, op, after string, ok bool) {
+	if c.AlignPairs && c.isPairLine(line) {
+		return "", "", "", false
+	}
+	kind := c.dialect().Classify(line, LineContext{Index: -1}, c)
+	for _, t := range c.tokenize(0, line, kind) {
+		switch t.Kind {
+		case TokenWhitespace:
+		case TokenKey:
+			key = t.Text
+		case TokenDelimiter:
+			return key, t.Text, line[t.Offset+len(t.Text):], true
+		default:
+			return "", "", "", false
+		}
+	}
+	return "", "", "", false
+}
@@ -0,0 +1,132 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tok is a Token without its position, for compact test tables.
+type tok struct {
+	kind TokenKind
+	text string
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		cfg  Options
+		want []tok
+	}{
+		{
+			line: "  [server] ; main",
+			want: []tok{{TokenWhitespace, "  "}, {TokenSectionName, "[server]"}, {TokenWhitespace, " "}, {TokenCommentMarker, ";"}, {TokenWhitespace, " "}, {TokenCommentText, "main"}},
+		},
+		{
+			line: "[server]extra ",
+			want: []tok{{TokenSectionName, "[server]"}, {TokenText, "extra"}, {TokenWhitespace, " "}},
+		},
+		{
+			line: "host  = \"a ; b\" # note",
+			want: []tok{{TokenKey, "host"}, {TokenWhitespace, "  "}, {TokenDelimiter, "="}, {TokenWhitespace, " "}, {TokenValue, "\"a ; b\""}, {TokenWhitespace, " "}, {TokenCommentMarker, "#"}, {TokenWhitespace, " "}, {TokenCommentText, "note"}},
+		},
+		{
+			line: "color = #fff",
+			want: []tok{{TokenKey, "color"}, {TokenWhitespace, " "}, {TokenDelimiter, "="}, {TokenWhitespace, " "}, {TokenValue, "#fff"}},
+		},
+		{
+			line: "\t;; comment ",
+			want: []tok{{TokenWhitespace, "\t"}, {TokenCommentMarker, ";;"}, {TokenWhitespace, " "}, {TokenCommentText, "comment"}, {TokenWhitespace, " "}},
+		},
+		{
+			line: "REM old",
+			cfg:  Options{CommentPrefixes: []string{"REM"}},
+			want: []tok{{TokenCommentMarker, "REM"}, {TokenWhitespace, " "}, {TokenCommentText, "old"}},
+		},
+		{
+			line: "bare_key",
+			want: []tok{{TokenKey, "bare_key"}},
+		},
+		{
+			line: "a=b=c",
+			cfg:  Options{SplitOn: "last"},
+			want: []tok{{TokenKey, "a=b"}, {TokenDelimiter, "="}, {TokenValue, "c"}},
+		},
+		{
+			line: `"a=b"=dword:1`,
+			cfg:  Options{Dialect: DialectReg},
+			want: []tok{{TokenKey, `"a=b"`}, {TokenDelimiter, "="}, {TokenValue, "dword:1"}},
+		},
+		{
+			line: "[HKEY_CURRENT_USER\\A;B #1]",
+			cfg:  Options{Dialect: DialectReg},
+			want: []tok{{TokenSectionName, "[HKEY_CURRENT_USER\\A;B #1]"}},
+		},
+		{
+			line: " \t",
+			want: []tok{{TokenWhitespace, " \t"}},
+		},
+	}
+	for _, tt := range tests {
+		var got []tok
+		for _, token := range Tokenize(tt.line, tt.cfg) {
+			got = append(got, tok{token.Kind, token.Text})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeLinesContinuations(t *testing.T) {
+	toks := TokenizeLines(regInput, Options{Dialect: DialectReg})
+	for i := 7; i <= 8; i++ { // the continuation lines of "Binary"
+		if kind := toks[i][1].Kind; kind != TokenValue {
+			t.Errorf("line %d: %q is a %v, want Value", i+1, regInput[i], kind)
+		}
+	}
+}
+
+// TestTokenizeCorpus checks that tokens cover every line of the corpus
+// exactly, with consistent positions, and agree with ParseKeyValues.
+func TestTokenizeCorpus(t *testing.T) {
+	sample, err := os.ReadFile("../test.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, _ := SplitLines(string(sample))
+	corpus := []struct {
+		lines []string
+		cfg   Options
+	}{
+		{lines, Options{}},
+		{lines, Options{SplitOn: "last", CommentPrefixes: []string{"//", "REM"}}},
+		{regInput, Options{Dialect: DialectReg}},
+		{[]string{"[", "]", "=", "==", " [x]y", "[s] ;", "k = 'a;b' ;c", "é = ü # ö", "\v= x"}, Options{}},
+		{[]string{"a += 1", "b?=2", "+= 3", "c + = 4", "d = x+=y", "e ::= 5"}, Options{Operators: []string{"+=", "?=", "::="}}},
+	}
+	for _, c := range corpus {
+		kvs := ParseKeyValues(c.lines, c.cfg)
+		for i, toks := range TokenizeLines(c.lines, c.cfg) {
+			var b strings.Builder
+			for _, token := range toks {
+				if token.Text == "" || token.Line != i+1 || token.Offset != b.Len() {
+					t.Errorf("line %d %q: bad token %+v", i+1, c.lines[i], token)
+				}
+				b.WriteString(token.Text)
+			}
+			if b.String() != c.lines[i] {
+				t.Errorf("tokens of line %d %q join to %q", i+1, c.lines[i], b.String())
+			}
+			key, _, _, ok := c.cfg.keyValue(c.lines[i])
+			if !ok {
+				continue
+			}
+			idx := slices.IndexFunc(kvs, func(kv KeyValue) bool { return kv.Line == i+1 })
+			if idx == -1 || kvs[idx].Key != key {
+				t.Errorf("line %d %q: Tokenize key %q disagrees with ParseKeyValues", i+1, c.lines[i], key)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package format
+
+import "strings"
+
+// keyRepeater is implemented by dialects in which repeating a key adds to it
+// rather than restating it, so Unique must leave repeated lines alone.
+type keyRepeater interface {
+	RepeatsKeys() bool
+}
+
+// RepeatsKeys reports whether every occurrence of a key counts in d, as in
+// systemd units, where two identical "ExecStartPre=" lines run the command
+// twice. Options.Unique does nothing in such a dialect.
+func RepeatsKeys(d Dialect) bool {
+	kr, ok := d.(keyRepeater)
+	return ok && kr.RepeatsKeys()
+}
+
+// Duplicate is a key/value line that repeats an earlier line of its section.
+type Duplicate struct {
+	Line  int // 1-based number of the repeated line
+	First int // 1-based number of the line it repeats
+}
+
+// uniqueText returns what two lines of kv's key must share to be duplicates:
+// the normalized value and inline comment, or nothing for a bare key.
+func uniqueText(kv KeyValue) string {
+	if !kv.HasValue {
+		return ""
+	}
+	value, comment := SplitInlineComment(kv.Value)
+	return "=" + normalizeValue(value) + "\x00" + strings.TrimSpace(comment)
+}
+
+// DuplicateLines returns the key/value lines Unique removes from lines: those
+// with the same key, the same value after normalization and the same inline
+// comment as an earlier line of the same section. Only the sections selected
+// by OnlySections count, and none do in a dialect that repeats keys.
+func DuplicateLines(lines []string, opts Options) []Duplicate {
+	if RepeatsKeys(opts.dialect()) {
+		return nil
+	}
+	var dups []Duplicate
+	first := make(map[[3]string]int)
+	for _, kv := range ParseKeyValues(lines, opts) {
+		if len(opts.OnlySections) > 0 && !sectionSelected(opts.OnlySections, kv.Section) {
+			continue
+		}
+		id := [3]string{kv.Section, kv.Key, uniqueText(kv)}
+		if n, ok := first[id]; ok {
+			dups = append(dups, Duplicate{Line: kv.Line, First: n})
+			continue
+		}
+		first[id] = kv.Line
+	}
+	return dups
+}
+
+// uniqueLines drops the key/value lines that repeat an earlier line of their
+// section, with their continuation lines. Comments above them stay.
+func uniqueLines(lines []string, cfg Options) []string {
+	dups := DuplicateLines(lines, cfg)
+	if len(dups) == 0 {
+		return lines
+	}
+	kinds := cfg.classifyLines(lines)
+	drop := make(map[int]bool)
+	for _, d := range dups {
+		i := d.Line - 1
+		drop[i] = true
+		for i+1 < len(lines) && kinds[i+1] == LineContinuation {
+			i++
+			drop[i] = true
+		}
+	}
+	result := make([]string, 0, len(lines)-len(drop))
+	for i, line := range lines {
+		if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	return result
+}
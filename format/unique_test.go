@@ -0,0 +1,75 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnique(t *testing.T) {
+	lines := []string{
+		"[server]",
+		"; the host",
+		"host = example.com",
+		"port = 80",
+		"; pasted again",
+		"host   =   example.com",
+		"host = example.org",
+		"port = 80 ; default",
+		"flag",
+		"flag",
+		"[client]",
+		"port = 80",
+		"[server]",
+		"port = 80",
+	}
+	tests := []struct {
+		name string
+		cfg  Options
+		want []string
+	}{
+		{
+			name: "unique",
+			cfg:  Options{SingleSpace: true, Unique: true},
+			want: []string{
+				"[server]", "; the host", "host = example.com", "port = 80", "; pasted again",
+				"host = example.org", "port = 80 ; default", "flag", "[client]", "port = 80", "[server]",
+			},
+		},
+		{
+			name: "only sections",
+			cfg:  Options{SingleSpace: true, Unique: true, OnlySections: []string{"client"}},
+			want: lines,
+		},
+		{
+			name: "systemd",
+			cfg:  Options{Unique: true, Dialect: DialectSystemd},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want == nil {
+				if got := DuplicateLines(lines, tt.cfg); got != nil {
+					t.Errorf("DuplicateLines() = %v, want none", got)
+				}
+				return
+			}
+			got, err := Lines(lines, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDuplicateLines(t *testing.T) {
+	lines := []string{"a = 1", "[s]", "k = v", "k = 'v'", "k =  v", "a = 1", "[s]", "k = v"}
+	want := []Duplicate{{Line: 5, First: 3}, {Line: 8, First: 3}}
+	if got := DuplicateLines(lines, Options{}); !slices.Equal(got, want) {
+		t.Errorf("DuplicateLines() = %v, want %v", got, want)
+	}
+}
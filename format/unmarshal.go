@@ -0,0 +1,252 @@
+package format
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unmarshal parses the INI file data and stores its keys in v, which must be a
+// pointer to a map[string]map[string]string (or a map of maps with string or
+// any values) or to a struct. Lines are classified by the same parser the
+// formatter uses, with the default Options, and a key given more than once
+// takes its last value, as FindKey resolves it.
+//
+// Struct fields are matched the way Marshal writes them: struct and map fields
+// by section name, other fields by preamble key, using the ini tag or the field
+// name, exactly or else case-insensitively. Keys without a matching field are
+// ignored. Inline comments are removed from values and a value quoted in full
+// is unquoted. Fields may be strings, booleans (true/false, yes/no, on/off,
+// 1/0), integers, floats, time.Duration, encoding.TextUnmarshaler
+// implementations, or slices of those split on top-level commas; a bare key
+// sets a boolean field to true. Conversion errors name the line, section and
+// key.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %T: need a non-nil pointer", v)}
+	}
+	rv = rv.Elem()
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+	default:
+		return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %s", rv.Type())}
+	}
+	lines, _ := SplitLines(strings.TrimPrefix(string(data), "\uFEFF"))
+	for _, kv := range ParseKeyValues(lines, Options{}) {
+		if err := unmarshalKey(rv, kv); err != nil {
+			var te *TypeError
+			if errors.As(err, &te) {
+				return &TypeError{Path: kv.Path(), Err: err}
+			}
+			return &ParseError{Line: kv.Line, Key: kv.Path(), Err: err}
+		}
+	}
+	return nil
+}
+
+// unmarshalKey stores one key of the file in the map or struct v.
+func unmarshalKey(v reflect.Value, kv KeyValue) error {
+	if v.Kind() == reflect.Map {
+		if v.Type().Key().Kind() != reflect.String {
+			return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %s: keys must be strings", v.Type())}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		name := reflect.ValueOf(kv.Section).Convert(v.Type().Key())
+		section := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(name); existing.IsValid() {
+			section.Set(existing)
+		}
+		if err := unmarshalSectionKey(section, kv); err != nil {
+			return err
+		}
+		v.SetMapIndex(name, section)
+		return nil
+	}
+	if kv.Section == "" {
+		field, ok := fieldByName(iniFields(v), kv.Key, false)
+		if !ok {
+			return nil
+		}
+		return setValue(field, kv)
+	}
+	field, ok := fieldByName(iniFields(v), kv.Section, true)
+	if !ok {
+		return nil
+	}
+	return unmarshalSectionKey(field, kv)
+}
+
+// unmarshalSectionKey stores a key in the section v, a map or struct,
+// allocating pointers and maps as needed.
+func unmarshalSectionKey(v reflect.Value, kv KeyValue) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %s: keys must be strings", v.Type())}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		value := reflect.New(v.Type().Elem()).Elem()
+		if err := setValue(value, kv); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(kv.Key).Convert(v.Type().Key()), value)
+		return nil
+	case reflect.Struct:
+		field, ok := fieldByName(iniFields(v), kv.Key, false)
+		if !ok {
+			return nil
+		}
+		return setValue(field, kv)
+	}
+	return &TypeError{Reason: fmt.Sprintf("cannot unmarshal a section into %s", v.Type())}
+}
+
+// fieldByName returns the field named name, preferring an exact match over a
+// case-insensitive one. section selects section fields or key fields.
+func fieldByName(fields []iniField, name string, section bool) (reflect.Value, bool) {
+	var fold reflect.Value
+	for _, f := range fields {
+		if isSectionValue(f.value) != section {
+			continue
+		}
+		if f.name == name {
+			return f.value, true
+		}
+		if !fold.IsValid() && strings.EqualFold(f.name, name) {
+			fold = f.value
+		}
+	}
+	return fold, fold.IsValid()
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// setValue converts the value of kv to the type of v and stores it.
+func setValue(v reflect.Value, kv KeyValue) error {
+	text := ValueText(kv.Value)
+	if !kv.HasValue && v.Kind() == reflect.Bool {
+		text = "true"
+	}
+	return setText(v, text)
+}
+
+// setText converts text to the type of v and stores it.
+func setText(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setText(v.Elem(), text)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", text)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %s", v.Type())}
+		}
+		v.Set(reflect.ValueOf(text))
+	case reflect.Bool:
+		b, ok := ParseBool(text)
+		if !ok {
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), text)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), text)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), text)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		if text != "" {
+			items = splitList(text, ',')
+		}
+		s := reflect.MakeSlice(v.Type(), 0, len(items))
+		for i, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" && i == len(items)-1 {
+				break // trailing comma
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setText(elem, ValueText(item)); err != nil {
+				return fmt.Errorf("item %d: %w", i+1, err)
+			}
+			s = reflect.Append(s, elem)
+		}
+		if len(items) > 0 {
+			v.Set(s)
+		}
+	default:
+		return &TypeError{Reason: fmt.Sprintf("cannot unmarshal into %s", v.Type())}
+	}
+	return nil
+}
+
+// ValueText returns what a raw value stands for: the value without its inline
+// comment and, when it is quoted in full, without the quotes. Double-quoted
+// values are unescaped as Marshal escapes them.
+func ValueText(value string) string {
+	value, _ = SplitInlineComment(value)
+	if len(value) < 2 || (value[0] != '"' && value[0] != '\'') || quotedEnd(value, 0) != len(value) {
+		return value
+	}
+	if value[0] == '"' {
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+	}
+	return value[1 : len(value)-1]
+}
+
+// ParseBool accepts the boolean spellings common in INI files: true, yes, on
+// and 1, and false, no, off and 0, in any case. The second result reports
+// whether text is one of them.
+func ParseBool(text string) (bool, bool) {
+	switch strings.ToLower(text) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,206 @@
+package format
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalMap(t *testing.T) {
+	data := "\uFEFFtop = level\n; comment\n[server]\nhost = example.com ; primary\nport = 80\nport = 8080\npath = \"/a ; b\"\nflag\n[empty]\n"
+	var got map[string]map[string]string
+	if err := Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"":       {"top": "level"},
+		"server": {"host": "example.com", "port": "8080", "path": "/a ; b", "flag": ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %v, want %v", got, want)
+	}
+
+	var anyMap map[string]map[string]any
+	if err := Unmarshal([]byte(data), &anyMap); err != nil {
+		t.Fatal(err)
+	}
+	if anyMap["server"]["port"] != "8080" {
+		t.Errorf("Unmarshal(any) server.port = %v, want 8080", anyMap["server"]["port"])
+	}
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	type server struct {
+		Host    string        `ini:"host"`
+		Port    uint16        `ini:"port"`
+		Timeout time.Duration `ini:"timeout"`
+		Ratio   float64       `ini:"ratio"`
+		TLS     bool          `ini:"tls"`
+		Verbose bool          `ini:"verbose"`
+		Tags    []string      `ini:"tags"`
+		Ports   []int         `ini:"ports"`
+		Addr    netip.Addr    `ini:"addr"`
+		Limit   *int          `ini:"limit"`
+	}
+	type config struct {
+		Name   string            `ini:"name"`
+		Server server            `ini:"server"`
+		Backup *server           `ini:"backup"`
+		Extra  map[string]string `ini:"extra"`
+		Skip   string            `ini:"-"`
+	}
+	data := `name = demo
+skip = ignored
+unknown = ignored
+
+[server]
+host    = example.com
+port    = 0x50
+timeout = 1m30s
+ratio   = 0.5
+tls     = yes
+verbose
+tags    = a, "b, c", d,
+ports   = 1, 2
+addr    = 10.0.0.1
+limit   = 5
+
+[BACKUP]
+host = backup.example.com
+
+[extra]
+k = v
+
+[unknown]
+x = 1
+`
+	var got config
+	if err := Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	limit := 5
+	want := config{
+		Name: "demo",
+		Server: server{
+			Host: "example.com", Port: 80, Timeout: 90 * time.Second, Ratio: 0.5,
+			TLS: true, Verbose: true, Tags: []string{"a", "b, c", "d"}, Ports: []int{1, 2},
+			Addr: netip.MustParseAddr("10.0.0.1"), Limit: &limit,
+		},
+		Backup: &server{Host: "backup.example.com"},
+		Extra:  map[string]string{"k": "v"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type server struct {
+		Port    int           `ini:"port"`
+		TLS     bool          `ini:"tls"`
+		Timeout time.Duration `ini:"timeout"`
+		Ports   []uint8       `ini:"ports"`
+		Addr    netip.Addr    `ini:"addr"`
+	}
+	type config struct {
+		Server server `ini:"server"`
+	}
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"[server]\nport = abc", `line 2: server.port: invalid int "abc"`},
+		{"\n[server]\ntls = maybe", `line 3: server.tls: invalid boolean "maybe"`},
+		{"[server]\ntimeout = 5", `line 2: server.timeout: invalid duration "5"`},
+		{"[server]\nports = 1, 300", `line 2: server.ports: item 2: invalid uint8 "300"`},
+		{"[server]\naddr = nope", "line 2: server.addr: ParseAddr"},
+	}
+	for _, tt := range tests {
+		var cfg config
+		err := Unmarshal([]byte(tt.data), &cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%q) error = %v, want %q", tt.data, err, tt.want)
+		}
+	}
+
+	var m map[string]map[string]string
+	if err := Unmarshal([]byte("a = 1"), m); err == nil {
+		t.Error("Unmarshal(non-pointer) expected error")
+	}
+	var s []string
+	if err := Unmarshal([]byte("a = 1"), &s); err == nil {
+		t.Error("Unmarshal(*[]string) expected error")
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	maps := map[string]map[string]string{
+		"": {"top": "level"},
+		"values": {
+			"plain":   "a b",
+			"spaces":  "a  b",
+			"padded":  " x ",
+			"comment": "x ; y",
+			"hash":    "#fff",
+			"quotes":  `say "hi" ; now`,
+			"single":  "'q'",
+			"escapes": `back\slash`,
+			"empty":   "",
+			"equals":  "a=b",
+		},
+	}
+	data, err := Marshal(maps, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotMaps map[string]map[string]string
+	if err := Unmarshal(data, &gotMaps); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotMaps, maps) {
+		t.Errorf("round trip = %q, want %q\n%s", gotMaps, maps, data)
+	}
+
+	type section struct {
+		Name    string        `ini:"name"`
+		Count   int64         `ini:"count"`
+		Enabled bool          `ini:"enabled"`
+		Ratio   float32       `ini:"ratio"`
+		Wait    time.Duration `ini:"wait"`
+		Items   []string      `ini:"items"`
+		Addr    netip.Addr    `ini:"addr"`
+	}
+	type document struct {
+		Title string            `ini:"title"`
+		Main  section           `ini:"main"`
+		Other *section          `ini:"other"`
+		Attrs map[string]string `ini:"attrs"`
+		Any   map[string]any    `ini:"any"`
+	}
+	doc := document{
+		Title: "  spaced  title ",
+		Main: section{
+			Name: "x # not a comment", Count: -42, Enabled: true, Ratio: 0.25,
+			Wait: 1500 * time.Millisecond, Items: []string{"a", "b c", "d  e"},
+			Addr: netip.MustParseAddr("::1"),
+		},
+		Other: &section{Name: "other", Items: []string{"only"}},
+		Attrs: map[string]string{"k": "v"},
+		Any:   map[string]any{"s": "str"},
+	}
+	for _, opts := range []Options{{}, {PerSection: true}, {SingleSpace: true}, {SortKeys: []string{"*"}}} {
+		data, err := Marshal(doc, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got document
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, doc) {
+			t.Errorf("round trip(%+v) = %+v, want %+v\n%s", opts, got, doc, data)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package format
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Validate reports the first option of c, or of the options of its Sections,
+// whose value is not one it accepts, or options that cannot be combined, as an
+// *OptionError. Lines and Explain validate their options first; the zero
+// value is valid.
+func (c Options) Validate() error {
+	oneOf := func(option, value string, allowed ...string) error {
+		if value == "" || slices.Contains(allowed, value) {
+			return nil
+		}
+		return &OptionError{Option: option, Reason: fmt.Sprintf("invalid %s %q (want %s)", option, value, strings.Join(allowed, ", "))}
+	}
+	for _, err := range []error{
+		oneOf("DedupeKeys", c.DedupeKeys, "first", "last"),
+		oneOf("SplitOn", c.SplitOn, "first", "last"),
+		oneOf("HexCase", c.HexCase, "lower", "upper"),
+		oneOf("ListSeparator", c.ListSeparator, ",", ";", "space"),
+		oneOf("ListTrailingComma", c.ListTrailingComma, "keep", "drop"),
+		oneOf("BlankLines", c.BlankLines, "keep", "squeeze", "sections"),
+		oneOf("Collate", c.Collate, CollateBytes, CollateUnicode),
+		oneOf("SortCase", c.SortCase, SortCaseSensitive, SortCaseInsensitive),
+		oneOf("JoinSeparator", c.JoinSeparator, "space", "none"),
+		oneOf("Retab", c.Retab, "spaces", "tabs"),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	if c.CollateLocale != "" {
+		if _, err := language.Parse(c.CollateLocale); err != nil {
+			return &OptionError{Option: "CollateLocale", Reason: fmt.Sprintf("invalid CollateLocale %q", c.CollateLocale), Err: err}
+		}
+	}
+	for _, o := range []struct {
+		option string
+		n      int
+	}{{"WrapValues", c.WrapValues}, {"TabWidth", c.TabWidth}, {"InlineCommentGap", c.InlineCommentGap}} {
+		if o.n < 0 {
+			return &OptionError{Option: o.option, Reason: fmt.Sprintf("invalid %s %d (want 0 or more)", o.option, o.n)}
+		}
+	}
+	if c.Nest < NestAll {
+		return &OptionError{Option: "Nest", Reason: fmt.Sprintf("invalid Nest %d (want NestAll, 0 or more)", c.Nest)}
+	}
+	if c.Flatten && c.Nest != 0 {
+		return &OptionError{Option: "Flatten", Reason: "Flatten and Nest cannot be combined"}
+	}
+	if c.RemoveEmptyValues && c.Dialect != nil && EmptyValuesMatter(c.Dialect) {
+		return &OptionError{Option: "RemoveEmptyValues", Reason: fmt.Sprintf("RemoveEmptyValues cannot be used with the %s dialect, where empty values mean something", c.Dialect.Name())}
+	}
+	for _, p := range c.CommentPrefixes {
+		if strings.TrimSpace(p) != p || p == "" || strings.ContainsAny(p, "=[") {
+			return &OptionError{Option: "CommentPrefixes", Reason: fmt.Sprintf("invalid CommentPrefixes entry %q", p)}
+		}
+	}
+	for _, op := range c.Operators {
+		if !validOperator(op) {
+			return &OptionError{Option: "Operators", Reason: fmt.Sprintf("invalid Operators entry %q (want characters followed by '=')", op)}
+		}
+	}
+	for _, o := range []struct {
+		option   string
+		patterns []string
+	}{{"SortKeys", c.SortKeys}, {"OnlySections", c.OnlySections}} {
+		for _, p := range o.patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return &OptionError{Option: o.option, Reason: fmt.Sprintf("invalid %s pattern %q", o.option, p), Err: err}
+			}
+		}
+	}
+	for _, s := range c.Sections {
+		if _, err := path.Match(s.Pattern, ""); err != nil {
+			return &OptionError{Option: "Sections", Reason: fmt.Sprintf("invalid Sections pattern %q", s.Pattern), Err: err}
+		}
+		if err := s.Options.Validate(); err != nil {
+			return &OptionError{Option: "Sections", Reason: fmt.Sprintf("Sections %q", s.Pattern), Err: err}
+		}
+	}
+	return nil
+}
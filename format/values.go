@@ -0,0 +1,293 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// valueSpan is a piece of a value. Atomic spans are interpolation placeholders
+// such as %(name)s, ${VAR} and %{VAR} whose interior must never be altered.
+type valueSpan struct {
+	text   string
+	atomic bool
+}
+
+// splitPlaceholders splits value into plain text and atomic spans: placeholders
+// and quoted strings, whose whitespace a parser keeps. Unterminated placeholders
+// and quotes are treated as plain text.
+func splitPlaceholders(value string) []valueSpan {
+	var spans []valueSpan
+	start := 0
+	for i := 0; i < len(value); {
+		end := placeholderEnd(value, i)
+		if end < 0 {
+			end = quotedEnd(value, i)
+		}
+		if end < 0 {
+			i++
+			continue
+		}
+		if i > start {
+			spans = append(spans, valueSpan{text: value[start:i]})
+		}
+		spans = append(spans, valueSpan{text: value[i:end], atomic: true})
+		i, start = end, end
+	}
+	if start < len(value) {
+		spans = append(spans, valueSpan{text: value[start:]})
+	}
+	return spans
+}
+
+// placeholderEnd returns the index just past the placeholder starting at i,
+// or -1 when no complete placeholder starts there.
+func placeholderEnd(value string, i int) int {
+	rest := value[i:]
+	switch {
+	case strings.HasPrefix(rest, "%("):
+		// configparser interpolation: %(name)s
+		if idx := strings.Index(rest, ")s"); idx > 1 {
+			return i + idx + 2
+		}
+	case strings.HasPrefix(rest, "${"), strings.HasPrefix(rest, "%{"):
+		// Braced references may nest, e.g. ${a:-${b}}.
+		depth := 0
+		for j := 1; j < len(rest); j++ {
+			switch rest[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i + j + 1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// quotedEnd returns the index just past the quoted string starting at i, or -1
+// when no terminated quoted string starts there. Backslash escapes the quote.
+func quotedEnd(value string, i int) int {
+	q := value[i]
+	if q != '"' && q != '\'' {
+		return -1
+	}
+	for j := i + 1; j < len(value); j++ {
+		switch value[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// normalizeValue trims value and collapses runs of whitespace to a single
+// space, leaving the interior of placeholders and quoted strings untouched.
+func normalizeValue(value string) string {
+	var b strings.Builder
+	pendingSpace := false
+	for _, span := range splitPlaceholders(value) {
+		if span.atomic {
+			if pendingSpace && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			pendingSpace = false
+			b.WriteString(span.text)
+			continue
+		}
+		for _, r := range span.text {
+			if unicode.IsSpace(r) {
+				pendingSpace = true
+				continue
+			}
+			if pendingSpace && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			pendingSpace = false
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatValue normalizes the value of the key/value line whose key is key,
+// applying the value options selected in c. Continuation lines joined to the
+// value are kept verbatim, or dropped with the value when it is redacted.
+func (c Options) formatValue(key, value string) string {
+	if head, tail, ok := strings.Cut(value, "\n"); ok {
+		if len(c.Redact) > 0 && matchesAny(c.Redact, key) {
+			return c.formatValue(key, strings.TrimSuffix(strings.TrimSpace(head), `\`))
+		}
+		return c.formatValue(key, head) + "\n" + tail
+	}
+	value = normalizeValue(value)
+	if c.NormalizeNumbers {
+		value = normalizeNumber(key, value, c.HexCase)
+	}
+	if c.NormalizeLists {
+		value = normalizeList(value, c.ListSeparator, c.ListTrailingComma == "drop")
+	}
+	if len(c.Redact) > 0 {
+		value = redactValue(c.Redact, c.RedactReveal, key, value)
+	}
+	if c.InlineCommentGap > 0 {
+		value = commentGap(value, c.InlineCommentGap)
+	}
+	return value
+}
+
+// commentGap puts exactly gap spaces between value and its inline comment,
+// if it has one after some text. A value that is only a comment is kept.
+func commentGap(value string, gap int) string {
+	idx := InlineCommentIndex(value)
+	if idx == -1 {
+		return value
+	}
+	text := strings.TrimRight(value[:idx], " \t")
+	if text == "" {
+		return value
+	}
+	return text + strings.Repeat(" ", gap) + value[idx:]
+}
+
+// listSeparatorByte maps an Options.ListSeparator name to the separator byte.
+func listSeparatorByte(name string) byte {
+	switch name {
+	case ";":
+		return ';'
+	case "space":
+		return ' '
+	}
+	return ','
+}
+
+// splitList splits value on sep at the top level only: separators inside
+// quotes, brackets or interpolation placeholders do not split. Items are
+// returned untrimmed.
+func splitList(value string, sep byte) []string {
+	var items []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			if i > 0 {
+				if end := placeholderEnd(value, i-1); end > 0 {
+					i = end - 1
+					continue
+				}
+			}
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+		case c == sep && depth == 0:
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// normalizeList rewrites a value containing top-level separators so that items
+// are trimmed and joined by the separator followed by a single space. Values
+// without a top-level separator are returned unchanged. A trailing separator is
+// kept unless dropTrailing is set.
+func normalizeList(value string, sepName string, dropTrailing bool) string {
+	sep := listSeparatorByte(sepName)
+	items := splitList(value, sep)
+	if len(items) < 2 {
+		return value
+	}
+	trailing := strings.TrimSpace(items[len(items)-1]) == ""
+	if trailing {
+		items = items[:len(items)-1]
+	}
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	joiner := string(sep) + " "
+	if sep == ' ' {
+		joiner = " "
+		items = slices.DeleteFunc(items, func(s string) bool { return s == "" })
+	}
+	out := strings.Join(items, joiner)
+	if trailing && !dropTrailing && sep != ' ' {
+		out += string(sep)
+	}
+	return out
+}
+
+// listKeySelected reports whether the key in section is selected by names,
+// where each name is either a bare key or a section-qualified "section.key".
+func listKeySelected(names []string, section, key string) bool {
+	for _, name := range names {
+		if name == key || (section != "" && name == section+"."+key) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortListValues sorts the list items of the values of the keys selected by
+// cfg.SortListValues, removing duplicate items when cfg.UniqueListValues is set.
+// Values of other keys are never touched since list order is usually meaningful.
+func sortListValues(lines []string, cfg Options) []string {
+	sep := listSeparatorByte(cfg.ListSeparator)
+	joiner := string(sep) + " "
+	if sep == ' ' {
+		joiner = " "
+	}
+	compare := cfg.compareFunc()
+	result := make([]string, len(lines))
+	section := ""
+	for i, line := range lines {
+		result[i] = line
+		switch {
+		case IsHeader(line):
+			section = HeaderName(line)
+			continue
+		case IsBlank(line), cfg.IsComment(line):
+			continue
+		}
+		before, after, ok := cfg.Cut(line)
+		if !ok || !listKeySelected(cfg.SortListValues, section, strings.TrimSpace(before)) {
+			continue
+		}
+		items := splitList(after, sep)
+		trailing := len(items) > 1 && strings.TrimSpace(items[len(items)-1]) == ""
+		if trailing {
+			items = items[:len(items)-1]
+		}
+		for j, item := range items {
+			items[j] = strings.TrimSpace(item)
+		}
+		items = slices.DeleteFunc(items, func(s string) bool { return s == "" })
+		slices.SortStableFunc(items, compare)
+		if cfg.UniqueListValues {
+			items = slices.Compact(items)
+		}
+		value := strings.Join(items, joiner)
+		if trailing && cfg.ListTrailingComma != "drop" && sep != ' ' {
+			value += string(sep)
+		}
+		result[i] = before + "= " + value
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package format
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"collapse whitespace", "  a   b \t c ", "a b c"},
+		{"configparser placeholder", " %(base_dir)s/logs", "%(base_dir)s/logs"},
+		{"placeholder interior kept", "%(multi   word)s  and   more", "%(multi   word)s and more"},
+		{"shell placeholder", "${SCHEME}://${HOST}", "${SCHEME}://${HOST}"},
+		{"shell placeholder interior kept", "x  ${A:-  b}   y", "x ${A:-  b} y"},
+		{"nested braces", "${a:-${b   c}}", "${a:-${b   c}}"},
+		{"percent braces", "%{VAR  NAME}", "%{VAR  NAME}"},
+		{"unterminated placeholder", "${open   ended", "${open ended"},
+		{"hash inside placeholder", "${A#  b}", "${A#  b}"},
+		{"quoted interior kept", `  "a   b"   c  'd  e'`, `"a   b" c 'd  e'`},
+		{"escaped quote", `"a \"  b"   c`, `"a \"  b" c`},
+		{"unterminated quote", `"a   b`, `"a b`},
+		{"empty", "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeValue(tt.value); got != tt.want {
+				t.Errorf("normalizeValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPlaceholders(t *testing.T) {
+	spans := splitPlaceholders("a %(b c)s d ${e} f")
+	var atomic []string
+	for _, s := range spans {
+		if s.atomic {
+			atomic = append(atomic, s.text)
+		}
+	}
+	if len(atomic) != 2 || atomic[0] != "%(b c)s" || atomic[1] != "${e}" {
+		t.Fatalf("splitPlaceholders() atomic spans = %q", atomic)
+	}
+}
+
+func TestNormalizeList(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		sep          string
+		dropTrailing bool
+		want         string
+	}{
+		{name: "commas", value: "a ,b,  c", sep: ",", want: "a, b, c"},
+		{name: "single item untouched", value: "alone", sep: ",", want: "alone"},
+		{name: "quoted comma", value: `"x,y" ,z`, sep: ",", want: `"x,y", z`},
+		{name: "bracketed comma", value: "f(a,b),[c,d] ,e", sep: ",", want: "f(a,b), [c,d], e"},
+		{name: "placeholder comma", value: "${A:-x,y},%(b,c)s", sep: ",", want: "${A:-x,y}, %(b,c)s"},
+		{name: "leading parenthesis", value: "(a,b),c", sep: ",", want: "(a,b), c"},
+		{name: "keep trailing", value: "a,b,", sep: ",", want: "a, b,"},
+		{name: "drop trailing", value: "a,b,", sep: ",", dropTrailing: true, want: "a, b"},
+		{name: "semicolons", value: "a;b ;c", sep: ";", want: "a; b; c"},
+		{name: "spaces", value: "a b", sep: "space", want: "a b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeList(tt.value, tt.sep, tt.dropTrailing)
+			if got != tt.want {
+				t.Fatalf("normalizeList(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			if again := normalizeList(got, tt.sep, tt.dropTrailing); again != got {
+				t.Fatalf("normalizeList() not idempotent: %q then %q", got, again)
+			}
+		})
+	}
+}
+
+func TestSortListValues(t *testing.T) {
+	lines := []string{
+		"plugins = zeta, alpha,mid",
+		"order = c, b, a",
+		"[extra]",
+		"features = b,a,b,",
+		"plugins = y,x",
+		"[other]",
+		"features = b,a",
+	}
+	cfg := Options{SortListValues: []string{"plugins", "extra.features"}, UniqueListValues: true}
+	want := []string{
+		"plugins = alpha, mid, zeta",
+		"order = c, b, a",
+		"[extra]",
+		"features = a, b,",
+		"plugins = x, y",
+		"[other]",
+		"features = b,a",
+	}
+	got := sortListValues(lines, cfg)
+	if !slices.Equal(got, want) {
+		t.Fatalf("sortListValues() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestInlineCommentGap(t *testing.T) {
+	read := func(name string) []string {
+		data, err := os.ReadFile("testdata/commentgap/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, _ := SplitLines(string(data))
+		return lines
+	}
+	cfg := Options{InlineCommentGap: 2}
+	in, want := read("ragged.ini"), read("ragged.gap2.ini")
+	got, err := Lines(in, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+		t.Errorf("comment gap is not idempotent:\n%s", strings.Join(again, "\n"))
+	}
+	// Only the gap before a comment is rewritten, so no line is lossy.
+	if lossy := LossyLines(in, cfg); len(lossy) != 0 {
+		t.Errorf("LossyLines() = %v, want none", lossy)
+	}
+	if kept, _ := Lines(in, Options{InlineCommentGap: 2, KeepLossy: true}); !slices.Equal(kept, want) {
+		t.Errorf("Lines() with KeepLossy =\n%s\nwant\n%s", strings.Join(kept, "\n"), strings.Join(want, "\n"))
+	}
+
+	tests := []struct {
+		name string
+		cfg  Options
+		in   []string
+		want []string
+	}{
+		{"single space", Options{InlineCommentGap: 3, SingleSpace: true}, []string{"port=80 ; x", "a=b"}, []string{"port = 80   ; x", "a = b"}},
+		{"one", Options{InlineCommentGap: 1}, []string{"a = b\t\t# c"}, []string{"a = b # c"}},
+		{"off", Options{}, []string{"a = b\t\t# c"}, []string{"a = b # c"}},
+		{"collapsed value still lossy", Options{InlineCommentGap: 2, KeepLossy: true}, []string{"a = b   c ; d"}, []string{"a = b   c ; d"}},
+		{"continued value", Options{Dialect: DialectProperties, InlineCommentGap: 2}, []string{"a = b ; c \\", "  d"}, []string{"a = b  ; c \\", "  d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(tt.in, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if _, err := Lines([]string{"a = b"}, Options{InlineCommentGap: -1}); err == nil {
+		t.Error("Lines() with InlineCommentGap -1: expected an error")
+	}
+}
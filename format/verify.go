@@ -0,0 +1,244 @@
+package format
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Entry is one piece of what a parser reads from a file: a section header, a
+// key and its value, or a directive such as the version line of a .reg file.
+type Entry struct {
+	Kind    LineKind // LineHeader, LineKeyValue or LineDirective
+	Section string   // the section of a key, or the name of a header
+	Key     string
+	Value   string // a key's value as formatting normalizes it, or a directive's text
+	Bare    bool   // the key has no delimiter
+	Line    int    // 1-based line number
+}
+
+func (e Entry) String() string {
+	switch e.Kind {
+	case LineHeader:
+		return "[" + e.Section + "]"
+	case LineDirective:
+		return e.Value
+	}
+	path := KeyValue{Section: e.Section, Key: e.Key}.Path()
+	switch {
+	case e.Bare:
+		return path
+	case e.Value == "":
+		return path + " ="
+	}
+	return path + " = " + e.Value
+}
+
+// Entries returns the headers, keys and directives of lines in file order.
+// Values are compared after normalization, so runs of whitespace outside
+// quotes and placeholders are collapsed; comments and layout are not part of
+// any entry.
+func Entries(lines []string, opts Options) []Entry {
+	kvs := ParseKeyValues(lines, opts)
+	var entries []Entry
+	for i, kind := range opts.classifyLines(lines) {
+		switch kind {
+		case LineHeader:
+			entries = append(entries, Entry{Kind: LineHeader, Section: HeaderName(lines[i]), Line: i + 1})
+		case LineDirective:
+			entries = append(entries, Entry{Kind: LineDirective, Value: normalizeValue(lines[i]), Line: i + 1})
+		case LineKeyValue:
+			kv := kvs[0]
+			kvs = kvs[1:]
+			entries = append(entries, Entry{
+				Kind: LineKeyValue, Section: kv.Section, Key: kv.Key,
+				Value: normalizeValue(kv.Value), Bare: !kv.HasValue, Line: kv.Line,
+			})
+		}
+	}
+	return entries
+}
+
+// same reports whether a and b hold the same data, wherever they are.
+func (e Entry) same(o Entry) bool {
+	return e.Kind == o.Kind && e.Section == o.Section && e.Key == o.Key && e.Value == o.Value && e.Bare == o.Bare
+}
+
+// expectedChange is an option that changes the data of a file on purpose,
+// with the normalization that undoes the difference it makes.
+type expectedChange struct {
+	option    string
+	normalize func([]Entry) []Entry
+}
+
+// expectedChanges returns the changes opts, or the options of its Sections,
+// make on purpose, in the order Verify discounts them.
+func expectedChanges(opts Options) []expectedChange {
+	anyOf := func(f func(Options) bool) bool {
+		return f(opts) || slices.ContainsFunc(opts.Sections, func(s SectionOptions) bool { return f(s.Options) })
+	}
+	keep := func(keep func(e Entry, entries []Entry, i int) bool) func([]Entry) []Entry {
+		return func(entries []Entry) []Entry {
+			var kept []Entry
+			for i, e := range entries {
+				if keep(e, entries, i) {
+					kept = append(kept, e)
+				}
+			}
+			return kept
+		}
+	}
+	var changes []expectedChange
+	if opts.Flatten || opts.Nest != 0 {
+		option := "Flatten"
+		if opts.Nest != 0 {
+			option = "Nest"
+		}
+		// Only the full path of each key is left to compare.
+		changes = append(changes, expectedChange{option, func(entries []Entry) []Entry {
+			var flat []Entry
+			for _, e := range entries {
+				if e.Kind == LineKeyValue {
+					e.Key, e.Section = KeyValue{Section: e.Section, Key: e.Key}.Path(), ""
+					flat = append(flat, e)
+				} else if e.Kind == LineDirective {
+					flat = append(flat, e)
+				}
+			}
+			return flat
+		}})
+	}
+	if name := opts.DefaultSection; name != "" {
+		changes = append(changes, expectedChange{"DefaultSection", func(entries []Entry) []Entry {
+			var moved []Entry
+			for _, e := range entries {
+				if e.Kind == LineKeyValue && e.Section == "" {
+					e.Section = name
+				}
+				if e.Kind != LineHeader || e.Section != name {
+					moved = append(moved, e)
+				}
+			}
+			return moved
+		}})
+	}
+	if anyOf(func(o Options) bool { return o.Unique }) && !RepeatsKeys(opts.dialect()) {
+		changes = append(changes, expectedChange{"Unique", keep(func(e Entry, entries []Entry, i int) bool {
+			return e.Kind != LineKeyValue || !slices.ContainsFunc(entries[:i], e.same)
+		})})
+	}
+	if anyOf(func(o Options) bool { return o.RemoveEmptyValues }) {
+		quoted := anyOf(func(o Options) bool { return o.RemoveEmptyQuoted })
+		changes = append(changes, expectedChange{"RemoveEmptyValues", keep(func(e Entry, _ []Entry, _ int) bool {
+			return e.Kind != LineKeyValue || !isEmptyValue(KeyValue{Value: e.Value, HasValue: !e.Bare}, Options{RemoveEmptyQuoted: quoted})
+		})})
+	}
+	if len(opts.Defaults) > 0 {
+		defaults := make(map[[2]string]string)
+		for _, kv := range opts.Defaults {
+			defaults[[2]string{kv.Section, kv.Key}] = defaultText(kv)
+		}
+		restates := func(e Entry) bool {
+			text, ok := defaults[[2]string{e.Section, e.Key}]
+			return e.Kind == LineKeyValue && ok && text == defaultText(KeyValue{Value: e.Value, HasValue: !e.Bare})
+		}
+		changes = append(changes, expectedChange{"Defaults", keep(func(e Entry, entries []Entry, i int) bool {
+			if e.Kind == LineHeader {
+				// A section left without keys goes too.
+				for _, o := range entries[i+1:] {
+					if o.Kind != LineKeyValue {
+						return false
+					}
+					if !restates(o) {
+						return true
+					}
+				}
+				return false
+			}
+			return !restates(e)
+		})})
+	}
+	if opts.PruneEmptySections {
+		changes = append(changes, expectedChange{"PruneEmptySections", keep(func(e Entry, entries []Entry, i int) bool {
+			return e.Kind != LineHeader || i+1 < len(entries) && entries[i+1].Kind == LineKeyValue
+		})})
+	}
+	dedupe := opts.DedupeKeys
+	for _, s := range opts.Sections {
+		dedupe = cmp.Or(dedupe, s.Options.DedupeKeys)
+	}
+	if dedupe != "" {
+		changes = append(changes, expectedChange{"DedupeKeys", keep(func(e Entry, entries []Entry, i int) bool {
+			if e.Kind != LineKeyValue {
+				return true
+			}
+			sameKey := func(o Entry) bool { return o.Kind == LineKeyValue && o.Section == e.Section && o.Key == e.Key }
+			if dedupe == "first" {
+				return !slices.ContainsFunc(entries[:i], sameKey)
+			}
+			return !slices.ContainsFunc(entries[i+1:], sameKey)
+		})})
+	}
+	if anyOf(func(o Options) bool {
+		return o.ExpandEnv || o.NormalizeNumbers || o.NormalizeLists || len(o.SortListValues) > 0 || len(o.Redact) > 0
+	}) {
+		changes = append(changes, expectedChange{"values", func(entries []Entry) []Entry {
+			cleared := slices.Clone(entries)
+			for i := range cleared {
+				if cleared[i].Kind == LineKeyValue {
+					cleared[i].Value = ""
+				}
+			}
+			return cleared
+		}})
+	}
+	if opts.SortSections || anyOf(func(o Options) bool { return len(o.SortKeys) > 0 }) {
+		option := "SortKeys"
+		if opts.SortSections {
+			option = "SortSections"
+		}
+		changes = append(changes, expectedChange{option, func(entries []Entry) []Entry {
+			sorted := slices.Clone(entries)
+			slices.SortStableFunc(sorted, func(a, b Entry) int {
+				return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Section, b.Section),
+					cmp.Compare(a.Key, b.Key), cmp.Compare(a.Value, b.Value))
+			})
+			return sorted
+		}})
+	}
+	return changes
+}
+
+// Verify compares the data of the formatted lines out with that of the
+// lines in they were formatted from, opts being the options used. Options
+// that change the data on purpose, such as SortKeys or RemoveEmptyValues, are
+// discounted: expected lists the ones that made a difference, by their
+// Options field name ("values" for those that rewrite values). A difference
+// no option explains is returned as a *VerifyError naming the first entry
+// that differs.
+func Verify(in, out []string, opts Options) (expected []string, err error) {
+	before, after := Entries(in, opts), Entries(out, opts)
+	for _, c := range expectedChanges(opts) {
+		if slices.EqualFunc(before, after, Entry.same) {
+			break
+		}
+		nb, na := c.normalize(before), c.normalize(after)
+		if !slices.EqualFunc(nb, before, Entry.same) || !slices.EqualFunc(na, after, Entry.same) {
+			expected = append(expected, c.option)
+		}
+		before, after = nb, na
+	}
+	for i := range max(len(before), len(after)) {
+		if i < len(before) && i < len(after) && before[i].same(after[i]) {
+			continue
+		}
+		e := &VerifyError{}
+		if i < len(before) {
+			e.InputLine, e.Input = before[i].Line, before[i].String()
+		}
+		if i < len(after) {
+			e.OutputLine, e.Output = after[i].Line, after[i].String()
+		}
+		return expected, e
+	}
+	return expected, nil
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	in := []string{"; top", "name=app", "[b]", "x = 1", "x = 1", "[a]", "k   =   v  w", "empty ="}
+	tests := []struct {
+		name         string
+		out          []string
+		opts         Options
+		wantExpected []string
+		wantErr      *VerifyError
+	}{
+		{
+			name: "layout only",
+			out:  []string{"name = app", "[b]", "x = 1", "x = 1", "[a]", "k = v w", "empty ="},
+		},
+		{
+			name:         "sorted and unique",
+			out:          []string{"name = app", "[a]", "empty =", "k = v w", "[b]", "x = 1"},
+			opts:         Options{SortSections: true, SortKeys: []string{"*"}, Unique: true},
+			wantExpected: []string{"Unique", "SortSections"},
+		},
+		{
+			name:    "changed value",
+			out:     []string{"name = app", "[b]", "x = 1", "x = 2", "[a]", "k = v w", "empty ="},
+			wantErr: &VerifyError{InputLine: 5, Input: "b.x = 1", OutputLine: 4, Output: "b.x = 2"},
+		},
+		{
+			name:    "lost key",
+			out:     []string{"name = app", "[b]", "x = 1", "x = 1", "[a]", "k = v w"},
+			wantErr: &VerifyError{InputLine: 8, Input: "a.empty ="},
+		},
+		{
+			name:         "removed empty value but lost a key too",
+			out:          []string{"name = app", "[b]", "x = 1", "[a]"},
+			opts:         Options{RemoveEmptyValues: true, Unique: true},
+			wantExpected: []string{"Unique", "RemoveEmptyValues"},
+			wantErr:      &VerifyError{InputLine: 7, Input: "a.k = v w"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := Verify(in, tt.out, tt.opts)
+			if !slices.Equal(expected, tt.wantExpected) {
+				t.Errorf("Verify() expected = %q, want %q", expected, tt.wantExpected)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+				return
+			}
+			var ve *VerifyError
+			if !errors.As(err, &ve) || *ve != *tt.wantErr {
+				t.Errorf("Verify() error = %#v, want %#v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyFormatted(t *testing.T) {
+	in := []string{"top=1", "[s]", "k=v", "k=v", "b = x,  y", "empty=", "[gone]", "[a]", "z=1"}
+	opts := Options{
+		SortSections: true, SortKeys: []string{"*"}, Unique: true, RemoveEmptyValues: true,
+		PruneEmptySections: true, NormalizeLists: true, DefaultSection: "main",
+	}
+	out, err := Lines(in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(in, out, opts); err != nil {
+		t.Errorf("Verify(Lines()) = %v\n%q", err, out)
+	}
+}
@@ -0,0 +1,179 @@
+package format
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Visitor holds the callbacks Walk calls for the lines of an INI file. Any of
+// them may be nil. An error returned by a callback stops the walk, and Walk
+// returns it.
+type Visitor struct {
+	// OnSection is called for a section header with its name, as HeaderName
+	// returns it.
+	OnSection func(name string, line int) error
+	// OnKey is called for a key line with its section, "" in the preamble,
+	// the trimmed key and the trimmed value, inline comment included and
+	// continuation lines joined as the dialect joins them. A bare key has
+	// an empty value.
+	OnKey func(section, key, value string, line int) error
+	// OnComment is called for a full-line comment with its text, without the
+	// comment prefix.
+	OnComment func(text string, line int) error
+	// OnDirective is called for a line the dialect keeps verbatim, such as
+	// the version line of a .reg file, with the trimmed line.
+	OnDirective func(text string, line int) error
+}
+
+// Walk reads the INI file from r one line at a time and calls the callbacks
+// of v in file order, classifying lines with the rules of opts as the
+// formatter does. Lines are numbered from 1. Unlike ParseKeyValues, which is
+// built on the same walker, Walk holds no more of the file than the lines of
+// one value and the comments after it, so it suits extracting keys from many
+// or large files.
+//
+// A key is reported once the line after its value is read, since
+// continuation lines may follow it; comments read in the meantime, which some
+// dialects skip inside a value, are reported after it, so that callbacks come
+// in the order of their lines.
+func Walk(r io.Reader, v Visitor, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	w := newWalker(opts, v)
+	src := bufio.NewReader(r)
+	for {
+		line, err := src.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("reading input: %w", err)
+		}
+		if line != "" {
+			if werr := w.line(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			return w.end()
+		}
+	}
+}
+
+// walker classifies lines one at a time and reports them to a Visitor. A key
+// line is held until the line after its value is read, since continuation
+// lines may follow it, together with the comments read in the meantime.
+type walker struct {
+	cfg     Options
+	v       Visitor
+	onKey   func(KeyValue) error // reports a key; set from v.OnKey by newWalker
+	ctx     LineContext
+	section string
+	n       int       // lines read so far
+	key     *KeyValue // the key line held for its continuation lines
+	held    []walkComment
+}
+
+// walkComment is a comment read while a key was held.
+type walkComment struct {
+	text string
+	line int
+}
+
+// newWalker returns a walker reporting to v.
+func newWalker(cfg Options, v Visitor) *walker {
+	w := &walker{cfg: cfg, v: v}
+	w.onKey = func(kv KeyValue) error {
+		if v.OnKey == nil {
+			return nil
+		}
+		return v.OnKey(kv.Section, kv.Key, kv.Value, kv.Line)
+	}
+	return w
+}
+
+// line classifies the next line and reports it, along with the key held
+// before it once it is complete.
+func (w *walker) line(line string) error {
+	w.n++
+	w.ctx.Index = w.n - 1
+	kind := w.cfg.dialect().Classify(line, w.ctx, w.cfg)
+	if kind == LineContinuation && w.cfg.skippedComment(line) {
+		kind = LineComment
+	}
+	if kind != LineComment || w.key == nil {
+		// Comments after a key are skipped, as configparser and systemd
+		// skip them inside a value: the line after them may continue it.
+		w.ctx.Prev, w.ctx.PrevKind = line, kind
+	}
+	switch kind {
+	case LineBlank:
+		// Blank lines may come between the lines of a value.
+		return nil
+	case LineContinuation:
+		if w.key != nil {
+			w.key.Value = w.cfg.dialect().JoinContinuation(w.key.Value, line)
+		}
+		return nil
+	case LineComment:
+		if w.key != nil {
+			w.held = append(w.held, walkComment{w.cfg.CommentText(line), w.n})
+			return nil
+		}
+		return w.comment(w.cfg.CommentText(line), w.n)
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	switch kind {
+	case LineHeader:
+		w.section = HeaderName(line)
+		if w.v.OnSection != nil {
+			return w.v.OnSection(w.section, w.n)
+		}
+	case LineDirective:
+		if w.v.OnDirective != nil {
+			return w.v.OnDirective(strings.TrimSpace(line), w.n)
+		}
+	case LineKeyValue:
+		kv := KeyValue{Section: w.section, Key: strings.TrimSpace(line), Line: w.n}
+		if before, after, ok := w.cfg.Cut(line); ok {
+			key, _ := w.cfg.splitOperator(before)
+			kv.Key, kv.Value, kv.HasValue = strings.TrimSpace(key), strings.TrimSpace(after), true
+		}
+		w.key = &kv
+	}
+	return nil
+}
+
+// end reports the key still held at the end of the input.
+func (w *walker) end() error {
+	return w.flush()
+}
+
+// flush reports the key held and the comments read after it.
+func (w *walker) flush() error {
+	if w.key == nil {
+		return nil
+	}
+	kv, held := *w.key, w.held
+	w.key, w.held = nil, nil
+	if err := w.onKey(kv); err != nil {
+		return err
+	}
+	for _, c := range held {
+		if err := w.comment(c.text, c.line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// comment reports a comment.
+func (w *walker) comment(text string, line int) error {
+	if w.v.OnComment == nil {
+		return nil
+	}
+	return w.v.OnComment(text, line)
+}
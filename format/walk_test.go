@@ -0,0 +1,185 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// walkEvents walks src and records every callback as a line of text.
+func walkEvents(src string, opts Options) ([]string, error) {
+	var events []string
+	err := Walk(strings.NewReader(src), Visitor{
+		OnSection: func(name string, line int) error {
+			events = append(events, fmt.Sprintf("%d section %s", line, name))
+			return nil
+		},
+		OnKey: func(section, key, value string, line int) error {
+			events = append(events, fmt.Sprintf("%d key %s.%s=%q", line, section, key, value))
+			return nil
+		},
+		OnComment: func(text string, line int) error {
+			events = append(events, fmt.Sprintf("%d comment %s", line, text))
+			return nil
+		},
+		OnDirective: func(text string, line int) error {
+			events = append(events, fmt.Sprintf("%d directive %s", line, text))
+			return nil
+		},
+	}, opts)
+	return events, err
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		in   string
+		want []string
+	}{
+		{
+			name: "ini",
+			in:   "top = 1\n; about a\n[a]\nk = v ; note\nbare\n\n[b]\r\n# crlf\r\nx=\r\n",
+			want: []string{
+				`1 key .top="1"`,
+				"2 comment about a",
+				"3 section a",
+				`4 key a.k="v ; note"`,
+				`5 key a.bare=""`,
+				"7 section b",
+				"8 comment crlf",
+				`9 key b.x=""`,
+			},
+		},
+		{
+			name: "no final newline",
+			in:   "[a]\nk = v",
+			want: []string{"1 section a", `2 key a.k="v"`},
+		},
+		{
+			name: "reg",
+			opts: Options{Dialect: DialectReg},
+			in:   "Windows Registry Editor Version 5.00\n\n[HKEY_CURRENT_USER\\X]\n\"Bin\"=hex:01,\\\n  02\n@=\"d\"\n",
+			want: []string{
+				"1 directive Windows Registry Editor Version 5.00",
+				`3 section HKEY_CURRENT_USER\X`,
+				"4 key HKEY_CURRENT_USER\\X.\"Bin\"=\"hex:01,02\"",
+				"6 key HKEY_CURRENT_USER\\X.@=\"\\\"d\\\"\"",
+			},
+		},
+		{
+			name: "pycfg continuation with a comment",
+			opts: Options{Dialect: DialectPyCfg},
+			in:   "[options]\npackages =\n    a\n    # pinned\n    b\n; next\nzip_safe: false\n",
+			want: []string{
+				"1 section options",
+				`2 key options.packages="\na\nb"`,
+				"4 comment pinned",
+				"6 comment next",
+				`7 key options.zip_safe="false"`,
+			},
+		},
+		{
+			name: "systemd skips comments inside values",
+			opts: Options{Dialect: DialectSystemd},
+			in:   "[Service]\nExecStart=/bin/app \\\n# the port\n  --port 80\nType=simple\n",
+			want: []string{
+				"1 section Service",
+				`2 key Service.ExecStart="/bin/app  --port 80"`,
+				"3 comment the port",
+				`5 key Service.Type="simple"`,
+			},
+		},
+		{
+			name: "gitconfig subsection",
+			opts: Options{Dialect: DialectGitConfig},
+			in:   "[remote \"origin\"]\n\turl = git@example.com:x.git\n",
+			want: []string{`1 section remote "origin"`, `2 key remote "origin".url="git@example.com:x.git"`},
+		},
+		{
+			name: "operators",
+			opts: Options{Operators: []string{"+="}},
+			in:   "DEPENDS += zlib\n",
+			want: []string{`1 key .DEPENDS="zlib"`},
+		},
+		{
+			name: "empty",
+			in:   "\n",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := walkEvents(tt.in, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Walk() events =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestWalkParseKeyValues checks that Walk reports the keys ParseKeyValues
+// returns.
+func TestWalkParseKeyValues(t *testing.T) {
+	sample, err := os.ReadFile("../test.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		src  string
+		opts Options
+	}{
+		{string(sample), Options{}},
+		{string(sample), Options{SplitOn: "last", CommentPrefixes: []string{"//", "REM"}}},
+		{strings.Join(regInput, "\n") + "\n", Options{Dialect: DialectReg}},
+	} {
+		var got []KeyValue
+		err := Walk(strings.NewReader(c.src), Visitor{OnKey: func(section, key, value string, line int) error {
+			got = append(got, KeyValue{Section: section, Key: key, Value: value, Line: line})
+			return nil
+		}}, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, _ := SplitLines(c.src)
+		want := ParseKeyValues(lines, c.opts)
+		for i := range want {
+			want[i].HasValue = false
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Walk() keys = %+v\nwant %+v", got, want)
+		}
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	stop := errors.New("stop")
+	var keys []string
+	err := Walk(strings.NewReader("a = 1\nb = 2\nc = 3\n"), Visitor{OnKey: func(_, key, _ string, _ int) error {
+		keys = append(keys, key)
+		if key == "b" {
+			return stop
+		}
+		return nil
+	}}, Options{})
+	if !errors.Is(err, stop) || !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("Walk() = %v after %q, want the callback's error after a and b", err, keys)
+	}
+
+	read := errors.New("read failed")
+	if err := Walk(iotest.ErrReader(read), Visitor{}, Options{}); !errors.Is(err, read) {
+		t.Errorf("Walk(failing reader) = %v, want %v", err, read)
+	}
+
+	var oe *OptionError
+	if err := Walk(strings.NewReader("a = 1\n"), Visitor{}, Options{SplitOn: "middle"}); !errors.As(err, &oe) {
+		t.Errorf("Walk() with invalid options = %v, want an *OptionError", err)
+	}
+}
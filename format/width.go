@@ -0,0 +1,78 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// DisplayWidth returns the number of terminal columns s occupies.
+// East Asian wide and full-width characters count as two columns, combining marks as none.
+func DisplayWidth(s string) int {
+	if isASCII(s) {
+		return len(s)
+	}
+	w := 0
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			w++
+		case isCombining(r):
+		default:
+			switch width.LookupRune(r).Kind() {
+			case width.EastAsianWide, width.EastAsianFullwidth:
+				w += 2
+			default:
+				w++
+			}
+		}
+	}
+	return w
+}
+
+// DefaultTabWidth is the tab width Options.TabWidth defaults to.
+const DefaultTabWidth = 8
+
+// ExpandedWidth returns the number of terminal columns s occupies when it
+// starts at column 0 and its tabs advance to the next multiple of tabWidth.
+func ExpandedWidth(s string, tabWidth int) int {
+	if !strings.Contains(s, "\t") {
+		return DisplayWidth(s)
+	}
+	w := 0
+	for i, part := range strings.Split(s, "\t") {
+		if i > 0 {
+			w += tabWidth - w%tabWidth
+		}
+		w += DisplayWidth(part)
+	}
+	return w
+}
+
+// width returns the columns s occupies at the start of a line, with tabs
+// expanded to c.TabWidth.
+func (c Options) width(s string) int {
+	tabWidth := c.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+	return ExpandedWidth(s, tabWidth)
+}
+
+// isASCII reports whether s contains only ASCII bytes.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isCombining reports whether r is a zero-width combining mark.
+func isCombining(r rune) bool {
+	return (r >= 0x0300 && r <= 0x036F) || (r >= 0x1AB0 && r <= 0x1AFF) ||
+		(r >= 0x1DC0 && r <= 0x1DFF) || (r >= 0x20D0 && r <= 0x20FF) ||
+		(r >= 0xFE20 && r <= 0xFE2F) || r == 0x200B || r == 0x200D
+}
@@ -0,0 +1,41 @@
+package format
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"key", 3},
+		{"größe", 5},
+		{"名前", 4},
+		{"é", 1},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := DisplayWidth(tt.in); got != tt.want {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandedWidth(t *testing.T) {
+	tests := []struct {
+		in       string
+		tabWidth int
+		want     int
+	}{
+		{"key", 8, 3},
+		{"\tkey", 8, 11},
+		{"\tkey", 4, 7},
+		{"ab\tc", 4, 5},
+		{"abcd\tc", 4, 9},
+		{"名\tx", 4, 5},
+	}
+	for _, tt := range tests {
+		if got := ExpandedWidth(tt.in, tt.tabWidth); got != tt.want {
+			t.Errorf("ExpandedWidth(%q, %d) = %d, want %d", tt.in, tt.tabWidth, got, tt.want)
+		}
+	}
+}
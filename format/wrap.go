@@ -0,0 +1,166 @@
+package format
+
+import "strings"
+
+// wrapValues breaks the values of key/value lines wider than cfg.WrapValues
+// columns onto continuation lines, for dialects that have them. Lines hold
+// their continuation lines joined by newlines, as after joinContinuations, so
+// a value wrapped before is rewrapped from its logical value and a second
+// pass changes nothing.
+func wrapValues(lines []string, cfg Options) []string {
+	result := make([]string, len(lines))
+	for i, kind := range cfg.classifyLines(lines) {
+		result[i] = lines[i]
+		if kind == LineKeyValue {
+			result[i], _ = cfg.wrapLine(lines[i])
+		}
+	}
+	return result
+}
+
+// LongValues returns the 1-based numbers of the key/value lines whose values
+// reach past column cfg.WrapValues and that wrapping would leave long: the
+// dialect has no continuation lines or the value has no safe break point.
+func LongValues(lines []string, cfg Options) []int {
+	if cfg.WrapValues <= 0 {
+		return nil
+	}
+	joined, _ := cfg.joinContinuations(lines)
+	var numbers []int
+	n := 1
+	for i, kind := range cfg.classifyLines(joined) {
+		if kind == LineKeyValue {
+			if _, fits := cfg.wrapLine(joined[i]); !fits {
+				numbers = append(numbers, n)
+			}
+		}
+		n += strings.Count(joined[i], "\n") + 1
+	}
+	return numbers
+}
+
+// wrapLine rewraps the value of a key/value line, with its continuation lines
+// joined by newlines, so that no line passes column cfg.WrapValues. The value
+// is joined into one line when it fits there. Continuation lines are indented
+// to the column where the value starts, unless the dialect keeps indentation
+// in the value, and end in the dialect's continuation marker, and breaks fall
+// after top-level commas or, in values without any, after spaces. A value
+// whose line breaks the dialect keeps, as configparser does, is left as
+// written. fits is false when the value stays too wide; the line is then
+// returned unchanged.
+func (c Options) wrapLine(line string) (wrapped string, fits bool) {
+	d := c.dialect()
+	first, _, _ := strings.Cut(line, "\n")
+	before, after, ok := c.Cut(first)
+	if !ok {
+		return line, true
+	}
+	delimiter := first[len(before) : len(first)-len(after)]
+	after = line[len(first)-len(after):]
+	value := strings.TrimLeft(after, " \t")
+	gap := after[:len(after)-len(value)]
+	segments := strings.Split(value, "\n")
+	value = segments[0]
+	for _, s := range segments[1:] {
+		value = d.JoinContinuation(strings.TrimRight(value, " \t"), s)
+	}
+
+	prefix := before + delimiter + gap
+	column := c.width(prefix)
+	if strings.Contains(value, "\n") {
+		fits = true
+		for i, s := range strings.Split(line, "\n") {
+			if i == 0 {
+				s = first
+			}
+			fits = fits && c.width(s) <= c.WrapValues
+		}
+		return line, fits
+	}
+	if column+DisplayWidth(value) <= c.WrapValues {
+		return prefix + value, true
+	}
+	marker, ok := d.Continuation()
+	tokens := wrapTokens(value)
+	if !ok || len(tokens) < 2 {
+		return line, false
+	}
+	// A marker that reads as a space takes the place of the whitespace
+	// before it.
+	spaced := marker != "" && d.JoinContinuation("v"+marker, "x") == "v x"
+
+	room := c.WrapValues - column - DisplayWidth(marker)
+	var parts []string
+	part := ""
+	fits = true
+	for _, tok := range tokens {
+		if part != "" && DisplayWidth(part+tok) > room {
+			parts = append(parts, part)
+			part = ""
+		}
+		part += tok
+		fits = fits && DisplayWidth(part) <= room
+	}
+	parts = append(parts, part)
+
+	indent := strings.Repeat(" ", column)
+	if indentIsValue(d) {
+		indent = ""
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	rejoined := ""
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteString("\n" + indent)
+		}
+		if i < len(parts)-1 {
+			// Otherwise the whitespace before a marker is part of the value.
+			if marker == "" || spaced {
+				p = strings.TrimRight(p, " \t")
+			}
+			p += marker
+		}
+		b.WriteString(p)
+		if i == 0 {
+			rejoined = p
+		} else {
+			rejoined = d.JoinContinuation(rejoined, indent+p)
+		}
+	}
+	// Breaks that would change the value as the dialect reads it, such as
+	// one with no whitespace where the marker reads as a space, are not
+	// made. Line breaks are the value of dialects without a marker.
+	if marker != "" && rejoined != value {
+		return line, false
+	}
+	return b.String(), fits
+}
+
+// wrapTokens splits value into the pieces wrapping may put on separate lines:
+// after each top-level comma or, when there is none, after each run of
+// top-level spaces. Whitespace stays with the piece before it.
+func wrapTokens(value string) []string {
+	sep := byte(',')
+	items := splitList(value, sep)
+	if len(items) == 1 {
+		sep = ' '
+		items = splitList(value, sep)
+	}
+	var tokens []string
+	for i, item := range items {
+		if i < len(items)-1 {
+			item += string(sep)
+		}
+		trimmed := strings.TrimLeft(item, " \t")
+		if n := len(tokens); n > 0 {
+			tokens[n-1] += item[:len(item)-len(trimmed)]
+		}
+		if trimmed != "" {
+			tokens = append(tokens, trimmed)
+		} else if len(tokens) == 0 {
+			tokens = append(tokens, item)
+		}
+	}
+	return tokens
+}
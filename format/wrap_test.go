@@ -0,0 +1,135 @@
+package format
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWrapTokens(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a, b,c", []string{"a, ", "b,", "c"}},
+		{"one two  three", []string{"one ", "two  ", "three"}},
+		{`"x, y", z`, []string{`"x, y", `, "z"}},
+		{"%(a,b)s", []string{"%(a,b)s"}},
+		{"hex:01,02", []string{"hex:01,", "02"}},
+	}
+	for _, tt := range tests {
+		if got := wrapTokens(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("wrapTokens(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWrapValuesReg(t *testing.T) {
+	cfg := Options{Dialect: DialectReg, WrapValues: 30}
+	lines := []string{
+		"Windows Registry Editor Version 5.00",
+		"",
+		`[HKEY_CURRENT_USER\Software\X]`,
+		`"Bin"=hex:01,02,03,04,05,06,07,08,09,0a,0b,0c`,
+		`"Str"="a long string value that cannot be wrapped"`,
+		`"Short"=hex:01,\`,
+		`  02`,
+	}
+	want := []string{
+		"Windows Registry Editor Version 5.00",
+		"",
+		`[HKEY_CURRENT_USER\Software\X]`,
+		`"Bin"   = hex:01,02,03,04,05,\`,
+		`          06,07,08,09,0a,0b,\`,
+		`          0c`,
+		`"Str"   = "a long string value that cannot be wrapped"`,
+		`"Short" = hex:01,02`,
+	}
+	got, err := Lines(lines, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+		t.Errorf("wrapping is not idempotent:\n%s", strings.Join(again, "\n"))
+	}
+	if kv, _ := FindKey(ParseKeyValues(got, cfg), `HKEY_CURRENT_USER\Software\X."Bin"`); kv.Value != "hex:01,02,03,04,05,06,07,08,09,0a,0b,0c" {
+		t.Errorf("wrapped value reads back as %q", kv.Value)
+	}
+	if got, want := LongValues(lines, cfg), []int{5}; !slices.Equal(got, want) {
+		t.Errorf("LongValues() = %v, want %v", got, want)
+	}
+}
+
+func TestWrapValuesINI(t *testing.T) {
+	lines := []string{"[s]", "hosts = " + strings.Repeat("host, ", 20) + "end", "short = 1"}
+	cfg := Options{WrapValues: 40}
+	got, err := Lines(lines, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[1] != lines[1] {
+		t.Errorf("plain INI value was wrapped: %q", got[1])
+	}
+	if got, want := LongValues(lines, cfg), []int{2}; !slices.Equal(got, want) {
+		t.Errorf("LongValues() = %v, want %v", got, want)
+	}
+}
+
+func TestWrapValuesDialects(t *testing.T) {
+	value := "aaaa, bbbb, cccc, dddd, eeee, ffff, gggg"
+	tests := []struct {
+		dialect Dialect
+		want    []string
+		read    string // the wrapped value as the dialect reads it
+	}{
+		{
+			dialect: DialectPyCfg,
+			want:    []string{"[s]", "value = aaaa, bbbb, cccc,", "        dddd, eeee, ffff, gggg"},
+			read:    "aaaa, bbbb, cccc,\ndddd, eeee, ffff, gggg",
+		},
+		{
+			dialect: DialectSystemd,
+			want:    []string{"[s]", `value = aaaa, bbbb, cccc,\`, `        dddd, eeee, ffff,\`, "        gggg"},
+			read:    value,
+		},
+		{
+			dialect: DialectGitConfig,
+			want:    []string{"[s]", `value = aaaa, bbbb, cccc, \`, `dddd, eeee, ffff, \`, "gggg"},
+			read:    value,
+		},
+		{
+			dialect: DialectProperties,
+			want:    []string{"[s]", `value = aaaa, bbbb, cccc, \`, `        dddd, eeee, ffff, \`, "        gggg"},
+			read:    value,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			cfg := Options{Dialect: tt.dialect, WrapValues: 30}
+			got, err := Lines([]string{"[s]", "value = " + value}, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if again, _ := Lines(got, cfg); !slices.Equal(again, got) {
+				t.Errorf("wrapping is not idempotent:\n%s", strings.Join(again, "\n"))
+			}
+			if kv, _ := FindKey(ParseKeyValues(got, cfg), "s.value"); kv.Value != tt.read {
+				t.Errorf("wrapped value reads back as %q, want %q", kv.Value, tt.read)
+			}
+		})
+	}
+
+	// systemd reads its marker as a space, so a break without whitespace
+	// would add one to the value.
+	cfg := Options{Dialect: DialectSystemd, WrapValues: 20}
+	line := "v = a,b,c,d,e,f,g,h,i,j,k,l"
+	if got, _ := Lines([]string{line}, cfg); !slices.Equal(got, []string{line}) {
+		t.Errorf("Lines() = %q, want the value unwrapped", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// findingsFormat resolves the --format of a command reporting findings: given
+// explicitly it is used as is, and otherwise it is "github" inside a GitHub
+// Actions workflow and "text" elsewhere.
+func findingsFormat(flag string, changed bool) (string, error) {
+	if !changed {
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			return "github", nil
+		}
+		return "text", nil
+	}
+	switch flag {
+	case "text", "github":
+		return flag, nil
+	}
+	return "", fmt.Errorf("invalid --format %q (want text or github)", flag)
+}
+
+// githubAnnotation renders a GitHub Actions workflow command, such as
+// ::error file=a.ini,line=3,title=inifmt::message, that shows a finding as an
+// annotation on the line.
+func githubAnnotation(level, file string, line int, message string) string {
+	return fmt.Sprintf("::%s file=%s,line=%d,title=inifmt::%s",
+		level, escapeGitHubProperty(file), line, escapeGitHubData(message))
+}
+
+// escapeGitHubData escapes the message of a workflow command.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a property value of a workflow command, which
+// additionally cannot contain ':' or ','.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestGitHubAnnotation(t *testing.T) {
+	tests := []struct {
+		level, file string
+		line        int
+		message     string
+		want        string
+	}{
+		{"error", "conf/app.ini", 3, "bad value", "::error file=conf/app.ini,line=3,title=inifmt::bad value"},
+		{"warning", "a,b:c.ini", 1, "100% sure\r\nreally: yes, 50%", "::warning file=a%2Cb%3Ac.ini,line=1,title=inifmt::100%25 sure%0D%0Areally: yes, 50%25"},
+	}
+	for _, tt := range tests {
+		if got := githubAnnotation(tt.level, tt.file, tt.line, tt.message); got != tt.want {
+			t.Errorf("githubAnnotation(%q, %q) = %q, want %q", tt.file, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestFindingsFormat(t *testing.T) {
+	tests := []struct {
+		flag    string
+		changed bool
+		actions string
+		want    string
+	}{
+		{"text", false, "", "text"},
+		{"text", false, "true", "github"},
+		{"text", true, "true", "text"},
+		{"github", true, "", "github"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GITHUB_ACTIONS", tt.actions)
+		if got, err := findingsFormat(tt.flag, tt.changed); err != nil || got != tt.want {
+			t.Errorf("findingsFormat(%q, %v) with GITHUB_ACTIONS=%q = %q, %v; want %q", tt.flag, tt.changed, tt.actions, got, err, tt.want)
+		}
+	}
+	if _, err := findingsFormat("json", true); err == nil {
+		t.Error("findingsFormat(json) expected error")
+	}
+}
@@ -2,9 +2,11 @@ module github.com/thecrazygm/inifmt
 
 go 1.26.4
 
-require github.com/spf13/cobra v1.10.2
-
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.10 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/spf13/cobra v1.10.2
+	github.com/spf13/pflag v1.0.10
+	golang.org/x/text v0.40.0
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
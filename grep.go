@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// grepOptions selects what the grep subcommand searches and prints.
+type grepOptions struct {
+	values     bool
+	comments   bool
+	lineNumber bool
+	regex      bool
+	ignoreCase bool
+}
+
+// newGrepCmd builds the grep subcommand, which searches keys and values with section context.
+func newGrepCmd(cfg *config) *cobra.Command {
+	var opts grepOptions
+	cmd := &cobra.Command{
+		Use:   "grep pattern file...",
+		Short: "Search keys (and values) and print matches with their section",
+		Long: `grep prints the key/value lines whose key contains pattern, prefixed with their
+section, e.g. "[server] read_timeout = 30". --values searches values too and -E
+treats pattern as a regular expression.
+
+Unlike plain grep it understands the file structure: commented-out settings
+are only searched with --comments. With several files, matches are prefixed
+with the file name. Exits 0 when something matched, 1 when nothing did and 2
+on errors.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MinimumNArgs(2)(cmd, args); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp // the pattern
+			}
+			return completeFiles(cmd, args, toComplete)
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			match, err := grepMatcher(args[0], opts)
+			if err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			files := args[1:]
+			found, failed := false, false
+			for _, file := range files {
+				in, err := readInput(file, cfg.source)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "inifmt grep: %s: %v\n", file, err)
+					failed = true
+					continue
+				}
+				prefix := ""
+				if len(files) > 1 {
+					prefix = file + ":"
+				}
+				n, err := grepLines(cmd.OutOrStdout(), in.lines, match, prefix, opts, cfg.format)
+				if err != nil {
+					return &exitError{code: 2, err: err}
+				}
+				found = found || n > 0
+			}
+			switch {
+			case failed:
+				return &exitError{code: 2}
+			case !found:
+				return &exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.values, "values", false, "Search values as well as keys")
+	cmd.Flags().BoolVar(&opts.comments, "comments", false, "Also search commented-out settings")
+	cmd.Flags().BoolVarP(&opts.lineNumber, "line-number", "n", false, "Prefix matches with their line number")
+	cmd.Flags().BoolVarP(&opts.regex, "extended-regexp", "E", false, "Treat pattern as a regular expression")
+	cmd.Flags().BoolVarP(&opts.ignoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	return cmd
+}
+
+// grepMatcher compiles pattern into a match function.
+func grepMatcher(pattern string, opts grepOptions) (func(string) bool, error) {
+	if !opts.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re.MatchString, nil
+}
+
+// grepLines writes the matching key lines of one file and returns how many matched.
+func grepLines(w io.Writer, lines []string, match func(string) bool, prefix string, opts grepOptions, cfg format.Options) (int, error) {
+	count := 0
+	section := ""
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		switch {
+		case format.IsBlank(line):
+			continue
+		case format.IsHeader(line):
+			section = format.HeaderName(line)
+			continue
+		case cfg.IsComment(line):
+			// Only commented-out assignments count, and only on request.
+			if !opts.comments {
+				continue
+			}
+			text = cfg.CommentText(line)
+			if _, _, ok := cfg.Cut(text); !ok {
+				continue
+			}
+		}
+		key, value := text, ""
+		if before, after, ok := cfg.Cut(text); ok {
+			key, value = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		if !match(key) && !(opts.values && match(value)) {
+			continue
+		}
+		out := prefix
+		if opts.lineNumber {
+			out += fmt.Sprintf("%d:", i+1)
+		}
+		if section != "" {
+			out += "[" + section + "] "
+		}
+		if _, err := fmt.Fprintln(w, out+strings.TrimSpace(line)); err != nil {
+			return count, fmt.Errorf("writing output: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestGrepLines(t *testing.T) {
+	lines := []string{
+		"debug = false",
+		"[server]",
+		"read_timeout  = 30",
+		"; write_timeout = 60",
+		"host          = example.com",
+		"[db]",
+		"url = timeout://x",
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		opts    grepOptions
+		want    string
+	}{
+		{
+			name:    "keys only",
+			pattern: "timeout",
+			want:    "[server] read_timeout  = 30\n",
+		},
+		{
+			name:    "values too",
+			pattern: "timeout",
+			opts:    grepOptions{values: true},
+			want:    "[server] read_timeout  = 30\n[db] url = timeout://x\n",
+		},
+		{
+			name:    "comments and line numbers",
+			pattern: "timeout",
+			opts:    grepOptions{comments: true, lineNumber: true},
+			want:    "3:[server] read_timeout  = 30\n4:[server] ; write_timeout = 60\n",
+		},
+		{
+			name:    "regex outside sections",
+			pattern: "^de",
+			opts:    grepOptions{regex: true},
+			want:    "debug = false\n",
+		},
+		{
+			name:    "fixed string is literal",
+			pattern: "^de",
+		},
+		{
+			name:    "ignore case",
+			pattern: "HOST",
+			opts:    grepOptions{ignoreCase: true},
+			want:    "[server] host          = example.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := grepMatcher(tt.pattern, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			n, err := grepLines(&b, lines, match, "", tt.opts, format.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("grepLines() = %q, want %q", got, tt.want)
+			}
+			if want := strings.Count(tt.want, "\n"); n != want {
+				t.Errorf("grepLines() matched %d lines, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestGrepExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.ini")
+	b := filepath.Join(dir, "b.ini")
+	if err := os.WriteFile(a, []byte("[server]\nport = 80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("[db]\nport = 5432\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args    []string
+		want    int
+		wantOut string
+	}{
+		{args: []string{"grep", "port", a}, want: 0, wantOut: "[server] port = 80\n"},
+		{args: []string{"grep", "port", a, b}, want: 0, wantOut: a + ":[server] port = 80\n" + b + ":[db] port = 5432\n"},
+		{args: []string{"grep", "host", a}, want: 1},
+		{args: []string{"grep", "port", filepath.Join(dir, "missing.ini")}, want: 2},
+		{args: []string{"grep", "-E", "(", a}, want: 2},
+		{args: []string{"grep", "port"}, want: 2},
+	}
+	for _, tt := range tests {
+		cmd := newRootCmd()
+		cmd.SetArgs(tt.args)
+		var out strings.Builder
+		cmd.SetOut(&out)
+		cmd.SetErr(io.Discard)
+		err := cmd.Execute()
+		got := 0
+		if err != nil {
+			var ee *exitError
+			if !errors.As(err, &ee) {
+				t.Fatalf("Execute(%q) returned %v, want *exitError", tt.args, err)
+			}
+			got = ee.code
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) exit code = %d, want %d", tt.args, got, tt.want)
+		}
+		if out.String() != tt.wantOut {
+			t.Errorf("Execute(%q) output = %q, want %q", tt.args, out.String(), tt.wantOut)
+		}
+	}
+}
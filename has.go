@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newHasCmd builds the has subcommand, a quiet existence check for scripts.
+func newHasCmd(cfg *config) *cobra.Command {
+	var printValue bool
+	cmd := &cobra.Command{
+		Use:   "has file section[.key]",
+		Short: "Test whether a section or key exists",
+		Long: `has exits 0 if the key exists (even with an empty value), 1 if it does not, and
+2 on errors. With only a section name it tests for the section.
+
+Comments are understood, so a commented-out "; port = 8080" does not count.
+Nothing is printed unless --print is given, which prints the key's value.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+		ValidArgsFunction: completeFileThen(cfg, completePaths),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in, err := readInput(args[0], cfg.source)
+			if err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			value, found := has(in.lines, args[1], cfg.format)
+			if !found {
+				return &exitError{code: 1}
+			}
+			if printValue {
+				fmt.Fprintln(cmd.OutOrStdout(), value)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&printValue, "print", false, "Print the key's value when it exists")
+	return cmd
+}
+
+// has reports whether path names an existing key, or a section when path names
+// one, returning the key's value.
+func has(lines []string, path string, cfg format.Options) (string, bool) {
+	if kv, ok := format.FindKey(format.ParseKeyValues(lines, cfg), path); ok {
+		return kv.Value, true
+	}
+	return "", slices.Contains(format.SectionNames(lines), path)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestHas(t *testing.T) {
+	lines := []string{
+		"top = 1",
+		"[server]",
+		"; port = 8080",
+		"host =",
+		"[mysvc.cache]",
+		"ttl = 60",
+	}
+	tests := []struct {
+		path      string
+		wantFound bool
+		wantValue string
+	}{
+		{path: "top", wantFound: true, wantValue: "1"},
+		{path: "server", wantFound: true},
+		{path: "server.host", wantFound: true},
+		{path: "server.port", wantFound: false},
+		{path: "mysvc.cache", wantFound: true},
+		{path: "mysvc.cache.ttl", wantFound: true, wantValue: "60"},
+		{path: "missing", wantFound: false},
+	}
+	for _, tt := range tests {
+		value, found := has(lines, tt.path, format.Options{})
+		if found != tt.wantFound || value != tt.wantValue {
+			t.Errorf("has(%q) = %q, %v; want %q, %v", tt.path, value, found, tt.wantValue, tt.wantFound)
+		}
+	}
+}
+
+func TestHasExitCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.ini")
+	if err := os.WriteFile(path, []byte("[server]\nport = 80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"has", path, "server.port"}, 0},
+		{[]string{"has", path, "server.host"}, 1},
+		{[]string{"has", filepath.Join(t.TempDir(), "missing.ini"), "server"}, 2},
+		{[]string{"has", path}, 2},
+	}
+	for _, tt := range tests {
+		cmd := newRootCmd()
+		cmd.SetArgs(tt.args)
+		cmd.SetErr(io.Discard)
+		err := cmd.Execute()
+		got := 0
+		if err != nil {
+			var ee *exitError
+			if !errors.As(err, &ee) {
+				t.Fatalf("Execute(%q) returned %v, want *exitError", tt.args, err)
+			}
+			got = ee.code
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) exit code = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
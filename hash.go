@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// canonicalVersion numbers the canonical form --hash digests. Digests are
+// comparable across releases only while it stays the same: a change to the
+// canonical preset, or to how formatting normalizes values, bumps it.
+const canonicalVersion = 1
+
+// hashes reports whether the run prints digests instead of the output, with
+// --hash or --hash-raw.
+func (cfg config) hashes() bool {
+	return cfg.hash || cfg.hashRaw
+}
+
+// writeHash prints the SHA-256 of data and the name of the file it is the
+// output for, as sha256sum does.
+func writeHash(w io.Writer, data []byte, name string) error {
+	if _, err := fmt.Fprintf(w, "%x  %s\n", sha256.Sum256(data), name); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stdoutOf runs inifmt with args and returns what it printed on stdout.
+func stdoutOf(t *testing.T, args ...string) string {
+	t.Helper()
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = saved }()
+
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"--no-config", "--quiet"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return mustRead(t, stdout.Name())
+}
+
+func TestHash(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.ini": "; settings\n[server]\nport = 80\nhost = localhost\n\n[log]\nlevel = info\n",
+		"b.ini": "[log]\r\nlevel=info\r\n[server]\r\nhost    =   localhost\r\n# the port\r\nport=80\r\n",
+		"c.ini": "[server]\nport = 8080\nhost = localhost\n",
+	})
+	hash := func(flag, name string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		got := stdoutOf(t, flag, path)
+		sum, file, ok := strings.Cut(strings.TrimSuffix(got, "\n"), "  ")
+		if !ok || file != path || len(sum) != 64 {
+			t.Fatalf("%s %s printed %q, want a sha256sum line", flag, name, got)
+		}
+		return sum
+	}
+
+	a, b, c := hash("--hash", "a.ini"), hash("--hash", "b.ini"), hash("--hash", "c.ini")
+	if a != b {
+		t.Errorf("--hash differs for equivalent files: %s and %s", a, b)
+	}
+	if a == c {
+		t.Errorf("--hash is the same for files with different values: %s", a)
+	}
+	// Changing the canonical form changes every digest; bump canonicalVersion
+	// along with this one.
+	if want := "922abc05b6cddfde054e9ed3d5a3a25974269c8664aa2f30fb2bcc4d58acd36c"; a != want {
+		t.Errorf("--hash = %s, want %s for canonical form version %d", a, want, canonicalVersion)
+	}
+
+	if hash("--hash-raw", "a.ini") == hash("--hash-raw", "b.ini") {
+		t.Error("--hash-raw is the same for files that format differently")
+	}
+	formatted := stdoutOf(t, "--color=never", filepath.Join(dir, "a.ini"))
+	if got, want := hash("--hash-raw", "a.ini"), fmt.Sprintf("%x", sha256.Sum256([]byte(formatted))); got != want {
+		t.Errorf("--hash-raw = %s, want the digest of the formatted output %s", got, want)
+	}
+}
+
+func TestHashSince(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"a.ini": "a=1\n", "b.ini": "b=1\n"})
+	writeFiles(t, dir, map[string]string{"a.ini": "a=2\n", "b.ini": "b = 2\n"})
+	got := stdoutOf(t, "--since=HEAD", "--hash")
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "  a.ini") || !strings.HasSuffix(lines[1], "  b.ini") {
+		t.Errorf("--hash over two files printed %q, want one line per file", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newKeysCmd builds the keys subcommand, which lists every key as a section.key path.
+func newKeysCmd(cfg *config) *cobra.Command {
+	var withValues bool
+	var outFormat string
+	cmd := &cobra.Command{
+		Use:   "keys [file]",
+		Short: "List all keys as section.key paths in file order",
+		Long: `keys prints one line per key in file order, as section.key (keys before the
+first section header are printed bare). Duplicate keys appear once per occurrence.
+
+Use --values to append "= value", or --format=json for structured output.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeOneFile,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			in, err := readInput(filename, cfg.source)
+			if err != nil {
+				return err
+			}
+			return writeKeys(cmd.OutOrStdout(), format.ParseKeyValues(in.lines, cfg.format), withValues, outFormat)
+		},
+	}
+	cmd.Flags().BoolVar(&withValues, "values", false, "Append '= value' to each key")
+	cmd.Flags().StringVar(&outFormat, "format", "text", "Output format: 'text' or 'json'")
+	return cmd
+}
+
+// keyRecord is the JSON representation of a key in keys output.
+type keyRecord struct {
+	Path    string  `json:"path"`
+	Section string  `json:"section"`
+	Key     string  `json:"key"`
+	Value   *string `json:"value"`
+	Line    int     `json:"line"`
+}
+
+// writeKeys renders kvs to w in the given format.
+func writeKeys(w io.Writer, kvs []format.KeyValue, withValues bool, outFormat string) error {
+	switch outFormat {
+	case "json":
+		records := make([]keyRecord, 0, len(kvs))
+		for _, kv := range kvs {
+			r := keyRecord{Path: kv.Path(), Section: kv.Section, Key: kv.Key, Line: kv.Line}
+			if kv.HasValue {
+				r.Value = &kv.Value
+			}
+			records = append(records, r)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "text":
+		for _, kv := range kvs {
+			line := kv.Path()
+			if withValues && kv.HasValue {
+				line = strings.TrimSuffix(line+" = "+kv.Value, " ")
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid --format %q (want text or json)", outFormat)
+}
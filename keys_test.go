@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestWriteKeys(t *testing.T) {
+	lines := []string{
+		"top = 1",
+		"; commented = out",
+		"[server]",
+		"port = 80",
+		"bare_key",
+		"port = 8080",
+		"empty =",
+	}
+	kvs := format.ParseKeyValues(lines, format.Options{})
+
+	var text bytes.Buffer
+	if err := writeKeys(&text, kvs, true, "text"); err != nil {
+		t.Fatal(err)
+	}
+	want := "top = 1\nserver.port = 80\nserver.bare_key\nserver.port = 8080\nserver.empty =\n"
+	if text.String() != want {
+		t.Errorf("writeKeys(text) =\n%s\nwant\n%s", text.String(), want)
+	}
+
+	var out bytes.Buffer
+	if err := writeKeys(&out, kvs, false, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var records []keyRecord
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("writeKeys(json) produced invalid JSON: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("writeKeys(json) returned %d records, want 5", len(records))
+	}
+	if r := records[2]; r.Path != "server.bare_key" || r.Value != nil || r.Line != 5 {
+		t.Errorf("bare key record = %+v", r)
+	}
+	if r := records[3]; r.Section != "server" || r.Key != "port" || r.Value == nil || *r.Value != "8080" {
+		t.Errorf("duplicate key record = %+v", r)
+	}
+
+	if err := writeKeys(&out, kvs, false, "yaml"); err == nil {
+		t.Error("writeKeys(yaml) expected error")
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// Diagnostic severities. Errors make lint exit with status 1.
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// diagnostic is a lint finding at a line of a file.
+type diagnostic struct {
+	line     int
+	rule     string
+	severity string
+	message  string
+}
+
+// lintRule checks one input and reports its findings in line order. fix, for
+// the rules with a mechanical fix, returns the input with every finding of
+// the rule fixed and nothing else changed.
+type lintRule struct {
+	name  string
+	check func(in *input, cfg format.Options) []diagnostic
+	fix   func(in *input, cfg format.Options) *input
+}
+
+// lintRules are the checks lint runs, in reporting order, which is also the
+// order --fix applies their fixes in.
+var lintRules = []lintRule{
+	{"mixed-line-endings", checkMixedLineEndings, fixMixedLineEndings},
+	{"preamble-keys", checkPreambleKeys, nil},
+	{"unicode-delimiters", checkUnicodeDelimiters, fixUnicodeDelimiters},
+	{"mixed-delimiters", checkMixedDelimiters, fixMixedDelimiters},
+	{"mixed-comment-markers", checkMixedCommentMarkers, fixMixedCommentMarkers},
+	{"header-text", checkHeaderText, fixHeaderText},
+	{"long-values", checkLongValues, nil},
+	{"empty-sections", checkEmptySections, fixEmptySections},
+	{"duplicate-lines", checkDuplicateLines, fixDuplicateLines},
+	{"duplicate-keys", checkDuplicateKeys, nil},
+	{"unbalanced-quotes", checkUnbalancedQuotes, nil},
+	{"dangling-continuations", checkDanglingContinuations, nil},
+	{"missing-final-newline", checkMissingFinalNewline, fixMissingFinalNewline},
+}
+
+// strictRules are the rules whose warnings --strict reports as errors.
+var strictRules = []string{"dangling-continuations"}
+
+// newLintCmd builds the lint subcommand, which reports problems that
+// formatting alone does not fix.
+func newLintCmd(cfg *config) *cobra.Command {
+	var outFormat, schemaFile string
+	var schemaStrict, strict, fix, write bool
+	var fixOnly []string
+	cmd := &cobra.Command{
+		Use:   "lint file...",
+		Short: "Report problems such as mixed line endings",
+		Long: `lint checks files for problems that confuse other tools and prints one line
+per finding as file:line: severity: message (rule).
+
+Rules, those marked * with a fix:
+  mixed-line-endings* the file mixes CRLF and LF line endings (error)
+  preamble-keys       keys appear before the first section header (warning)
+  unicode-delimiters* keys are delimited by a full-width or other Unicode
+                      equals sign instead of '=' (warning)
+  mixed-delimiters*   key lines use both '=' and ':', in the dialects that
+                      read either (warning)
+  mixed-comment-markers*
+                      comments start with more than one marker, such as
+                      ';' and '#' (warning)
+  header-text*        text that is not a comment follows a section header
+                      (warning)
+  long-values         with --wrap-values, values past the column that
+                      wrapping would leave long (warning)
+  empty-sections*     sections without keys, which --prune-empty-sections
+                      removes; with --keep-commented, only those without
+                      comments either (warning)
+  duplicate-lines*    key lines repeating an earlier line of their section,
+                      which --unique removes (warning)
+  duplicate-keys      keys set again in their section with a different
+                      value (warning)
+  unbalanced-quotes   values that open a quote they never close, which some
+                      parsers read past the end of the line for (warning)
+  dangling-continuations
+                      lines ending in a continuation backslash at the end
+                      of the file or before a blank line, a header or a
+                      comment (warning; error with --strict)
+  missing-final-newline*
+                      the last line has no line ending (warning)
+
+--fix applies the fixes of the rules marked *, or only of the rules
+--fix-only names, and reports the findings that remain. With -w each file is
+rewritten in place; otherwise the fixed file is printed and the findings go
+to stderr. A fix changes only what its rule reports: line endings become the
+more common one, delimiters and comment markers the most common one, text
+after a header becomes a comment, empty sections and repeated lines are
+removed and a line ending is added at the end of the file.
+
+--schema FILE also checks the values of keys against their declared types.
+The schema is an INI file mapping the keys to types: string, int, float,
+bool, duration (such as 1m30s) or enum(a,b,c), with an optional inclusive
+range for numbers, e.g.
+
+  [server]
+  port    = int(1..65535)
+  timeout = duration
+  level   = enum(debug,info,warn,error)
+
+Quoted values are checked without their quotes. A value that does not parse
+is a warning (schema-type), or an error with --schema-strict.
+
+Use --format=github to print GitHub Actions workflow commands instead, which
+show the findings as annotations on a pull request; it is the default when
+GITHUB_ACTIONS=true.
+
+Exits 0 when there are no errors, 1 when an error was reported (with --fix,
+one that remains) and 2 when a file could not be read or written.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+		ValidArgsFunction: completeFiles,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			how, err := findingsFormat(outFormat, cmd.Flags().Changed("format"))
+			if err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			var sch schema
+			if schemaFile != "" {
+				if sch, err = loadSchema(schemaFile, cfg.source); err != nil {
+					return &exitError{code: 2, err: err}
+				}
+			}
+			schemaSeverity := severityWarning
+			if schemaStrict {
+				schemaSeverity = severityError
+			}
+			fix = fix || cmd.Flags().Changed("fix-only")
+			if err := checkFixOnly(fixOnly); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			if fix && !write && len(args) > 1 {
+				return &exitError{code: 2, err: errors.New("--fix prints the fixed file; use -w to fix more than one file in place")}
+			}
+			out := cmd.OutOrStdout()
+			if fix && !write {
+				out = cmd.ErrOrStderr()
+			}
+			errorsFound, failed := false, false
+			for _, file := range args {
+				in, err := readInput(file, cfg.source)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "inifmt lint: %s: %v\n", file, err)
+					failed = true
+					continue
+				}
+				opts := dialectOptions(cfg.format, file, in.lines)
+				if fix {
+					fixed := fixInput(in, opts, fixOnly)
+					if err := writeFixed(cmd.OutOrStdout(), file, in, fixed, write); err != nil {
+						fmt.Fprintf(cmd.ErrOrStderr(), "inifmt lint: %s: %v\n", file, err)
+						failed = true
+						continue
+					}
+					in = fixed
+				}
+				diags := lintInput(in, opts)
+				if strict {
+					for i, d := range diags {
+						if slices.Contains(strictRules, d.rule) {
+							diags[i].severity = severityError
+						}
+					}
+				}
+				diags = append(diags, sch.check(in, opts, schemaSeverity)...)
+				if err := writeDiagnostics(out, file, diags, how); err != nil {
+					return &exitError{code: 2, err: err}
+				}
+				for _, d := range diags {
+					errorsFound = errorsFound || d.severity == severityError
+				}
+			}
+			switch {
+			case failed:
+				return &exitError{code: 2}
+			case errorsFound:
+				return &exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&cfg.format.WrapValues, "wrap-values", 0, "Report values past this column that --wrap-values cannot wrap (80 when bare)")
+	cmd.Flags().Lookup("wrap-values").NoOptDefVal = "80"
+	cmd.Flags().BoolVar(&cfg.format.KeepCommentedSections, "keep-commented", false, "Do not report sections that hold comments as empty")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Report dangling continuation backslashes as errors")
+	cmd.Flags().StringVar(&schemaFile, "schema", "", "INI file declaring the type of each key, e.g. 'port = int(1..65535)'")
+	cmd.Flags().BoolVar(&schemaStrict, "schema-strict", false, "Report values that do not match their --schema type as errors")
+	cmd.Flags().BoolVar(&fix, "fix", false, "Fix the findings of the rules that have a fix and report the rest")
+	cmd.Flags().StringSliceVar(&fixOnly, "fix-only", nil, "Fix only the findings of these rules (comma-separated; implies --fix)")
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "With --fix, write the fixed files in place instead of printing the fixed file")
+	cmd.Flags().StringVar(&outFormat, "format", "text", "Output format: 'text', or 'github' for GitHub Actions annotations (the default when GITHUB_ACTIONS=true)")
+	return cmd
+}
+
+// lintInput runs every lint rule over in.
+func lintInput(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for _, r := range lintRules {
+		for _, d := range r.check(in, cfg) {
+			d.rule = r.name
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// writeDiagnostics prints the findings for file as text, or as GitHub Actions
+// annotations when how is "github".
+func writeDiagnostics(w io.Writer, file string, diags []diagnostic, how string) error {
+	for _, d := range diags {
+		line := fmt.Sprintf("%s:%d: %s: %s (%s)", file, d.line, d.severity, d.message, d.rule)
+		if how == "github" {
+			line = githubAnnotation(d.severity, file, d.line, d.message+" ("+d.rule+")")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxListedLines caps the line numbers spelled out in a diagnostic.
+const maxListedLines = 10
+
+// checkMixedLineEndings reports files with both CRLF and LF line endings,
+// listing the lines of the less common style. A final line without a
+// terminator counts as neither.
+func checkMixedLineEndings(in *input, _ format.Options) []diagnostic {
+	crlf, lf := lineEndingLines(in)
+	if len(crlf) == 0 || len(lf) == 0 {
+		return nil
+	}
+	minority, minorityName, fix := crlf, "CRLF", "lf"
+	if len(lf) < len(crlf) {
+		minority, minorityName, fix = lf, "LF", "crlf"
+	}
+	return []diagnostic{{
+		line:     minority[0],
+		severity: severityError,
+		message: fmt.Sprintf("mixed line endings: %d LF and %d CRLF lines; %s on %s (fix: --line-ending=%s)",
+			len(lf), len(crlf), minorityName, listLines(minority), fix),
+	}}
+}
+
+// lineEndingLines returns the numbers of the lines of in that end in CRLF
+// and of those that end in LF.
+func lineEndingLines(in *input) (crlf, lf []int) {
+	for i, eol := range in.endings {
+		switch eol {
+		case "\r\n":
+			crlf = append(crlf, i+1)
+		case "\n":
+			lf = append(lf, i+1)
+		}
+	}
+	return crlf, lf
+}
+
+// listLines formats line numbers as "line 3" or "lines 3, 7 and 9", naming at
+// most maxListedLines of them.
+func listLines(lines []int) string {
+	if len(lines) == 1 {
+		return "line " + strconv.Itoa(lines[0])
+	}
+	shown := lines[:min(len(lines), maxListedLines)]
+	parts := make([]string, len(shown))
+	for i, n := range shown {
+		parts[i] = strconv.Itoa(n)
+	}
+	if rest := len(lines) - len(shown); rest > 0 {
+		return fmt.Sprintf("lines %s and %d more", strings.Join(parts, ", "), rest)
+	}
+	return fmt.Sprintf("lines %s and %s", strings.Join(parts[:len(parts)-1], ", "), parts[len(parts)-1])
+}
+
+// checkPreambleKeys reports keys before the first section header, which strict
+// parsers reject.
+func checkPreambleKeys(in *input, cfg format.Options) []diagnostic {
+	var preamble []format.KeyValue
+	for _, kv := range format.ParseKeyValues(in.lines, cfg) {
+		if kv.Section != "" {
+			break
+		}
+		preamble = append(preamble, kv)
+	}
+	if len(preamble) == 0 {
+		return nil
+	}
+	noun := "key"
+	if len(preamble) > 1 {
+		noun = "keys"
+	}
+	return []diagnostic{{
+		line:     preamble[0].Line,
+		severity: severityWarning,
+		message: fmt.Sprintf("%d %s before the first section header; strict parsers reject them (fix: --default-section=NAME)",
+			len(preamble), noun),
+	}}
+}
+
+// checkUnicodeDelimiters reports key lines delimited by a look-alike of '=',
+// which the formatter leaves alone and parsers reject.
+func checkUnicodeDelimiters(in *input, cfg format.Options) []diagnostic {
+	lines := format.UnicodeDelimiterLines(in.lines, cfg)
+	if len(lines) == 0 {
+		return nil
+	}
+	return []diagnostic{{
+		line:     lines[0],
+		severity: severityWarning,
+		message: fmt.Sprintf("Unicode equals sign instead of '=' on %s; parsers reject it (fix: --normalize-unicode-delimiters)",
+			listLines(lines)),
+	}}
+}
+
+// markerLines groups lines by the text of one token kind, such as the
+// delimiters of key lines, and returns the texts with the most common first,
+// ties in order of appearance. pick returns the text of a line, or "" to
+// leave it out.
+func markerLines(in *input, cfg format.Options, pick func(line string, toks []format.Token) string) ([]string, map[string][]int) {
+	var texts []string
+	lines := make(map[string][]int)
+	for i, toks := range format.TokenizeLines(in.lines, cfg) {
+		text := pick(in.lines[i], toks)
+		if text == "" {
+			continue
+		}
+		if _, ok := lines[text]; !ok {
+			texts = append(texts, text)
+		}
+		lines[text] = append(lines[text], i+1)
+	}
+	slices.SortStableFunc(texts, func(a, b string) int { return len(lines[b]) - len(lines[a]) })
+	return texts, lines
+}
+
+// mixedMarkers reports, as one diagnostic, the lines that use another text
+// than the most common one, as in "mixed delimiters: 4 '=' and 1 ':' key
+// lines; ':' on line 3".
+func mixedMarkers(what, noun string, texts []string, lines map[string][]int) []diagnostic {
+	if len(texts) < 2 {
+		return nil
+	}
+	counts := make([]string, len(texts))
+	var others []int
+	for i, text := range texts {
+		counts[i] = fmt.Sprintf("%d '%s'", len(lines[text]), text)
+		if i > 0 {
+			others = append(others, lines[text]...)
+		}
+	}
+	slices.Sort(others)
+	which := "'" + texts[1] + "'"
+	if len(texts) > 2 {
+		which = "others"
+	}
+	return []diagnostic{{
+		line:     others[0],
+		severity: severityWarning,
+		message: fmt.Sprintf("mixed %s: %s and %s %s; %s on %s",
+			what, strings.Join(counts[:len(counts)-1], ", "), counts[len(counts)-1], noun, which, listLines(others)),
+	}}
+}
+
+// keyDelimiter returns a function giving the delimiter of a key line, '='
+// or ':', or "" for other lines. Assignment operators such as "+=" do not
+// count.
+func keyDelimiter(cfg format.Options) func(line string, toks []format.Token) string {
+	return func(line string, toks []format.Token) string {
+		if !slices.ContainsFunc(toks, func(t format.Token) bool { return t.Kind == format.TokenDelimiter && t.Text == "=" }) {
+			return ""
+		}
+		before, _, _ := cfg.Cut(line)
+		if d := line[len(before)]; d == '=' || d == ':' {
+			return string(d)
+		}
+		return ""
+	}
+}
+
+// checkMixedDelimiters reports files whose key lines use both '=' and ':',
+// in the dialects that read either.
+func checkMixedDelimiters(in *input, cfg format.Options) []diagnostic {
+	texts, lines := markerLines(in, cfg, keyDelimiter(cfg))
+	return mixedMarkers("delimiters", "key lines", texts, lines)
+}
+
+// commentPrefixes returns the full-line comment prefixes cfg recognizes.
+func commentPrefixes(cfg format.Options) []string {
+	if cfg.CommentPrefixes != nil {
+		return cfg.CommentPrefixes
+	}
+	if cfg.Dialect != nil {
+		return cfg.Dialect.CommentPrefixes()
+	}
+	return format.DialectINI.CommentPrefixes()
+}
+
+// commentPrefixOf returns the prefix the marker of a full-line comment is
+// made of: ";" for ";;".
+func commentPrefixOf(marker string, cfg format.Options) string {
+	for _, p := range commentPrefixes(cfg) {
+		if p != "" && strings.HasPrefix(marker, p) {
+			return p
+		}
+	}
+	return marker
+}
+
+// checkMixedCommentMarkers reports files whose full-line comments start with
+// more than one marker, such as ';' and '#'.
+func checkMixedCommentMarkers(in *input, cfg format.Options) []diagnostic {
+	texts, lines := markerLines(in, cfg, commentLineMarker(cfg))
+	return mixedMarkers("comment markers", "comments", texts, lines)
+}
+
+// commentLineMarker returns a function giving the prefix of a full-line comment,
+// or "" for other lines.
+func commentLineMarker(cfg format.Options) func(line string, toks []format.Token) string {
+	return func(line string, toks []format.Token) string {
+		if !cfg.IsComment(line) {
+			return ""
+		}
+		for _, tok := range toks {
+			if tok.Kind == format.TokenCommentMarker {
+				return commentPrefixOf(tok.Text, cfg)
+			}
+		}
+		return ""
+	}
+}
+
+// headerText returns the text after a header that is not a comment, or "".
+func headerText(toks []format.Token) string {
+	for _, tok := range toks {
+		if tok.Kind == format.TokenText {
+			return tok.Text
+		}
+	}
+	return ""
+}
+
+// checkHeaderText reports section headers followed by text that is not a
+// comment, which some parsers take as part of the name and others reject.
+func checkHeaderText(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for i, toks := range format.TokenizeLines(in.lines, cfg) {
+		if text := headerText(toks); text != "" {
+			diags = append(diags, diagnostic{
+				line:     i + 1,
+				severity: severityWarning,
+				message:  fmt.Sprintf("text after the header of [%s]: %s", format.HeaderName(in.lines[i]), shortValue(text)),
+			})
+		}
+	}
+	return diags
+}
+
+// checkLongValues reports values past the --wrap-values column that have no
+// safe break point, or are in a dialect without continuation lines.
+func checkLongValues(in *input, cfg format.Options) []diagnostic {
+	lines := format.LongValues(in.lines, cfg)
+	if len(lines) == 0 {
+		return nil
+	}
+	return []diagnostic{{
+		line:     lines[0],
+		severity: severityWarning,
+		message: fmt.Sprintf("values past column %d that cannot be wrapped on %s",
+			cfg.WrapValues, listLines(lines)),
+	}}
+}
+
+// checkEmptySections reports sections without keys, which are usually left
+// over from deleting them.
+func checkEmptySections(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for _, n := range format.EmptySections(in.lines, cfg) {
+		diags = append(diags, diagnostic{
+			line:     n,
+			severity: severityWarning,
+			message:  fmt.Sprintf("section [%s] has no keys (fix: --prune-empty-sections)", format.HeaderName(in.lines[n-1])),
+		})
+	}
+	return diags
+}
+
+// checkDuplicateLines reports key lines that repeat an earlier line of their
+// section, which --unique removes.
+func checkDuplicateLines(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for _, d := range format.DuplicateLines(in.lines, cfg) {
+		diags = append(diags, diagnostic{
+			line:     d.Line,
+			severity: severityWarning,
+			message:  fmt.Sprintf("line repeats line %d (fix: --unique)", d.First),
+		})
+	}
+	return diags
+}
+
+// checkDuplicateKeys reports keys set again in their section with a different
+// value, where parsers disagree on which value wins. Dialects whose keys
+// repeat on purpose, such as systemd units, are not checked.
+func checkDuplicateKeys(in *input, cfg format.Options) []diagnostic {
+	if cfg.Dialect != nil && format.RepeatsKeys(cfg.Dialect) {
+		return nil
+	}
+	var diags []diagnostic
+	first := make(map[string]format.KeyValue)
+	for _, kv := range format.ParseKeyValues(in.lines, cfg) {
+		prev, ok := first[kv.Path()]
+		if !ok {
+			first[kv.Path()] = kv
+			continue
+		}
+		if prev.HasValue != kv.HasValue || keyValueText(prev) != keyValueText(kv) {
+			diags = append(diags, diagnostic{
+				line:     kv.Line,
+				severity: severityWarning,
+				message:  fmt.Sprintf("%s is set again with a different value than on line %d", kv.Path(), prev.Line),
+			})
+		}
+	}
+	return diags
+}
+
+// keyValueText returns the value of kv without its inline comment and
+// enclosing quotes, with runs of whitespace collapsed.
+func keyValueText(kv format.KeyValue) string {
+	return strings.Join(strings.Fields(format.ValueText(kv.Value)), " ")
+}
+
+// maxShownValue caps the characters of a value quoted in a diagnostic.
+const maxShownValue = 40
+
+// shortValue returns value for display, cut to maxShownValue characters.
+func shortValue(value string) string {
+	if r := []rune(value); len(r) > maxShownValue {
+		return string(r[:maxShownValue]) + "..."
+	}
+	return value
+}
+
+// checkUnbalancedQuotes reports values with a quote that never closes, such
+// as path = "C:\Program Files\App, which the formatter leaves as it is.
+func checkUnbalancedQuotes(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for _, kv := range format.UnbalancedQuotes(in.lines, cfg) {
+		diags = append(diags, diagnostic{
+			line:     kv.Line,
+			severity: severityWarning,
+			message:  fmt.Sprintf("unbalanced quote in %s = %s", kv.Key, shortValue(kv.Value)),
+		})
+	}
+	return diags
+}
+
+// checkDanglingContinuations reports lines ending in a backslash that have no
+// line to continue into, which parsers read in different ways.
+func checkDanglingContinuations(in *input, cfg format.Options) []diagnostic {
+	var diags []diagnostic
+	for _, n := range format.DanglingContinuations(in.lines, cfg) {
+		diags = append(diags, diagnostic{
+			line:     n,
+			severity: severityWarning,
+			message:  "continuation backslash with no line to continue into",
+		})
+	}
+	return diags
+}
+
+// checkMissingFinalNewline reports a last line without a line ending, which
+// line-based tools such as cat and wc miss.
+func checkMissingFinalNewline(in *input, _ format.Options) []diagnostic {
+	if len(in.endings) == 0 || in.endings[len(in.endings)-1] != "" {
+		return nil
+	}
+	return []diagnostic{{
+		line:     len(in.endings),
+		severity: severityWarning,
+		message:  "no line ending at the end of the file",
+	}}
+}
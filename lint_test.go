@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestLineEndings(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"a", []string{""}},
+		{"a\r\nb\nc", []string{"\r\n", "\n", ""}},
+		{"\n\r\n", []string{"\n", "\r\n"}},
+	}
+	for _, tt := range tests {
+		if got := lineEndings(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("lineEndings(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if lines, _ := format.SplitLines(tt.text); len(lines) != len(tt.want) {
+			t.Errorf("lineEndings(%q) has %d entries for %d lines", tt.text, len(tt.want), len(lines))
+		}
+	}
+}
+
+func TestCheckMixedLineEndings(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"[s]\na=1\nb=2\n", ""},
+		{"[s]\r\na=1\r\nb=2\r\n", ""},
+		{"[s]\r\na=1\r\nb=2", ""},
+		{"[s]\na=1\r\nb=2\n", "2: mixed line endings: 2 LF and 1 CRLF lines; CRLF on line 2 (fix: --line-ending=lf)"},
+		{"[s]\r\na=1\nb=2\r\nc=3\n", "1: mixed line endings: 2 LF and 2 CRLF lines; CRLF on lines 1 and 3 (fix: --line-ending=lf)"},
+		{"[s]\r\na=1\nb=2\r\nc=3\r\n", "2: mixed line endings: 1 LF and 3 CRLF lines; LF on line 2 (fix: --line-ending=crlf)"},
+		{strings.Repeat("a=1\n", 20) + strings.Repeat("b=2\r\n", 12), "21: mixed line endings: 20 LF and 12 CRLF lines; CRLF on lines 21, 22, 23, 24, 25, 26, 27, 28, 29, 30 and 2 more (fix: --line-ending=lf)"},
+	}
+	for _, tt := range tests {
+		lines, eol := format.SplitLines(tt.text)
+		in := &input{lines: lines, eol: eol, endings: lineEndings(tt.text)}
+		var got string
+		for _, d := range checkMixedLineEndings(in, format.Options{}) {
+			got = fmt.Sprintf("%d: %s", d.line, d.message)
+		}
+		if got != tt.want {
+			t.Errorf("checkMixedLineEndings(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLintCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"clean.ini":   "[s]\na = 1\n",
+		"mixed.ini":   "[s]\r\na = 1\nb = 2\r\n",
+		"loose.ini":   "; top\nx = 1\ny = 2\n[s]\na = 1\n",
+		"wide.ini":    "[s]\nname＝demo\n",
+		"empty.ini":   "[s]\na = 1\n\n[gone]\n\n[stub]\n; later\n",
+		"quotes.ini":  "[s]\npath = \"C:\\Program Files\\App\nok = \"a \\\" b\" ; it's fine\nmsg = don't\nlong = \"" + strings.Repeat("x", 50) + "\n",
+		"app.service": "[Service]\nExecStart=/bin/app \\\n# flags\n  --verbose\nExecStop=/bin/stop \\\n\n[Install]\nWantedBy=multi-user.target \\\n",
+		"export.reg":  "Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER\\Software\\X]\r\n\"a\"=\"1\"\r\n",
+		"messy.ini":   "; a\n[s] junk\n# b\nk = 1\nk = 1\nk = 2",
+		"setup.cfg":   "[metadata]\nname = x\nversion: 1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GITHUB_ACTIONS", "")
+	tests := []struct {
+		files []string
+		flags []string
+		want  string
+		code  int
+	}{
+		{[]string{"clean.ini", "export.reg"}, nil, "", 0},
+		{[]string{"loose.ini"}, nil, "loose.ini:2: warning: 2 keys before the first section header; strict parsers reject them (fix: --default-section=NAME) (preamble-keys)\n", 0},
+		{[]string{"mixed.ini"}, nil, "mixed.ini:2: error: mixed line endings: 1 LF and 2 CRLF lines; LF on line 2 (fix: --line-ending=crlf) (mixed-line-endings)\n", 1},
+		{[]string{"wide.ini"}, nil, "wide.ini:2: warning: Unicode equals sign instead of '=' on line 2; parsers reject it (fix: --normalize-unicode-delimiters) (unicode-delimiters)\n", 0},
+		{[]string{"clean.ini"}, []string{"--wrap-values=4"}, "clean.ini:2: warning: values past column 4 that cannot be wrapped on line 2 (long-values)\n", 0},
+		{[]string{"empty.ini"}, nil, "empty.ini:4: warning: section [gone] has no keys (fix: --prune-empty-sections) (empty-sections)\n" +
+			"empty.ini:6: warning: section [stub] has no keys (fix: --prune-empty-sections) (empty-sections)\n", 0},
+		{[]string{"empty.ini"}, []string{"--keep-commented"}, "empty.ini:4: warning: section [gone] has no keys (fix: --prune-empty-sections) (empty-sections)\n", 0},
+		{[]string{"quotes.ini"}, nil, "quotes.ini:2: warning: unbalanced quote in path = \"C:\\Program Files\\App (unbalanced-quotes)\n" +
+			"quotes.ini:5: warning: unbalanced quote in long = \"" + strings.Repeat("x", 39) + "... (unbalanced-quotes)\n", 0},
+		{[]string{"app.service"}, nil, "app.service:5: warning: continuation backslash with no line to continue into (dangling-continuations)\n" +
+			"app.service:8: warning: continuation backslash with no line to continue into (dangling-continuations)\n", 0},
+		{[]string{"app.service"}, []string{"--strict"}, "app.service:5: error: continuation backslash with no line to continue into (dangling-continuations)\n" +
+			"app.service:8: error: continuation backslash with no line to continue into (dangling-continuations)\n", 1},
+		{[]string{"messy.ini"}, nil, "messy.ini:3: warning: mixed comment markers: 1 ';' and 1 '#' comments; '#' on line 3 (mixed-comment-markers)\n" +
+			"messy.ini:2: warning: text after the header of [s]: junk (header-text)\n" +
+			"messy.ini:5: warning: line repeats line 4 (fix: --unique) (duplicate-lines)\n" +
+			"messy.ini:6: warning: s.k is set again with a different value than on line 4 (duplicate-keys)\n" +
+			"messy.ini:6: warning: no line ending at the end of the file (missing-final-newline)\n", 0},
+		{[]string{"setup.cfg"}, nil, "setup.cfg:3: warning: mixed delimiters: 1 '=' and 1 ':' key lines; ':' on line 3 (mixed-delimiters)\n", 0},
+		{[]string{"clean.ini", "missing.ini"}, nil, "", 2},
+		{[]string{"mixed.ini", "loose.ini"}, []string{"--format=github"}, "::error file=mixed.ini,line=2,title=inifmt::mixed line endings: 1 LF and 2 CRLF lines; LF on line 2 (fix: --line-ending=crlf) (mixed-line-endings)\n" +
+			"::warning file=loose.ini,line=2,title=inifmt::2 keys before the first section header; strict parsers reject them (fix: --default-section=NAME) (preamble-keys)\n", 1},
+		{[]string{"clean.ini"}, []string{"--format=json"}, "", 2},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		cmd := newRootCmd()
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		args := append([]string{"lint"}, tt.flags...)
+		for _, f := range tt.files {
+			args = append(args, filepath.Join(dir, f))
+		}
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		code := 0
+		var ee *exitError
+		if errors.As(err, &ee) {
+			code = ee.code
+		} else if err != nil {
+			t.Fatalf("lint %v: unexpected error %v", tt.files, err)
+		}
+		got := strings.ReplaceAll(out.String(), dir+string(filepath.Separator), "")
+		if got != tt.want || code != tt.code {
+			t.Errorf("lint %v = %q, exit %d; want %q, exit %d", tt.files, got, code, tt.want, tt.code)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// fixableRules returns the names of the lint rules with a fix.
+func fixableRules() []string {
+	var names []string
+	for _, r := range lintRules {
+		if r.fix != nil {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// checkFixOnly reports a --fix-only name that is not a fixable rule.
+func checkFixOnly(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(fixableRules(), name) {
+			return fmt.Errorf("invalid --fix-only rule %q (want one of %s)", name, strings.Join(fixableRules(), ", "))
+		}
+	}
+	return nil
+}
+
+// fixInput applies, in rule order, the fix of every rule in only, or of every
+// fixable rule when only is empty, that has findings in in.
+func fixInput(in *input, cfg format.Options, only []string) *input {
+	for _, r := range lintRules {
+		if r.fix == nil || len(only) > 0 && !slices.Contains(only, r.name) {
+			continue
+		}
+		if len(r.check(in, cfg)) > 0 {
+			in = r.fix(in, cfg)
+		}
+	}
+	return in
+}
+
+// writeFixed writes the fixed input back to file with write, compressed
+// again if it was, or prints it to w. Each line keeps its own line ending.
+func writeFixed(w io.Writer, file string, orig, fixed *input, write bool) error {
+	if write && isURL(file) {
+		return errors.New("--write cannot write back to a URL")
+	}
+	if write && fixed.text == orig.text {
+		return nil
+	}
+	data, err := encodeLines(strings.SplitAfter(fixed.text, "\n"), fixed.enc, "")
+	if err != nil {
+		return err
+	}
+	if !write {
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+		return nil
+	}
+	if fixed.gzip != nil {
+		if data, err = compressOutput(data, fixed.gzip); err != nil {
+			return err
+		}
+	}
+	if err := writeToFile(file, data); err != nil {
+		return fmt.Errorf("writing to file: %w", err)
+	}
+	return nil
+}
+
+// withEndings returns in with lines in place of its lines, each ending in the
+// ending at the same index, decoded again as readInput would.
+func withEndings(in *input, lines, endings []string) *input {
+	var b strings.Builder
+	for i, line := range lines {
+		b.WriteString(line)
+		b.WriteString(endings[i])
+	}
+	text := b.String()
+	split, eol := format.SplitLines(text)
+	return &input{text: text, lines: split, eol: eol, endings: lineEndings(text), enc: in.enc, gzip: in.gzip}
+}
+
+// withLines returns in with lines, which are lines of in with some removed,
+// in place of its lines. Each line keeps the ending of the line of in it
+// matches, in order.
+func withLines(in *input, lines []string) *input {
+	endings := make([]string, len(lines))
+	next := 0
+	for i, line := range lines {
+		endings[i] = in.eol
+		if j := slices.Index(in.lines[next:], line); j != -1 {
+			endings[i] = in.endings[next+j]
+			next += j + 1
+		}
+	}
+	return withEndings(in, lines, endings)
+}
+
+// editTokens returns in with every line rebuilt from its tokens after edit
+// has changed their texts.
+func editTokens(in *input, cfg format.Options, edit func(line string, toks []format.Token)) *input {
+	lines := make([]string, len(in.lines))
+	for i, toks := range format.TokenizeLines(in.lines, cfg) {
+		edit(in.lines[i], toks)
+		var b strings.Builder
+		for _, tok := range toks {
+			b.WriteString(tok.Text)
+		}
+		lines[i] = b.String()
+	}
+	return withEndings(in, lines, in.endings)
+}
+
+// fixMixedLineEndings ends every line in the more common line ending, as
+// --line-ending would. A last line without one is left without.
+func fixMixedLineEndings(in *input, _ format.Options) *input {
+	crlf, lf := lineEndingLines(in)
+	eol := "\n"
+	if len(lf) < len(crlf) {
+		eol = "\r\n"
+	}
+	endings := slices.Clone(in.endings)
+	for i, e := range endings {
+		if e != "" {
+			endings[i] = eol
+		}
+	}
+	return withEndings(in, in.lines, endings)
+}
+
+// fixUnicodeDelimiters replaces Unicode equals signs delimiting keys with '='.
+func fixUnicodeDelimiters(in *input, cfg format.Options) *input {
+	return withEndings(in, format.NormalizeUnicodeDelimiters(in.lines, cfg), in.endings)
+}
+
+// fixMixedDelimiters rewrites the delimiters of key lines as the most common
+// of '=' and ':'.
+func fixMixedDelimiters(in *input, cfg format.Options) *input {
+	delimiter := keyDelimiter(cfg)
+	texts, _ := markerLines(in, cfg, delimiter)
+	if len(texts) < 2 {
+		return in
+	}
+	lines := slices.Clone(in.lines)
+	for i, toks := range format.TokenizeLines(in.lines, cfg) {
+		if d := delimiter(lines[i], toks); d != "" && d != texts[0] {
+			before, after, _ := cfg.Cut(lines[i])
+			lines[i] = before + texts[0] + after
+		}
+	}
+	return withEndings(in, lines, in.endings)
+}
+
+// fixMixedCommentMarkers rewrites the markers of full-line comments with the
+// most common prefix, as many times as the marker repeated its own: ";;"
+// becomes "##". A prefix ending in a letter, such as REM, gets a space before
+// the text.
+func fixMixedCommentMarkers(in *input, cfg format.Options) *input {
+	texts, _ := markerLines(in, cfg, commentLineMarker(cfg))
+	if len(texts) < 2 {
+		return in
+	}
+	major := texts[0]
+	last, _ := utf8.DecodeLastRuneInString(major)
+	return editTokens(in, cfg, func(line string, toks []format.Token) {
+		if !cfg.IsComment(line) {
+			return
+		}
+		for i, tok := range toks {
+			if tok.Kind != format.TokenCommentMarker {
+				continue
+			}
+			p := commentPrefixOf(tok.Text, cfg)
+			if p != major {
+				toks[i].Text = strings.Repeat(major, len(tok.Text)/len(p))
+				if (unicode.IsLetter(last) || unicode.IsDigit(last)) && i+1 < len(toks) && toks[i+1].Kind == format.TokenCommentText {
+					toks[i].Text += " "
+				}
+			}
+			return
+		}
+	})
+}
+
+// fixHeaderText turns the text after a header into a comment on the header
+// line, with the most common of ';' and '#' among the file's comments, or
+// the first of them its comment prefixes hold.
+func fixHeaderText(in *input, cfg format.Options) *input {
+	texts, _ := markerLines(in, cfg, commentLineMarker(cfg))
+	marker := ";"
+	headerMarker := func(p string) bool { return p == ";" || p == "#" }
+	if i := slices.IndexFunc(texts, headerMarker); i != -1 {
+		marker = texts[i]
+	} else if i := slices.IndexFunc(commentPrefixes(cfg), headerMarker); i != -1 {
+		marker = commentPrefixes(cfg)[i]
+	}
+	return editTokens(in, cfg, func(_ string, toks []format.Token) {
+		for i, tok := range toks {
+			if tok.Kind == format.TokenText {
+				toks[i].Text = marker + " " + tok.Text
+			}
+		}
+	})
+}
+
+// fixEmptySections removes the sections without keys as
+// --prune-empty-sections does.
+func fixEmptySections(in *input, cfg format.Options) *input {
+	return withLines(in, format.RemoveEmptySections(in.lines, cfg))
+}
+
+// fixDuplicateLines removes the key lines that repeat an earlier line of
+// their section, with their continuation lines, as --unique does.
+func fixDuplicateLines(in *input, cfg format.Options) *input {
+	toks := format.TokenizeLines(in.lines, cfg)
+	drop := make(map[int]bool)
+	for _, d := range format.DuplicateLines(in.lines, cfg) {
+		for i := d.Line - 1; i < valueEnd(toks, d.Line-1); i++ {
+			drop[i] = true
+		}
+	}
+	var lines, endings []string
+	for i, line := range in.lines {
+		if !drop[i] {
+			lines = append(lines, line)
+			endings = append(endings, in.endings[i])
+		}
+	}
+	if len(endings) > 0 && drop[len(in.lines)-1] {
+		endings[len(endings)-1] = in.endings[len(in.endings)-1]
+	}
+	return withEndings(in, lines, endings)
+}
+
+// fixMissingFinalNewline ends the last line in the line ending of the first.
+func fixMissingFinalNewline(in *input, _ format.Options) *input {
+	endings := slices.Clone(in.endings)
+	if len(endings) > 0 {
+		endings[len(endings)-1] = in.eol
+	}
+	return withEndings(in, in.lines, endings)
+}
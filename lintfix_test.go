@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// textInput returns text as readInput would decode it.
+func textInput(text string) *input {
+	lines, eol := format.SplitLines(text)
+	return &input{text: text, lines: lines, eol: eol, endings: lineEndings(text)}
+}
+
+// lintRuleNamed returns the lint rule called name.
+func lintRuleNamed(t *testing.T, name string) lintRule {
+	t.Helper()
+	i := slices.IndexFunc(lintRules, func(r lintRule) bool { return r.name == name })
+	if i == -1 {
+		t.Fatalf("no lint rule %s", name)
+	}
+	return lintRules[i]
+}
+
+func TestLintFixes(t *testing.T) {
+	tests := []struct {
+		rule string
+		file string // picks the dialect
+		in   string
+		want string
+	}{
+		{"mixed-line-endings", "a.ini", "[s]\na=1\r\nb=2\n", "[s]\na=1\nb=2\n"},
+		{"mixed-line-endings", "a.ini", "[s]\r\na=1\nb=2\r\nc=3", "[s]\r\na=1\r\nb=2\r\nc=3"},
+		{"unicode-delimiters", "a.ini", "[s]\r\nname＝demo\r\nurl = a＝b\r\n", "[s]\r\nname=demo\r\nurl = a＝b\r\n"},
+		{"mixed-delimiters", "setup.cfg", "[m]\nname = x\nversion: 1\nurl = http://a\n", "[m]\nname = x\nversion= 1\nurl = http://a\n"},
+		{"mixed-delimiters", "setup.cfg", "[m]\na: 1\nb = 2\nc :3\n", "[m]\na: 1\nb : 2\nc :3\n"},
+		{"mixed-comment-markers", "a.ini", "; one\n;; two\n[s]\n  # three\na = 1 # inline\n", "; one\n;; two\n[s]\n  ; three\na = 1 # inline\n"},
+		{"mixed-comment-markers", "a.ini", "# one\n#two\n;three\n;; four\n", "# one\n#two\n#three\n## four\n"},
+		{"header-text", "a.ini", "[s] junk\na = 1\n[t] ; fine\n", "[s] ; junk\na = 1\n[t] ; fine\n"},
+		{"header-text", "app.service", "# web\n[Service]   old name\nType=simple\n", "# web\n[Service]   # old name\nType=simple\n"},
+		{"empty-sections", "a.ini", "[a]\r\nk = v\r\n\r\n; about b\r\n[b]\n\n[c]\r\nx = 1", "[a]\r\nk = v\r\n\r\n[c]\r\nx = 1"},
+		{"duplicate-lines", "a.ini", "[s]\na = 1\nb = 2\na  =  1\n[t]\na = 1\n", "[s]\na = 1\nb = 2\n[t]\na = 1\n"},
+		{"duplicate-lines", ".gitconfig", "[alias]\n\tst = status \\\n\t  -s\n\tst = status \\\n\t  -s\n\tco = checkout", "[alias]\n\tst = status \\\n\t  -s\n\tco = checkout"},
+		{"missing-final-newline", "a.ini", "[s]\r\na = 1", "[s]\r\na = 1\r\n"},
+		{"missing-final-newline", "a.ini", "a = 1", "a = 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r := lintRuleNamed(t, tt.rule)
+			in := textInput(tt.in)
+			cfg := dialectOptions(format.Options{}, tt.file, in.lines)
+			if len(r.check(in, cfg)) == 0 {
+				t.Fatalf("%s reports nothing for %q", tt.rule, tt.in)
+			}
+			fixed := r.fix(in, cfg)
+			if fixed.text != tt.want {
+				t.Errorf("fix(%q) = %q, want %q", tt.in, fixed.text, tt.want)
+			}
+			if diags := r.check(fixed, cfg); len(diags) > 0 {
+				t.Errorf("%s still reports %+v after the fix", tt.rule, diags)
+			}
+			if again := r.fix(fixed, cfg); again.text != fixed.text {
+				t.Errorf("fix is not idempotent: %q, then %q", fixed.text, again.text)
+			}
+		})
+	}
+}
+
+func TestFixInput(t *testing.T) {
+	text := "; top\r\n[s] junk\r\na = 1\r\n# note\r\na = 1\r\nb＝2\r\nb = 3\n\n[gone]\r\n\r\n[t]\r\nx = 1"
+	in := textInput(text)
+	cfg := dialectOptions(format.Options{}, "a.ini", in.lines)
+
+	fixed := fixInput(in, cfg, nil)
+	want := "; top\r\n[s] ; junk\r\na = 1\r\n; note\r\nb=2\r\nb = 3\r\n\r\n[t]\r\nx = 1\r\n"
+	if fixed.text != want {
+		t.Errorf("fixInput() = %q, want %q", fixed.text, want)
+	}
+	var left []string
+	for _, d := range lintInput(fixed, cfg) {
+		left = append(left, d.rule)
+	}
+	if !slices.Equal(left, []string{"duplicate-keys"}) {
+		t.Errorf("after fixInput() lint reports %q, want only duplicate-keys", left)
+	}
+
+	fixed = fixInput(in, cfg, []string{"missing-final-newline", "header-text"})
+	if want := strings.Replace(text, "junk", "; junk", 1) + "\r\n"; fixed.text != want {
+		t.Errorf("fixInput(only two rules) = %q, want %q", fixed.text, want)
+	}
+}
+
+func TestLintFixCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GITHUB_ACTIONS", "")
+	const broken = "[s]\r\na = 1\nname＝demo\r\n[gone]\r\n"
+	tests := []struct {
+		name   string
+		flags  []string
+		files  map[string]string
+		want   map[string]string // file contents afterwards
+		stdout string
+		stderr string
+		code   int
+	}{
+		{
+			name:   "in place",
+			flags:  []string{"--fix", "-w"},
+			files:  map[string]string{"a.ini": broken, "b.ini": "x = 1\ny = 2\n"},
+			want:   map[string]string{"a.ini": "[s]\r\na = 1\r\nname=demo\r\n", "b.ini": "x = 1\ny = 2\n"},
+			stdout: "b.ini:1: warning: 2 keys before the first section header; strict parsers reject them (fix: --default-section=NAME) (preamble-keys)\n",
+		},
+		{
+			name:   "printed",
+			flags:  []string{"--fix"},
+			files:  map[string]string{"a.ini": broken},
+			want:   map[string]string{"a.ini": broken},
+			stdout: "[s]\r\na = 1\r\nname=demo\r\n",
+		},
+		{
+			name:   "only some rules",
+			flags:  []string{"--fix-only=unicode-delimiters,empty-sections", "-w"},
+			files:  map[string]string{"a.ini": broken},
+			want:   map[string]string{"a.ini": "[s]\r\na = 1\nname=demo\r\n"},
+			stdout: "a.ini:2: error: mixed line endings: 1 LF and 2 CRLF lines; LF on line 2 (fix: --line-ending=crlf) (mixed-line-endings)\n",
+			code:   1,
+		},
+		{
+			name:   "remaining findings go to stderr",
+			flags:  []string{"--fix"},
+			files:  map[string]string{"a.ini": "[s]\na = 1\na = 2"},
+			want:   map[string]string{"a.ini": "[s]\na = 1\na = 2"},
+			stdout: "[s]\na = 1\na = 2\n",
+			stderr: "a.ini:3: warning: s.a is set again with a different value than on line 2 (duplicate-keys)\n",
+		},
+		{
+			name:  "several files need -w",
+			flags: []string{"--fix"},
+			files: map[string]string{"a.ini": broken, "b.ini": broken},
+			want:  map[string]string{"a.ini": broken, "b.ini": broken},
+			code:  2,
+		},
+		{
+			name:  "rule without a fix",
+			flags: []string{"--fix-only=preamble-keys", "-w"},
+			files: map[string]string{"a.ini": broken},
+			want:  map[string]string{"a.ini": broken},
+			code:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+			writeFiles(t, sub, tt.files)
+			var stdout, stderr bytes.Buffer
+			cmd := newRootCmd()
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			args := append([]string{"lint"}, tt.flags...)
+			for _, name := range slices.Sorted(maps.Keys(tt.files)) {
+				args = append(args, filepath.Join(sub, name))
+			}
+			cmd.SetArgs(args)
+			code := 0
+			if err := cmd.Execute(); err != nil {
+				var ee *exitError
+				if !errors.As(err, &ee) {
+					t.Fatal(err)
+				}
+				code = ee.code
+			}
+			if code != tt.code {
+				t.Errorf("exit %d, want %d", code, tt.code)
+			}
+			trim := func(s string) string { return strings.ReplaceAll(s, sub+string(filepath.Separator), "") }
+			if got := trim(stdout.String()); got != tt.stdout {
+				t.Errorf("stdout = %q, want %q", got, tt.stdout)
+			}
+			if got := trim(stderr.String()); tt.code != 2 && got != tt.stderr {
+				t.Errorf("stderr = %q, want %q", got, tt.stderr)
+			}
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(sub, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// logLevels maps the --log-level values to slog levels.
+var logLevels = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+}
+
+// newLogger returns the logger for warnings and diagnostics, writing to w at
+// the --log-level and in the --log-format of cfg. Without --log-level,
+// --quiet keeps only errors and --verbose adds info records to the default
+// of warnings.
+func newLogger(w io.Writer, cfg config) (*slog.Logger, error) {
+	name := cfg.logLevel
+	switch {
+	case name != "":
+	case cfg.quiet:
+		name = "error"
+	case cfg.verbose:
+		name = "info"
+	default:
+		name = "warn"
+	}
+	level, ok := logLevels[name]
+	if !ok {
+		return nil, optionError("--log-level", fmt.Errorf("invalid --log-level %q (want error, warn, info or debug)", name))
+	}
+	switch cfg.logFormat {
+	case "", "text":
+		return slog.New(&textHandler{w: w, level: level, mu: new(sync.Mutex)}), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), nil
+	}
+	return nil, optionError("--log-format", fmt.Errorf("invalid --log-format %q (want text or json)", cfg.logFormat))
+}
+
+// logger returns the logger of cfg, or one discarding everything when it has
+// none, as in tests that build a config by hand.
+func (cfg config) logger() *slog.Logger {
+	if cfg.log == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return cfg.log
+}
+
+// levelLabels are the prefixes textHandler writes for each level.
+var levelLabels = map[slog.Level]string{
+	slog.LevelError: "[Error]",
+	slog.LevelWarn:  "[Warning]",
+	slog.LevelInfo:  "[Info]",
+	slog.LevelDebug: "[Debug]",
+}
+
+// textHandler writes records for people: one "[Warning] message" line each.
+// Messages are whole sentences naming the file they are about; the attributes
+// repeat them as fields for --log-format=json and are not written.
+type textHandler struct {
+	w     io.Writer
+	level slog.Level
+	mu    *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	label, ok := levelLabels[r.Level]
+	if !ok {
+		label = "[" + r.Level.String() + "]"
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, label, r.Message)
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *textHandler) WithGroup(string) slog.Handler { return h }
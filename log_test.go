@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestNewLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{"default", config{}, "[Error] e\n[Warning] w\n"},
+		{"quiet", config{quiet: true}, "[Error] e\n"},
+		{"verbose", config{verbose: true}, "[Error] e\n[Warning] w\n[Info] i\n"},
+		{"debug", config{logLevel: "debug"}, "[Error] e\n[Warning] w\n[Info] i\n[Debug] d\n"},
+		{"level beats quiet", config{quiet: true, logLevel: "info"}, "[Error] e\n[Warning] w\n[Info] i\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log, err := newLogger(&out, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.Error("e")
+			log.Warn("w")
+			log.Info("i")
+			log.Debug("d")
+			if out.String() != tt.want {
+				t.Errorf("logged %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	log, err := newLogger(&out, config{logFormat: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logLossyLines(log, "a.ini", []int{3})
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("logged %q, not a JSON object: %v", out.String(), err)
+	}
+	if record["level"] != "WARN" || record["file"] != "a.ini" || record["line"] != 3.0 {
+		t.Errorf("logged %v, want a WARN record with file a.ini and line 3", record)
+	}
+}
+
+func TestNewLoggerInvalid(t *testing.T) {
+	for _, cfg := range []config{{logLevel: "trace"}, {logFormat: "xml"}} {
+		var oe *format.OptionError
+		if _, err := newLogger(&bytes.Buffer{}, cfg); !errors.As(err, &oe) {
+			t.Errorf("newLogger(%+v) = %v, want an OptionError", cfg, err)
+		}
+	}
+}
+
+func TestLogsStayOffStdout(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(path, []byte("a=1\nlong=2  two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = saved }()
+
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--log-level=debug", "--log-format=json", path})
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = saved
+	got, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a    = 1\nlong = 2 two\n"; string(got) != want {
+		t.Errorf("stdout = %q, want only the formatted file %q", got, want)
+	}
+	for _, want := range []string{`"level":"WARN"`, `"level":"INFO"`, `"level":"DEBUG"`, `"status":"formatted"`, `"duration":`} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, missing %s", stderr.String(), want)
+		}
+	}
+}
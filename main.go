@@ -3,264 +3,1275 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/language"
+	"golang.org/x/text/transform"
+
+	"github.com/thecrazygm/inifmt/format"
 )
 
 // config holds the application configuration.
 type config struct {
-	write       bool
-	perSection  bool
-	singleSpace bool
+	write           bool
+	check           bool
+	diff            bool
+	checkFormat     string
+	source          sourceOptions
+	output          string
+	outputDir       string
+	copyUnchanged   bool
+	toUTF8          bool
+	lineEnding      string
+	canonical       bool
+	hash            bool
+	hashRaw         bool
+	preset          string
+	listPresets     bool
+	showConfig      string
+	summary         bool
+	checkIgnoreEOL  bool
+	since           string
+	files           int          // number of file arguments, for the conflicts of several files
+	header          func() error // among several files, writes the line naming the file before its output
+	progress        string
+	quiet           bool
+	stdinFilename   string
+	timings         int
+	logLevel        string
+	logFormat       string
+	log             *slog.Logger
+	verbose         bool
+	explain         bool
+	report          string
+	to              string
+	from            string
+	csvComments     bool
+	dialect         string
+	embedded        string
+	nest            string
+	keepCompressed  bool
+	color           string
+	noConfig        bool
+	cache           bool
+	cacheDir        string
+	noCache         bool
+	cacheSettings   string // the options part of cache keys, when the cache is on
+	force           bool
+	forceLossy      bool
+	verify          bool
+	redact          bool
+	redactKeys      []string
+	matchKeys       string
+	pruneDefaults   string
+	ignoreKeys      string
+	noDefaultRedact bool
+	format          format.Options
 }
 
-// formatConfig holds formatting configuration.
-type formatConfig struct {
-	perSection bool
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(exitCode(err))
+	}
 }
 
-func main() {
+// exitError carries a specific process exit status out of a command. A nil err
+// means the status is the whole message and nothing is printed. Commands
+// returning it set SilenceErrors so the error is printed only once, by main.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode maps an error returned by a command to the process exit status:
+// 2 for invalid flags and output --verify rejects, 130 when interrupted and 1
+// for any other failure.
+// Errors carried by an exitError are printed here since their commands
+// silence cobra's own error output.
+func exitCode(err error) int {
+	var ee *exitError
+	var oe *format.OptionError
+	var ve *format.VerifyError
+	switch {
+	case errors.As(err, &ee):
+		if ee.err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", ee.err)
+		}
+		return ee.code
+	case errors.As(err, &oe), errors.As(err, &ve):
+		return 2
+	case errors.Is(err, format.ErrCanceled):
+		return 130
+	}
+	return 1
+}
+
+// newRootCmd builds the inifmt command and its flags.
+func newRootCmd() *cobra.Command {
 	var cfg config
 	rootCmd := &cobra.Command{
-		Use:   "inifmt [file]",
+		Use:   "inifmt [file...]",
 		Short: "Aligns '=' signs in INI-style files for readability.",
 		Long: `inifmt is a tool to neatly align '=' signs in INI-style configuration files.
 
 If a file is provided as an argument, it will be read and formatted.
 If no file is provided, input will be read from stdin (e.g., pipe or redirect).
+Several files are formatted one after another, each under a '==> file <=='
+header unless --write rewrites each in place; one that cannot be read does not
+stop the others.
 
 By default, alignment is global (across the whole file).
 Use --per-section/-s to align within each section independently.
-Use --single-space/-u to remove formatting and ensure only a single space around '='.`,
-		Args: cobra.MaximumNArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(cfg, args)
+Use --per-block/-b and --group-by-comments to also restart alignment at blank lines
+and full-line comments; they compose with --per-section.
+Use --single-space/-u to remove formatting and ensure only a single space around '='.
+Use --encoding for files that are not UTF-8; output keeps the input encoding unless --to-utf8 is given.
+UTF-16 and UTF-8 input starting with a byte order mark is detected automatically.
+Use --expand-env to substitute ${VAR} and $VAR references in values from the environment.
+Use --to=markdown or --to=html to render the file as a document for reading.
+Use --to=flat for one 'section.key = value' line per key, and --from=flat to turn
+such lines back into a sectioned file.
+Use --to=csv to tabulate the keys of one or more files for spreadsheets.
+Use --since REF to format only the files changed since a git revision.
+Use --check in CI to exit 1, writing nothing, when a file is not formatted.
+Use --diff to review the changes formatting would make as a unified diff.
+The dialect is detected from the file name (.reg, .gitconfig, .desktop, systemd
+units, setup.cfg, .env, .properties, my.cnf) or, failing that, the content;
+--verbose reports the choice and --dialect overrides it.
+In Markdown files (.md) only the ` + "```ini and ```cfg" + ` code blocks are formatted.
+Gzip-compressed input is decompressed; --write compresses the result again.
+The file may also be an http(s) URL, which is fetched and formatted to stdout
+or, with -o, to a local file.
+Output to a terminal is syntax-highlighted unless NO_COLOR is set or --color=never is given.
+
+Settings are also read from a ` + projectConfigName + ` file in the directory of the
+formatted file or one of its parents. Its keys are flag names, e.g.
+sort-keys = ["aliases", "hosts"]; flags given on the command line win.
+Personal defaults can be kept in $XDG_CONFIG_HOME/inifmt/config.toml
+(~/.config/inifmt/config.toml), below the project config in precedence.
+
+Use --canonical for a fully canonical form suitable for golden-file comparison:
+sections sorted, keys sorted within sections, duplicate keys resolved keeping the
+last value, comments stripped, exactly one blank line between sections and none
+elsewhere, single-space style, LF line endings and a final newline. It is shorthand
+for ` + presetFlags(canonicalPreset) + `;
+any of these flags given explicitly overrides the preset.
+
+Use --preset to start from a named bundle of options: aligned (the defaults),
+dense, tidy or canonical. Flags and project config settings override the
+bundle's individual options; --list-presets shows what each one sets.
+
+Use --show-config [file] to see the value every setting ends up with and where
+it came from: a flag, the NO_COLOR environment variable, a config file line,
+a preset, the file's dialect or the default.`,
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeFiles,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			defer func() { usageFor(cmd, err) }()
+			if cfg.listPresets {
+				return writePresets(cmd.OutOrStdout())
+			}
+			if cfg.since != "" {
+				return exitStatus(cmd, runSince(cmd, &cfg, args))
+			}
+			cfg.files = len(args)
+			if len(args) > 1 && cfg.to != "csv" {
+				return exitStatus(cmd, runEach(cmd, &cfg, args))
+			}
+			filename := ""
+			switch {
+			case len(args) > 0 && cfg.stdinFilename != "":
+				return optionError("--stdin-filename", errors.New("--stdin-filename names stdin and cannot be combined with a file argument"))
+			case len(args) > 0:
+				filename = args[0]
+			case cfg.write && cfg.stdinFilename == "" && givenOnCommandLine(cmd.Flags().Lookup("write")):
+				return optionError("--write", errors.New("--write needs a file to write to, but the input is stdin; give the file as an argument or name it with --stdin-filename"))
+			default:
+				// Config lookup and dialect detection go by the name.
+				filename = cfg.stdinFilename
+			}
+			if err := resolveSettings(cmd, &cfg, filename); err != nil {
+				return err
+			}
+			switch cfg.showConfig {
+			case "":
+			case "text", "json":
+				return writeSettings(cmd.OutOrStdout(), effectiveSettings(cmd.Flags()), cfg.showConfig)
+			default:
+				return optionError("--show-config", fmt.Errorf("invalid --show-config %q (want text or json)", cfg.showConfig))
+			}
+			return exitStatus(cmd, run(cmd.Context(), cfg, args))
 		},
 	}
 
-	rootCmd.Flags().BoolVarP(&cfg.write, "write", "w", false, "Write changes back to the file (if file argument is given)")
-	rootCmd.Flags().BoolVarP(&cfg.perSection, "per-section", "s", false, "Align '=' within each INI section independently")
-	rootCmd.Flags().BoolVarP(&cfg.singleSpace, "single-space", "u", false, "Remove formatting and ensure only a single space around '='")
+	rootCmd.Flags().BoolVarP(&cfg.write, "write", "w", false, "Write changes back to the file (if file arguments are given)")
+	rootCmd.Flags().BoolVar(&cfg.check, "check", false, "Write nothing and exit 1 when a file is not formatted, naming it on stderr")
+	rootCmd.Flags().BoolVar(&cfg.diff, "diff", false, "Print a unified diff of the changes formatting would make instead of the output, and exit 1 when there are any")
+	rootCmd.Flags().StringVar(&cfg.checkFormat, "format", "text", "How --check reports files: 'text', or 'github' for GitHub Actions annotations (the default when GITHUB_ACTIONS=true)")
+	rootCmd.Flags().StringVarP(&cfg.output, "output", "o", "", "Write the output to this file instead of stdout")
+	rootCmd.Flags().StringVar(&cfg.outputDir, "output-dir", "", "Write each formatted file to the same relative path under this directory, leaving the original untouched")
+	rootCmd.Flags().BoolVar(&cfg.copyUnchanged, "copy-unchanged", false, "With --output-dir, also copy skipped files, such as binary ones, so the directory holds every input")
+	rootCmd.Flags().StringVar(&cfg.stdinFilename, "stdin-filename", "", "Path the input on stdin comes from: used to find the config and dialect and in messages, and written with --write")
+	rootCmd.Flags().StringVar(&cfg.since, "since", "", "Format only the INI files that differ from this git revision in the working tree, and untracked ones; file arguments narrow the search to those paths")
+	rootCmd.Flags().BoolVarP(&cfg.format.PerSection, "per-section", "s", false, "Align '=' within each INI section independently")
+	rootCmd.Flags().BoolVarP(&cfg.format.PerBlock, "per-block", "b", false, "Restart alignment after every blank line")
+	rootCmd.Flags().BoolVar(&cfg.format.GroupByComments, "group-by-comments", false, "Restart alignment at every full-line comment")
+	rootCmd.Flags().BoolVarP(&cfg.format.SingleSpace, "single-space", "u", false, "Remove formatting and ensure only a single space around '='")
+	rootCmd.PersistentFlags().StringVar(&cfg.source.encoding, "encoding", "utf-8", "Character encoding of the input: latin1, windows-1252, utf-8 or utf-16")
+	rootCmd.PersistentFlags().StringArrayVar(&cfg.source.headers, "header", nil, "HTTP header sent when an input is a URL, as 'Name: value' (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&cfg.source.maxSize, "max-size", "10M", "Largest URL response or decompressed input accepted, in bytes or with a K, M or G suffix")
+	rootCmd.Flags().BoolVar(&cfg.toUTF8, "to-utf8", false, "Write output as UTF-8 regardless of the input encoding")
+	rootCmd.Flags().BoolVar(&cfg.format.ExpandEnv, "expand-env", false, "Substitute ${VAR} and $VAR in values from the environment ($$ is a literal $)")
+	rootCmd.Flags().BoolVar(&cfg.format.EmptyUnset, "empty-unset", false, "With --expand-env, substitute unset variables with empty strings instead of failing")
+	rootCmd.Flags().BoolVar(&cfg.format.SortSections, "sort-sections", false, "Sort sections by name (the preamble and pinned sections stay first)")
+	rootCmd.Flags().StringSliceVar(&cfg.format.PinnedSections, "pinned-sections", []string{"DEFAULT"}, "With --sort-sections, keep these sections first in the given order (case-insensitive)")
+	rootCmd.Flags().StringSliceVar(&cfg.format.SortKeys, "sort-keys", nil, "Sort keys within each blank-line-delimited block of the given sections (names or globs; all when bare); comments above a key move with it")
+	rootCmd.Flags().Lookup("sort-keys").NoOptDefVal = "*"
+	rootCmd.Flags().StringVar(&cfg.format.SortCase, "sort-case", format.SortCaseSensitive, "Case handling when sorting sections, keys and list items: 'sensitive', or 'insensitive' to fold case and keep names equal but for case in their original order")
+	rootCmd.Flags().StringVar(&cfg.format.Collate, "collate", format.CollateBytes, "Sort order of sections, keys and list items: 'bytes', or 'unicode' for case-insensitive, accent-aware ordering")
+	rootCmd.Flags().StringVar(&cfg.format.CollateLocale, "collate-locale", "", "With --collate=unicode, sort by the rules of this locale (e.g. de, sv)")
+	rootCmd.Flags().BoolVar(&cfg.format.GroupByPrefix, "group-by-prefix", false, "With --sort-keys, sort each whole section and put a blank line between runs of keys with different prefixes (e.g. db_host, db_port)")
+	rootCmd.Flags().StringVar(&cfg.format.GroupSeparators, "group-separators", format.DefaultGroupSeparators, "Characters ending the key prefix --group-by-prefix groups by")
+	rootCmd.Flags().BoolVar(&cfg.format.UnicodeEquals, "normalize-unicode-delimiters", false, "Treat full-width (＝) and other Unicode equals signs delimiting keys as '=' and write them as '='")
+	rootCmd.Flags().IntVar(&cfg.format.WrapValues, "wrap-values", 0, "Break values past this column onto continuation lines at commas or spaces, in dialects with continuation lines (80 when bare)")
+	rootCmd.Flags().Lookup("wrap-values").NoOptDefVal = "80"
+	rootCmd.Flags().IntVar(&cfg.format.InlineCommentGap, "inline-comment-gap", 0, "Put exactly this many spaces between a value and its inline comment (0 leaves one)")
+	rootCmd.Flags().BoolVar(&cfg.format.JoinContinuations, "join-continuations", false, "Put values continued over several lines back on one line, moving comments between their lines above it")
+	rootCmd.Flags().StringVar(&cfg.format.JoinSeparator, "join-separator", "space", "What --join-continuations puts between the lines of a value: 'space' or 'none'")
+	rootCmd.Flags().IntVar(&cfg.format.TabWidth, "tab-width", format.DefaultTabWidth, "Columns between tab stops when measuring keys that contain tabs for alignment, and for --retab")
+	rootCmd.Flags().StringVar(&cfg.format.Retab, "retab", "", "Rewrite the leading whitespace of comments, continuation lines and other indented lines with 'spaces' or 'tabs'; padding and values are never touched")
+	rootCmd.Flags().StringVar(&cfg.format.SplitOn, "split-on", "first", "Which '=' separates key from value: 'first' or 'last'")
+	rootCmd.Flags().StringSliceVar(&cfg.format.Operators, "operators", nil, "Assignment operators to keep whole and align on their '=', e.g. '+=,?=,:='")
+	rootCmd.Flags().BoolVar(&cfg.format.NormalizeNumbers, "normalize-numbers", false, "Strip redundant leading zeros from decimal integer values and normalize the case of hex ones")
+	rootCmd.Flags().StringVar(&cfg.format.HexCase, "hex-case", "lower", "Hex digits for --normalize-numbers: 'lower' (0x1a) or 'upper' (0x1A)")
+	rootCmd.Flags().BoolVar(&cfg.format.NormalizeLists, "normalize-lists", false, "Rewrite list values with one separator and a single space between items")
+	rootCmd.Flags().StringVar(&cfg.format.ListSeparator, "list-separator", ",", "Item separator for --normalize-lists: ',', ';' or 'space'")
+	rootCmd.Flags().StringVar(&cfg.format.ListTrailingComma, "list-trailing-comma", "keep", "With --normalize-lists, 'keep' or 'drop' a trailing separator")
+	rootCmd.Flags().StringSliceVar(&cfg.format.SortListValues, "sort-list-values", nil, "Sort the list items of these keys (comma-separated, optionally section.key qualified)")
+	rootCmd.Flags().BoolVar(&cfg.format.UniqueListValues, "unique-list-values", false, "With --sort-list-values, drop duplicate list items")
+	rootCmd.Flags().StringSliceVar(&cfg.format.OnlySections, "only-sections", nil, "Format only these sections (names or globs; @preamble for keys before the first header) and leave the rest untouched")
+	rootCmd.Flags().StringVar(&cfg.matchKeys, "match-keys", "", "Realign only the key lines whose key matches this regex, aligned among themselves, and leave every other line untouched")
+	rootCmd.Flags().StringVar(&cfg.ignoreKeys, "ignore-keys", "", "Leave the key lines whose key matches this regex untouched, and every line but the other key lines")
+	rootCmd.Flags().StringVar(&cfg.format.DefaultSection, "default-section", "", "Move keys before the first section header into this section, creating it at the top if needed")
+	rootCmd.Flags().StringVar(&cfg.nest, "nest", "", "Turn the dot-separated prefixes of preamble keys into sections: the first N components, or all but the last when bare ('all')")
+	rootCmd.Flags().Lookup("nest").NoOptDefVal = "all"
+	rootCmd.Flags().BoolVar(&cfg.format.Flatten, "flatten", false, "Remove section headers, prefixing each key with its section name and a dot; comments and order are kept")
+	rootCmd.Flags().StringVar(&cfg.format.DedupeKeys, "dedupe-keys", "", "Resolve duplicate keys within a section, keeping the 'first' or 'last' occurrence")
+	rootCmd.Flags().BoolVar(&cfg.format.Unique, "unique", false, "Remove key lines repeating an earlier line of their section with the same key and value; not in systemd units")
+	rootCmd.Flags().BoolVar(&cfg.keepCompressed, "keep-compressed", false, "Write gzip-compressed output to stdout when the input is compressed")
+	rootCmd.Flags().StringVar(&cfg.dialect, "dialect", "auto", "File dialect: "+strings.Join(format.DialectNames(), ", ")+", or 'auto' to detect it from the file name or content")
+	rootCmd.Flags().StringVar(&cfg.embedded, "embedded", "auto", "Format only the INI code blocks of a document: 'markdown', 'none', or 'auto' for markdown in .md files")
+	rootCmd.Flags().StringSliceVar(&cfg.format.CommentPrefixes, "comment-prefixes", format.DefaultCommentPrefixes, "Prefixes that start a full-line comment, e.g. '//,;,#' or 'REM'")
+	rootCmd.Flags().BoolVar(&cfg.format.AlignCommentIndent, "align-comment-indent", false, "Indent full-line comments like the key below them; section-level comments go to column 0")
+	rootCmd.Flags().BoolVar(&cfg.format.AlignPairs, "align-pairs", false, "Align the 'Name: value;' pairs of Inno Setup style lines in columns within each section")
+	rootCmd.Flags().BoolVar(&cfg.format.StripComments, "strip-comments", false, "Remove full-line comments and trailing text after section headers")
+	rootCmd.Flags().BoolVar(&cfg.format.RemoveEmptyValues, "remove-empty-values", false, "Remove key lines whose value is empty, such as 'option ='; refused in systemd units")
+	rootCmd.Flags().BoolVar(&cfg.format.RemoveEmptyQuoted, "empty-quoted", false, "With --remove-empty-values, count quoted empty values such as 'key = \"\"' as empty too")
+	rootCmd.Flags().BoolVar(&cfg.format.RemoveWithComments, "with-comments", false, "With --remove-empty-values, also remove the comments directly above removed keys")
+	rootCmd.Flags().StringVar(&cfg.pruneDefaults, "prune-defaults", "", "Remove key lines whose section, key and value match this defaults file, and the sections left empty")
+	rootCmd.Flags().BoolVar(&cfg.format.PruneEmptySections, "prune-empty-sections", false, "Remove sections without keys, with the comments directly above their headers")
+	rootCmd.Flags().BoolVar(&cfg.format.KeepCommentedSections, "keep-commented", false, "With --prune-empty-sections, keep sections that still hold comments")
+	rootCmd.Flags().StringVar(&cfg.format.BlankLines, "blank-lines", "keep", "Blank line handling: 'keep', 'squeeze' runs into one, or 'sections' for one blank line between sections only")
+	rootCmd.Flags().StringVar(&cfg.lineEnding, "line-ending", "lf", "Line ending of the output: 'lf', 'crlf', or 'auto' to keep the input's")
+	rootCmd.Flags().BoolVar(&cfg.redact, "redact", false, "Mask values of secret-looking keys (password, passwd, secret, token, api_key, private_key)")
+	rootCmd.Flags().StringSliceVar(&cfg.redactKeys, "redact-keys", nil, "Additional key regexes to redact (case-insensitive); implies --redact")
+	rootCmd.Flags().BoolVar(&cfg.noDefaultRedact, "no-default-redact-keys", false, "Redact only keys matching --redact-keys, not the default patterns")
+	rootCmd.Flags().BoolVar(&cfg.format.RedactReveal, "redact-reveal", false, "Keep the first and last two characters of redacted values")
+	rootCmd.Flags().BoolVar(&cfg.format.KeepLossy, "no-lossy", false, "Leave key lines untouched when formatting would change their value, such as collapsing whitespace inside it")
+	rootCmd.Flags().BoolVar(&cfg.forceLossy, "force-lossy", false, "Change values that formatting alters, such as collapsed whitespace, without warning")
+	rootCmd.Flags().BoolVar(&cfg.force, "force", false, "Allow --write together with destructive options such as --redact")
+	rootCmd.Flags().BoolVar(&cfg.verify, "verify", false, "Re-read the formatted output and refuse to write it, exiting 2, when its sections, keys and values differ from the input beyond what the options change on purpose")
+	rootCmd.Flags().StringVar(&cfg.to, "to", "ini", "Output format: 'ini', 'flat' for one section.key = value line per key, 'csv' for a table of every key, or 'markdown' or 'html' to render the file as a document")
+	rootCmd.Flags().BoolVar(&cfg.csvComments, "csv-comments", false, "With --to=csv, add a column with each key's inline comment")
+	rootCmd.Flags().StringVar(&cfg.from, "from", "ini", "Input format: 'ini', or 'flat' for section.key = value lines as written by --to=flat")
+	rootCmd.Flags().StringVar(&cfg.color, "color", "auto", "Colorize output on a terminal: 'auto', 'always' or 'never'")
+	rootCmd.Flags().Lookup("color").NoOptDefVal = "always"
+	rootCmd.Flags().BoolVar(&cfg.noConfig, "no-config", false, "Ignore the project config file ("+projectConfigName+") and the user config file")
+	rootCmd.Flags().BoolVar(&cfg.cache, "cache", false, "With --write, --check or --diff, remember which files are already formatted and skip them on later runs while their content, the options and inifmt stay the same")
+	rootCmd.Flags().StringVar(&cfg.cacheDir, "cache-dir", "", "Keep the --cache entries in this directory instead of inifmt under the user cache directory; implies --cache")
+	rootCmd.Flags().BoolVar(&cfg.noCache, "no-cache", false, "Neither read nor write the cache, even when a config file turns it on")
+	rootCmd.Flags().BoolVar(&cfg.canonical, "canonical", false, "Produce a fully canonical form (see above for the options it implies)")
+	rootCmd.Flags().BoolVar(&cfg.hash, "hash", false, "Print the SHA-256 of each file's canonical form (version "+strconv.Itoa(canonicalVersion)+", as --canonical formats it) instead of the output, as sha256sum does")
+	rootCmd.Flags().BoolVar(&cfg.hashRaw, "hash-raw", false, "Print the SHA-256 of each file's formatted output instead of the output, as sha256sum does")
+	rootCmd.Flags().StringVar(&cfg.preset, "preset", "", "Start from a named bundle of options: aligned, dense, tidy or canonical")
+	rootCmd.Flags().StringVar(&cfg.showConfig, "show-config", "", "Print every setting's final value and where it came from ('text' or 'json'), then exit")
+	rootCmd.Flags().Lookup("show-config").NoOptDefVal = "text"
+	rootCmd.Flags().BoolVar(&cfg.summary, "summary", false, "Print a summary of the files examined, formatted, unchanged, skipped and failed to stderr")
+	rootCmd.Flags().BoolVar(&cfg.checkIgnoreEOL, "check-ignore-eol", false, "Count a file whose output differs from it only in line endings or the final newline as unchanged")
+	rootCmd.Flags().StringVar(&cfg.progress, "progress", "auto", "Show a progress line on a terminal: 'always', 'never', or 'auto' for runs of more than "+strconv.Itoa(progressThreshold)+" files")
+	rootCmd.Flags().Lookup("progress").NoOptDefVal = "always"
+	rootCmd.Flags().BoolVarP(&cfg.quiet, "quiet", "q", false, "Print no summary or warnings on stderr (--log-level=error)")
+	rootCmd.Flags().StringVar(&cfg.logLevel, "log-level", "", "Log records of this level and above on stderr: error, warn, info or debug (default warn; error with --quiet, info with --verbose)")
+	rootCmd.Flags().StringVar(&cfg.logFormat, "log-format", "text", "Format of log records: 'text' for one line each, or 'json' for one JSON object each")
+	rootCmd.Flags().BoolVarP(&cfg.verbose, "verbose", "v", false, "Report decisions such as the detected dialect on stderr (--log-level=info)")
+	rootCmd.Flags().BoolVar(&cfg.explain, "explain", false, "Explain on stderr how each group of lines was aligned and which lines were left out")
+	rootCmd.Flags().StringVar(&cfg.report, "report", "", "Write a JSON report of every file's outcome and the totals to this file")
+	rootCmd.Flags().IntVar(&cfg.timings, "timings", 0, "Time the read, format and write phases of every file and list the slowest on stderr (10 when bare); with --report, add the times to it")
+	rootCmd.Flags().Lookup("timings").NoOptDefVal = strconv.Itoa(defaultSlowestFiles)
+	rootCmd.Flags().BoolVar(&cfg.listPresets, "list-presets", false, "List the presets and the options each one implies, then exit")
+
+	rootCmd.RegisterFlagCompletionFunc("preset", completePresets)
+	rootCmd.RegisterFlagCompletionFunc("dialect", cobra.FixedCompletions(append([]cobra.Completion{"auto"}, format.DialectNames()...), cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("sort-case", cobra.FixedCompletions([]cobra.Completion{format.SortCaseSensitive, format.SortCaseInsensitive}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("collate", cobra.FixedCompletions([]cobra.Completion{format.CollateBytes, format.CollateUnicode}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("embedded", cobra.FixedCompletions([]cobra.Completion{"auto", "markdown", "none"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("to", cobra.FixedCompletions([]cobra.Completion{"ini", "flat", "csv", "markdown", "html"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]cobra.Completion{"error", "warn", "info", "debug"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]cobra.Completion{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("retab", cobra.FixedCompletions([]cobra.Completion{"spaces", "tabs"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("join-separator", cobra.FixedCompletions([]cobra.Completion{"space", "none"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("from", cobra.FixedCompletions([]cobra.Completion{"ini", "flat"}, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(newKeysCmd(&cfg))
+	rootCmd.AddCommand(newHasCmd(&cfg))
+	rootCmd.AddCommand(newEnvCmd(&cfg))
+	rootCmd.AddCommand(newToFlagsCmd(&cfg))
+	rootCmd.AddCommand(newApplyCmd(&cfg))
+	rootCmd.AddCommand(newGrepCmd(&cfg))
+	rootCmd.AddCommand(newLintCmd(&cfg))
+	rootCmd.AddCommand(newCommentCmd(&cfg))
+	rootCmd.AddCommand(newUncommentCmd(&cfg))
+	rootCmd.AddCommand(newEnsureCmd(&cfg))
+	rootCmd.AddCommand(newDoctorCmd(&cfg))
+	rootCmd.AddCommand(newMerge3Cmd(&cfg))
+	rootCmd.SetFlagErrorFunc(flagError)
+
+	return rootCmd
+}
+
+// resolveSettings completes cfg, through the flags of cmd, with the settings
+// for filename that the command line leaves open: those of its config files,
+// the preset and its dialect.
+func resolveSettings(cmd *cobra.Command, cfg *config, filename string) error {
+	// Logs go to stderr only, never into the formatted output.
+	var err error
+	if cfg.log, err = newLogger(cmd.ErrOrStderr(), *cfg); err != nil {
+		return err
+	}
+	var sections []sectionConfig
+	if !cfg.noConfig {
+		if sections, err = applyConfigs(cmd.Flags(), filename, cfg.log); err != nil {
+			return err
+		}
+		// The config files may set the log level and format.
+		if cfg.log, err = newLogger(cmd.ErrOrStderr(), *cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.hash {
+		if cfg.preset != "" && cfg.preset != "canonical" {
+			return optionError("--hash", fmt.Errorf("--hash and --preset=%s cannot be combined: --hash digests the canonical form", cfg.preset))
+		}
+		cfg.preset = "canonical"
+		cfg.logger().Info(fmt.Sprintf("--hash: digesting canonical form version %d", canonicalVersion))
+	}
+	if cfg.canonical {
+		if cfg.preset != "" && cfg.preset != "canonical" {
+			return optionError("--canonical", fmt.Errorf("--canonical and --preset=%s cannot be combined", cfg.preset))
+		}
+		cfg.preset = "canonical"
+	}
+	if cfg.preset != "" {
+		p, err := lookupPreset(cfg.preset)
+		if err != nil {
+			return err
+		}
+		if err := applyPreset(cmd.Flags(), p); err != nil {
+			return err
+		}
+	}
+	if err := applyDialect(cmd.Flags(), cfg, filename); err != nil {
+		return err
+	}
+	if len(sections) > 0 {
+		if cfg.format.Sections, err = sectionOptions(cmd.Flags(), cfg, sections); err != nil {
+			return err
+		}
+	}
+	if len(cfg.format.OnlySections) > 0 && !cmd.Flags().Changed("line-ending") {
+		// The unselected sections must stay byte-for-byte identical.
+		if err := setFlag(cmd.Flags(), "line-ending", "auto", "only-sections"); err != nil {
+			return err
+		}
+	}
+	if cfg.check {
+		if cfg.checkFormat, err = findingsFormat(cfg.checkFormat, cmd.Flags().Changed("format")); err != nil {
+			return optionError("--format", err)
+		}
+	}
+	if cfg.cacheEnabled() {
+		cfg.cacheSettings = cacheSettings(cmd.Flags(), sections)
+	}
+	return nil
+}
+
+// usageFor has cobra print the usage of cmd after err, once its arguments are
+// parsed, only when err is a *format.OptionError: the usage helps with an
+// invalid flag or argument, not with a file that cannot be read.
+func usageFor(cmd *cobra.Command, err error) {
+	var oe *format.OptionError
+	if !errors.As(err, &oe) {
+		cmd.SilenceUsage = true
+	}
+}
+
+// exitStatus returns err, making the command leave an *exitError without a
+// message, such as that of --check finding unformatted files, to main.
+func exitStatus(cmd *cobra.Command, err error) error {
+	var ee *exitError
+	if errors.As(err, &ee) && ee.err == nil {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+	}
+	return err
+}
+
+// flagError reports a command line cobra could not parse as a
+// *format.OptionError naming the flag at fault, when there is one.
+func flagError(_ *cobra.Command, err error) error {
+	var invalid *pflag.InvalidValueError
+	var named interface{ GetSpecifiedName() string }
+	switch {
+	case errors.As(err, &invalid):
+		return optionError("--"+invalid.GetFlag().Name, err)
+	case errors.As(err, &named) && named.GetSpecifiedName() != "":
+		return optionError("--"+named.GetSpecifiedName(), err)
+	}
+	return optionError("", err)
+}
+
+// optionError reports err, an invalid flag value or combination of flags
+// naming flag, as a *format.OptionError.
+func optionError(flag string, err error) error {
+	return &format.OptionError{Option: flag, Err: err}
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+// validateConfig rejects option values outside their allowed set and the
+// flag combinations listed in conflicts.
+func validateConfig(cfg config) error {
+	switch cfg.format.DedupeKeys {
+	case "", "first", "last":
+	default:
+		return optionError("--dedupe-keys", fmt.Errorf("invalid --dedupe-keys %q (want first or last)", cfg.format.DedupeKeys))
+	}
+	for _, p := range cfg.format.SortKeys {
+		if _, err := path.Match(p, ""); err != nil {
+			return optionError("--sort-keys", fmt.Errorf("invalid --sort-keys pattern %q: %w", p, err))
+		}
+	}
+	for _, p := range cfg.format.CommentPrefixes {
+		if strings.TrimSpace(p) != p || p == "" || strings.ContainsAny(p, "=[") {
+			return optionError("--comment-prefixes", fmt.Errorf("invalid --comment-prefixes entry %q", p))
+		}
 	}
+	for _, op := range cfg.format.Operators {
+		if !strings.HasSuffix(op, "=") || len(op) < 2 || strings.ContainsAny(op[:len(op)-1], "=[ \t") {
+			return optionError("--operators", fmt.Errorf("invalid --operators entry %q (want characters followed by '=')", op))
+		}
+	}
+	if cfg.timings < 0 {
+		return optionError("--timings", fmt.Errorf("invalid --timings %d (want a number of files)", cfg.timings))
+	}
+	if cfg.format.WrapValues < 0 {
+		return optionError("--wrap-values", fmt.Errorf("invalid --wrap-values %d (want a column)", cfg.format.WrapValues))
+	}
+	if cfg.format.InlineCommentGap < 0 {
+		return optionError("--inline-comment-gap", fmt.Errorf("invalid --inline-comment-gap %d (want 0 or more spaces)", cfg.format.InlineCommentGap))
+	}
+	if cfg.format.TabWidth < 0 {
+		return optionError("--tab-width", fmt.Errorf("invalid --tab-width %d (want a positive width)", cfg.format.TabWidth))
+	}
+	switch cfg.format.SplitOn {
+	case "", "first", "last":
+	default:
+		return optionError("--split-on", fmt.Errorf("invalid --split-on %q (want first or last)", cfg.format.SplitOn))
+	}
+	switch cfg.format.ListSeparator {
+	case "", ",", ";", "space":
+	default:
+		return optionError("--list-separator", fmt.Errorf("invalid --list-separator %q (want ',', ';' or space)", cfg.format.ListSeparator))
+	}
+	switch cfg.format.HexCase {
+	case "", "lower", "upper":
+	default:
+		return optionError("--hex-case", fmt.Errorf("invalid --hex-case %q (want lower or upper)", cfg.format.HexCase))
+	}
+	switch cfg.format.JoinSeparator {
+	case "", "space", "none":
+	default:
+		return optionError("--join-separator", fmt.Errorf("invalid --join-separator %q (want space or none)", cfg.format.JoinSeparator))
+	}
+	switch cfg.format.Retab {
+	case "", "spaces", "tabs":
+	default:
+		return optionError("--retab", fmt.Errorf("invalid --retab %q (want spaces or tabs)", cfg.format.Retab))
+	}
+	switch cfg.format.ListTrailingComma {
+	case "", "keep", "drop":
+	default:
+		return optionError("--list-trailing-comma", fmt.Errorf("invalid --list-trailing-comma %q (want keep or drop)", cfg.format.ListTrailingComma))
+	}
+	switch cfg.format.BlankLines {
+	case "", "keep", "squeeze", "sections":
+	default:
+		return optionError("--blank-lines", fmt.Errorf("invalid --blank-lines %q (want keep, squeeze or sections)", cfg.format.BlankLines))
+	}
+	switch cfg.format.SortCase {
+	case "", format.SortCaseSensitive, format.SortCaseInsensitive:
+	default:
+		return optionError("--sort-case", fmt.Errorf("invalid --sort-case %q (want sensitive or insensitive)", cfg.format.SortCase))
+	}
+	switch cfg.format.Collate {
+	case "", format.CollateBytes, format.CollateUnicode:
+	default:
+		return optionError("--collate", fmt.Errorf("invalid --collate %q (want bytes or unicode)", cfg.format.Collate))
+	}
+	if cfg.format.CollateLocale != "" {
+		if _, err := language.Parse(cfg.format.CollateLocale); err != nil {
+			return optionError("--collate-locale", fmt.Errorf("invalid --collate-locale %q: %w", cfg.format.CollateLocale, err))
+		}
+	}
+	switch cfg.lineEnding {
+	case "", "lf", "crlf", "auto":
+	default:
+		return optionError("--line-ending", fmt.Errorf("invalid --line-ending %q (want lf, crlf or auto)", cfg.lineEnding))
+	}
+	switch cfg.color {
+	case "", "auto", "always", "never":
+	default:
+		return optionError("--color", fmt.Errorf("invalid --color %q (want auto, always or never)", cfg.color))
+	}
+	switch cfg.progress {
+	case "", "auto", "always", "never":
+	default:
+		return optionError("--progress", fmt.Errorf("invalid --progress %q (want auto, always or never)", cfg.progress))
+	}
+	switch cfg.to {
+	case "", "ini", "flat", "csv", "markdown", "html":
+	default:
+		return optionError("--to", fmt.Errorf("invalid --to %q (want ini, flat, csv, markdown or html)", cfg.to))
+	}
+	switch cfg.embedded {
+	case "", "auto", "none", "markdown":
+	default:
+		return optionError("--embedded", fmt.Errorf("invalid --embedded %q (want auto, markdown or none)", cfg.embedded))
+	}
+	if _, err := parseNest(cfg.nest); err != nil {
+		return optionError("--nest", err)
+	}
+	switch cfg.from {
+	case "", "ini", "flat":
+	default:
+		return optionError("--from", fmt.Errorf("invalid --from %q (want ini or flat)", cfg.from))
+	}
+	return checkConflicts(cfg)
 }
 
 // run executes the main application logic.
-func run(cfg config, args []string) error {
-	// Determine input source
-	var input io.Reader = os.Stdin
+func run(ctx context.Context, cfg config, args []string) error {
+	if cfg.to == "csv" {
+		cfg, err := prepareConfig(cfg)
+		if err != nil {
+			return err
+		}
+		return writeCSV(ctx, os.Stdout, args, cfg)
+	}
 	var filename string
 	if len(args) > 0 {
 		filename = args[0]
-		file, err := os.Open(filename)
-		if err != nil {
-			return fmt.Errorf("opening file: %w", err)
-		}
-		defer file.Close()
-		input = file
 	}
+	return runFiles(ctx, cfg, []fileJob{{filename: filename, cfg: cfg}})
+}
 
-	// Process input
-	scanner := bufio.NewScanner(input)
-	var result []string
-	var processErr error
+// runEach formats files, each with the settings resolved for it from the
+// flags of cmd and the config files and dialect of the file. A file whose
+// settings cannot be resolved, such as one under a malformed config file,
+// fails alone and the others are still formatted.
+func runEach(cmd *cobra.Command, cfg *config, files []string) error {
+	// Flags wrong for every file alike are refused once, before any file.
+	if err := validateConfig(*cfg); err != nil {
+		return err
+	}
+	base := *cfg
+	saved := saveFlags(cmd.Flags())
+	defer func() {
+		*cfg = base
+		restoreFlags(cmd.Flags(), saved)
+	}()
+	jobs := make([]fileJob, 0, len(files))
+	for _, file := range files {
+		*cfg = base
+		restoreFlags(cmd.Flags(), saved)
+		err := resolveSettings(cmd, cfg, file)
+		jobs = append(jobs, fileJob{file, *cfg, err})
+	}
+	// Settings of the run as a whole, such as --summary, come from the
+	// config of the first file like those of a single file would.
+	first := jobs[0]
+	if i := slices.IndexFunc(jobs, func(j fileJob) bool { return j.err == nil }); i >= 0 {
+		first = jobs[i]
+	}
+	if first.cfg.to == "csv" {
+		// The table is one output, which a file left out would spoil.
+		for _, job := range jobs {
+			if job.err != nil {
+				return job.err
+			}
+		}
+		return run(cmd.Context(), first.cfg, files)
+	}
+	return runFiles(cmd.Context(), first.cfg, jobs)
+}
 
-	if cfg.singleSpace {
-		result, processErr = singleSpaceFormat(scanner)
-	} else {
-		fc := formatConfig{
-			perSection: cfg.perSection,
+// prepareConfig checks the settings of cfg, settled for one file, and turns
+// those the dialect rules out or that need parsing into format options.
+func prepareConfig(cfg config) (config, error) {
+	if err := validateConfig(cfg); err != nil {
+		return cfg, err
+	}
+	if d := cfg.format.Dialect; d != nil && format.EmptyValuesMatter(d) {
+		// Empty values reset settings; leave them, but format the rest.
+		removing := cfg.format.RemoveEmptyValues
+		for i := range cfg.format.Sections {
+			removing = removing || cfg.format.Sections[i].Options.RemoveEmptyValues
+			cfg.format.Sections[i].Options.RemoveEmptyValues = false
 		}
-		result, processErr = alignIni(scanner, fc)
+		if removing {
+			cfg.logger().Warn(fmt.Sprintf("--remove-empty-values ignored: empty values reset settings in the %s dialect", d.Name()), "dialect", d.Name())
+			cfg.format.RemoveEmptyValues = false
+		}
+	}
+	if d := cfg.format.Dialect; d != nil && cfg.format.SortSections && format.KeepsSectionOrder(d) {
+		cfg.logger().Info(fmt.Sprintf("--sort-sections ignored: section order matters in the %s dialect", d.Name()), "dialect", d.Name())
+	}
+	if d := cfg.format.Dialect; d != nil && cfg.format.Unique && format.RepeatsKeys(d) {
+		cfg.logger().Info(fmt.Sprintf("--unique ignored: repeated keys add up in the %s dialect", d.Name()), "dialect", d.Name())
+	}
+	if cfg.redact || len(cfg.redactKeys) > 0 {
+		if cfg.write && !cfg.force {
+			// A refusal to lose data rather than a usage error.
+			return cfg, errors.New("--redact would destroy the real values; refusing to combine it with --write without --force")
+		}
+		patterns, err := format.RedactPatterns(cfg.redactKeys, !cfg.noDefaultRedact)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.format.Redact = patterns
+		for i := range cfg.format.Sections {
+			cfg.format.Sections[i].Options.Redact = patterns
+		}
+	}
+	var err error
+	if cfg.format.MatchKeys, err = keyPattern("--match-keys", cfg.matchKeys); err != nil {
+		return cfg, err
+	}
+	if cfg.format.IgnoreKeys, err = keyPattern("--ignore-keys", cfg.ignoreKeys); err != nil {
+		return cfg, err
 	}
+	if cfg.pruneDefaults != "" {
+		in, err := readInput(cfg.pruneDefaults, cfg.source)
+		if err != nil {
+			return cfg, fmt.Errorf("reading --prune-defaults: %w", err)
+		}
+		cfg.format.Defaults = format.ParseKeyValues(in.lines, cfg.format)
+	}
+	cfg.format.Nest, _ = parseNest(cfg.nest)
+	return cfg, nil
+}
 
-	if processErr != nil {
-		return fmt.Errorf("processing input: %w", processErr)
+// keyPattern compiles the regex of --match-keys or --ignore-keys; "" means
+// the flag is not given.
+func keyPattern(flag, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
 	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, optionError(flag, fmt.Errorf("invalid %s regex %q: %w", flag, pattern, err))
+	}
+	return re, nil
+}
 
-	// Handle output
-	if cfg.write && filename != "" {
-		if err := writeToFile(filename, result); err != nil {
-			return fmt.Errorf("writing to file: %w", err)
+// fileJob is a file to format, "" for stdin, with the settings resolved for
+// it.
+type fileJob struct {
+	filename string
+	cfg      config
+	err      error // why its settings could not be resolved; the file fails with it unread
+}
+
+// runFiles formats the files of jobs one after another. A file that fails,
+// to be read or to have its settings settled, does not stop the others; the
+// error returned joins their errors. cfg holds the settings of the run as a
+// whole, such as --report and --summary.
+func runFiles(ctx context.Context, cfg config, jobs []fileJob) error {
+	for i := range jobs {
+		if jobs[i].err != nil {
+			continue
 		}
-	} else {
-		if cfg.write {
-			fmt.Fprintln(os.Stderr, "[Warning] --write ignored when reading from stdin")
+		var err error
+		if jobs[i].cfg, err = prepareConfig(jobs[i].cfg); err != nil {
+			// Wrong settings of a lone file are a usage error of the run;
+			// among several files, the file fails alone.
+			if len(jobs) == 1 {
+				return err
+			}
+			jobs[i].err = err
 		}
-		for _, line := range result {
-			fmt.Println(line)
+	}
+	cache, err := openCache(cfg)
+	if err != nil {
+		return err
+	}
+	report := newRunReport()
+	report.OutputDir = cfg.outputDir
+	bar := newProgressBar(cfg, len(jobs))
+	var errs []error
+	headed := false // whether a file header was written
+	for _, job := range jobs {
+		c, name := job.cfg, job.cfg.displayName(job.filename)
+		if bar != nil {
+			// Log records erase the progress line rather than run into it.
+			c.log, _ = newLogger(bar.writer(os.Stderr), c)
 		}
+		if len(jobs) > 1 {
+			// A file that cannot be read gets no header.
+			c.header = func() error {
+				err := writeFileHeader(os.Stdout, name, !headed)
+				headed = true
+				return err
+			}
+		}
+		start := time.Now()
+		timer := newPhaseTimer(c.timings > 0)
+		var res fileResult
+		err := job.err
+		if err == nil {
+			res, err = formatFile(ctx, c, job.filename, cache, timer)
+		}
+		logFileDone(c, name, res.Status, res.Reason, err, time.Since(start))
+		report.add(name, res.Status, res.Reason, err)
+		report.setTimings(timer.result())
+		report.setRemoved(res.Removed)
+		if c.check && err == nil && res.Status == statusFormatted && (!c.quiet || c.checkFormat == "github") {
+			if err := writeCheckFinding(os.Stdout, bar.writer(os.Stderr), name, res.line, res.Reason, c.checkFormat); err != nil {
+				return err
+			}
+		}
+		bar.add(err != nil)
+		if err != nil {
+			if len(jobs) > 1 {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			errs = append(errs, err)
+		}
+		if errors.Is(err, format.ErrCanceled) {
+			break
+		}
+	}
+	bar.clear()
+	if err := cache.prune(); err != nil {
+		cfg.logger().Warn(fmt.Sprintf("cannot prune the cache: %v", err), "error", err)
+	}
+	report.finish()
+	if cfg.report != "" {
+		if err := report.writeJSON(cfg.report); err != nil {
+			return err
+		}
+	}
+	if (len(report.Files) > 1 || cfg.summary) && !cfg.quiet {
+		if err := report.writeSummary(os.Stderr); err != nil {
+			return err
+		}
+	}
+	if cfg.timings > 0 && !cfg.quiet {
+		if err := report.writeSlowest(os.Stderr, cfg.timings); err != nil {
+			return err
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	if len(errs) == 0 && (cfg.check || cfg.diff) && report.Totals.Formatted > 0 {
+		return &exitError{code: 1}
 	}
+	return errors.Join(errs...)
+}
 
+// writeFileHeader writes the line naming the file whose output follows, as in
+// "==> a.ini <==", set off from the output of the file before unless it is
+// the first.
+func writeFileHeader(w io.Writer, name string, first bool) error {
+	header := "==> " + name + " <==\n"
+	if !first {
+		header = "\n" + header
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
 	return nil
 }
 
-// writeToFile writes lines to the specified file.
-func writeToFile(filename string, lines []string) error {
-	file, err := os.Create(filename)
+// formatFile formats filename, or stdin when it is empty, and writes the
+// result. It reports whether the output differs from the input, or why the
+// file was skipped, and the keys --prune-defaults removed. With --check and
+// --diff the output is not written, as checkFile tells. A file the cache,
+// when not nil, records as already formatted is left alone. timer, if not
+// nil, times the read, format and write phases.
+func formatFile(ctx context.Context, cfg config, filename string, cache *resultCache, timer *phaseTimer) (fileResult, error) {
+	if cfg.write && isURL(filename) {
+		return fileResult{}, optionError("--write", errors.New("--write cannot write back to a URL; use -o to save a formatted copy"))
+	}
+	in, err := readInput(filename, cfg.source)
+	timer.lap(phaseRead)
 	if err != nil {
-		return fmt.Errorf("creating file: %w", err)
+		return fileResult{}, err
+	}
+	if strings.ContainsRune(in.text, 0) {
+		cfg.logger().Warn(fmt.Sprintf("skipping %s: binary file", cfg.displayName(filename)), "file", cfg.displayName(filename), "reason", "binary file")
+		if cfg.outputDir != "" && cfg.copyUnchanged {
+			return fileResult{Status: statusSkipped, Reason: "binary file"}, copyMirrored(cfg.outputDir, filename)
+		}
+		return fileResult{Status: statusSkipped, Reason: "binary file"}, nil
+	}
+	key := cache.key(cfg, filename, in)
+	if cache.formatted(key) {
+		cfg.logger().Debug(fmt.Sprintf("%s: already formatted, as the cache records", cfg.displayName(filename)), "file", cfg.displayName(filename))
+		return fileResult{Status: statusUnchanged}, nil
 	}
-	defer file.Close()
 
-	for _, line := range lines {
-		if _, err := fmt.Fprintln(file, line); err != nil {
-			return fmt.Errorf("writing line: %w", err)
+	if embeddedFormat(cfg, cfg.displayName(filename)) == "markdown" {
+		result, errs := format.Markdown(in.lines, cfg.format)
+		for _, e := range errs {
+			cfg.logger().Warn(fmt.Sprintf("%s: ini block at line %d left as is: %v", cfg.displayName(filename), e.Line, e.Err), "file", cfg.displayName(filename), "line", e.Line, "error", e.Err)
 		}
+		status, reason := statusFormatted, ""
+		if !cfg.toUTF8 {
+			status, reason = outputStatus(in, result, in.outputEOL(cfg.lineEnding), cfg.checkIgnoreEOL)
+		}
+		timer.lap(phaseFormat)
+		if cfg.check || cfg.diff {
+			return checkFile(cfg, filename, in, result, fileResult{Status: status, Reason: reason}, cache, key)
+		}
+		err := writeOutput(cfg, filename, in, result)
+		timer.lap(phaseWrite)
+		if err == nil {
+			cache.cacheFile(cfg, cfg.displayName(filename), key, in, result, in.outputEOL(cfg.lineEnding))
+		}
+		return fileResult{Status: status, Reason: reason}, err
 	}
-	return nil
+
+	result, err := processLines(ctx, filename, in.lines, cfg)
+	if err != nil {
+		return fileResult{}, err
+	}
+	if cfg.explain {
+		if err := explainFile(os.Stderr, cfg, filename, in.lines); err != nil {
+			return fileResult{}, err
+		}
+	}
+	if !cfg.forceLossy && !cfg.format.KeepLossy && !cfg.hashes() && cfg.from != "flat" {
+		logLossyLines(cfg.logger(), cfg.displayName(filename), format.LossyLines(in.lines, cfg.format))
+	}
+	if cfg.format.Unique && cfg.from != "flat" {
+		logDuplicateLines(cfg.logger(), cfg.displayName(filename), format.DuplicateLines(in.lines, cfg.format))
+	}
+	var removed []removedKey
+	if len(cfg.format.Defaults) > 0 && cfg.from != "flat" {
+		removed = defaultKeys(format.DefaultLines(in.lines, cfg.format))
+		logDefaultLines(cfg.logger(), cfg.displayName(filename), removed)
+	}
+	status, reason := statusFormatted, ""
+	if cfg.to == "ini" && !cfg.toUTF8 {
+		status, reason = outputStatus(in, result, in.outputEOL(cfg.lineEnding), cfg.checkIgnoreEOL)
+	}
+	if cfg.check || cfg.diff {
+		timer.lap(phaseFormat)
+		return checkFile(cfg, filename, in, result, fileResult{Status: status, Reason: reason, Removed: removed}, cache, key)
+	}
+	switch cfg.to {
+	case "flat":
+		result = flattenLines(result, cfg.format)
+	case "markdown", "html":
+		result = renderDocument(result, cfg.to, cfg.format)
+	}
+	if useColor(cfg) {
+		result = colorizeLines(result, cfg.format)
+	}
+	timer.lap(phaseFormat)
+
+	err = writeOutput(cfg, filename, in, result)
+	timer.lap(phaseWrite)
+	if err == nil {
+		cache.cacheFile(cfg, cfg.displayName(filename), key, in, result, in.outputEOL(cfg.lineEnding))
+	}
+	return fileResult{Status: status, Reason: reason, Removed: removed}, err
 }
 
-// alignIni aligns INI content according to the given configuration.
-func alignIni(scanner *bufio.Scanner, cfg formatConfig) ([]string, error) {
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// logFileDone logs at debug level how formatting the file name went, in what
+// dialect and how long it took.
+func logFileDone(cfg config, name string, status fileStatus, reason string, err error, elapsed time.Duration) {
+	dialect := format.DialectINI.Name()
+	if cfg.format.Dialect != nil {
+		dialect = cfg.format.Dialect.Name()
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("reading input: %w", err)
+	if err != nil {
+		status, reason = statusFailed, err.Error()
+	}
+	outcome := string(status)
+	if reason != "" {
+		outcome += " (" + reason + ")"
 	}
+	elapsed = elapsed.Round(time.Microsecond)
+	cfg.logger().Debug(fmt.Sprintf("%s: %s in %v, dialect %s", name, outcome, elapsed, dialect),
+		"file", name, "status", status, "reason", reason, "dialect", dialect, "duration", elapsed)
+}
 
-	if len(lines) == 0 { // If all lines were consumed by scanner error or input was empty
-		return make([]string, 0), nil
-	}
-
-	for i, line := range lines {
-		raw := strings.TrimRight(line, " \t")
-		trimmed := strings.TrimSpace(raw)
-		if strings.HasPrefix(trimmed, "[") {
-			if idx := strings.Index(trimmed, "]"); idx != -1 {
-				header := trimmed[:idx+1]
-				rest := strings.TrimSpace(trimmed[idx+1:])
-				if rest != "" {
-					marker := rest[:1]
-					text := strings.TrimSpace(rest[1:])
-					lines[i] = header + " " + marker + " " + text
-				} else {
-					lines[i] = header
-				}
-				continue
-			}
-		}
-		lines[i] = raw
+// logLossyLines warns about the lines of name whose values formatting
+// changed beyond their padding.
+func logLossyLines(log *slog.Logger, name string, numbers []int) {
+	for _, n := range numbers {
+		log.Warn(fmt.Sprintf("%s:%d: value whitespace collapsed; use --no-lossy to keep the line or --force-lossy to silence this", name, n), "file", name, "line", n)
 	}
+}
 
-	if !cfg.perSection {
-		return alignSection(lines), nil
+// logDuplicateLines reports, in verbose output, the lines of name --unique
+// removed.
+func logDuplicateLines(log *slog.Logger, name string, dups []format.Duplicate) {
+	for _, d := range dups {
+		log.Info(fmt.Sprintf("%s:%d: removed duplicate of line %d", name, d.Line, d.First), "file", name, "line", d.Line, "first", d.First)
 	}
+}
 
-	result := make([]string, 0, len(lines))
-	var sectionLines []string
+// logDefaultLines reports, in verbose output, the keys of name
+// --prune-defaults removed.
+func logDefaultLines(log *slog.Logger, name string, removed []removedKey) {
+	for _, k := range removed {
+		log.Info(fmt.Sprintf("%s:%d: removed %s, same as its default", name, k.Line, k.Key), "file", name, "line", k.Line, "key", k.Key)
+	}
+}
 
-	flushSection := func() {
-		if len(sectionLines) > 0 {
-			result = append(result, alignSection(sectionLines)...)
-			sectionLines = nil
-		}
+// parseNest parses a --nest depth: "" for none, "all" or a positive number.
+func parseNest(s string) (int, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "all":
+		return format.NestAll, nil
 	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --nest %q (want all or a positive number)", s)
+	}
+	return n, nil
+}
 
-	for _, line := range lines {
-		raw := strings.TrimRight(line, " \t")
-		trimmed := strings.TrimSpace(raw)
-		if strings.HasPrefix(trimmed, "[") {
-			if idx := strings.Index(trimmed, "]"); idx != -1 {
-				flushSection()
-				header := trimmed[:idx+1]
-				comment := strings.TrimSpace(trimmed[idx+1:])
-				if comment != "" {
-					result = append(result, header+" "+comment)
-				} else {
-					result = append(result, header)
-				}
-				continue
+// embeddedFormat returns the document format whose embedded INI blocks are
+// formatted instead of the whole of filename, or "" to format it as INI.
+func embeddedFormat(cfg config, filename string) string {
+	switch cfg.embedded {
+	case "markdown":
+		return "markdown"
+	case "", "auto":
+		name, _, _ := strings.Cut(filename, "?") // URL query
+		switch path.Ext(strings.TrimSuffix(strings.ToLower(name), ".gz")) {
+		case ".md", ".markdown":
+			if cfg.to == "" || cfg.to == "ini" {
+				return "markdown"
 			}
 		}
-		sectionLines = append(sectionLines, line)
 	}
-	flushSection()
-	// Ensure non-nil return even if all lines were section headers or filtered out
-	if result == nil && len(lines) > 0 {
-		return make([]string, 0), nil
+	return ""
+}
+
+// displayName names filename in messages: stdin is "-", or the
+// --stdin-filename it stands for.
+func (cfg config) displayName(filename string) string {
+	switch {
+	case filename != "":
+		return filename
+	case cfg.stdinFilename != "":
+		return cfg.stdinFilename
+	}
+	return "-"
+}
+
+// processLines converts the input lines of filename from the --from format
+// and formats them, giving up when ctx is done or on an interrupt. The
+// interrupt is caught only while formatting, so that one while reading input
+// or fetching a URL still ends inifmt at once; files are replaced only once
+// their new contents are complete.
+func processLines(ctx context.Context, filename string, lines []string, cfg config) ([]string, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.from == "flat" {
+		var err error
+		if lines, err = unflattenLines(lines, cfg.format); err != nil {
+			return nil, fmt.Errorf("reading flat input: %w", err)
+		}
+	}
+	result, err := format.LinesContext(ctx, lines, cfg.format)
+	if err != nil {
+		return nil, inputError(cfg.displayName(filename), err)
+	}
+	if cfg.verify {
+		if err := verifyLines(cfg, cfg.displayName(filename), lines, result); err != nil {
+			return nil, err
+		}
 	}
 	return result, nil
 }
 
-// alignSection aligns the equals signs in the given lines.
-func alignSection(lines []string) []string {
-	if len(lines) == 0 {
-		return make([]string, 0)
+// verifyFlags name the flags behind the expected differences format.Verify
+// reports.
+var verifyFlags = map[string]string{
+	"Flatten":            "--flatten",
+	"Nest":               "--nest",
+	"DefaultSection":     "--default-section",
+	"Unique":             "--unique",
+	"RemoveEmptyValues":  "--remove-empty-values",
+	"Defaults":           "--prune-defaults",
+	"PruneEmptySections": "--prune-empty-sections",
+	"DedupeKeys":         "--dedupe-keys",
+	"values":             "--expand-env, --normalize-lists, --sort-list-values or --redact",
+	"SortKeys":           "--sort-keys",
+	"SortSections":       "--sort-sections",
+}
+
+// verifyLines checks for --verify that the formatted lines out of the file
+// name hold the same data as the lines in, and reports in verbose output the
+// options that changed it on purpose.
+func verifyLines(cfg config, name string, in, out []string) error {
+	expected, err := format.Verify(in, out, cfg.format)
+	for _, option := range expected {
+		cfg.logger().Info(fmt.Sprintf("%s: --verify: differences expected from %s", name, verifyFlags[option]), "file", name, "option", option)
 	}
+	if err != nil {
+		return fmt.Errorf("%s: --verify: %w; nothing written", name, err)
+	}
+	return nil
+}
 
-	// First pass – determine the maximum key length (excluding indentation) among lines with '='.
-	maxKeyLen := 0
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
-			continue
+// inputError names the file name in err when it is a *format.ParseError that
+// does not name a file yet.
+func inputError(name string, err error) error {
+	var pe *format.ParseError
+	if errors.As(err, &pe) && pe.File == "" {
+		named := *pe
+		named.File = name
+		return &named
+	}
+	return err
+}
+
+// writeOutput encodes lines like the input they came from and writes them back
+// to filename, or for stdin to the --stdin-filename, with --write, to its
+// mirror with --output-dir, or to stdout otherwise.
+func writeOutput(cfg config, filename string, in *input, lines []string) error {
+	outEnc := in.enc
+	if cfg.toUTF8 {
+		outEnc = encoding.Nop
+	}
+	data, err := encodeLines(lines, outEnc, in.outputEOL(cfg.lineEnding))
+	if err != nil {
+		return err
+	}
+	if cfg.hashes() {
+		return writeHash(os.Stdout, data, cfg.displayName(filename))
+	}
+	// With --write, stdin is written to the file --stdin-filename names.
+	target := filename
+	if target == "" {
+		target = cfg.stdinFilename
+	}
+	// Compressed files are written back compressed; stdout gets plain text
+	// unless asked otherwise.
+	if in.gzip != nil && ((cfg.write && target != "") || cfg.outputDir != "" || cfg.keepCompressed) {
+		if data, err = compressOutput(data, in.gzip); err != nil {
+			return err
 		}
-		before, _, ok := strings.Cut(line, "=")
-		if !ok {
-			continue
+	}
+
+	if cfg.outputDir != "" {
+		return writeMirrored(cfg.outputDir, target, data)
+	}
+	if cfg.write && target != "" {
+		if err := writeToFile(target, data); err != nil {
+			return fmt.Errorf("writing to file: %w", err)
+		}
+		return nil
+	}
+	if cfg.output != "" {
+		if err := writeToFile(cfg.output, data); err != nil {
+			return fmt.Errorf("writing to file: %w", err)
 		}
-		key := strings.TrimSpace(before)
-		if l := len(key); l > maxKeyLen {
-			maxKeyLen = l
+		return nil
+	}
+	if cfg.write {
+		cfg.logger().Warn("--write ignored when reading from stdin without --stdin-filename")
+	}
+	if cfg.header != nil {
+		if err := cfg.header(); err != nil {
+			return err
 		}
 	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
 
-	result := make([]string, 0, len(lines))
+// input is a decoded input file split into lines.
+type input struct {
+	text    string // the decoded contents
+	lines   []string
+	eol     string            // line ending of the first line
+	endings []string          // line ending of every line; "" for a last line without one
+	enc     encoding.Encoding // encoding the input was decoded from
+	gzip    *gzipInput        // gzip stream the input was decompressed from, or nil
+}
 
-	for _, line := range lines {
-		original := strings.TrimRight(line, " \t") // drop trailing whitespace
-		trimmed := strings.TrimSpace(original)
+// readInput reads and decodes filename, which may be an http(s) URL, or stdin
+// when filename is empty. A byte order mark takes precedence over the
+// configured encoding.
+func readInput(filename string, src sourceOptions) (*input, error) {
+	enc, err := lookupEncoding(src.encoding)
+	if err != nil {
+		return nil, err
+	}
 
-		// Handle comment / blank lines
-		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
-			result = append(result, original)
-			continue
+	var r io.Reader = os.Stdin
+	if isURL(filename) {
+		body, err := fetchURL(filename, src)
+		if err != nil {
+			return nil, err
 		}
-
-		before, after, ok := strings.Cut(original, "=")
-		if !ok {
-			// Line without '=' – leave as-is (after trimming trailing whitespace)
-			result = append(result, original)
-			continue
+		r = bytes.NewReader(body)
+	} else if filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
 		}
+		defer file.Close()
+		r = file
+	}
+	limit, err := parseSize(src.maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("--max-size: %w", err)
+	}
+	r, gz, err := decompressInput(bufio.NewReader(r), filename, limit)
+	if err != nil {
+		return nil, err
+	}
+	// A byte order mark is unambiguous, so it takes precedence over --encoding.
+	buffered := bufio.NewReader(r)
+	r = buffered
+	if bomEnc := detectBOM(buffered); bomEnc != nil {
+		enc = bomEnc
+	}
+	if enc != encoding.Nop {
+		r = transform.NewReader(r, enc.NewDecoder())
+	}
 
-		key := strings.TrimSpace(before)
-		// Normalize internal whitespace in value
-		right := strings.Join(strings.Fields(after), " ")
-
-		spacesNeeded := max(maxKeyLen-len(key), 0)
-		formatted := key + strings.Repeat(" ", spacesNeeded) + " = " + right
-		result = append(result, formatted)
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
 	}
+	lines, eol := format.SplitLines(string(raw))
+	return &input{text: string(raw), lines: lines, eol: eol, endings: lineEndings(string(raw)), enc: enc, gzip: gz}, nil
+}
 
-	return result
+// outputEOL returns the line ending output uses for a --line-ending value.
+func (in *input) outputEOL(lineEnding string) string {
+	switch lineEnding {
+	case "crlf":
+		return "\r\n"
+	case "auto":
+		return in.eol
+	}
+	return "\n"
 }
 
-// singleSpaceFormat formats lines to have single spaces around '=' and trims trailing whitespace.
-func singleSpaceFormat(scanner *bufio.Scanner) ([]string, error) {
-	result := make([]string, 0)
-	for scanner.Scan() {
-		line := strings.TrimRight(scanner.Text(), " \t") // remove trailing spaces
-		if before, after, ok := strings.Cut(line, "="); ok {
-			left := strings.TrimSpace(before)
-			// Normalize internal whitespace in value
-			right := strings.Join(strings.Fields(after), " ")
-			result = append(result, fmt.Sprintf("%s = %s", left, right))
+// lineEndings returns the terminator of every line of text as split by
+// format.SplitLines: "\r\n", "\n", or "" for a final line without one.
+func lineEndings(text string) []string {
+	var endings []string
+	for text != "" {
+		idx := strings.IndexByte(text, '\n')
+		if idx == -1 {
+			endings = append(endings, "")
+			break
+		}
+		if idx > 0 && text[idx-1] == '\r' {
+			endings = append(endings, "\r\n")
 		} else {
-			result = append(result, line)
+			endings = append(endings, "\n")
 		}
+		text = text[idx+1:]
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("reading input: %w", err)
+	return endings
+}
+
+// writeToFile replaces the specified file with the encoded output. The output
+// goes to a temporary file next to it, which is renamed over the file once
+// complete, so an interrupted write never leaves a partial file behind. An
+// existing file keeps its permissions.
+func writeToFile(filename string, data []byte) error {
+	// Follow a symlink so that it keeps pointing at the file.
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
 	}
-	return result, nil
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	defer os.Remove(file.Name()) // a no-op once renamed
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("writing data: %w", err)
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return fmt.Errorf("writing data: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing data: %w", err)
+	}
+	if err := os.Rename(file.Name(), filename); err != nil {
+		return fmt.Errorf("replacing file: %w", err)
+	}
+	return nil
 }
@@ -1,225 +1,736 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/thecrazygm/inifmt/format"
 )
 
-func TestAlignSection(t *testing.T) {
+func TestOnlySectionsKeepsLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.ini")
+	content := "[other]\r\na  =  1\r\n[mysvc]\r\nk=v\r\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--only-sections=mysvc", "-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[other]\r\na  =  1\r\n[mysvc]\r\nk = v\r\n"; string(got) != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalEquivalentFiles(t *testing.T) {
+	a := "; config A\n[server]\nport=80\nhost =  example.com\n\n\n[db]\nname=app\nname=final\n"
+	b := "[db]\r\n# renamed later\r\nname = final\r\n[server]\r\nhost=example.com\r\n\r\nport   =   80\r\n"
+
+	dir := t.TempDir()
+	var outputs []string
+	for i, content := range []string{a, b} {
+		path := filepath.Join(dir, []string{"a.ini", "b.ini"}[i])
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"--canonical", "-w", path})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute() unexpected error: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(got))
+	}
+
+	want := "[db]\nname = final\n\n[server]\nhost = example.com\nport = 80\n"
+	if outputs[0] != want || outputs[1] != want {
+		t.Fatalf("canonical outputs differ:\na: %q\nb: %q\nwant %q", outputs[0], outputs[1], want)
+	}
+}
+
+func TestCanonicalPresetOverridable(t *testing.T) {
+	cmd := newRootCmd()
+	if err := cmd.Flags().Parse([]string{"--canonical", "--blank-lines=keep"}); err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := lookupPreset("canonical")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyPreset(cmd.Flags(), canonical); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmd.Flags().Lookup("blank-lines").Value.String(); got != "keep" {
+		t.Errorf("explicit --blank-lines overridden by preset: got %q", got)
+	}
+	if got := cmd.Flags().Lookup("sort-keys").Value.String(); got != "[*]" {
+		t.Errorf("preset did not set --sort-keys: got %q", got)
+	}
+}
+
+func TestRedactRefusesWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.ini")
+	if err := os.WriteFile(path, []byte("password=real\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), config{write: true, redact: true}, []string{path}); err == nil {
+		t.Fatal("run(--redact --write) expected error without --force")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "password=real\n" {
+		t.Fatalf("file modified despite refusal: %q", got)
+	}
+	if err := run(context.Background(), config{write: true, redact: true, force: true}, []string{path}); err != nil {
+		t.Fatalf("run(--redact --write --force) unexpected error: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "password = ********\n" {
+		t.Fatalf("forced redaction not written: %q", got)
+	}
+}
+
+func TestNoLossy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "motd.ini")
+	if err := os.WriteFile(path, []byte("name=x\nmotd=Hello,  world\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--no-lossy", "-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "name = x\nmotd=Hello,  world\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"--no-lossy", "--force-lossy", path})
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil {
+		t.Error("--no-lossy --force-lossy expected error")
+	}
+}
+
+func TestLogLossyLines(t *testing.T) {
+	var out bytes.Buffer
+	log, err := newLogger(&out, config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logLossyLines(log, "a.ini", []int{2, 5})
+	want := "[Warning] a.ini:2: value whitespace collapsed; use --no-lossy to keep the line or --force-lossy to silence this\n" +
+		"[Warning] a.ini:5: value whitespace collapsed; use --no-lossy to keep the line or --force-lossy to silence this\n"
+	if out.String() != want {
+		t.Errorf("logLossyLines = %q, want %q", out.String(), want)
+	}
+}
+
+func TestValidateCollate(t *testing.T) {
 	tests := []struct {
-		name  string
-		lines []string
+		collate, locale string
+		ok              bool
 	}{
-		{
-			name:  "basic alignment",
-			lines: []string{"key1=val1", "longerkey=val2", "k=v"},
-		},
-		{
-			name:  "with comments and blanks",
-			lines: []string{"; comment", "key1=val1", "", "longerkey=val2"},
-		},
-		{
-			name:  "no equals sign",
-			lines: []string{"key1", "key2"},
-		},
-		{
-			name:  "empty input",
-			lines: []string{},
-		},
-		{
-			name:  "mixed with and without equals",
-			lines: []string{"key1=val1", "noequals", "key2=val2"},
-		},
-		{
-			name:  "pre-aligned",
-			lines: []string{"key1 = val1", "key2 = val2"},
-		},
+		{"bytes", "", true},
+		{"unicode", "de-u-co-phonebk", true},
+		{"natural", "", false},
+		{"unicode", "not a locale", false},
+	}
+	for _, tt := range tests {
+		cfg := config{}
+		cfg.format.Collate, cfg.format.CollateLocale = tt.collate, tt.locale
+		if err := validateConfig(cfg); (err == nil) != tt.ok {
+			t.Errorf("validateConfig(collate %q %q) = %v, want ok %v", tt.collate, tt.locale, err, tt.ok)
+		}
+	}
+}
+
+func TestEmbeddedMarkdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md")
+	content := "Set:\n\n```ini\nname=app\nlong_name  =  x\n```\n\nkey=value prose\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := "Set:\n\n```ini\nname      = app\nlong_name = x\n```\n\nkey=value prose\n"
+	if got := mustRead(t, path); got != want {
+		t.Errorf("output = %q, want %q", got, want)
 	}
 
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"--embedded=markdown", "--to=flat", path})
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil {
+		t.Error("--embedded=markdown --to=flat expected error")
+	}
+}
+
+func TestParseNest(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", 0, true},
+		{"all", format.NestAll, true},
+		{"2", 2, true},
+		{"0", 0, false},
+		{"deep", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseNest(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseNest(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFlattenFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("[server]\nport=80\nhost=x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--flatten", "-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "server.port = 80\nserver.host = x\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if err := validateConfig(config{nest: "all", format: format.Options{Flatten: true}}); err == nil {
+		t.Error("validateConfig(--nest --flatten) expected error")
+	}
+}
+
+func TestWriteToFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.ini")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	if err := writeToFile(link, []byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new\n" {
+		t.Errorf("target of the symlink = %q, want %q", got, "new\n")
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s is no longer a symlink", link)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("mode of %s = %v, want 0600", path, info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d entries after writing, want 2 (no temporary file)", len(entries))
+	}
+}
+
+func TestRunCanceledLeavesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	content := "a=1\nlong=2\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := run(ctx, config{write: true}, []string{path})
+	if !errors.Is(err, format.ErrCanceled) {
+		t.Fatalf("run(canceled) = %v, want format.ErrCanceled", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf("file = %q after a canceled run, want it unchanged", got)
+	}
+}
+
+func TestInterruptWhileReading(t *testing.T) {
+	if os.Getenv("INIFMT_TEST_MAIN") == "1" {
+		os.Args = []string{"inifmt", "--no-config"}
+		main()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("no interrupt signal to send on windows")
+	}
+	// inifmt waits on a stdin that never ends; an interrupt must still stop it.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInterruptWhileReading$")
+	cmd.Env = append(os.Environ(), "INIFMT_TEST_MAIN=1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	time.Sleep(200 * time.Millisecond)
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("inifmt exited successfully after an interrupt")
+		}
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("inifmt kept reading stdin after an interrupt")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("a = $INIFMT_TEST_MISSING\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []string
+		want int
+	}{
+		{"unknown flag", context.Background(), []string{"--no-such-flag", path}, 2},
+		{"invalid flag value", context.Background(), []string{"--wrap-values=wide", path}, 2},
+		{"invalid option", context.Background(), []string{"--dedupe-keys=middle", path}, 2},
+		{"conflicting options", context.Background(), []string{"--write", "--output=x.ini", path}, 2},
+		{"parse error", context.Background(), []string{"--expand-env", path}, 1},
+		{"canceled", ctx, []string{path}, 130},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := alignSection(tt.lines)
-			assertAligned(t, got)
+			cmd := newRootCmd()
+			cmd.SetArgs(append([]string{"--no-config", "--quiet"}, tt.args...))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+			err := cmd.ExecuteContext(tt.ctx)
+			if got := exitCode(err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", err, got, tt.want)
+			}
 		})
 	}
 }
 
-func TestSingleSpaceFormat(t *testing.T) {
+func TestParseErrorNamesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("[s]\na = $INIFMT_TEST_MISSING\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{}
+	cfg.format.ExpandEnv = true
+	err := run(context.Background(), cfg, []string{path})
+	var pe *format.ParseError
+	if !errors.As(err, &pe) || pe.File != path || pe.Line != 2 {
+		t.Fatalf("run() = %v, want a ParseError for %s line 2", err, path)
+	}
+}
+
+func TestRemoveEmptyValuesSystemd(t *testing.T) {
+	dir := t.TempDir()
+	unit := filepath.Join(dir, "app.service")
+	content := "[Service]\nExecStart=\nExecStart=/usr/bin/app\n"
+	if err := os.WriteFile(unit, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write", "--remove-empty-values", unit})
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustRead(t, unit); strings.Count(got, "ExecStart") != 2 {
+		t.Errorf("unit = %q, want the resetting ExecStart= kept", got)
+	}
+	if !strings.Contains(stderr.String(), "--remove-empty-values ignored") {
+		t.Errorf("stderr = %q, want a warning", stderr.String())
+	}
+}
+
+func TestUniqueVerbose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(path, []byte("[a]\nk = v\nx = 1\nk =  v\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write", "--verbose", "--unique", path})
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "[a]\nk = v\nx = 1\n"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if want := path + ":4: removed duplicate of line 2"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr = %q, missing %q", stderr.String(), want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(path, []byte("[b]\nx=1\n[a]\nk=v\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write", "--verify", "--verbose", "--sort-sections", path})
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "[a]\nk = v\n[b]\nx = 1\n"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if want := "--verify: differences expected from --sort-sections"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr = %q, missing %q", stderr.String(), want)
+	}
+
+	err := verifyLines(config{}, "app.ini", []string{"[a]", "k = v"}, []string{"[a]", "k = w"})
+	if err == nil || exitCode(err) != 2 {
+		t.Fatalf("verifyLines() = %v, want an error exiting 2", err)
+	}
+	if want := `app.ini: --verify: formatted output has "a.k = w" at line 2 where the input has "a.k = v" at line 2; nothing written`; err.Error() != want {
+		t.Errorf("verifyLines() = %q, want %q", err, want)
+	}
+}
+
+func TestWriteStdinFilename(t *testing.T) {
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(stdin, []byte("a=1\nlong=2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
 	tests := []struct {
-		name  string
-		lines []string
+		name    string
+		target  string
+		wantErr bool
 	}{
-		{
-			name:  "basic conversion",
-			lines: []string{"key1=val1", "key2  =  val2", "key3= val3"},
-		},
-		{
-			name:  "no equals sign",
-			lines: []string{"key1", "key2"},
-		},
-		{
-			name:  "already correct",
-			lines: []string{"key1 = val1"},
-		},
-		{
-			name:  "empty input",
-			lines: []string{},
-		},
-		{
-			name:  "comments and blanks",
-			lines: []string{"; comment", "key1=val1", ""},
-		},
+		{"new file", filepath.Join(dir, "new.ini"), false},
+		{"existing file", filepath.Join(dir, "old.ini"), false},
+		{"missing directory", filepath.Join(dir, "missing", "app.ini"), true},
+	}
+	if err := os.WriteFile(tests[1].target, []byte("stale\n"), 0o600); err != nil {
+		t.Fatal(err)
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// singleSpaceFormat expects a scanner
-			input := strings.Join(tt.lines, "\n")
-			if len(tt.lines) > 0 {
-				input += "\n"
+			in, err := os.Open(stdin)
+			if err != nil {
+				t.Fatal(err)
 			}
-			scanner := bufio.NewScanner(strings.NewReader(input))
-			got, err := singleSpaceFormat(scanner)
+			defer in.Close()
+			out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
 			if err != nil {
-				t.Fatalf("singleSpaceFormat() unexpected error: %v", err)
+				t.Fatal(err)
+			}
+			defer out.Close()
+			savedIn, savedOut := os.Stdin, os.Stdout
+			os.Stdin, os.Stdout = in, out
+			defer func() { os.Stdin, os.Stdout = savedIn, savedOut }()
+
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{"--no-config", "--write", "--stdin-filename", tt.target})
+			cmd.SetErr(io.Discard)
+			err = cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() = %v, want error %v", err, tt.wantErr)
+			}
+			if stdout := mustRead(t, out.Name()); stdout != "" {
+				t.Errorf("stdout = %q, want nothing", stdout)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, want := mustRead(t, tt.target), "a    = 1\nlong = 2\n"; got != want {
+				t.Errorf("%s = %q, want %q", tt.target, got, want)
 			}
-			assertSingleSpace(t, got)
 		})
 	}
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--stdin-filename", "a.ini", stdin})
+	cmd.SetErr(io.Discard)
+	cmd.SetOut(io.Discard)
+	if err := cmd.Execute(); exitCode(err) != 2 {
+		t.Errorf("--stdin-filename with a file argument = %v, want a usage error", err)
+	}
 }
 
-func TestAlignIni(t *testing.T) {
+func TestMatchKeysFlag(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shared.ini")
+	if err := os.WriteFile(path, []byte("[s]\nother=1\nmyapp_port=80\nmyapp_host  =  h\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write", "--match-keys", "^myapp_", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "[s]\nother=1\nmyapp_port = 80\nmyapp_host = h\n"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+
+	for _, flag := range []string{"--match-keys", "--ignore-keys"} {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"--no-config", flag, "(", path})
+		cmd.SetErr(io.Discard)
+		cmd.SetOut(io.Discard)
+		if err := cmd.Execute(); exitCode(err) != 2 || !strings.Contains(err.Error(), flag) {
+			t.Errorf("%s with an invalid regex = %v, want a usage error", flag, err)
+		}
+	}
+}
+
+func TestPruneDefaultsFlag(t *testing.T) {
+	dir := t.TempDir()
+	defaults := filepath.Join(dir, "defaults.ini")
+	path := filepath.Join(dir, "config.ini")
+	report := filepath.Join(dir, "report.json")
+	if err := os.WriteFile(defaults, []byte("[server]\nhost = localhost\nport = 80\n[log]\nlevel = info\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("[server]\nhost = \"localhost\"\nport = 8080\n[log]\nlevel = info\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--write", "--verify", "--prune-defaults", defaults, "--report", report, path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustRead(t, path), "[server]\nport = 8080\n"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	var r runReport
+	if err := json.Unmarshal([]byte(mustRead(t, report)), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := []removedKey{{2, "server.host", `"localhost"`}, {5, "log.level", "info"}}
+	if len(r.Files) != 1 || !slices.Equal(r.Files[0].Removed, want) {
+		t.Errorf("report files = %+v, want removed %+v", r.Files, want)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--prune-defaults", filepath.Join(dir, "missing.ini"), path})
+	cmd.SetOut(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--prune-defaults") {
+		t.Errorf("missing defaults file = %v, want an error naming --prune-defaults", err)
+	}
+}
+
+func TestMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"a.ini": "a=1\n", "b.ini": "b = 2\n", "conf.d/c.ini": "[s]\nc=3\n"}
+	c := filepath.Join("conf.d", "c.ini")
 	tests := []struct {
-		name       string
-		cfg        formatConfig
-		inputLines []string
+		name   string
+		args   []string
+		stdout string
+		stderr string // a part of stderr
+		code   int
+		files  map[string]string // files after the run, when changed
 	}{
 		{
-			name: "global alignment",
-			cfg:  formatConfig{perSection: false},
-			inputLines: []string{
-				"key1=val1",
-				"[section1]",
-				"longkey=val2",
-				"k=v",
-			},
+			name:   "headers",
+			args:   []string{"a.ini", "b.ini", c},
+			stdout: "==> a.ini <==\na = 1\n\n==> b.ini <==\nb = 2\n\n==> " + c + " <==\n[s]\nc = 3\n",
+		},
+		{
+			name:   "a missing file does not stop the others",
+			args:   []string{"a.ini", "missing.ini", "b.ini"},
+			stdout: "==> a.ini <==\na = 1\n\n==> b.ini <==\nb = 2\n",
+			stderr: "missing.ini: opening file",
+			code:   1,
+		},
+		{
+			name:   "a missing first file",
+			args:   []string{"missing.ini", "a.ini"},
+			stdout: "==> a.ini <==\na = 1\n",
+			stderr: "missing.ini: opening file",
+			code:   1,
+		},
+		{
+			name:  "write",
+			args:  []string{"-w", "a.ini", "b.ini", c},
+			files: map[string]string{"a.ini": "a = 1\n", "b.ini": "b = 2\n", "conf.d/c.ini": "[s]\nc = 3\n"},
 		},
 		{
-			name: "per-section alignment",
-			cfg:  formatConfig{perSection: true},
-			inputLines: []string{
-				"global_key=global_value",
-				"[section1]",
-				"s1key1=val1",
-				"s1longerkey=val2",
-				"[section2]",
-				"s2key=val3",
-				"s2lk=val4",
-				"; comment in section 2",
-			},
+			name:  "write with a missing file",
+			args:  []string{"-w", "missing.ini", "a.ini"},
+			code:  1,
+			files: map[string]string{"a.ini": "a = 1\n"},
 		},
+		{name: "check", args: []string{"--check", "a.ini", "b.ini"}, stderr: "a.ini is not formatted", code: 1},
+		{name: "diff", args: []string{"--diff", "b.ini", "a.ini"}, stdout: "--- a.ini\n+++ a.ini\n@@ -1 +1 @@\n-a=1\n+a = 1\n", code: 1},
 		{
-			name: "per-section with comments included",
-			cfg:  formatConfig{perSection: true},
-			inputLines: []string{
-				"[section1]",
-				"; comment1",
-				"key1=val1",
-				"[section2]",
-				"longkey=val2",
-				"; comment2",
-			},
+			name:   "hash",
+			args:   []string{"--hash-raw", "a.ini", "b.ini"},
+			stdout: fmt.Sprintf("%x  a.ini\n%x  b.ini\n", sha256.Sum256([]byte("a = 1\n")), sha256.Sum256([]byte("b = 2\n"))),
 		},
 		{
-			name:       "empty input",
-			cfg:        formatConfig{perSection: false},
-			inputLines: []string{},
+			name:  "output dir",
+			args:  []string{"--output-dir=out", "a.ini", c},
+			files: map[string]string{"out/a.ini": "a = 1\n", "out/conf.d/c.ini": "[s]\nc = 3\n"},
 		},
+		{name: "output", args: []string{"-o", "out.ini", "a.ini", "b.ini"}, stderr: "--output holds one file", code: 2},
+		{name: "stdin filename", args: []string{"--stdin-filename=x.ini", "a.ini", "b.ini"}, stderr: "--stdin-filename", code: 2},
+		{name: "show config", args: []string{"--show-config", "a.ini", "b.ini"}, stderr: "--show-config", code: 2},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			input := strings.Join(tt.inputLines, "\n")
-			if len(tt.inputLines) > 0 {
-				input += "\n"
+			writeFiles(t, dir, files)
+			stdout, stderr, code := runInifmt(t, dir, "", append([]string{"--color=never"}, tt.args...)...)
+			if code != tt.code {
+				t.Errorf("exit %d, want %d (stderr %q)", code, tt.code, stderr)
 			}
-			scanner := bufio.NewScanner(strings.NewReader(input))
-			got, err := alignIni(scanner, tt.cfg)
-			if err != nil {
-				t.Fatalf("alignIni() unexpected error: %v", err)
-			}
-			if tt.cfg.perSection {
-				var block []string
-				for _, l := range got {
-					trimmed := strings.TrimSpace(l)
-					if strings.HasPrefix(trimmed, "[") {
-						if len(block) > 0 {
-							assertAligned(t, block)
-							block = nil
-						}
-						continue
-					}
-					block = append(block, l)
-				}
-				if len(block) > 0 {
-					assertAligned(t, block)
+			if stdout != tt.stdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.stderr)
+			}
+			// Only usage errors come with the usage.
+			if usage := strings.Contains(stderr, "Usage:"); usage != (tt.code == 2) {
+				t.Errorf("stderr has the usage: %t, want %t", usage, tt.code == 2)
+			}
+			for name, want := range tt.files {
+				if got := mustRead(t, filepath.Join(dir, name)); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
 				}
-			} else {
-				assertAligned(t, got)
 			}
 		})
 	}
 }
 
-func assertAligned(t *testing.T, lines []string) {
-	t.Helper()
-	eqMin, eqMax := -1, -1
-	for i, l := range lines {
-		if !strings.Contains(l, "=") {
-			// skip non key/value lines
-			continue
-		}
-		if !strings.Contains(l, " = ") {
-			t.Fatalf("line %d not normalized around '=': %q", i, l)
-		}
-		if strings.HasSuffix(l, " ") {
-			t.Fatalf("line %d has trailing spaces: %q", i, l)
-		}
-		pos := strings.Index(l, "=")
-		leading := len(l) - len(strings.TrimLeft(l, " \t"))
-		col := pos - leading
-		if eqMin == -1 {
-			eqMin, eqMax = col, col
-		} else {
-			if col < eqMin {
-				eqMin = col
-			}
-			if col > eqMax {
-				eqMax = col
+func TestRuntimeErrorsWithoutUsage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"env.ini": "a = $INIFMT_TEST_MISSING\n", "secret.ini": "password = x\n"})
+	tests := []struct {
+		name  string
+		args  []string
+		usage bool
+	}{
+		{name: "missing file", args: []string{"missing.ini"}},
+		{name: "unset variable", args: []string{"--expand-env", "env.ini"}},
+		{name: "redact and write", args: []string{"--redact", "-w", "secret.ini"}},
+		{name: "unknown flag", args: []string{"--bogus", "env.ini"}, usage: true},
+		{name: "conflicting flags", args: []string{"--check", "--diff", "env.ini"}, usage: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stderr, code := runInifmt(t, dir, "", tt.args...)
+			if code == 0 || !strings.Contains(stderr, "Error:") {
+				t.Fatalf("exit %d, stderr %q; want an error", code, stderr)
 			}
-		}
+			if usage := strings.Contains(stderr, "Usage:"); usage != tt.usage {
+				t.Errorf("stderr has the usage: %t, want %t", usage, tt.usage)
+			}
+		})
+	}
+}
+
+func TestSubcommandErrorsWithoutUsage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"env.ini": "a = $INIFMT_TEST_MISSING\n", "secret.ini": "password = x\n"})
+	t.Chdir(dir)
+	tests := []struct {
+		name  string
+		args  []string
+		usage bool
+	}{
+		{name: "env of a missing file", args: []string{"env", "missing.ini"}},
+		{name: "env of a missing section", args: []string{"env", "env.ini", "nosuchsection"}},
+		{name: "env format", args: []string{"env", "--format=json", "secret.ini"}, usage: true},
+		{name: "apply to a missing file", args: []string{"apply", "--values-env=APP", "missing.ini"}},
+		{name: "apply without values", args: []string{"apply", "secret.ini"}, usage: true},
+		{name: "apply missing", args: []string{"apply", "--values-env=APP", "--missing=drop", "secret.ini"}, usage: true},
+		{name: "ensure of a missing file", args: []string{"ensure", "missing.ini", "s.k=v"}},
+		{name: "ensure key", args: []string{"ensure", "secret.ini", "k"}, usage: true},
+		{name: "ensure arguments", args: []string{"ensure"}, usage: true},
 	}
-	if eqMax-eqMin > 1 {
-		t.Fatalf("alignment columns vary more than 1 space: min %d max %d", eqMin, eqMax)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := newRootCmd()
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err == nil {
+				t.Fatal("Execute() expected an error")
+			}
+			if usage := strings.Contains(out.String(), "Usage:"); usage != tt.usage {
+				t.Errorf("output has the usage: %t, want %t", usage, tt.usage)
+			}
+		})
 	}
 }
 
-// assertSingleSpace verifies each line containing '=' has exactly one space on each side and no trailing whitespace.
-func assertSingleSpace(t *testing.T, lines []string) {
-	t.Helper()
-	for i, l := range lines {
-		if !strings.Contains(l, "=") {
-			continue
+func TestMultipleFilesBadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.ini":                "a=1\n",
+		"bad/.inifmt.toml":     "single-space = [\n",
+		"bad/b.ini":            "b=1\n",
+		"invalid/.inifmt.toml": "line-ending = \"bogus\"\n",
+		"invalid/c.ini":        "c=1\n",
+		"z.ini":                "z=1\n",
+	})
+	t.Chdir(dir)
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"-w", "--quiet", "a.ini", "bad/b.ini", "invalid/c.ini", "z.ini"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	err := cmd.Execute()
+	for _, name := range []string{"bad/b.ini", "invalid/c.ini"} {
+		if err == nil || !strings.Contains(err.Error(), name+":") {
+			t.Errorf("Execute() = %v, want an error for %s", err, name)
 		}
-		if !strings.Contains(l, " = ") {
-			t.Fatalf("line %d does not contain ' = ' delimiter: %q", i, l)
-		}
-		if strings.Count(l, " = ") != 1 {
-			t.Fatalf("line %d contains multiple ' = ' sequences: %q", i, l)
-		}
-		if strings.HasSuffix(l, " ") {
-			t.Fatalf("line %d has trailing space: %q", i, l)
+	}
+	// The files with sound settings are formatted all the same.
+	for name, want := range map[string]string{
+		"a.ini":         "a = 1\n",
+		"bad/b.ini":     "b=1\n",
+		"invalid/c.ini": "c=1\n",
+		"z.ini":         "z = 1\n",
+	} {
+		if got := mustRead(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
 		}
 	}
 }
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// Conflict markers, as git writes them.
+const (
+	conflictOurs   = "<<<<<<< ours"
+	conflictSep    = "======="
+	conflictTheirs = ">>>>>>> theirs"
+)
+
+// newMerge3Cmd builds the merge3 subcommand, a structural three-way merge.
+func newMerge3Cmd(cfg *config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "merge3 base ours theirs",
+		Short: "Merge two versions of a file key by key against their common base",
+		Long: `merge3 merges the changes ours and theirs made to base key by key rather than
+line by line. A key added, removed or changed on one side only takes that
+side's version, so two branches adding different keys to the same section
+merge cleanly, and a section theirs removed goes when ours left it alone.
+Comments and layout come from ours; theirs contributes key lines. The result
+is formatted in the dialect of ours.
+
+A key both sides changed to different values, or one side changed and the
+other removed, is a conflict: it is written between <<<<<<< ours, ======= and
+>>>>>>> theirs markers, with nothing on the side that removed it.
+
+The result is printed, or written to the file -o names. Exits 0 on a clean
+merge, 1 when there are conflicts and 2 on errors. As a git merge driver:
+
+  git config merge.inifmt.driver 'inifmt merge3 %O %A %B -o %A'
+  echo '*.ini merge=inifmt' >> .gitattributes`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(3)(cmd, args); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			return nil
+		},
+		ValidArgsFunction: completeFiles,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var files [3]*input
+			for i, name := range args {
+				in, err := readInput(name, cfg.source)
+				if err != nil {
+					return &exitError{code: 2, err: err}
+				}
+				files[i] = in
+			}
+			ours := files[1]
+			opts := dialectOptions(cfg.format, args[1], ours.lines)
+			merged, conflicts := merge3(files[0].lines, ours.lines, files[2].lines, opts)
+			result, err := format.Lines(merged, opts)
+			if err != nil {
+				return &exitError{code: 2, err: inputError(args[1], err)}
+			}
+			result = markConflicts(result, conflicts, opts)
+			out := *cfg
+			out.output = output
+			out.lineEnding = "auto"
+			if err := writeOutput(out, "", ours, result); err != nil {
+				return &exitError{code: 2, err: err}
+			}
+			if len(conflicts) > 0 {
+				return &exitError{code: 1, err: fmt.Errorf("%d %s", len(conflicts), plural(len(conflicts), "conflict", "conflicts"))}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the result to this file instead of stdout")
+	return cmd
+}
+
+// mergeKey identifies a key of a file: its section, its name and which
+// occurrence of the name in the section it is.
+type mergeKey struct {
+	section, key string
+	n            int
+}
+
+// mergeFile is one of the three files of a merge.
+type mergeFile struct {
+	lines []string
+	keys  []mergeKey
+	kvs   map[mergeKey]format.KeyValue
+	spans map[mergeKey][2]int // lines[start:end] holds the key and its continuation lines
+}
+
+// newMergeFile indexes the keys of lines.
+func newMergeFile(lines []string, cfg format.Options) *mergeFile {
+	f := &mergeFile{lines: lines, kvs: make(map[mergeKey]format.KeyValue), spans: make(map[mergeKey][2]int)}
+	seen := make(map[[2]string]int)
+	toks := format.TokenizeLines(lines, cfg)
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		name := [2]string{kv.Section, kv.Key}
+		k := mergeKey{kv.Section, kv.Key, seen[name]}
+		seen[name]++
+		f.keys = append(f.keys, k)
+		f.kvs[k] = kv
+		f.spans[k] = [2]int{kv.Line - 1, valueEnd(toks, kv.Line-1)}
+	}
+	return f
+}
+
+// get returns the key k, or nil when the file does not have it.
+func (f *mergeFile) get(k mergeKey) *format.KeyValue {
+	if kv, ok := f.kvs[k]; ok {
+		return &kv
+	}
+	return nil
+}
+
+// raw returns the lines of the key k.
+func (f *mergeFile) raw(k mergeKey) []string {
+	span := f.spans[k]
+	return f.lines[span[0]:span[1]]
+}
+
+// valueEnd returns the index of the line after the key line at i and the
+// continuation lines of its value.
+func valueEnd(toks [][]format.Token, i int) int {
+	for i++; i < len(toks); i++ {
+		first := slices.IndexFunc(toks[i], func(t format.Token) bool { return t.Kind != format.TokenWhitespace })
+		if first == -1 || toks[i][first].Kind != format.TokenValue {
+			break
+		}
+	}
+	return i
+}
+
+// sameKey reports whether a and b, either of which may be missing, set the
+// same value. Runs of whitespace do not count, as formatting collapses them.
+func sameKey(a, b *format.KeyValue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.HasValue == b.HasValue && strings.Join(strings.Fields(a.Value), " ") == strings.Join(strings.Fields(b.Value), " ")
+}
+
+// mergeConflict is a key ours and theirs changed differently. A side that
+// removed the key has nil.
+type mergeConflict struct {
+	key          mergeKey
+	ours, theirs *format.KeyValue
+}
+
+// merge3 merges the changes ours and theirs made to base into the lines of
+// ours. A conflicting key keeps the line of ours, or of theirs when ours
+// removed it, for markConflicts to replace once the result is formatted.
+func merge3(base, ours, theirs []string, cfg format.Options) ([]string, []mergeConflict) {
+	b, o, t := newMergeFile(base, cfg), newMergeFile(ours, cfg), newMergeFile(theirs, cfg)
+	keys := slices.Clone(o.keys)
+	for _, k := range slices.Concat(t.keys, b.keys) {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+
+	replace := make(map[int][]string) // the first line of a span of ours, and what replaces the span
+	drop := make(map[int]bool)
+	var added []mergeKey
+	var conflicts []mergeConflict
+	for _, k := range keys {
+		bv, ov, tv := b.get(k), o.get(k), t.get(k)
+		switch {
+		case sameKey(ov, tv), sameKey(tv, bv):
+			// Nothing to take from theirs.
+			continue
+		case sameKey(ov, bv):
+		default:
+			conflicts = append(conflicts, mergeConflict{k, ov, tv})
+			if ov == nil {
+				added = append(added, k)
+			}
+			continue
+		}
+		// Theirs changed the key and ours did not.
+		switch {
+		case ov == nil:
+			added = append(added, k)
+		case tv == nil:
+			span := o.spans[k]
+			replace[span[0]] = nil
+		default:
+			span := o.spans[k]
+			replace[span[0]] = t.raw(k)
+		}
+		for i := o.spans[k][0] + 1; i < o.spans[k][1]; i++ {
+			drop[i] = true
+		}
+	}
+
+	var result []string
+	for i, line := range ours {
+		if lines, ok := replace[i]; ok {
+			result = append(result, lines...)
+		} else if !drop[i] {
+			result = append(result, line)
+		}
+	}
+	for _, k := range added {
+		result = insertKey(result, k.section, t.raw(k), cfg)
+	}
+	return dropSections(result, base, theirs, cfg), conflicts
+}
+
+// insertKey inserts the lines of a key after the last key of section,
+// creating the section at the end of the file when it does not exist.
+func insertKey(lines []string, section string, key []string, cfg format.Options) []string {
+	at, found := -1, section == ""
+	if section == "" {
+		at = slices.IndexFunc(lines, format.IsHeader)
+		if at == -1 {
+			at = len(lines)
+		}
+	}
+	for i, line := range lines {
+		if format.IsHeader(line) && format.HeaderName(line) == section {
+			at, found = i+1, true
+		}
+	}
+	if !found {
+		if len(lines) > 0 && !format.IsBlank(lines[len(lines)-1]) {
+			lines = append(lines, "")
+		}
+		return append(append(lines, "["+section+"]"), key...)
+	}
+	toks := format.TokenizeLines(lines, cfg)
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		if kv.Section == section {
+			at = valueEnd(toks, kv.Line-1)
+		}
+	}
+	return slices.Insert(lines, at, key...)
+}
+
+// dropSections removes the sections theirs removed from base that the merge
+// left without keys, along with their comments.
+func dropSections(lines, base, theirs []string, cfg format.Options) []string {
+	kept := format.SectionNames(theirs)
+	gone := make(map[string]bool)
+	for _, name := range format.SectionNames(base) {
+		if !slices.Contains(kept, name) {
+			gone[name] = true
+		}
+	}
+	if len(gone) == 0 {
+		return lines
+	}
+	hasKeys := make(map[string]bool)
+	for _, kv := range format.ParseKeyValues(lines, cfg) {
+		hasKeys[kv.Section] = true
+	}
+	var result []string
+	skipping := false
+	for _, line := range lines {
+		if format.IsHeader(line) {
+			name := format.HeaderName(line)
+			skipping = gone[name] && !hasKeys[name]
+		}
+		if !skipping {
+			result = append(result, line)
+		}
+	}
+	// A file that did not end in a blank line does not end in one now.
+	if len(lines) > 0 && !format.IsBlank(lines[len(lines)-1]) {
+		for len(result) > 0 && format.IsBlank(result[len(result)-1]) {
+			result = result[:len(result)-1]
+		}
+	}
+	return result
+}
+
+// markConflicts replaces the line of each conflicting key in the formatted
+// lines with conflict markers around the versions of ours and theirs. The
+// version of a side the line did not come from takes the line's padding.
+func markConflicts(lines []string, conflicts []mergeConflict, cfg format.Options) []string {
+	if len(conflicts) == 0 {
+		return lines
+	}
+	f := newMergeFile(lines, cfg)
+	blocks := make(map[int][]string)
+	for _, c := range conflicts {
+		span, ok := f.spans[c.key]
+		if !ok {
+			continue
+		}
+		current := f.lines[span[0]:span[1]]
+		var ours, theirs []string
+		switch {
+		case c.ours == nil:
+			theirs = current
+		case c.theirs == nil:
+			ours = current
+		default:
+			ours, theirs = current, []string{conflictSide(current[0], *c.theirs, cfg)}
+		}
+		block := append([]string{conflictOurs}, ours...)
+		block = append(append(block, conflictSep), theirs...)
+		blocks[span[0]] = append(block, conflictTheirs)
+		for i := span[0] + 1; i < span[1]; i++ {
+			blocks[i] = nil
+		}
+	}
+	var result []string
+	for i, line := range lines {
+		if block, ok := blocks[i]; ok {
+			result = append(result, block...)
+		} else {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// conflictSide writes kv in the layout of line, a formatted line of the same
+// key. A value continued over several lines is kept on one.
+func conflictSide(line string, kv format.KeyValue, cfg format.Options) string {
+	if !kv.HasValue {
+		return kv.Key
+	}
+	before, after, ok := cfg.Cut(line)
+	if !ok {
+		return kv.Key + " = " + strings.Join(strings.Fields(kv.Value), " ")
+	}
+	return before + "=" + valueGap(before, after) + strings.Join(strings.Fields(kv.Value), " ")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestMerge3Fixtures(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "merge3", "*"))
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "merged.ini")
+			cmd := newRootCmd()
+			cmd.SetArgs([]string{"merge3",
+				filepath.Join(dir, "base.ini"), filepath.Join(dir, "ours.ini"), filepath.Join(dir, "theirs.ini"), "-o", out})
+			err := cmd.Execute()
+			want := mustRead(t, filepath.Join(dir, "merged.ini"))
+			wantCode := 0
+			if strings.Contains(want, conflictOurs) {
+				wantCode = 1
+			}
+			if got := exitCode(err); err != nil && got != wantCode || err == nil && wantCode != 0 {
+				t.Fatalf("merge3 = %v, want exit code %d", err, wantCode)
+			}
+			if got := mustRead(t, out); got != want {
+				t.Errorf("merged =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              format.Options
+		base, ours, their []string
+		want              []string
+		conflicts         int
+	}{
+		{
+			name:  "both sides make the same change",
+			base:  []string{"[a]", "x = 1"},
+			ours:  []string{"[a]", "x = 2", "y = 1"},
+			their: []string{"[a]", "x =  2", "y = 1"},
+			want:  []string{"[a]", "x = 2", "y = 1"},
+		},
+		{
+			name:      "add/add with different values",
+			base:      []string{"[a]"},
+			ours:      []string{"[a]", "x = 1"},
+			their:     []string{"[a]", "x = 2"},
+			want:      []string{"[a]", "x = 1"},
+			conflicts: 1,
+		},
+		{
+			name:  "preamble key added by theirs",
+			base:  []string{"[a]", "x = 1"},
+			ours:  []string{"[a]", "x = 1"},
+			their: []string{"top = 1", "[a]", "x = 1"},
+			want:  []string{"top = 1", "[a]", "x = 1"},
+		},
+		{
+			name:  "theirs removes a section ours kept using",
+			base:  []string{"[a]", "x = 1", "[b]", "y = 1"},
+			ours:  []string{"[a]", "x = 1", "[b]", "y = 1", "z = 1"},
+			their: []string{"[a]", "x = 1"},
+			want:  []string{"[a]", "x = 1", "[b]", "z = 1"},
+		},
+		{
+			name:  "continuation lines",
+			opts:  format.Options{Dialect: format.DialectPyCfg},
+			base:  []string{"[options]", "packages =", "    a", "zip_safe = false"},
+			ours:  []string{"[options]", "packages =", "    a", "zip_safe = true"},
+			their: []string{"[options]", "packages =", "    a", "    b", "zip_safe = false"},
+			want:  []string{"[options]", "packages =", "    a", "    b", "zip_safe = true"},
+		},
+		{
+			name:      "delete/change",
+			base:      []string{"[a]", "x = 1"},
+			ours:      []string{"[a]"},
+			their:     []string{"[a]", "x = 2"},
+			want:      []string{"[a]", "x = 2"},
+			conflicts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := merge3(tt.base, tt.ours, tt.their, tt.opts)
+			if !slices.Equal(got, tt.want) || len(conflicts) != tt.conflicts {
+				t.Errorf("merge3() = %q with %d conflicts, want %q with %d", got, len(conflicts), tt.want, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMerge3Args(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.ini")
+	if err := os.WriteFile(path, []byte("a = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{path, path}, {path, path, filepath.Join(t.TempDir(), "missing.ini")}} {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"merge3"}, args...))
+		if err := cmd.Execute(); exitCode(err) != 2 {
+			t.Errorf("merge3 %q = %v, want exit code 2", args, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// mirrorPath returns where --output-dir writes the output of name: the same
+// path relative to the current directory, below dir. A name that is not
+// below the current directory, such as ../app.ini or an absolute path
+// elsewhere, has no place in the mirror and is refused, so output never
+// lands outside dir.
+func mirrorPath(dir, name string) (string, error) {
+	if name == "" {
+		return "", optionError("--output-dir", errors.New("--output-dir needs the name of the input to mirror, but the input is stdin; name it with --stdin-filename"))
+	}
+	if isURL(name) {
+		return "", optionError("--output-dir", errors.New("--output-dir mirrors local files, not URLs; use -o to save a formatted copy"))
+	}
+	rel := name
+	if filepath.IsAbs(name) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if rel, err = filepath.Rel(wd, name); err != nil {
+			rel = name
+		}
+	}
+	rel = filepath.Clean(rel)
+	if !filepath.IsLocal(rel) || rel == "." {
+		return "", fmt.Errorf("%s is not below the current directory, so --output-dir has no place for it", name)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// writeMirrored writes data to the mirror of name below dir, creating the
+// directories it needs. The original is never the destination: a mirror that
+// resolves to name itself, as with --output-dir=., is refused.
+func writeMirrored(dir, name string, data []byte) error {
+	dest, err := mirrorPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if src, err := os.Stat(name); err == nil {
+		if dst, err := os.Stat(dest); err == nil && os.SameFile(src, dst) {
+			return fmt.Errorf("--output-dir would overwrite %s itself; choose a directory outside the inputs' tree", name)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	if err := writeToFile(dest, data); err != nil {
+		return fmt.Errorf("writing to file: %w", err)
+	}
+	return nil
+}
+
+// copyMirrored copies the file name unchanged to its mirror below dir, for
+// --copy-unchanged.
+func copyMirrored(dir, name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	return writeMirrored(dir, name, data)
+}
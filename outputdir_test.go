@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestMirrorPath(t *testing.T) {
+	wd := t.TempDir()
+	t.Chdir(wd)
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{"app.ini", filepath.Join("out", "app.ini"), ""},
+		{filepath.Join("conf", "db.ini"), filepath.Join("out", "conf", "db.ini"), ""},
+		{"./conf/../x.ini", filepath.Join("out", "x.ini"), ""},
+		{filepath.Join(wd, "conf", "abs.ini"), filepath.Join("out", "conf", "abs.ini"), ""},
+		{"../app.ini", "", "not below"},
+		{"conf/../../app.ini", "", "not below"},
+		{filepath.Join(filepath.Dir(wd), "app.ini"), "", "not below"},
+		{".", "", "not below"},
+		{"", "", "--stdin-filename"},
+		{"https://example.com/app.ini", "", "URLs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mirrorPath("out", tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mirrorPath(%q) = %q, %v, want an error containing %q", tt.name, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("mirrorPath(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+	var oe *format.OptionError
+	if _, err := mirrorPath("out", ""); !errors.As(err, &oe) {
+		t.Errorf("mirrorPath(stdin) error = %v, want a usage error", err)
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	const messy = "[a]\nx=1\nlonger=2\n"
+	const aligned = "[a]\nx      = 1\nlonger = 2\n"
+	dir := gitRepo(t, map[string]string{"a.ini": "a=1\n"})
+	writeFiles(t, dir, map[string]string{
+		"a.ini":        messy,
+		"conf/b.ini":   messy,
+		"conf/bin.ini": "a=1\x00\n",
+	})
+
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		files map[string]string
+	}{
+		{
+			name:  "formatted files",
+			args:  []string{"--since=HEAD"},
+			files: map[string]string{"a.ini": aligned, "conf/b.ini": aligned},
+		},
+		{
+			name:  "copy unchanged",
+			args:  []string{"--since=HEAD", "--copy-unchanged"},
+			files: map[string]string{"a.ini": aligned, "conf/b.ini": aligned, "conf/bin.ini": "a=1\x00\n"},
+		},
+		{
+			name:  "one file",
+			args:  []string{filepath.Join("conf", "b.ini")},
+			files: map[string]string{"conf/b.ini": aligned},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "build", "configs")
+			report := filepath.Join(t.TempDir(), "report.json")
+			cmd := newRootCmd()
+			cmd.SetArgs(append([]string{"--no-config", "--quiet", "--output-dir=" + out, "--report=" + report}, tt.args...))
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			filepath.WalkDir(out, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(out, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			if len(got) != len(tt.files) {
+				t.Errorf("output dir holds %q, want %d files", got, len(tt.files))
+			}
+			for name, content := range tt.files {
+				if got := mustRead(t, filepath.Join(out, name)); got != content {
+					t.Errorf("%s = %q, want %q", name, got, content)
+				}
+			}
+			// The originals are left alone.
+			for _, name := range []string{"a.ini", "conf/b.ini"} {
+				if got := mustRead(t, filepath.Join(dir, name)); got != messy {
+					t.Errorf("original %s = %q, want it unchanged", name, got)
+				}
+			}
+			if got := mustRead(t, report); !strings.Contains(got, `"output_dir": "`+out+`"`) {
+				t.Errorf("report = %s, want the output dir", got)
+			}
+		})
+	}
+}
+
+func TestOutputDirSummary(t *testing.T) {
+	r := newRunReport()
+	r.OutputDir = "build/configs"
+	r.add("a.ini", statusFormatted, "", nil)
+	var b strings.Builder
+	if err := r.writeSummary(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(b.String(), "  written to build/configs\n") {
+		t.Errorf("summary = %q, want the destination", b.String())
+	}
+}
+
+func TestOutputDirRefused(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFiles(t, dir, map[string]string{"app.ini": "a=1\n", "sub/x.ini": "b=1\n"})
+
+	// A mirror that is the input itself.
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--quiet", "--output-dir=.", "app.ini"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "overwrite") {
+		t.Errorf("--output-dir=. error = %v, want a refusal", err)
+	}
+	if got := mustRead(t, "app.ini"); got != "a=1\n" {
+		t.Errorf("app.ini = %q, want it unchanged", got)
+	}
+
+	// A file outside the current directory.
+	t.Chdir(filepath.Join(dir, "sub"))
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"--no-config", "--quiet", "--output-dir=out", "../app.ini"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not below") {
+		t.Errorf("--output-dir with ../app.ini error = %v, want a refusal", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "sub")); len(entries) != 1 {
+		t.Errorf("sub holds %d entries, want only x.ini", len(entries))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// presetFlag is a flag value implied by a preset.
+type presetFlag struct{ flag, value string }
+
+// preset is a named bundle of flag values selected with --preset.
+type preset struct {
+	name        string
+	description string
+	flags       []presetFlag
+}
+
+// canonicalPreset lists the flag values implied by --canonical, in the order
+// they are documented. Flags given explicitly on the command line win.
+var canonicalPreset = []presetFlag{
+	{"sort-sections", "true"},
+	{"sort-keys", "*"},
+	{"dedupe-keys", "last"},
+	{"strip-comments", "true"},
+	{"blank-lines", "sections"},
+	{"single-space", "true"},
+	{"line-ending", "lf"},
+}
+
+// presets are the bundles selectable with --preset, in the order
+// --list-presets shows them.
+var presets = []preset{
+	{"aligned", "align '=' across the whole file (the defaults)", nil},
+	{"dense", "one space around '=' and no repeated, leading or trailing blank lines", []presetFlag{
+		{"single-space", "true"},
+		{"blank-lines", "squeeze"},
+	}},
+	{"tidy", "align each section, sort keys and indent comments like their keys", []presetFlag{
+		{"per-section", "true"},
+		{"sort-keys", "*"},
+		{"align-comment-indent", "true"},
+	}},
+	{"canonical", "fully canonical form for golden files (same as --canonical)", canonicalPreset},
+}
+
+// lookupPreset returns the preset called name.
+func lookupPreset(name string) (preset, error) {
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		if p.name == name {
+			return p, nil
+		}
+		names[i] = p.name
+	}
+	return preset{}, fmt.Errorf("unknown --preset %q (want %s)", name, strings.Join(names, ", "))
+}
+
+// applyPreset sets every flag of the preset that was not given explicitly.
+func applyPreset(flags *pflag.FlagSet, preset preset) error {
+	for _, p := range preset.flags {
+		if flags.Changed(p.flag) {
+			continue
+		}
+		if err := setFlag(flags, p.flag, p.value, "preset "+preset.name); err != nil {
+			return fmt.Errorf("applying preset: %w", err)
+		}
+	}
+	return nil
+}
+
+// presetFlags renders a preset as the equivalent command-line flags.
+func presetFlags(preset []presetFlag) string {
+	parts := make([]string, len(preset))
+	for i, p := range preset {
+		if p.value == "true" {
+			parts[i] = "--" + p.flag
+		} else {
+			parts[i] = "--" + p.flag + "=" + p.value
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// writePresets lists the presets with their descriptions and the flags each
+// one implies.
+func writePresets(w io.Writer) error {
+	for _, p := range presets {
+		implies := presetFlags(p.flags)
+		if implies == "" {
+			implies = "(no flags)"
+		}
+		if _, err := fmt.Fprintf(w, "%-10s %s\n%-10s %s\n", p.name, p.description, "", implies); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	input := "\n[b]\nzeta  =  1\n  ; about alpha\nalpha=2\n\n\n[a]\nlonger_key=3\n\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--preset=aligned"}, "\n[b]\nzeta       = 1\n  ; about alpha\nalpha      = 2\n\n\n[a]\nlonger_key = 3\n\n"},
+		{[]string{"--preset=dense"}, "[b]\nzeta = 1\n  ; about alpha\nalpha = 2\n\n[a]\nlonger_key = 3\n"},
+		{[]string{"--preset=tidy"}, "\n[b]\n; about alpha\nalpha = 2\nzeta  = 1\n\n\n[a]\nlonger_key = 3\n\n"},
+		{[]string{"--preset=tidy", "--per-section=false"}, "\n[b]\n; about alpha\nalpha      = 2\nzeta       = 1\n\n\n[a]\nlonger_key = 3\n\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "in.ini")
+		if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs(append(tt.args, "--no-config", "-w", path))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := mustRead(t, path); got != tt.want {
+			t.Errorf("%v: output = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPresetFromProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := "preset = \"dense\"\nblank-lines = \"keep\"\n"
+	if err := os.WriteFile(filepath.Join(dir, projectConfigName), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "in.ini")
+	if err := os.WriteFile(path, []byte("a  =  1\n\n\nb=2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"-w", path})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	// The config's own blank-lines setting beats the preset's.
+	if got, want := mustRead(t, path), "a = 1\n\n\nb = 2\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestPresetErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--preset=fancy"},
+		{"--canonical", "--preset=dense"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetArgs(append(args, "--no-config", "testdata/none.ini"))
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestListPresets(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--list-presets"})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range presets {
+		if !strings.Contains(out.String(), p.name+" ") {
+			t.Errorf("--list-presets output is missing %s:\n%s", p.name, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "--single-space --blank-lines=squeeze") {
+		t.Errorf("--list-presets output is missing the flags of dense:\n%s", out.String())
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
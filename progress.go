@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressThreshold is the number of files past which --progress=auto shows
+// a progress line.
+const progressThreshold = 100
+
+// progressInterval is the least time between two redraws of the progress
+// line.
+const progressInterval = 200 * time.Millisecond
+
+// eraseLine returns the cursor to the start of the line and clears it.
+const eraseLine = "\r\x1b[K"
+
+// progressBar keeps a single updating line such as
+// "formatted 4312/18000 files (3 failed)" on a terminal. A nil *progressBar,
+// used when progress is off, shows nothing.
+type progressBar struct {
+	mu     sync.Mutex
+	w      io.Writer
+	total  int
+	done   int
+	failed int
+	shown  bool      // the line is on the screen
+	drawn  time.Time // when it was last drawn
+	now    func() time.Time
+}
+
+// showProgress decides whether to show progress for total files: never with
+// --quiet or when stderr is not a terminal; otherwise for --progress=always,
+// and for --progress=auto past progressThreshold files.
+func showProgress(mode string, quiet, terminal bool, total int) bool {
+	if quiet || !terminal || mode == "never" {
+		return false
+	}
+	return mode == "always" || total > progressThreshold
+}
+
+// newProgressBar returns the progress line for formatting total files, drawn
+// on stderr, or nil when showProgress says not to.
+func newProgressBar(cfg config, total int) *progressBar {
+	if !showProgress(cfg.progress, cfg.quiet, isTerminal(os.Stderr), total) {
+		return nil
+	}
+	return &progressBar{w: os.Stderr, total: total, now: time.Now}
+}
+
+// add counts a file as done, failed or not, and redraws the line unless it
+// was drawn less than progressInterval ago.
+func (p *progressBar) add(failed bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if failed {
+		p.failed++
+	}
+	if now := p.now(); !p.shown || now.Sub(p.drawn) >= progressInterval {
+		p.drawn = now
+		p.draw()
+	}
+}
+
+// draw writes the line over the one on the screen. p.mu must be held.
+func (p *progressBar) draw() {
+	line := fmt.Sprintf("formatted %d/%d files", p.done, p.total)
+	if p.failed > 0 {
+		line += fmt.Sprintf(" (%d failed)", p.failed)
+	}
+	fmt.Fprint(p.w, eraseLine+line)
+	p.shown = true
+}
+
+// clear erases the line, as before the summary is printed. The next add
+// draws it again.
+func (p *progressBar) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+}
+
+// erase erases the line if it is on the screen. p.mu must be held.
+func (p *progressBar) erase() {
+	if p.shown {
+		fmt.Fprint(p.w, eraseLine)
+		p.shown = false
+	}
+}
+
+// writer returns w made to erase the line before anything is written to it,
+// so log records never run into it. The line comes back with the next file.
+func (p *progressBar) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+	return progressWriter{p, w}
+}
+
+// progressWriter is a writer sharing the terminal with a progress line.
+type progressWriter struct {
+	p *progressBar
+	w io.Writer
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	pw.p.erase()
+	return pw.w.Write(b)
+}
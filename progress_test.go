@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestShowProgress(t *testing.T) {
+	tests := []struct {
+		mode     string
+		quiet    bool
+		terminal bool
+		total    int
+		want     bool
+	}{
+		{"auto", false, true, progressThreshold + 1, true},
+		{"auto", false, true, progressThreshold, false},
+		{"always", false, true, 1, true},
+		{"always", false, false, 1000, false},
+		{"always", true, true, 1000, false},
+		{"never", false, true, 1000, false},
+	}
+	for _, tt := range tests {
+		if got := showProgress(tt.mode, tt.quiet, tt.terminal, tt.total); got != tt.want {
+			t.Errorf("showProgress(%q, quiet %v, terminal %v, %d) = %v, want %v", tt.mode, tt.quiet, tt.terminal, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	p := &progressBar{w: &out, total: 3, now: func() time.Time { return now }}
+
+	p.add(false)
+	p.add(true) // too soon to redraw
+	now = now.Add(progressInterval)
+	p.add(false)
+	want := eraseLine + "formatted 1/3 files" + eraseLine + "formatted 3/3 files (1 failed)"
+	if out.String() != want {
+		t.Fatalf("progress = %q, want %q", out.String(), want)
+	}
+
+	// A log record erases the line first.
+	out.Reset()
+	log, err := newLogger(p.writer(&out), config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Warn("a.ini:2: note")
+	log.Warn("a.ini:3: note")
+	if want := eraseLine + "[Warning] a.ini:2: note\n[Warning] a.ini:3: note\n"; out.String() != want {
+		t.Errorf("log output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	p.clear()
+	if out.String() != "" {
+		t.Errorf("clear() of an erased line wrote %q", out.String())
+	}
+
+	var nilBar *progressBar
+	nilBar.add(true)
+	nilBar.clear()
+	if w := nilBar.writer(&out); w != io.Writer(&out) {
+		t.Errorf("nil progressBar writer() = %T, want the writer itself", w)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// docSection is a section prepared for rendering as a document. The preamble
+// has no name.
+type docSection struct {
+	name   string
+	groups []docGroup
+}
+
+// docGroup is a run of full-line comments and the key rows that follow them.
+type docGroup struct {
+	comments []string
+	rows     []docRow
+}
+
+// docRow is a key with its value and inline comment.
+type docRow struct {
+	key, value, comment string
+}
+
+// buildDocument groups lines into sections whose comments stay attached to the
+// keys directly below them. A comment followed by a blank line stands alone.
+func buildDocument(lines []string, cfg format.Options) []docSection {
+	sections := []docSection{{}}
+	cur := &sections[0]
+	group := func() *docGroup {
+		if len(cur.groups) == 0 {
+			cur.groups = append(cur.groups, docGroup{})
+		}
+		return &cur.groups[len(cur.groups)-1]
+	}
+	for _, line := range lines {
+		switch {
+		case format.IsHeader(line):
+			sections = append(sections, docSection{name: format.HeaderName(line)})
+			cur = &sections[len(sections)-1]
+		case format.IsBlank(line):
+			if g := group(); len(g.comments) > 0 && len(g.rows) == 0 {
+				cur.groups = append(cur.groups, docGroup{})
+			}
+		case cfg.IsComment(line):
+			if g := group(); len(g.rows) > 0 {
+				cur.groups = append(cur.groups, docGroup{})
+			}
+			g := group()
+			g.comments = append(g.comments, cfg.CommentText(line))
+		default:
+			row := docRow{key: strings.TrimSpace(line)}
+			if before, after, ok := cfg.Cut(line); ok {
+				row.key = strings.TrimSpace(before)
+				row.value, row.comment = format.SplitInlineComment(after)
+			}
+			g := group()
+			g.rows = append(g.rows, row)
+		}
+	}
+	// Drop the empty preamble and groups left behind by trailing blank lines.
+	var result []docSection
+	for i, s := range sections {
+		var groups []docGroup
+		for _, g := range s.groups {
+			if len(g.comments) > 0 || len(g.rows) > 0 {
+				groups = append(groups, g)
+			}
+		}
+		s.groups = groups
+		if i == 0 && len(groups) == 0 {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// hasInlineComments reports whether any row carries an inline comment, which
+// adds a third column to the table.
+func hasInlineComments(rows []docRow) bool {
+	for _, r := range rows {
+		if r.comment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderDocument renders lines as a Markdown or HTML document.
+func renderDocument(lines []string, to string, cfg format.Options) []string {
+	doc := buildDocument(lines, cfg)
+	if to == "html" {
+		return renderHTML(doc)
+	}
+	return renderMarkdown(doc)
+}
+
+// markdownEscaper escapes the characters that carry meaning in Markdown text
+// and table cells.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`,
+)
+
+// renderMarkdown renders each section as a heading followed by its comments
+// as paragraphs and its keys as tables.
+func renderMarkdown(doc []docSection) []string {
+	var out []string
+	para := func(lines ...string) {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, lines...)
+	}
+	for _, s := range doc {
+		if s.name != "" {
+			para("## " + markdownEscaper.Replace(s.name))
+		}
+		for _, g := range s.groups {
+			if len(g.comments) > 0 {
+				var text []string
+				for _, c := range g.comments {
+					text = append(text, markdownEscaper.Replace(c))
+				}
+				para(text...)
+			}
+			if len(g.rows) == 0 {
+				continue
+			}
+			withComments := hasInlineComments(g.rows)
+			table := []string{"| Key | Value |", "| --- | --- |"}
+			if withComments {
+				table = []string{"| Key | Value | Comment |", "| --- | --- | --- |"}
+			}
+			for _, r := range g.rows {
+				row := fmt.Sprintf("| %s | %s |", markdownEscaper.Replace(r.key), markdownEscaper.Replace(r.value))
+				if withComments {
+					row += " " + markdownEscaper.Replace(r.comment) + " |"
+				}
+				table = append(table, row)
+			}
+			para(table...)
+		}
+	}
+	return out
+}
+
+// renderHTML renders the same structure as renderMarkdown as minimal semantic
+// HTML, one <section> per INI section.
+func renderHTML(doc []docSection) []string {
+	var out []string
+	for _, s := range doc {
+		out = append(out, "<section>")
+		if s.name != "" {
+			out = append(out, "<h2>"+html.EscapeString(s.name)+"</h2>")
+		}
+		for _, g := range s.groups {
+			if len(g.comments) > 0 {
+				var text []string
+				for _, c := range g.comments {
+					text = append(text, html.EscapeString(c))
+				}
+				out = append(out, "<p>"+strings.Join(text, "\n")+"</p>")
+			}
+			if len(g.rows) == 0 {
+				continue
+			}
+			withComments := hasInlineComments(g.rows)
+			out = append(out, "<table>")
+			if withComments {
+				out = append(out, "<thead><tr><th>Key</th><th>Value</th><th>Comment</th></tr></thead>")
+			} else {
+				out = append(out, "<thead><tr><th>Key</th><th>Value</th></tr></thead>")
+			}
+			out = append(out, "<tbody>")
+			for _, r := range g.rows {
+				row := "<tr><td>" + html.EscapeString(r.key) + "</td><td>" + html.EscapeString(r.value) + "</td>"
+				if withComments {
+					row += "<td>" + html.EscapeString(r.comment) + "</td>"
+				}
+				out = append(out, row+"</tr>")
+			}
+			out = append(out, "</tbody>", "</table>")
+		}
+		out = append(out, "</section>")
+	}
+	return out
+}
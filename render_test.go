@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestBuildDocumentAttachesComments(t *testing.T) {
+	lines := []string{
+		"; about the file",
+		"",
+		"[server]",
+		"; where to listen",
+		"host = 0.0.0.0 ; all interfaces",
+		"port = 80",
+		"# TLS",
+		"cert = a.pem",
+	}
+	want := []docSection{
+		{groups: []docGroup{{comments: []string{"about the file"}}}},
+		{name: "server", groups: []docGroup{
+			{comments: []string{"where to listen"}, rows: []docRow{{"host", "0.0.0.0", "all interfaces"}, {"port", "80", ""}}},
+			{comments: []string{"TLS"}, rows: []docRow{{"cert", "a.pem", ""}}},
+		}},
+	}
+	got := buildDocument(lines, format.Options{})
+	if len(got) != len(want) {
+		t.Fatalf("buildDocument() = %d sections, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].name != want[i].name || len(got[i].groups) != len(want[i].groups) {
+			t.Fatalf("section %d = %+v, want %+v", i, got[i], want[i])
+		}
+		for j, g := range want[i].groups {
+			if !slices.Equal(got[i].groups[j].comments, g.comments) || !slices.Equal(got[i].groups[j].rows, g.rows) {
+				t.Errorf("section %d group %d = %+v, want %+v", i, j, got[i].groups[j], g)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	lines := []string{"[db|main]", "; the pool", "size = 5 # max", "path = /var/*.db"}
+	want := strings.Join([]string{
+		`## db\|main`,
+		"",
+		"the pool",
+		"",
+		"| Key | Value | Comment |",
+		"| --- | --- | --- |",
+		"| size | 5 | max |",
+		`| path | /var/\*.db |  |`,
+	}, "\n")
+	if got := strings.Join(renderDocument(lines, "markdown", format.Options{}), "\n"); got != want {
+		t.Errorf("renderDocument(markdown) =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderHTMLEscapes(t *testing.T) {
+	lines := []string{"[s]", "q = <a href=\"x\">&</a>"}
+	got := strings.Join(renderDocument(lines, "html", format.Options{}), "\n")
+	if !strings.Contains(got, "<td>&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;</td>") {
+		t.Errorf("renderDocument(html) did not escape the value:\n%s", got)
+	}
+	if !strings.Contains(got, "<h2>s</h2>") {
+		t.Errorf("renderDocument(html) missing section heading:\n%s", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// fileStatus is the outcome of formatting one file.
+type fileStatus string
+
+const (
+	statusFormatted fileStatus = "formatted" // the output differs from the input
+	statusUnchanged fileStatus = "unchanged" // the input was already formatted
+	statusSkipped   fileStatus = "skipped"   // not formatted, e.g. a binary file
+	statusFailed    fileStatus = "failed"
+)
+
+// fileResult is the outcome of one file of a run.
+type fileResult struct {
+	File    string       `json:"file"`
+	Status  fileStatus   `json:"status"`
+	Reason  string       `json:"reason,omitempty"`  // why the file was skipped or failed, or that only its line endings change
+	Timings *fileTimings `json:"timings,omitempty"` // with --timings
+	Removed []removedKey `json:"removed,omitempty"` // keys --prune-defaults removed
+	line    int          // with --check, the first line formatting changes
+}
+
+// removedKey is a key line removed from a file, as section.key.
+type removedKey struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// defaultKeys lists the keys of kvs for the report.
+func defaultKeys(kvs []format.KeyValue) []removedKey {
+	keys := make([]removedKey, 0, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, removedKey{Line: kv.Line, Key: kv.Path(), Value: kv.Value})
+	}
+	return keys
+}
+
+// runTotals counts the files of a run by outcome.
+type runTotals struct {
+	Examined  int `json:"examined"`
+	Formatted int `json:"formatted"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+// runReport collects the outcome of every file of a run. The summary table
+// and the JSON report are both rendered from it, so they always agree.
+type runReport struct {
+	Files     []fileResult `json:"files"`
+	Totals    runTotals    `json:"totals"`
+	ElapsedMS int64        `json:"elapsed_ms"`
+	OutputDir string       `json:"output_dir,omitempty"` // with --output-dir
+	start     time.Time
+}
+
+// newRunReport starts collecting results; the elapsed time counts from now.
+func newRunReport() *runReport {
+	return &runReport{Files: []fileResult{}, start: time.Now()}
+}
+
+// add records the outcome of file. A non-nil err makes it a failure.
+func (r *runReport) add(file string, status fileStatus, reason string, err error) {
+	if err != nil {
+		status, reason = statusFailed, err.Error()
+	}
+	r.Files = append(r.Files, fileResult{File: file, Status: status, Reason: reason})
+	r.Totals.Examined++
+	switch status {
+	case statusFormatted:
+		r.Totals.Formatted++
+	case statusUnchanged:
+		r.Totals.Unchanged++
+	case statusSkipped:
+		r.Totals.Skipped++
+	case statusFailed:
+		r.Totals.Failed++
+	}
+}
+
+// setTimings records the phase durations of the file added last; nil, without
+// --timings, records nothing.
+func (r *runReport) setTimings(t *fileTimings) {
+	if len(r.Files) > 0 {
+		r.Files[len(r.Files)-1].Timings = t
+	}
+}
+
+// setRemoved records the keys removed from the file added last.
+func (r *runReport) setRemoved(keys []removedKey) {
+	if len(r.Files) > 0 && len(keys) > 0 {
+		r.Files[len(r.Files)-1].Removed = keys
+	}
+}
+
+// finish stops the clock.
+func (r *runReport) finish() {
+	r.ElapsedMS = time.Since(r.start).Milliseconds()
+}
+
+// writeSummary prints the totals as a table.
+func (r *runReport) writeSummary(w io.Writer) error {
+	t := r.Totals
+	_, err := fmt.Fprintf(w, "inifmt: %d %s examined in %s\n  formatted  %d\n  unchanged  %d\n  skipped    %d\n  failed     %d\n",
+		t.Examined, plural(t.Examined, "file", "files"), time.Duration(r.ElapsedMS)*time.Millisecond,
+		t.Formatted, t.Unchanged, t.Skipped, t.Failed)
+	if err == nil && r.OutputDir != "" {
+		_, err = fmt.Fprintf(w, "  written to %s\n", r.OutputDir)
+	}
+	if err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes the report as JSON to path.
+func (r *runReport) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// plural returns one when n is 1 and many otherwise.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReport(t *testing.T) {
+	r := newRunReport()
+	r.add("a.ini", statusFormatted, "", nil)
+	r.add("b.ini", statusUnchanged, "", nil)
+	r.add("c.ini", statusUnchanged, "", nil)
+	r.add("d.bin", statusSkipped, "binary file", nil)
+	r.add("e.ini", statusFormatted, "", errors.New("boom"))
+	r.finish()
+	want := runTotals{Examined: 5, Formatted: 1, Unchanged: 2, Skipped: 1, Failed: 1}
+	if r.Totals != want {
+		t.Fatalf("totals = %+v, want %+v", r.Totals, want)
+	}
+	if got := r.Files[4]; got.Status != statusFailed || got.Reason != "boom" {
+		t.Errorf("failed file = %+v", got)
+	}
+
+	var out bytes.Buffer
+	if err := r.writeSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{"inifmt: 5 files examined in ", "  formatted  1\n", "  unchanged  2\n", "  skipped    1\n", "  failed     1\n"} {
+		if !strings.Contains(out.String(), row) {
+			t.Errorf("summary %q lacks %q", out.String(), row)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := r.writeJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	var decoded runReport
+	if err := json.Unmarshal([]byte(mustRead(t, path)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Totals != r.Totals || len(decoded.Files) != len(r.Files) {
+		t.Errorf("JSON report = %+v, want totals %+v", decoded, r.Totals)
+	}
+}
+
+func TestFormatFileStatus(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		args    []string
+		status  fileStatus
+		reason  string
+	}{
+		{"[s]\na = 1\n", nil, statusUnchanged, ""},
+		{"[s]\na=1\n", nil, statusFormatted, ""},
+		{"[s]\na = 1", nil, statusFormatted, reasonEOLOnly},
+		{"[s]\r\na = 1\r\n", nil, statusFormatted, reasonEOLOnly},
+		{"[s]\r\na = 1", []string{"--check-ignore-eol"}, statusUnchanged, ""},
+		{"[s]\r\na=1\r\n", []string{"--check-ignore-eol"}, statusFormatted, ""},
+		{"[s]\na = \x00\n", nil, statusSkipped, "binary file"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "f.ini")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs(append(tt.args, "--write", "--quiet", "--report", filepath.Join(dir, "r.json"), path))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		var r runReport
+		if err := json.Unmarshal([]byte(mustRead(t, filepath.Join(dir, "r.json"))), &r); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Files) != 1 || r.Files[0].Status != tt.status || r.Files[0].Reason != tt.reason {
+			t.Errorf("%q: report %+v, want %s %q", tt.content, r.Files, tt.status, tt.reason)
+		}
+		if tt.status == statusSkipped && mustRead(t, path) != tt.content {
+			t.Errorf("%q: skipped file was rewritten", tt.content)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// valueCheck reports whether the text of a value, unquoted and without its
+// inline comment, is valid for a schema type.
+type valueCheck func(text string) bool
+
+// schemaTypes builds the check of each schema type from the arguments written
+// in parentheses after its name, "" when there are none.
+var schemaTypes = map[string]func(args string) (valueCheck, error){
+	"string":   noArgs(func(string) bool { return true }),
+	"int":      numberType(func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }),
+	"float":    numberType(func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }),
+	"bool":     noArgs(func(text string) bool { _, ok := format.ParseBool(text); return ok }),
+	"duration": noArgs(func(text string) bool { _, err := time.ParseDuration(text); return err == nil }),
+	"enum":     enumType,
+}
+
+// noArgs makes a type that takes no arguments from its check.
+func noArgs(check valueCheck) func(string) (valueCheck, error) {
+	return func(args string) (valueCheck, error) {
+		if args != "" {
+			return nil, errors.New("takes no arguments")
+		}
+		return check, nil
+	}
+}
+
+// numberType makes a number type whose optional MIN..MAX argument bounds the
+// value inclusively; either bound may be left out.
+func numberType[T int64 | float64](parse func(string) (T, error)) func(string) (valueCheck, error) {
+	return func(args string) (valueCheck, error) {
+		var lo, hi T
+		hasLo, hasHi := false, false
+		if args != "" {
+			l, h, ok := strings.Cut(args, "..")
+			if !ok {
+				return nil, fmt.Errorf("invalid range %q (want MIN..MAX)", args)
+			}
+			var err error
+			if l = strings.TrimSpace(l); l != "" {
+				if lo, err = parse(l); err != nil {
+					return nil, fmt.Errorf("invalid minimum %q", l)
+				}
+				hasLo = true
+			}
+			if h = strings.TrimSpace(h); h != "" {
+				if hi, err = parse(h); err != nil {
+					return nil, fmt.Errorf("invalid maximum %q", h)
+				}
+				hasHi = true
+			}
+		}
+		return func(text string) bool {
+			n, err := parse(text)
+			return err == nil && (!hasLo || n >= lo) && (!hasHi || n <= hi)
+		}, nil
+	}
+}
+
+// enumType makes a type accepting exactly the comma-separated words of args.
+func enumType(args string) (valueCheck, error) {
+	var words []string
+	for word := range strings.SplitSeq(args, ",") {
+		if word = strings.TrimSpace(word); word == "" {
+			return nil, errors.New("needs a comma-separated list of values")
+		}
+		words = append(words, word)
+	}
+	return func(text string) bool { return slices.Contains(words, text) }, nil
+}
+
+// schemaRule is the declared type of a key.
+type schemaRule struct {
+	spec  string // the type as written, e.g. "int(1..65535)"
+	check valueCheck
+}
+
+// schema maps section.key paths to their declared types.
+type schema map[string]schemaRule
+
+// loadSchema reads a schema: an INI file whose keys are those of the checked
+// files and whose values are type names, e.g. "port = int(1..65535)".
+func loadSchema(path string, source sourceOptions) (schema, error) {
+	in, err := readInput(path, source)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+	s := make(schema)
+	for _, kv := range format.ParseKeyValues(in.lines, format.Options{}) {
+		spec, _ := format.SplitInlineComment(kv.Value)
+		spec = strings.TrimSpace(spec)
+		check, err := parseTypeSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s:%d: %s: %w", path, kv.Line, kv.Path(), err)
+		}
+		s[kv.Path()] = schemaRule{spec: spec, check: check}
+	}
+	return s, nil
+}
+
+// parseTypeSpec parses a type such as "bool" or "enum(debug,info)".
+func parseTypeSpec(spec string) (valueCheck, error) {
+	name, args := spec, ""
+	if i := strings.IndexByte(spec, '('); i >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return nil, fmt.Errorf("type %q lacks a closing parenthesis", spec)
+		}
+		name, args = strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:len(spec)-1])
+	}
+	build, ok := schemaTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q (want %s)", name, strings.Join(sortedKeys(schemaTypes), ", "))
+	}
+	check, err := build(args)
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %w", name, err)
+	}
+	return check, nil
+}
+
+// check reports the keys of in whose values do not parse as their declared
+// type, with the given severity. Bare keys have no value to check.
+func (s schema) check(in *input, cfg format.Options, severity string) []diagnostic {
+	if len(s) == 0 {
+		return nil
+	}
+	var diags []diagnostic
+	for _, kv := range format.ParseKeyValues(in.lines, cfg) {
+		rule, ok := s[kv.Path()]
+		if !ok || !kv.HasValue {
+			continue
+		}
+		if text := format.ValueText(kv.Value); !rule.check(text) {
+			diags = append(diags, diagnostic{
+				line:     kv.Line,
+				rule:     "schema-type",
+				severity: severity,
+				message:  fmt.Sprintf("%s: value %q is not %s", kv.Path(), text, rule.spec),
+			})
+		}
+	}
+	return diags
+}
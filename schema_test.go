@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestParseTypeSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid []string
+		bad   []string
+	}{
+		{"string", []string{"", "anything"}, nil},
+		{"int", []string{"0", "-5", "65536"}, []string{"", "1.5", "0x10", "ten"}},
+		{"int(1..65535)", []string{"1", "80", "65535"}, []string{"0", "65536", "-1"}},
+		{"int(0..)", []string{"0", "99999"}, []string{"-1"}},
+		{"float(..1)", []string{"0.5", "1", "-3"}, []string{"1.01", "x"}},
+		{"bool", []string{"true", "No", "ON", "0"}, []string{"", "maybe", "2"}},
+		{"duration", []string{"30s", "1m30s", "0"}, []string{"30", "soon"}},
+		{"enum(debug, info,warn)", []string{"debug", "info", "warn"}, []string{"Debug", "error", ""}},
+	}
+	for _, tt := range tests {
+		check, err := parseTypeSpec(tt.spec)
+		if err != nil {
+			t.Errorf("parseTypeSpec(%q) error: %v", tt.spec, err)
+			continue
+		}
+		for _, v := range tt.valid {
+			if !check(v) {
+				t.Errorf("%s rejects %q", tt.spec, v)
+			}
+		}
+		for _, v := range tt.bad {
+			if check(v) {
+				t.Errorf("%s accepts %q", tt.spec, v)
+			}
+		}
+	}
+	for _, spec := range []string{"port", "int(1-5)", "int(a..b)", "bool(x)", "enum()", "enum(a,,b)", "int(1..5"} {
+		if _, err := parseTypeSpec(spec); err == nil {
+			t.Errorf("parseTypeSpec(%q) expected error", spec)
+		}
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.ini")
+	if err := os.WriteFile(path, []byte("[server]\nport = int(1..65535) ; TCP port\nenabled = bool\nlevel = enum(debug,info)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sch, err := loadSchema(path, sourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{"[server]", "port = \"70000\"", "enabled = yes ; on", "level = 'trace'", "flag", "other = x"}
+	var got []string
+	for _, d := range sch.check(&input{lines: lines}, format.Options{}, severityWarning) {
+		got = append(got, fmt.Sprintf("%d: %s: %s (%s)", d.line, d.severity, d.message, d.rule))
+	}
+	want := []string{
+		`2: warning: server.port: value "70000" is not int(1..65535) (schema-type)`,
+		`4: warning: server.level: value "trace" is not enum(debug,info) (schema-type)`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("check() =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("[s]\nk = number\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSchema(path, sourceOptions{}); err == nil {
+		t.Error("loadSchema() expected error for an unknown type")
+	}
+}
+
+func TestLintSchemaStrict(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.ini")
+	file := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(schemaPath, []byte("[s]\nport = int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("[s]\nport = http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_ACTIONS", "")
+	for _, tt := range []struct {
+		strict bool
+		code   int
+	}{{false, 0}, {true, 1}} {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"lint", "--schema", schemaPath, fmt.Sprintf("--schema-strict=%t", tt.strict), file})
+		code := 0
+		var ee *exitError
+		if err := cmd.Execute(); errors.As(err, &ee) {
+			code = ee.code
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("lint --schema-strict=%t exit %d, want %d", tt.strict, code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// sourceAnnotation is the flag annotation recording where the value of a flag
+// set by inifmt itself, rather than on the command line, came from.
+const sourceAnnotation = "inifmt-source"
+
+// setFlag sets a flag on behalf of source, such as a config file or preset,
+// and records the source for --show-config. A list is replaced rather than
+// appended to, whether a layer with lower precedence set it before or not.
+func setFlag(flags *pflag.FlagSet, name, value, source string) error {
+	if flag := flags.Lookup(name); flag != nil {
+		if list, ok := flag.Value.(pflag.SliceValue); ok {
+			var items []string
+			if value != "" {
+				items = strings.Split(value, ",")
+			}
+			if err := list.Replace(items); err != nil {
+				return err
+			}
+			flag.Changed = true
+			return flags.SetAnnotation(name, sourceAnnotation, []string{source})
+		}
+	}
+	if err := flags.Set(name, value); err != nil {
+		return err
+	}
+	return flags.SetAnnotation(name, sourceAnnotation, []string{source})
+}
+
+// flagState is whether a flag is set, and by what source.
+type flagState struct {
+	changed bool
+	source  []string
+}
+
+// saveFlags records the state of every flag of flags, so that the settings
+// resolved for one file can be undone with restoreFlags before the next. The
+// values themselves live in the config the flags are bound to.
+func saveFlags(flags *pflag.FlagSet) map[string]flagState {
+	states := make(map[string]flagState)
+	flags.VisitAll(func(f *pflag.Flag) {
+		states[f.Name] = flagState{f.Changed, f.Annotations[sourceAnnotation]}
+	})
+	return states
+}
+
+// restoreFlags returns the flags of flags to the states saveFlags recorded.
+func restoreFlags(flags *pflag.FlagSet, states map[string]flagState) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		s := states[f.Name]
+		f.Changed = s.changed
+		if s.source == nil {
+			delete(f.Annotations, sourceAnnotation)
+		} else {
+			f.Annotations[sourceAnnotation] = s.source
+		}
+	})
+}
+
+// givenOnCommandLine reports whether flag was set on the command line rather
+// than by inifmt on behalf of a config file, preset or dialect.
+func givenOnCommandLine(flag *pflag.Flag) bool {
+	return flag.Changed && len(flag.Annotations[sourceAnnotation]) == 0
+}
+
+// flagSource describes where the value of a flag came from.
+func flagSource(flag *pflag.Flag) string {
+	if source := flag.Annotations[sourceAnnotation]; len(source) > 0 {
+		return source[0]
+	}
+	if flag.Changed {
+		return "flag"
+	}
+	return "default"
+}
+
+// internalFlags are the flags --show-config leaves out because they act on
+// inifmt itself rather than on how files are formatted.
+var internalFlags = []string{"help", "show-config", "list-presets"}
+
+// setting is one line of --show-config output.
+type setting struct {
+	Name   string `json:"name"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveSettings lists the final value and source of every setting in
+// flags, in name order.
+func effectiveSettings(flags *pflag.FlagSet) []setting {
+	var settings []setting
+	flags.VisitAll(func(f *pflag.Flag) {
+		for _, name := range internalFlags {
+			if f.Name == name {
+				return
+			}
+		}
+		s := setting{Name: f.Name, Value: f.Value.String(), Source: flagSource(f)}
+		if list, ok := f.Value.(pflag.SliceValue); ok {
+			s.Value = list.GetSlice()
+		}
+		if f.Name == "color" && !f.Changed && len(f.Annotations[sourceAnnotation]) == 0 {
+			if _, ok := os.LookupEnv("NO_COLOR"); ok {
+				s.Value, s.Source = "never", "env NO_COLOR"
+			}
+		}
+		settings = append(settings, s)
+	})
+	return settings
+}
+
+// writeSettings prints settings as an INI file with the source of each value
+// as an inline comment, or as JSON when how is "json". Values that are empty
+// or could be mistaken for a comment are quoted.
+func writeSettings(w io.Writer, settings []setting, how string) error {
+	if how == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(settings); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+		return nil
+	}
+	lines := make([]string, len(settings))
+	for i, s := range settings {
+		value, ok := s.Value.(string)
+		if !ok {
+			value = strings.Join(s.Value.([]string), ",")
+		}
+		if value == "" || strings.ContainsAny(value, ";#\"") {
+			value = strconv.Quote(value)
+		}
+		lines[i] = fmt.Sprintf("%s = %s ; %s", s.Name, value, s.Source)
+	}
+	lines, err := format.Lines(lines, format.Options{})
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestShowConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, projectConfigName)
+	content := "# house style\nblank-lines = \"sections\"\nsingle_space = true\nsort-keys = [\"a\", \"b\"]\n"
+	if err := os.WriteFile(config, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NO_COLOR", "1")
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--show-config=json", "--preset=dense", "--single-space=false", filepath.Join(dir, "x.reg")})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var settings []setting
+	if err := json.Unmarshal(out.Bytes(), &settings); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]setting)
+	for _, s := range settings {
+		got[s.Name] = s
+	}
+	tests := []struct {
+		name   string
+		value  any
+		source string
+	}{
+		{"blank-lines", "sections", config + ":2"}, // config beats preset
+		{"single-space", "false", "flag"},          // flag beats config and preset
+		{"sort-keys", []any{"a", "b"}, config + ":4"},
+		{"preset", "dense", "flag"},
+		{"comment-prefixes", []any{";"}, "dialect reg"},
+		{"line-ending", "auto", "dialect reg"},
+		{"color", "never", "env NO_COLOR"},
+		{"per-block", "false", "default"},
+	}
+	for _, tt := range tests {
+		s, ok := got[tt.name]
+		if !ok {
+			t.Errorf("--show-config is missing %s", tt.name)
+			continue
+		}
+		if !equalJSON(s.Value, tt.value) || s.Source != tt.source {
+			t.Errorf("%s = %v (%s), want %v (%s)", tt.name, s.Value, s.Source, tt.value, tt.source)
+		}
+	}
+	if _, ok := got["show-config"]; ok {
+		t.Error("--show-config lists itself")
+	}
+}
+
+func TestShowConfigText(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--show-config", "--no-config", "--preset=tidy", "-u"})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	// The '=' column depends on the longest flag name, so compare without it.
+	text := regexp.MustCompile(` +=`).ReplaceAllString(out.String(), " =")
+	for _, want := range []string{
+		"\nper-section = true ; preset tidy\n",
+		"\nsingle-space = true ; flag\n",
+		"\ndedupe-keys = \"\" ; default\n",
+		"\ncomment-prefixes = \";,#\" ; default\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("--show-config output is missing %q:\n%s", want, out.String())
+		}
+	}
+
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"--show-config=yaml", "--no-config"})
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	if err := cmd.Execute(); err == nil {
+		t.Error("--show-config=yaml expected error")
+	}
+}
+
+func equalJSON(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
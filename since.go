@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// runSince formats the INI files git reports as changed since cfg.since,
+// each with the settings resolved for it, narrowed to paths when given.
+func runSince(cmd *cobra.Command, cfg *config, paths []string) error {
+	if err := checkConflicts(*cfg); err != nil {
+		return err
+	}
+	var err error
+	if cfg.log, err = newLogger(cmd.ErrOrStderr(), *cfg); err != nil {
+		return err
+	}
+	files, err := changedFiles(cmd.Context(), cfg.since, paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		cfg.logger().Info(fmt.Sprintf("no INI files changed since %s", cfg.since), "since", cfg.since)
+		return runFiles(cmd.Context(), *cfg, nil)
+	}
+	cfg.logger().Info(fmt.Sprintf("%s changed since %s", count(len(files), "INI file", "INI files"), cfg.since), "since", cfg.since, "files", len(files))
+	return runEach(cmd, cfg, files)
+}
+
+// changedFiles asks git for the INI files, as isINIFile tells them, that
+// differ between the revision ref and the working tree, and for the
+// untracked files that are not ignored, within paths when any are given.
+// Renamed files are listed by their new path and deleted files are left out.
+// The paths returned are relative to the current directory.
+func changedFiles(ctx context.Context, ref string, paths []string) ([]string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, optionError("--since", errors.New("--since needs git, which is not on the PATH"))
+	}
+	if out, err := git(ctx, "rev-parse", "--is-inside-work-tree"); err != nil || strings.TrimSpace(out) != "true" {
+		return nil, optionError("--since", errors.New("--since needs a git work tree, and the current directory is not in one"))
+	}
+	commit, err := git(ctx, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return nil, optionError("--since", fmt.Errorf("invalid --since %q: not a revision of this repository", ref))
+	}
+	// git lists paths from the top of the work tree; prefix is the current
+	// directory from there.
+	prefix, err := git(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git(ctx, append([]string{"diff", "--name-status", "-z", "--find-renames", "--no-relative", strings.TrimSpace(commit), "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, append([]string{"ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range append(parseNameStatus(diff), splitNUL(untracked)...) {
+		if !isINIFile(name) {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(strings.TrimSpace(prefix)), filepath.FromSlash(name))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, rel)
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// parseNameStatus returns the paths of the files that exist in the working
+// tree from the output of git diff --name-status -z: the new path of renamed
+// and copied files, and none for deleted ones.
+func parseNameStatus(out string) []string {
+	fields := splitNUL(out)
+	var files []string
+	for i := 0; i < len(fields); {
+		status := fields[i]
+		i++
+		n := 1
+		if strings.HasPrefix(status, "R") || strings.HasPrefix(status, "C") {
+			n = 2
+		}
+		if i+n > len(fields) {
+			break
+		}
+		if !strings.HasPrefix(status, "D") {
+			files = append(files, fields[i+n-1])
+		}
+		i += n
+	}
+	return files
+}
+
+// splitNUL splits the NUL-terminated fields of git's -z output.
+func splitNUL(out string) []string {
+	if out == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+}
+
+// isINIFile reports whether inifmt formats name when it picks the files
+// itself: it has one of format.Extensions, or a name or extension that gives
+// its dialect, looking through a .gz suffix.
+func isINIFile(name string) bool {
+	if format.HasExtension(strings.TrimSuffix(name, ".gz")) {
+		return true
+	}
+	_, reason, _ := detectDialect("auto", name, nil)
+	return reason == dialectByName
+}
+
+// git runs git with args in the current directory and returns its output.
+// Its error names the git command and says what git printed.
+func git(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
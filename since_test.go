@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"modified and added", "M\x00a.ini\x00A\x00b.ini\x00", []string{"a.ini", "b.ini"}},
+		{"deleted", "D\x00gone.ini\x00M\x00a.ini\x00", []string{"a.ini"}},
+		{"renamed", "R087\x00old.ini\x00new.ini\x00", []string{"new.ini"}},
+		{"copied", "C100\x00a.ini\x00copy.ini\x00", []string{"copy.ini"}},
+		{"type changed", "T\x00link.ini\x00", []string{"link.ini"}},
+		{"truncated", "R100\x00old.ini\x00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNameStatus(tt.out); !slices.Equal(got, tt.want) {
+				t.Errorf("parseNameStatus(%q) = %q, want %q", tt.out, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsINIFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"app.ini":             true,
+		"conf/db.CONF":        true,
+		"old.ini.gz":          true,
+		"app.service":         true,
+		".gitconfig":          true,
+		"setup.cfg":           true,
+		"export.reg":          true,
+		"README.md":           false,
+		"main.go":             false,
+		"notes.txt":           false,
+		"archive.tar.gz":      false,
+		"settings.properties": true,
+	} {
+		if got := isINIFile(name); got != want {
+			t.Errorf("isINIFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// gitRepo makes a git work tree in a new directory, commits files to it and
+// changes into it.
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFiles(t, dir, files)
+	runGit(t, "init", "-q")
+	runGit(t, "add", "-A")
+	runGit(t, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+// writeFiles writes files, keyed by their path under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runGit runs git with args in the current directory.
+func runGit(t *testing.T, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestSince(t *testing.T) {
+	const messy = "[a]\nx=1\nlonger=2\n"
+	dir := gitRepo(t, map[string]string{
+		"keep.ini":         messy,
+		"edit.ini":         messy,
+		"old.ini":          messy,
+		"gone.ini":         messy,
+		"notes.txt":        messy,
+		"app.service":      "[Service]\nType=simple\n",
+		"sub/.inifmt.toml": "single-space = true\n",
+		"sub/local.ini":    messy,
+		".gitignore":       "ignored.ini\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"edit.ini":      messy + "y=3\n",
+		"notes.txt":     messy + "y=3\n",
+		"app.service":   "[Service]\nType=simple\nExecStart=/bin/true\n",
+		"sub/local.ini": messy + "y=3\n",
+		"fresh.conf":    messy,
+		"ignored.ini":   messy,
+	})
+	runGit(t, "mv", "old.ini", "new.ini")
+	if err := os.Remove("gone.ini"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := changedFiles(t.Context(), "HEAD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"app.service", "edit.ini", "fresh.conf", "new.ini", filepath.Join("sub", "local.ini")}
+	if !slices.Equal(files, want) {
+		t.Fatalf("changedFiles() = %q, want %q", files, want)
+	}
+
+	report := filepath.Join(t.TempDir(), "report.json")
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--since=HEAD", "--no-config", "-w", "--quiet", "--report=" + report})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	aligned := "[a]\nx      = 1\nlonger = 2\n"
+	for name, content := range map[string]string{
+		"keep.ini":      messy,
+		"edit.ini":      "[a]\nx      = 1\nlonger = 2\ny      = 3\n",
+		"new.ini":       aligned,
+		"fresh.conf":    aligned,
+		"ignored.ini":   messy,
+		"notes.txt":     messy + "y=3\n",
+		"app.service":   "[Service]\nType      = simple\nExecStart = /bin/true\n",
+		"sub/local.ini": "[a]\nx      = 1\nlonger = 2\ny      = 3\n",
+	} {
+		if got := mustRead(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+	if got := mustRead(t, report); !strings.Contains(got, `"formatted": 5`) {
+		t.Errorf("report = %s, want 5 files formatted", got)
+	}
+}
+
+func TestSinceResolvesEachFile(t *testing.T) {
+	const messy = "[a]\nx=1\nlonger=2\n"
+	dir := gitRepo(t, map[string]string{
+		"a.ini":            messy,
+		"sub/.inifmt.toml": "single-space = true\ncomment-prefixes = [\"//\"]\n",
+		"sub/b.ini":        messy,
+		"z.ini":            messy,
+	})
+	writeFiles(t, dir, map[string]string{
+		"a.ini":     messy + "// c=d\n",
+		"sub/b.ini": messy + "// c=d\n",
+		"z.ini":     messy + "// c=d\n",
+	})
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--since=HEAD", "-w", "--quiet"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	// The config of sub applies to sub/b.ini alone, not to the files
+	// formatted after it.
+	for name, content := range map[string]string{
+		"a.ini":     "[a]\nx      = 1\nlonger = 2\n// c   = d\n",
+		"sub/b.ini": "[a]\nx = 1\nlonger = 2\n// c=d\n",
+		"z.ini":     "[a]\nx      = 1\nlonger = 2\n// c   = d\n",
+	} {
+		if got := mustRead(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestSincePaths(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"a.ini": "a=1\n", "conf/b.ini": "b=1\n"})
+	writeFiles(t, dir, map[string]string{"a.ini": "a=2\n", "conf/b.ini": "b=2\n", "conf/c.ini": "c=1\n"})
+
+	files, err := changedFiles(t.Context(), "HEAD", []string{"conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join("conf", "b.ini"), filepath.Join("conf", "c.ini")}; !slices.Equal(files, want) {
+		t.Errorf("changedFiles(conf) = %q, want %q", files, want)
+	}
+
+	// From a subdirectory, paths are relative to it.
+	t.Chdir(filepath.Join(dir, "conf"))
+	files, err = changedFiles(t.Context(), "HEAD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join("..", "a.ini"), "b.ini", "c.ini"}; !slices.Equal(files, want) {
+		t.Errorf("changedFiles() from conf = %q, want %q", files, want)
+	}
+}
+
+func TestSinceOutput(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"a.ini": "a=1\n", "b.ini": "b=1\n"})
+	writeFiles(t, dir, map[string]string{"a.ini": "a=2\n", "b.ini": "b=2\n"})
+
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = saved }()
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--since=HEAD", "--no-config", "--color=never", "--quiet"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = saved
+	got := mustRead(t, stdout.Name())
+	if want := "==> a.ini <==\na = 2\n\n==> b.ini <==\nb = 2\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestSinceErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	outside := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(outside))
+	t.Chdir(outside)
+	_, err := changedFiles(t.Context(), "HEAD", nil)
+	var oe *format.OptionError
+	if !errors.As(err, &oe) || !strings.Contains(err.Error(), "not in one") {
+		t.Errorf("changedFiles() outside a repository error = %v, want a usage error", err)
+	}
+
+	gitRepo(t, map[string]string{"a.ini": "a=1\n"})
+	if _, err := changedFiles(t.Context(), "no-such-branch", nil); !errors.As(err, &oe) || !strings.Contains(err.Error(), `"no-such-branch"`) {
+		t.Errorf("changedFiles(no-such-branch) error = %v, want a usage error", err)
+	}
+	if _, err := changedFiles(t.Context(), "--output=x", nil); !errors.As(err, &oe) {
+		t.Errorf("changedFiles(--output=x) error = %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// defaultSlowestFiles is the number of files --timings lists when bare.
+const defaultSlowestFiles = 10
+
+// phase is a step of formatting one file that --timings measures:
+//
+//   - read: from opening the file (or fetching the URL) until its lines are
+//     decoded and split;
+//   - format: from there until the output lines are final, including
+//     warnings, --explain and conversion to the --to format;
+//   - write: encoding the output and writing it to the file or stdout.
+type phase int
+
+const (
+	phaseRead phase = iota
+	phaseFormat
+	phaseWrite
+)
+
+// phaseTimer measures the phases of formatting one file. A nil *phaseTimer,
+// used without --timings, measures nothing.
+type phaseTimer struct {
+	last  time.Time
+	times [phaseWrite + 1]time.Duration
+}
+
+// newPhaseTimer starts timing a file's first phase, or returns nil when
+// timings are off.
+func newPhaseTimer(enabled bool) *phaseTimer {
+	if !enabled {
+		return nil
+	}
+	return &phaseTimer{last: time.Now()}
+}
+
+// lap ends phase p, charging it the time since the previous phase ended, and
+// starts the next one.
+func (t *phaseTimer) lap(p phase) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.times[p] += now.Sub(t.last)
+	t.last = now
+}
+
+// fileTimings are the phase durations of one file in the JSON report, in
+// milliseconds.
+type fileTimings struct {
+	ReadMS   float64 `json:"read_ms"`
+	FormatMS float64 `json:"format_ms"`
+	WriteMS  float64 `json:"write_ms"`
+	TotalMS  float64 `json:"total_ms"`
+}
+
+// result returns the durations measured so far, or nil for a nil timer.
+func (t *phaseTimer) result() *fileTimings {
+	if t == nil {
+		return nil
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	read, format, write := t.times[phaseRead], t.times[phaseFormat], t.times[phaseWrite]
+	return &fileTimings{ReadMS: ms(read), FormatMS: ms(format), WriteMS: ms(write), TotalMS: ms(read + format + write)}
+}
+
+// writeSlowest prints the n files of the report that took longest, slowest
+// first, with the time each phase took.
+func (r *runReport) writeSlowest(w io.Writer, n int) error {
+	var timed []fileResult
+	for _, f := range r.Files {
+		if f.Timings != nil {
+			timed = append(timed, f)
+		}
+	}
+	slices.SortStableFunc(timed, func(a, b fileResult) int {
+		return cmp.Compare(b.Timings.TotalMS, a.Timings.TotalMS)
+	})
+	total := len(timed)
+	timed = timed[:min(n, total)]
+	if _, err := fmt.Fprintf(w, "inifmt: slowest %d of %d timed %s\n", len(timed), total, plural(total, "file", "files")); err != nil {
+		return fmt.Errorf("writing timings: %w", err)
+	}
+	for _, f := range timed {
+		t := f.Timings
+		if _, err := fmt.Fprintf(w, "  %9.3fms  %s (read %.3fms, format %.3fms, write %.3fms)\n", t.TotalMS, f.File, t.ReadMS, t.FormatMS, t.WriteMS); err != nil {
+			return fmt.Errorf("writing timings: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPhaseTimerOff(t *testing.T) {
+	var timer *phaseTimer
+	timer.lap(phaseRead)
+	if got := timer.result(); got != nil {
+		t.Errorf("nil timer result = %+v, want nil", got)
+	}
+	if newPhaseTimer(false) != nil {
+		t.Error("newPhaseTimer(false) is not nil")
+	}
+}
+
+func TestTimingsReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.ini")
+	if err := os.WriteFile(path, []byte("a=1\nlong=2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.json")
+	for _, timings := range []bool{false, true} {
+		args := []string{"--no-config", "--write", "--quiet", "--report", report, path}
+		if timings {
+			args = append([]string{"--timings"}, args...)
+		}
+		cmd := newRootCmd()
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var r struct {
+			Files []map[string]json.RawMessage `json:"files"`
+		}
+		if err := json.Unmarshal([]byte(mustRead(t, report)), &r); err != nil {
+			t.Fatal(err)
+		}
+		raw, ok := r.Files[0]["timings"]
+		if !timings {
+			if ok {
+				t.Errorf("report has timings without --timings: %s", raw)
+			}
+			continue
+		}
+		var phases map[string]float64
+		if err := json.Unmarshal(raw, &phases); err != nil {
+			t.Fatalf("timings %s: %v", raw, err)
+		}
+		for _, key := range []string{"read_ms", "format_ms", "write_ms", "total_ms"} {
+			if _, ok := phases[key]; !ok {
+				t.Errorf("timings %s lack %s", raw, key)
+			}
+		}
+	}
+}
+
+func TestWriteSlowest(t *testing.T) {
+	r := newRunReport()
+	for _, f := range []struct {
+		name  string
+		total float64
+	}{{"fast.ini", 1}, {"slow.ini", 9}, {"untimed.ini", 0}, {"medium.ini", 5}} {
+		r.add(f.name, statusFormatted, "", nil)
+		if f.total > 0 {
+			r.setTimings(&fileTimings{FormatMS: f.total, TotalMS: f.total})
+		}
+	}
+	var out bytes.Buffer
+	if err := r.writeSlowest(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "inifmt: slowest 2 of 3 timed files" || !strings.Contains(lines[1], "slow.ini") || !strings.Contains(lines[2], "medium.ini") {
+		t.Errorf("writeSlowest() = %q, want slow.ini then medium.ini", out.String())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+// newToFlagsCmd builds the to-flags subcommand, which turns keys into
+// command-line arguments.
+func newToFlagsCmd(cfg *config) *cobra.Command {
+	var lines bool
+	var outFormat, prefix, duplicates string
+	cmd := &cobra.Command{
+		Use:   "to-flags [file]",
+		Short: "Print keys as --section.key=value command-line arguments",
+		Long: `to-flags prints an argument for every key in file order: --section.key=value,
+or --key=value for keys before the first section header. A key without a
+delimiter becomes a bare --section.key. Values lose their inline comment and,
+when quoted in full, their quotes.
+
+The arguments are printed on one line, each one single-quoted for POSIX sh when
+it holds anything but letters, digits and "-_./:,=+@%", so the output is safe
+in $(...) and with xargs. Use --lines for one argument per line, quoted the
+same way, or --format=json for a JSON array of unquoted arguments.
+
+--prefix replaces the leading "--". A key given twice in a section repeats its
+argument, as most flag parsers take the last one; --duplicates=error refuses
+such files instead.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeOneFile,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			in, err := readInput(filename, cfg.source)
+			if err != nil {
+				return err
+			}
+			flags, err := keyFlags(format.ParseKeyValues(in.lines, cfg.format), prefix, duplicates)
+			if err != nil {
+				return err
+			}
+			return writeFlags(cmd.OutOrStdout(), flags, lines, outFormat)
+		},
+	}
+	cmd.Flags().BoolVar(&lines, "lines", false, "Print one argument per line")
+	cmd.Flags().StringVar(&outFormat, "format", "shell", "Output format: 'shell' for quoted arguments or 'json' for an array")
+	cmd.Flags().StringVar(&prefix, "prefix", "--", "What comes before each section.key")
+	cmd.Flags().StringVar(&duplicates, "duplicates", "repeat", "A key given twice in a section: 'repeat' its argument or 'error'")
+	return cmd
+}
+
+// keyFlags converts kvs into arguments. With duplicates "error", a key
+// given twice in a section is an error; with "repeat" each occurrence gives an
+// argument.
+func keyFlags(kvs []format.KeyValue, prefix, duplicates string) ([]string, error) {
+	switch duplicates {
+	case "repeat", "error":
+	default:
+		return nil, fmt.Errorf("invalid --duplicates %q (want repeat or error)", duplicates)
+	}
+	flags := make([]string, 0, len(kvs))
+	seen := make(map[string]int)
+	for _, kv := range kvs {
+		if first, ok := seen[kv.Path()]; ok && duplicates == "error" {
+			return nil, fmt.Errorf("key %q is given at lines %d and %d", kv.Path(), first, kv.Line)
+		}
+		seen[kv.Path()] = kv.Line
+		flag := prefix + kv.Path()
+		if kv.HasValue {
+			flag += "=" + format.ValueText(kv.Value)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// shellWord returns s as one POSIX sh word: as it is when it holds only
+// characters the shell leaves alone, single-quoted otherwise.
+func shellWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && !strings.ContainsRune("-_./:,=+@%", r) {
+			return shellQuote(s)
+		}
+	}
+	return s
+}
+
+// writeFlags renders flags in the given format.
+func writeFlags(w io.Writer, flags []string, lines bool, outFormat string) error {
+	switch outFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(flags)
+	case "shell":
+		words := make([]string, len(flags))
+		for i, f := range flags {
+			words[i] = shellWord(f)
+		}
+		sep := " "
+		if lines {
+			sep = "\n"
+		}
+		if len(words) == 0 {
+			return nil
+		}
+		_, err := fmt.Fprintln(w, strings.Join(words, sep))
+		return err
+	}
+	return fmt.Errorf("invalid --format %q (want shell or json)", outFormat)
+}
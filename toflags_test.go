@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thecrazygm/inifmt/format"
+)
+
+func TestKeyFlags(t *testing.T) {
+	kvs := format.ParseKeyValues([]string{
+		"debug = true",
+		"[server]",
+		"host = example.com ; public",
+		`motd = "it's up"`,
+		"verbose",
+		"port = 80",
+		"port = 8080",
+	}, format.Options{})
+
+	got, err := keyFlags(kvs, "--", "repeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--debug=true", "--server.host=example.com", "--server.motd=it's up", "--server.verbose", "--server.port=80", "--server.port=8080"}
+	if !slices.Equal(got, want) {
+		t.Errorf("keyFlags() = %q, want %q", got, want)
+	}
+	if got, _ := keyFlags(kvs[:1], "-D", "repeat"); !slices.Equal(got, []string{"-Ddebug=true"}) {
+		t.Errorf("keyFlags(-D) = %q", got)
+	}
+	if _, err := keyFlags(kvs, "--", "error"); err == nil || !strings.Contains(err.Error(), "lines 6 and 7") {
+		t.Errorf("keyFlags(error) = %v, want the repeated key's lines", err)
+	}
+	if _, err := keyFlags(kvs, "--", "last"); err == nil {
+		t.Error("keyFlags(last) expected an error")
+	}
+}
+
+func TestWriteFlags(t *testing.T) {
+	flags := []string{"--a=1", "--b=two words", "--c=it's", "--d="}
+	tests := []struct {
+		lines  bool
+		format string
+		want   string
+	}{
+		{false, "shell", `--a=1 '--b=two words' '--c=it'\''s' --d=` + "\n"},
+		{true, "shell", "--a=1\n'--b=two words'\n'--c=it'\\''s'\n--d=\n"},
+		{false, "json", "[\n  \"--a=1\",\n  \"--b=two words\",\n  \"--c=it's\",\n  \"--d=\"\n]\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeFlags(&b, flags, tt.lines, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("writeFlags(lines=%v, %s) = %q, want %q", tt.lines, tt.format, b.String(), tt.want)
+		}
+	}
+	var b bytes.Buffer
+	if err := writeFlags(&b, nil, false, "shell"); err != nil || b.String() != "" {
+		t.Errorf("writeFlags(none) = %q, %v, want nothing", b.String(), err)
+	}
+	if err := writeFlags(&b, flags, false, "yaml"); err == nil {
+		t.Error("writeFlags(yaml) expected an error")
+	}
+}
+
+// TestToFlagsShell checks that sh reads the arguments back as they were.
+func TestToFlagsShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	path := filepath.Join(t.TempDir(), "app.ini")
+	if err := os.WriteFile(path, []byte("[x]\na = $HOME `id` \"q\" 'r' \\ *;\nb = plain\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"to-flags", path})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := exec.Command(sh, "-c", `printf '%s\n' `+out.String()).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--x.a=$HOME `id` \"q\" 'r' \\ *;\n--x.b=plain\n"
+	if string(got) != want {
+		t.Errorf("sh read %q, want %q", got, want)
+	}
+
+	out.Reset()
+	cmd = newRootCmd()
+	cmd.SetArgs([]string{"to-flags", "--format=json", path})
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var args []string
+	if err := json.Unmarshal(out.Bytes(), &args); err != nil || len(args) != 2 {
+		t.Errorf("to-flags --format=json = %s, %v", out.String(), err)
+	}
+}